@@ -0,0 +1,59 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// ExportActorData writes to w the records of the repository concerning the actor, for answering the requests
+// for the data stored about them, as JSON Lines, with one DumpRecord per line, like Dump does:
+//   - the actor, their metadata, and their collections, stored under the path of their IRI
+//   - the objects, the activities and the collections mentioning the IRI of the actor, like the activities
+//     published by them, or addressed to them, and the collections they are a member of
+//   - the OAuth data mentioning it, like their access tokens
+//
+// The records are found by reading all of them, so it's as slow as Dump. It returns a NotFound error when the actor
+// is not stored.
+func (r *repo) ExportActorData(iri vocab.IRI, w io.Writer) error {
+	p := itemPath(iri)
+	if len(p) == 0 {
+		return errors.NotValidf("invalid actor IRI %s", iri)
+	}
+	if _, err := r.Load(iri, BypassCache()); err != nil {
+		return err
+	}
+	under := append(append([]byte{}, p...), sep...)
+	mentions := actorMentions(iri)
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return r.dumpRecords(func(rec DumpRecord) error {
+		if !bytes.HasPrefix([]byte(rec.Key), under) && !containsAny(rec.Value, mentions) {
+			return nil
+		}
+		return enc.Encode(rec)
+	})
+}
+
+// actorMentions returns the forms of the IRI found in the JSON values mentioning it: as a string, with and without
+// the escaping of the HTML characters.
+func actorMentions(iri vocab.IRI) [][]byte {
+	mentions := [][]byte{[]byte(`"` + iri.String() + `"`)}
+	if escaped, err := json.Marshal(iri.String()); err == nil && !bytes.Equal(escaped, mentions[0]) {
+		mentions = append(mentions, escaped)
+	}
+	return mentions
+}
+
+func containsAny(raw []byte, subs [][]byte) bool {
+	for _, s := range subs {
+		if bytes.Contains(raw, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package badger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+func Test_repo_ExportActorData(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Inbox = vocab.Inbox.IRI(jdoe)
+	jdoe2 := vocab.PersonNew("https://example.com/actors/jdoe2")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	note.To = vocab.ItemCollection{jdoe.ID}
+	other := vocab.ObjectNew(vocab.NoteType)
+	other.ID = "https://example.com/objects/2"
+	other.To = vocab.ItemCollection{jdoe2.ID}
+	for _, it := range []vocab.Item{jdoe, jdoe2, note, other} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Inbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Inbox.GetLink(), err)
+	}
+	if err = r.SaveMetadata(processing.Metadata{Pw: []byte("hash")}, jdoe.ID); err != nil {
+		t.Fatalf("unable to save the metadata of %s: %s", jdoe.ID, err)
+	}
+	client := &osin.DefaultClient{Id: "app"}
+	if err = r.CreateClient(client); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+	access := &osin.AccessData{Client: client, AccessToken: "token", ExpiresIn: 3600, CreatedAt: time.Now().UTC(), UserData: jdoe.ID}
+	if err = r.SaveAccess(access); err != nil {
+		t.Fatalf("unable to save access: %s", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err = r.ExportActorData(jdoe.ID, &buf); err != nil {
+		t.Fatalf("ExportActorData() error = %s", err)
+	}
+	keys := make(map[string]DumpRecordType)
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		rec := DumpRecord{}
+		if err = json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid record %s: %s", sc.Bytes(), err)
+		}
+		keys[rec.Key] = rec.Type
+	}
+	want := map[string]DumpRecordType{
+		"example.com/actors/jdoe/__raw":       DumpObject,
+		"example.com/actors/jdoe/__meta_data": DumpMetadata,
+		"example.com/actors/jdoe/inbox/__raw": DumpCollection,
+		"example.com/objects/1/__raw":         DumpObject,
+		"oauth/access/token":                  DumpOAuth,
+	}
+	for k, typ := range want {
+		if keys[k] != typ {
+			t.Errorf("ExportActorData() record %s = %q, want %q", k, keys[k], typ)
+		}
+	}
+	for _, k := range []string{"example.com/actors/jdoe2/__raw", "example.com/objects/2/__raw", "oauth/clients/app"} {
+		if _, ok := keys[k]; ok {
+			t.Errorf("ExportActorData() contains the record %s, of another actor", k)
+		}
+	}
+
+	if err = r.ExportActorData("https://example.com/actors/missing", &buf); !errors.IsNotFound(err) {
+		t.Errorf("ExportActorData() of a missing actor error = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,198 @@
+package badger
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// ActorNameIndex indexes actors by their lower cased preferred username, name, and the words of their name,
+// for looking them up by prefix.
+type ActorNameIndex struct{}
+
+func (ActorNameIndex) Name() string {
+	return "actorName"
+}
+
+func (ActorNameIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0)
+	if vocab.IsNil(it) || !vocab.ActorTypes.Contains(it.GetType()) {
+		return values
+	}
+	appendValue := func(v string) {
+		if v = strings.ToLower(strings.TrimSpace(v)); len(v) > 0 && !stringsContain(values, v) {
+			values = append(values, v)
+		}
+	}
+	_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+		for _, n := range a.PreferredUsername {
+			appendValue(n.String())
+		}
+		for _, n := range a.Name {
+			appendValue(n.String())
+			for _, w := range strings.Fields(n.String()) {
+				appendValue(w)
+			}
+		}
+		return nil
+	})
+	return values
+}
+
+func (ActorNameIndex) Lookup(_ *filters.Filters) ([]ValueRange, bool) {
+	return nil, false
+}
+
+// normalizeActorQuery lower cases the query, and removes the leading @ of a mention.
+func normalizeActorQuery(q string) string {
+	q = strings.TrimPrefix(strings.TrimSpace(q), "@")
+	if i := strings.IndexByte(q, '@'); i > 0 {
+		// NOTE(marius): for mentions of the form user@host we match only the user name.
+		q = q[:i]
+	}
+	return strings.ToLower(q)
+}
+
+// FindActors returns the actors whose preferred username, name, or a word of their name, starts with q,
+// ignoring case. The results are ordered by the matched value, and at most limit of them are returned,
+// a limit lower than 1 meaning all of them.
+func (r *repo) FindActors(q string, limit int) (vocab.ItemCollection, error) {
+	q = normalizeActorQuery(q)
+	if len(q) == 0 {
+		return vocab.ItemCollection{}, nil
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	actors := make(vocab.ItemCollection, 0)
+	stale := make([][]byte, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if hasIndex(r.indexes, ActorNameIndex{}.Name()) {
+			actors, stale = r.findActorsInIndex(tx, q, limit)
+			return nil
+		}
+		actors = r.findActorsByScan(tx, q, limit)
+		return nil
+	})
+	if err != nil || len(stale) == 0 {
+		return actors, err
+	}
+	err = r.update(func(tx dbTxn) error {
+		for _, k := range stale {
+			if err := tx.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.errFn("unable to remove %d stale %s index keys: %+s", len(stale), ActorNameIndex{}.Name(), err)
+	}
+	return actors, nil
+}
+
+// findActorsInIndex returns the actors found under the q prefix of the actor name index, and the index keys
+// which are stale, as the objects they point to are missing, are no longer actors, or no longer have the value.
+func (r *repo) findActorsInIndex(tx dbTxn, q string, limit int) (vocab.ItemCollection, [][]byte) {
+	actors := make(vocab.ItemCollection, 0)
+	stale := make([][]byte, 0)
+	namePrefix := getIndexPrefix(ActorNameIndex{}.Name())
+	prefix := append(append([]byte{}, namePrefix...), q...)
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	seen := make(map[string]struct{})
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().KeyCopy(nil)
+		p := indexKeyPath(k)
+		if p == nil {
+			continue
+		}
+		act, err := loadRawItem(tx, p)
+		if errors.IsNotFound(err) {
+			stale = append(stale, k)
+			continue
+		}
+		if err != nil || vocab.IsNil(act) {
+			r.errFn("unable to load actor %s: %+s", p, err)
+			continue
+		}
+		// NOTE(marius): the index key is left behind by the objects which changed outside Save, like the moved
+		// actors, so we check that it still matches the values of the loaded actor.
+		v := string(k[len(namePrefix) : len(k)-len(p)-1])
+		if !stringsContain(ActorNameIndex{}.Values(act), v) {
+			stale = append(stale, k)
+			continue
+		}
+		if _, ok := seen[string(p)]; ok {
+			continue
+		}
+		seen[string(p)] = struct{}{}
+		actors = append(actors, act)
+		if limit > 0 && len(actors) >= limit {
+			break
+		}
+	}
+	return actors, stale
+}
+
+// findActorsByScan is used when the actor name index is not maintained, and it needs to decode all the objects.
+func (r *repo) findActorsByScan(tx dbTxn, q string, limit int) vocab.ItemCollection {
+	type match struct {
+		value string
+		actor vocab.Item
+	}
+	matches := make([]match, 0)
+
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		if !isObjectKey(i.Key()) {
+			continue
+		}
+		_ = i.Value(func(raw []byte) error {
+			act, err := loadItem(raw)
+			if err != nil || vocab.IsNil(act) {
+				return err
+			}
+			// NOTE(marius): the actors are ordered by their smallest matching value, like in the index.
+			m := match{actor: act}
+			for _, v := range (ActorNameIndex{}).Values(act) {
+				if strings.HasPrefix(v, q) && (m.value == "" || v < m.value) {
+					m.value = v
+				}
+			}
+			if m.value != "" {
+				matches = append(matches, m)
+			}
+			return nil
+		})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].value != matches[j].value {
+			return matches[i].value < matches[j].value
+		}
+		return bytes.Compare(itemPath(matches[i].actor.GetLink()), itemPath(matches[j].actor.GetLink())) < 0
+	})
+
+	actors := make(vocab.ItemCollection, 0, len(matches))
+	for _, m := range matches {
+		actors = append(actors, m.actor)
+		if limit > 0 && len(actors) >= limit {
+			break
+		}
+	}
+	return actors
+}
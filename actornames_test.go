@@ -0,0 +1,119 @@
+package badger
+
+import (
+	"reflect"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_FindActors(t *testing.T) {
+	actors := []struct {
+		iri      vocab.IRI
+		username string
+		name     string
+	}{
+		{iri: "http://example.com/actors/jdoe", username: "jdoe", name: "John Doe"},
+		{iri: "http://example.com/actors/jane", username: "Jane", name: "Jane Smith"},
+		{iri: "http://example.com/actors/alice", username: "alice", name: "Alice"},
+	}
+	tests := []struct {
+		name  string
+		q     string
+		limit int
+		want  vocab.IRIs
+	}{
+		{name: "username prefix", q: "j", want: vocab.IRIs{actors[1].iri, actors[0].iri}},
+		{name: "limit", q: "j", limit: 1, want: vocab.IRIs{actors[1].iri}},
+		{name: "name word, case insensitive", q: "SMI", want: vocab.IRIs{actors[1].iri}},
+		{name: "mention", q: "@doe@example.com", want: vocab.IRIs{actors[0].iri}},
+		{name: "no match", q: "bob", want: vocab.IRIs{}},
+	}
+	for _, indexes := range [][]Indexer{nil, DefaultIndexes} {
+		r, err := initBadgerForTesting(t)
+		if err != nil {
+			t.Fatalf("Unable to initialize badger: %s", err)
+		}
+		r.indexes = indexes
+		for _, a := range actors {
+			act := vocab.PersonNew(a.iri)
+			act.PreferredUsername = vocab.DefaultNaturalLanguageValue(a.username)
+			act.Name = vocab.DefaultNaturalLanguageValue(a.name)
+			if _, err = r.Save(act); err != nil {
+				t.Fatalf("unable to save %s: %s", a.iri, err)
+			}
+		}
+		// NOTE(marius): objects with the same name, that are not actors, need to be ignored.
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = "http://example.com/objects/1"
+		note.Name = vocab.DefaultNaturalLanguageValue("jdoe")
+		if _, err = r.Save(note); err != nil {
+			t.Fatalf("unable to save %s: %s", note.ID, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				found, err := r.FindActors(tt.q, tt.limit)
+				if err != nil {
+					t.Fatalf("FindActors() error = %s", err)
+				}
+				got := make(vocab.IRIs, 0)
+				for _, it := range found {
+					got = append(got, it.GetLink())
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindActors(%q, %d) with %d indexes = %v, want %v", tt.q, tt.limit, len(indexes), got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func Test_repo_FindActors_Stale(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = []Indexer{ActorNameIndex{}}
+
+	act := vocab.PersonNew("http://example.com/actors/jdoe")
+	act.PreferredUsername = vocab.DefaultNaturalLanguageValue("jdoe")
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save %s: %s", act.ID, err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+
+	// NOTE(marius): the index keys of a missing object, of an object which is not an actor, and of a value
+	// the actor no longer has.
+	stale := [][]byte{
+		getIndexKey(ActorNameIndex{}.Name(), "jdoe", itemPath("http://example.com/actors/missing")),
+		getIndexKey(ActorNameIndex{}.Name(), "jdoe", itemPath(note.ID)),
+		getIndexKey(ActorNameIndex{}.Name(), "jdoe2", itemPath(act.ID)),
+	}
+	_ = r.Open()
+	tx := r.d.NewTransaction(true)
+	for _, k := range stale {
+		_ = tx.Set(k, nil)
+	}
+	err = tx.Commit()
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to set the stale index keys: %s", err)
+	}
+
+	found, err := r.FindActors("jdoe", 0)
+	if err != nil {
+		t.Fatalf("FindActors() error = %s", err)
+	}
+	if len(found) != 1 || !found[0].GetLink().Equals(act.ID, false) {
+		t.Errorf("FindActors() = %v, want only %s", found, act.ID)
+	}
+	keys, _ := r.Keys(string(getIndexPrefix(ActorNameIndex{}.Name())), 0)
+	if len(keys) != 1 || keys[0] != string(getIndexKey(ActorNameIndex{}.Name(), "jdoe", itemPath(act.ID))) {
+		t.Errorf("the %s index keys = %q, want only the one of %s", ActorNameIndex{}.Name(), keys, act.ID)
+	}
+}
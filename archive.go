@@ -0,0 +1,298 @@
+package badger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/go-ap/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The archives written by ExportArchive start with a header made of archiveMagic and the random salt used for
+// deriving the AES-256 key from the passphrase, with scrypt. It's followed by chunks of the encrypted content,
+// each having a byte which is 1 for the last chunk and 0 otherwise, the big endian uint32 size of the sealed chunk,
+// and the chunk sealed with AES-GCM, with the flag byte as additional data, so the truncated archives are detected.
+// The nonce of a chunk is made of the random nonce prefix from the header and the big endian index of the chunk.
+const (
+	archiveMagic       = "GOAP-BADGER-ARCHIVE\x01"
+	archiveSaltSize    = 16
+	archiveNonceSize   = 4
+	archiveChunkSize   = 64 * 1024
+	archiveVersion     = 1
+	archiveManifestRec = DumpRecordType("manifest")
+)
+
+// ArchiveManifest is the last record of an archive, which describes the records preceding it.
+type ArchiveManifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Records is the number of records of each type.
+	Records map[DumpRecordType]int `json:"records"`
+	// SHA256 is the hex encoded SHA-256 checksum of the lines of the records.
+	SHA256 string `json:"sha256"`
+}
+
+// ExportArchive writes to w an archive of the whole instance, for disaster recovery and for moving it to another
+// host: the records written by Dump, with the objects, the collections, the metadata containing the private keys,
+// and the OAuth data, followed by an ArchiveManifest record. The archive is compressed with gzip and encrypted with
+// AES-256-GCM, with a key derived from the passphrase. It can be read back by ImportArchive.
+func (r *repo) ExportArchive(w io.Writer, passphrase []byte) (ArchiveManifest, error) {
+	m := ArchiveManifest{Version: archiveVersion, Created: time.Now().UTC(), Records: make(map[DumpRecordType]int)}
+	if len(passphrase) == 0 {
+		return m, errors.NotValidf("empty archive passphrase")
+	}
+	aw, err := newArchiveWriter(w, passphrase)
+	if err != nil {
+		return m, err
+	}
+	gz := gzip.NewWriter(aw)
+	h := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(gz, h))
+	enc.SetEscapeHTML(false)
+	err = r.dumpRecords(func(rec DumpRecord) error {
+		m.Records[rec.Type]++
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return m, err
+	}
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	if err = json.NewEncoder(gz).Encode(DumpRecord{Type: archiveManifestRec, Value: raw}); err != nil {
+		return m, err
+	}
+	if err = gz.Close(); err != nil {
+		return m, err
+	}
+	return m, aw.Close()
+}
+
+// ImportArchive copies the records of an archive written by ExportArchive, after checking them against its manifest.
+// Like for the other imports, the type keys, the filter indexes, the collection cursors and the membership keys are
+// rebuilt for the copied objects.
+//
+// The chunks of the archive are authenticated while they are read, so the import of a modified archive fails
+// at the first modified chunk, and the import of a truncated one fails at its end. Like for LoadDump, a checkpoint
+// is stored after every thousand records, and the records copied before it are skipped when resuming.
+func (r *repo) ImportArchive(rd io.Reader, passphrase []byte, opt MigrationOptions) (MigrationReport, error) {
+	ar, err := newArchiveReader(rd, passphrase)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	gz, err := gzip.NewReader(ar)
+	if err != nil {
+		return MigrationReport{}, errors.NewNotValid(err, "invalid archive")
+	}
+	imp, err := r.newImporter("archive", "", opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	return imp.finish(importArchiveRecords(imp, bufio.NewReader(gz)))
+}
+
+func importArchiveRecords(imp *importer, br *bufio.Reader) error {
+	h := sha256.New()
+	counts := make(map[DumpRecordType]int)
+	skip := imp.resumeRecords()
+	for read := 1; ; read++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return errors.NotValidf("the archive doesn't end with a manifest")
+		}
+		if err != nil && err != io.EOF {
+			return errors.NewNotValid(err, "unable to read the archive")
+		}
+		rec := DumpRecord{}
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return errors.Annotatef(err, "invalid archive record")
+		}
+		if rec.Type == archiveManifestRec {
+			return checkArchiveManifest(rec.Value, counts, hex.EncodeToString(h.Sum(nil)))
+		}
+		if rec.Key == "" || !isExportedKey([]byte(rec.Key)) {
+			return errors.NotValidf("invalid archive record key %q", rec.Key)
+		}
+		h.Write(line)
+		counts[rec.Type]++
+		if read <= skip {
+			continue
+		}
+		if err = imp.set([]byte(rec.Key), rec.Value); err != nil {
+			return err
+		}
+		if err = imp.recordCheckpoint(read); err != nil {
+			return err
+		}
+	}
+}
+
+func checkArchiveManifest(raw []byte, counts map[DumpRecordType]int, sum string) error {
+	m := ArchiveManifest{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return errors.Annotatef(err, "invalid archive manifest")
+	}
+	if m.Version != archiveVersion {
+		return errors.NotValidf("unsupported archive version %d", m.Version)
+	}
+	if m.SHA256 != sum {
+		return errors.NotValidf("the archive checksum %s doesn't match the manifest %s", sum, m.SHA256)
+	}
+	for _, typ := range []DumpRecordType{DumpObject, DumpCollection, DumpMetadata, DumpOAuth} {
+		if m.Records[typ] != counts[typ] {
+			return errors.NotValidf("the archive has %d %s records, the manifest %d", counts[typ], typ, m.Records[typ])
+		}
+	}
+	return nil
+}
+
+func archiveCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func archiveNonce(prefix []byte, index uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte{}, prefix...), index)
+}
+
+// archiveWriter encrypts the content written to it in chunks of archiveChunkSize.
+type archiveWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint64
+	buf    []byte
+}
+
+func newArchiveWriter(w io.Writer, passphrase []byte) (*archiveWriter, error) {
+	header := make([]byte, len(archiveMagic)+archiveSaltSize+archiveNonceSize)
+	copy(header, archiveMagic)
+	if _, err := rand.Read(header[len(archiveMagic):]); err != nil {
+		return nil, err
+	}
+	salt := header[len(archiveMagic) : len(archiveMagic)+archiveSaltSize]
+	aead, err := archiveCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	prefix := header[len(archiveMagic)+archiveSaltSize:]
+	return &archiveWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, archiveChunkSize)}, nil
+}
+
+func (a *archiveWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		c := copy(a.buf[len(a.buf):cap(a.buf)], p)
+		a.buf = a.buf[:len(a.buf)+c]
+		p = p[c:]
+		n += c
+		if len(a.buf) == cap(a.buf) && len(p) > 0 {
+			if err := a.seal(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close writes the last chunk, which can be empty.
+func (a *archiveWriter) Close() error {
+	return a.seal(true)
+}
+
+func (a *archiveWriter) seal(last bool) error {
+	flag := []byte{0}
+	if last {
+		flag[0] = 1
+	}
+	sealed := a.aead.Seal(nil, archiveNonce(a.prefix, a.index), a.buf, flag)
+	a.index++
+	a.buf = a.buf[:0]
+	header := binary.BigEndian.AppendUint32(flag, uint32(len(sealed)))
+	if _, err := a.w.Write(header); err != nil {
+		return err
+	}
+	_, err := a.w.Write(sealed)
+	return err
+}
+
+// archiveReader decrypts the chunks written by archiveWriter, and fails when the last one is missing.
+type archiveReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint64
+	buf    []byte
+	last   bool
+}
+
+func newArchiveReader(r io.Reader, passphrase []byte) (*archiveReader, error) {
+	header := make([]byte, len(archiveMagic)+archiveSaltSize+archiveNonceSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, []byte(archiveMagic)) {
+		return nil, errors.NotValidf("invalid archive header")
+	}
+	aead, err := archiveCipher(passphrase, header[len(archiveMagic):len(archiveMagic)+archiveSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &archiveReader{r: r, aead: aead, prefix: header[len(archiveMagic)+archiveSaltSize:]}, nil
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	for len(a.buf) == 0 {
+		if a.last {
+			return 0, io.EOF
+		}
+		if err := a.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, a.buf)
+	a.buf = a.buf[n:]
+	return n, nil
+}
+
+func (a *archiveReader) open() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		return errors.NotValidf("the archive is truncated")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > archiveChunkSize+uint32(a.aead.Overhead()) {
+		return errors.NotValidf("invalid archive chunk size %d", size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(a.r, sealed); err != nil {
+		return errors.NotValidf("the archive is truncated")
+	}
+	buf, err := a.aead.Open(nil, archiveNonce(a.prefix, a.index), sealed, header[:1])
+	if err != nil {
+		return errors.NotValidf("unable to decrypt the archive, the passphrase is wrong or the archive is corrupted")
+	}
+	a.index++
+	a.buf = buf
+	a.last = header[0] == 1
+	return nil
+}
@@ -0,0 +1,92 @@
+package badger
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+func Test_repo_ExportArchive(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	// NOTE(marius): the random content doesn't compress, so the archive has more than one chunk.
+	for i := 0; i < 100; i++ {
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = vocab.IRI(fmt.Sprintf("https://example.com/objects/%d", i))
+		content := make([]byte, 2000)
+		_, _ = rand.Read(content)
+		note.Content = vocab.DefaultNaturalLanguageValue(hex.EncodeToString(content))
+		if _, err = r.Save(note); err != nil {
+			t.Fatalf("unable to save %s: %s", note.ID, err)
+		}
+		if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+			t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+		}
+	}
+	if err = r.SaveMetadata(processing.Metadata{Pw: []byte("hash")}, jdoe.ID); err != nil {
+		t.Fatalf("unable to save the metadata of %s: %s", jdoe.ID, err)
+	}
+	if err = r.CreateClient(&osin.DefaultClient{Id: "app", Secret: "s3cr3t"}); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+
+	buf := bytes.Buffer{}
+	pw := []byte("correct horse battery staple")
+	m, err := r.ExportArchive(&buf, pw)
+	if err != nil {
+		t.Fatalf("ExportArchive() error = %s", err)
+	}
+	if m.Records[DumpObject] != 101 || m.Records[DumpOAuth] != 1 || m.Records[DumpMetadata] != 1 {
+		t.Errorf("ExportArchive() manifest = %+v", m)
+	}
+	archive := buf.Bytes()
+	if len(archive) < 2*archiveChunkSize {
+		t.Fatalf("ExportArchive() wrote %d bytes, want more than two chunks", len(archive))
+	}
+
+	imported, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = imported.ImportArchive(bytes.NewReader(archive), []byte("wrong"), MigrationOptions{}); !errors.IsNotValid(err) {
+		t.Errorf("ImportArchive() with a wrong passphrase error = %v, want NotValid", err)
+	}
+	truncated := archive[:len(archive)-100]
+	if _, err = imported.ImportArchive(bytes.NewReader(truncated), pw, MigrationOptions{DryRun: true}); !errors.IsNotValid(err) {
+		t.Errorf("ImportArchive() of a truncated archive error = %v, want NotValid", err)
+	}
+	report, err := imported.ImportArchive(bytes.NewReader(archive), pw, MigrationOptions{})
+	if err != nil {
+		t.Fatalf("ImportArchive() error = %s", err)
+	}
+	if want := m.Records[DumpObject] + m.Records[DumpCollection]; report.Objects != want {
+		t.Errorf("ImportArchive() copied %d objects, want %d", report.Objects, want)
+	}
+	col, err := imported.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the imported outbox error = %s", err)
+	}
+	if n := len(col.(vocab.ItemCollection)); n != 100 {
+		t.Errorf("Load() of the imported outbox returned %d items, want 100", n)
+	}
+	if m, err := imported.LoadMetadata(jdoe.ID); err != nil || string(m.Pw) != "hash" {
+		t.Errorf("LoadMetadata() of the imported actor = %v, %v", m, err)
+	}
+	if _, err = imported.GetClient("app"); err != nil {
+		t.Errorf("GetClient() of the imported client error = %s", err)
+	}
+}
@@ -0,0 +1,252 @@
+package badger
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-ap/errors"
+)
+
+const (
+	// backupExt is the extension of the backups, which are in the format of the badger backup command.
+	backupExt = ".bak"
+	// backupManifestExt is the extension of the manifests uploaded after their backups.
+	backupManifestExt = ".manifest.json"
+	// backupTimeFormat is the format of the times in the names of the backups, which sort in chronological order.
+	backupTimeFormat = "20060102T150405Z"
+)
+
+// BackupSink is the interface of the destinations of the backups. Upload stores the content read from r under name.
+// The package provides DirSink, for local directories, S3Sink, for the S3 compatible object storages, and SFTPSink,
+// for SFTP servers. The other destinations can be implemented by the callers.
+type BackupSink interface {
+	Upload(name string, r io.Reader) error
+}
+
+// BackupManifest describes a backup, and is uploaded to the sink next to it, in JSON format,
+// so the integrity of the backup can be checked before restoring it.
+type BackupManifest struct {
+	// Name is the name of the backup in the sink.
+	Name string `json:"name"`
+	// Time is the time when the backup started.
+	Time time.Time `json:"time"`
+	// Size is the size in bytes of the backup.
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA-256 checksum of the backup.
+	SHA256 string `json:"sha256"`
+	// Version is the badger version of the latest key in the backup.
+	Version uint64 `json:"version"`
+}
+
+// BackupOptions configures BackupTo.
+type BackupOptions struct {
+	// Since is the version returned by a previous backup, for an incremental backup containing only the keys
+	// written after it. Zero makes a full backup.
+	Since uint64
+	// Passphrase, when set, encrypts the backup like the archives of ExportArchive.
+	Passphrase []byte
+}
+
+// Backup writes to w a full backup of the database, in the format of the badger backup command,
+// which can be read by Restore.
+func (r *repo) Backup(w io.Writer) (uint64, error) {
+	return r.BackupTo(w, BackupOptions{})
+}
+
+// BackupTo writes to w a backup of the database, which can be read by RestoreFrom. It returns the version of the
+// latest key in the backup, to be used as the Since option of the next incremental backup.
+//
+// The incremental backups are restored in order, after the full backup they are based on.
+func (r *repo) BackupTo(w io.Writer, opt BackupOptions) (uint64, error) {
+	if err := r.Open(); err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	since := opt.Since
+	if since > 0 {
+		// NOTE(marius): badger backs up the versions starting with since, which were in the previous backup.
+		since++
+	}
+	if len(opt.Passphrase) == 0 {
+		return r.d.Backup(w, since)
+	}
+	aw, err := newArchiveWriter(w, opt.Passphrase)
+	if err != nil {
+		return 0, err
+	}
+	version, err := r.d.Backup(aw, since)
+	if err != nil {
+		return version, err
+	}
+	return version, aw.Close()
+}
+
+// Restore loads the keys of a backup written by Backup, replacing the ones stored at the same keys.
+func (r *repo) Restore(rd io.Reader) error {
+	return r.RestoreFrom(rd, nil)
+}
+
+// RestoreFrom loads the keys of a backup written by BackupTo, decrypting it with the passphrase, when set.
+// An encrypted backup which was modified, or truncated, fails to restore.
+// The repositories returned by DryRun can't restore backups, as the loaded keys can't be recorded.
+func (r *repo) RestoreFrom(rd io.Reader, passphrase []byte) error {
+	if r.dryRun != nil {
+		return errors.NotImplementedf("unable to restore a backup in dry run mode")
+	}
+	if len(passphrase) > 0 {
+		ar, err := newArchiveReader(rd, passphrase)
+		if err != nil {
+			return err
+		}
+		rd = ar
+	}
+	if err := r.Open(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := r.d.Load(rd, 256); err != nil {
+		return errors.Annotatef(err, "unable to restore the backup")
+	}
+	r.clearCaches()
+	return nil
+}
+
+// UploadBackup streams a backup of the database to the sink, and then uploads its manifest.
+// When the backup fails, its manifest is not uploaded, so the sink doesn't contain a valid backup for it.
+func (r *repo) UploadBackup(sink BackupSink) (BackupManifest, error) {
+	now := time.Now().UTC()
+	m := BackupManifest{Name: "badger-" + now.Format(backupTimeFormat) + backupExt, Time: now}
+
+	pr, pw := io.Pipe()
+	h := sha256.New()
+	cw := countingWriter{w: io.MultiWriter(pw, h)}
+	errc := make(chan error, 1)
+	go func() {
+		var err error
+		m.Version, err = r.Backup(&cw)
+		_ = pw.CloseWithError(err)
+		errc <- err
+	}()
+	err := sink.Upload(m.Name, pr)
+	// NOTE(marius): unblocks the backup, when the sink stopped reading.
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	if bErr := <-errc; bErr != nil {
+		return m, errors.Annotatef(bErr, "unable to back up the database")
+	}
+	if err != nil {
+		return m, errors.Annotatef(err, "unable to upload backup %s", m.Name)
+	}
+	m.Size = cw.n
+	m.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return m, err
+	}
+	name := strings.TrimSuffix(m.Name, backupExt) + backupManifestExt
+	if err = sink.Upload(name, strings.NewReader(string(raw))); err != nil {
+		return m, errors.Annotatef(err, "unable to upload manifest %s", name)
+	}
+	r.logFn("Uploaded backup %s: %d bytes", m.Name, m.Size)
+	return m, nil
+}
+
+// ScheduleBackups uploads a backup to the sink at every interval, until ctx is done.
+// The failed uploads are logged, and retried at the next interval.
+func (r *repo) ScheduleBackups(ctx context.Context, sink BackupSink, every time.Duration) {
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := r.UploadBackup(sink); err != nil {
+					r.errFn("scheduled backup failed: %+s", err)
+				}
+			}
+		}
+	}()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// DirSink is a BackupSink storing the backups in a local directory, keeping only the latest of them.
+type DirSink struct {
+	// Path is the directory where the backups are stored, which is created if it doesn't exist.
+	Path string
+	// Keep is the number of the latest backups kept, with their manifests. When zero, all of them are kept.
+	Keep int
+}
+
+// Upload writes the content of r to the file name in the directory, through a temporary file, so a failed
+// upload doesn't leave a partial backup behind. After uploading a manifest, the older backups are removed.
+func (s DirSink) Upload(name string, r io.Reader) error {
+	if err := mkDirIfNotExists(s.Path); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.Path, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), filepath.Join(s.Path, name)); err != nil {
+		return err
+	}
+	if strings.HasSuffix(name, backupManifestExt) {
+		return s.rotate()
+	}
+	return nil
+}
+
+// rotate removes the backups older than the latest Keep ones, together with their manifests.
+func (s DirSink) rotate() error {
+	if s.Keep <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(filepath.Join(s.Path, "*"+backupExt))
+	if err != nil {
+		return err
+	}
+	if len(backups) <= s.Keep {
+		return nil
+	}
+	sort.Strings(backups)
+	for _, b := range backups[:len(backups)-s.Keep] {
+		if err = os.Remove(b); err != nil {
+			return err
+		}
+		if err = os.Remove(strings.TrimSuffix(b, backupExt) + backupManifestExt); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package badger
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_UploadBackup(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+
+	sink := DirSink{Path: filepath.Join(t.TempDir(), "backups")}
+	m, err := r.UploadBackup(sink)
+	if err != nil {
+		t.Fatalf("UploadBackup() error = %s", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(sink.Path, m.Name))
+	if err != nil {
+		t.Fatalf("unable to read the backup %s: %s", m.Name, err)
+	}
+	sum := sha256.Sum256(raw)
+	if int64(len(raw)) != m.Size || hex.EncodeToString(sum[:]) != m.SHA256 {
+		t.Errorf("UploadBackup() manifest = %+v, doesn't match the backup of %d bytes", m, len(raw))
+	}
+	rawManifest, err := os.ReadFile(filepath.Join(sink.Path, strings.TrimSuffix(m.Name, backupExt)+backupManifestExt))
+	if err != nil {
+		t.Fatalf("unable to read the manifest of %s: %s", m.Name, err)
+	}
+	uploaded := BackupManifest{}
+	if err = json.Unmarshal(rawManifest, &uploaded); err != nil || uploaded.SHA256 != m.SHA256 {
+		t.Errorf("uploaded manifest = %s, want %+v", rawManifest, m)
+	}
+
+	restored, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	f, err := os.Open(filepath.Join(sink.Path, m.Name))
+	if err != nil {
+		t.Fatalf("unable to open the backup %s: %s", m.Name, err)
+	}
+	defer f.Close()
+	if err = restored.Restore(f); err != nil {
+		t.Fatalf("Restore() error = %s", err)
+	}
+	if _, err = restored.Load(ob.ID); err != nil {
+		t.Errorf("Load() of the restored object error = %s", err)
+	}
+}
+
+func TestDirSink_rotate(t *testing.T) {
+	sink := DirSink{Path: t.TempDir(), Keep: 2}
+	names := []string{"badger-20250101T000000Z", "badger-20250102T000000Z", "badger-20250103T000000Z"}
+	for _, name := range names {
+		if err := sink.Upload(name+backupExt, strings.NewReader("backup")); err != nil {
+			t.Fatalf("Upload() error = %s", err)
+		}
+		if err := sink.Upload(name+backupManifestExt, strings.NewReader("{}")); err != nil {
+			t.Fatalf("Upload() error = %s", err)
+		}
+	}
+	entries, _ := os.ReadDir(sink.Path)
+	if len(entries) != 4 {
+		t.Errorf("DirSink kept %d files, want the 2 latest backups and their manifests", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(sink.Path, names[0]+backupExt)); !os.IsNotExist(err) {
+		t.Errorf("DirSink kept the oldest backup %s", names[0])
+	}
+}
+
+func Test_repo_BackupTo_Incremental(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	passphrase := []byte("correct horse battery staple")
+	first := vocab.ObjectNew(vocab.NoteType)
+	first.ID = "https://example.com/objects/1"
+	if _, err = r.Save(first); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	full := bytes.Buffer{}
+	version, err := r.BackupTo(&full, BackupOptions{Passphrase: passphrase})
+	if err != nil {
+		t.Fatalf("BackupTo() error = %s", err)
+	}
+	if bytes.Contains(full.Bytes(), []byte(first.ID)) {
+		t.Errorf("BackupTo() with a passphrase wrote the objects unencrypted")
+	}
+
+	second := vocab.ObjectNew(vocab.NoteType)
+	second.ID = "https://example.com/objects/2"
+	if _, err = r.Save(second); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	incremental := bytes.Buffer{}
+	if _, err = r.BackupTo(&incremental, BackupOptions{Since: version}); err != nil {
+		t.Fatalf("BackupTo() incremental error = %s", err)
+	}
+	if bytes.Contains(incremental.Bytes(), []byte(first.ID)) || !bytes.Contains(incremental.Bytes(), []byte(second.ID)) {
+		t.Errorf("BackupTo() incremental should contain only the objects saved after the full backup")
+	}
+
+	restored, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	// NOTE(marius): the objects looked up before the restore need to be found afterwards.
+	restored.notFound = cache.NewNotFound(time.Minute)
+	if _, err = restored.Load(first.ID); !errors.IsNotFound(err) {
+		t.Fatalf("Load() before the restore error = %v, want NotFound", err)
+	}
+	if err = restored.RestoreFrom(bytes.NewReader(full.Bytes()), []byte("wrong")); err == nil {
+		t.Errorf("RestoreFrom() with the wrong passphrase should fail")
+	}
+	if err = restored.RestoreFrom(&full, passphrase); err != nil {
+		t.Fatalf("RestoreFrom() error = %s", err)
+	}
+	if err = restored.RestoreFrom(&incremental, nil); err != nil {
+		t.Fatalf("RestoreFrom() incremental error = %s", err)
+	}
+	for _, iri := range []vocab.IRI{first.ID, second.ID} {
+		if _, err = restored.Load(iri); err != nil {
+			t.Errorf("Load() of the restored %s error = %s", iri, err)
+		}
+	}
+}
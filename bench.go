@@ -0,0 +1,177 @@
+package badger
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// The defaults of the BenchOptions.
+const (
+	DefaultBenchItems    = 1000
+	DefaultBenchItemSize = 1 << 10
+	DefaultBenchScans    = 10
+)
+
+// benchBase is the IRI under which Bench saves its objects.
+const benchBase = vocab.IRI("https://bench.example.com/objects")
+
+// BenchWorkload is the name of a workload run by Bench.
+type BenchWorkload string
+
+const (
+	// BenchWrite saves the objects, one by one.
+	BenchWrite BenchWorkload = "write"
+	// BenchRead loads the saved objects, one by one.
+	BenchRead BenchWorkload = "read"
+	// BenchScan loads the storage collection containing all the saved objects.
+	BenchScan BenchWorkload = "scan"
+)
+
+// BenchOptions configures Bench.
+type BenchOptions struct {
+	// Dir is the scratch directory of the database, which must be empty, and which is kept after the run.
+	// When empty, a temporary directory is used, and removed after the run.
+	Dir string
+	// Items is the number of objects written and read. When 0, DefaultBenchItems is used.
+	Items int
+	// ItemSize is the size, in bytes, of the content of the objects. When 0, DefaultBenchItemSize is used.
+	ItemSize int
+	// Scans is the number of times the collection of all the objects is loaded. When 0, DefaultBenchScans is used.
+	Scans int
+	// ProgressFn is called after each workload.
+	ProgressFn func(BenchResult)
+}
+
+// BenchResult contains the measurements of a workload run by Bench.
+type BenchResult struct {
+	Workload BenchWorkload
+	Ops      int
+	Duration time.Duration
+	// Throughput is the number of operations per second.
+	Throughput float64
+	// P50, P90 and P99 are the percentiles of the latencies of the operations.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+func (b BenchResult) String() string {
+	return fmt.Sprintf("%s: %d ops in %s, %.1f ops/s, p50 %s, p90 %s, p99 %s, max %s",
+		b.Workload, b.Ops, b.Duration, b.Throughput, b.P50, b.P90, b.P99, b.Max)
+}
+
+// BenchReport is the result of Bench.
+type BenchReport struct {
+	Dir      string
+	Items    int
+	ItemSize int
+	Results  []BenchResult
+}
+
+// Bench runs the write, read and scan workloads against a database in a scratch directory, configured like conf,
+// and measures their throughput and latencies, for validating the hardware and the tuning of the storage.
+// The Path of conf is replaced by the scratch directory, and its Mirror, Canary and MaxLoadItems are not used,
+// while the badger options, the encryption, the caches and the indexes are.
+func Bench(conf Config, opt BenchOptions) (BenchReport, error) {
+	if opt.Items <= 0 {
+		opt.Items = DefaultBenchItems
+	}
+	if opt.ItemSize <= 0 {
+		opt.ItemSize = DefaultBenchItemSize
+	}
+	if opt.Scans <= 0 {
+		opt.Scans = DefaultBenchScans
+	}
+	report := BenchReport{Dir: opt.Dir, Items: opt.Items, ItemSize: opt.ItemSize, Results: make([]BenchResult, 0, 3)}
+
+	if report.Dir == "" {
+		dir, err := os.MkdirTemp("", "storage-bench-")
+		if err != nil {
+			return report, errors.Annotatef(err, "unable to create a scratch directory")
+		}
+		defer os.RemoveAll(dir)
+		report.Dir = dir
+	} else if entries, err := os.ReadDir(report.Dir); err == nil && len(entries) > 0 {
+		return report, errors.NotValidf("the scratch directory %s is not empty", report.Dir)
+	}
+
+	conf.Path = report.Dir
+	conf.Mirror, conf.Canary, conf.MaxLoadItems = nil, nil, 0
+	r, err := New(conf)
+	if err != nil {
+		return report, err
+	}
+
+	content := strings.Repeat("a", opt.ItemSize)
+	iris := make(vocab.IRIs, opt.Items)
+	for i := range iris {
+		iris[i] = benchBase.AddPath(fmt.Sprintf("%d", i))
+	}
+	workloads := []struct {
+		name BenchWorkload
+		ops  int
+		run  func(i int) error
+	}{
+		{name: BenchWrite, ops: opt.Items, run: func(i int) error {
+			ob := vocab.ObjectNew(vocab.NoteType)
+			ob.ID = iris[i]
+			ob.Content = vocab.DefaultNaturalLanguageValue(content)
+			_, err := r.Save(ob)
+			return err
+		}},
+		{name: BenchRead, ops: opt.Items, run: func(i int) error {
+			_, err := r.Load(iris[i], BypassCache())
+			return err
+		}},
+		{name: BenchScan, ops: opt.Scans, run: func(_ int) error {
+			_, err := r.Load(benchBase, BypassCache())
+			return err
+		}},
+	}
+	for _, w := range workloads {
+		latencies := make([]time.Duration, w.ops)
+		start := time.Now()
+		for i := 0; i < w.ops; i++ {
+			opStart := time.Now()
+			if err = w.run(i); err != nil {
+				return report, errors.Annotatef(err, "the %s workload failed", w.name)
+			}
+			latencies[i] = time.Since(opStart)
+		}
+		res := benchResult(w.name, time.Since(start), latencies)
+		report.Results = append(report.Results, res)
+		if opt.ProgressFn != nil {
+			opt.ProgressFn(res)
+		}
+	}
+	return report, nil
+}
+
+// benchResult returns the measurements of a workload from the latencies of its operations.
+func benchResult(name BenchWorkload, d time.Duration, latencies []time.Duration) BenchResult {
+	res := BenchResult{Workload: name, Ops: len(latencies), Duration: d}
+	if len(latencies) == 0 {
+		return res
+	}
+	if d > 0 {
+		res.Throughput = float64(len(latencies)) / d.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return latencies[max(i, 0)]
+	}
+	res.P50, res.P90, res.P99 = percentile(0.5), percentile(0.9), percentile(0.99)
+	res.Max = latencies[len(latencies)-1]
+	return res
+}
@@ -0,0 +1,41 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ap/errors"
+)
+
+func TestBench(t *testing.T) {
+	dir := t.TempDir()
+	report, err := Bench(Config{}, BenchOptions{Dir: dir, Items: 20, ItemSize: 64, Scans: 2})
+	if err != nil {
+		t.Fatalf("Bench() error = %s", err)
+	}
+	want := map[BenchWorkload]int{BenchWrite: 20, BenchRead: 20, BenchScan: 2}
+	if len(report.Results) != len(want) {
+		t.Fatalf("Bench() = %+v, want the results of %v", report, want)
+	}
+	for _, res := range report.Results {
+		if res.Ops != want[res.Workload] || res.Throughput <= 0 || res.P50 > res.P99 || res.P99 > res.Max {
+			t.Errorf("Bench() %s = %s, want %d ops", res.Workload, res, want[res.Workload])
+		}
+	}
+
+	if _, err = Bench(Config{}, BenchOptions{Dir: dir, Items: 1}); !errors.IsNotValid(err) {
+		t.Errorf("Bench() in a non empty directory error = %v, want NotValid", err)
+	}
+}
+
+func Test_benchResult(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	res := benchResult(BenchRead, time.Second, latencies)
+	if res.Ops != 100 || res.Throughput != 100 || res.P50 != 50*time.Millisecond || res.P90 != 90*time.Millisecond ||
+		res.P99 != 99*time.Millisecond || res.Max != 100*time.Millisecond {
+		t.Errorf("benchResult() = %s", res)
+	}
+}
@@ -0,0 +1,68 @@
+package badger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/go-ap/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltRootBucket is the bucket under which storage-boltdb keeps all its data.
+const BoltRootBucket = ":"
+
+// BoltMigrationOptions configures the migration done by MigrateFromBoltDB.
+type BoltMigrationOptions struct {
+	MigrationOptions
+	// RootBucket is the bucket containing the data to migrate. When empty, BoltRootBucket is used.
+	RootBucket string
+}
+
+// MigrateFromBoltDB copies the data of the storage-boltdb database at path: the objects, the collections, their
+// metadata and the OAuth data. The nested buckets of boltdb become the segments of the badger keys, which is
+// the layout both storages use for the same data.
+//
+// The type keys get created while copying, and the filter indexes, the collection cursors and the membership keys
+// are rebuilt after, by ReindexAll. The boltdb database is opened read-only, and is left unchanged.
+func (r *repo) MigrateFromBoltDB(path string, opt BoltMigrationOptions) (MigrationReport, error) {
+	root := opt.RootBucket
+	if root == "" {
+		root = BoltRootBucket
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return MigrationReport{}, errors.Annotatef(err, "unable to open boltdb database %s", path)
+	}
+	defer db.Close()
+
+	imp, err := r.newImporter("boltdb", path, opt.MigrationOptions)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		rb := tx.Bucket([]byte(root))
+		if rb == nil {
+			return errors.NewNotFound(ErrNotFound, "root bucket %q not found in %s", root, path)
+		}
+		return walkBoltBucket(rb, nil, imp.set)
+	})
+	return imp.finish(err)
+}
+
+// walkBoltBucket calls fn for all the keys found in bucket and in its nested buckets, with the names
+// of the buckets prepended to them, as segments of the badger key.
+func walkBoltBucket(bucket *bolt.Bucket, prefix []byte, fn func(k, v []byte) error) error {
+	return bucket.ForEach(func(k, v []byte) error {
+		key := k
+		if len(prefix) > 0 {
+			key = bytes.Join([][]byte{prefix, k}, sep)
+		}
+		if v == nil {
+			if nested := bucket.Bucket(k); nested != nil {
+				return walkBoltBucket(nested, key, fn)
+			}
+		}
+		return fn(key, v)
+	})
+}
@@ -0,0 +1,108 @@
+package badger
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// initBoltForTesting creates a boltdb database with the values at the paths, which get split in nested buckets.
+func initBoltForTesting(t *testing.T, values map[string][]byte) string {
+	path := filepath.Join(t.TempDir(), "storage.bdb")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatalf("unable to create boltdb database: %s", err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		for p, v := range values {
+			b, err := tx.CreateBucketIfNotExists([]byte(BoltRootBucket))
+			if err != nil {
+				return err
+			}
+			pieces := strings.Split(p, "/")
+			for _, name := range pieces[:len(pieces)-1] {
+				if b, err = b.CreateBucketIfNotExists([]byte(name)); err != nil {
+					return err
+				}
+			}
+			if err = b.Put([]byte(pieces[len(pieces)-1]), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to populate boltdb database: %s", err)
+	}
+	return path
+}
+
+func Test_repo_MigrateFromBoltDB(t *testing.T) {
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	raw, _ := encodeItemFn(note)
+	path := initBoltForTesting(t, map[string][]byte{
+		"example.com/objects/1/__raw":       raw,
+		"example.com/objects/1/__meta_data": []byte(`{}`),
+		"oauth/clients/app":                 []byte(`{"Id":"app"}`),
+	})
+
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	report, err := r.MigrateFromBoltDB(path, BoltMigrationOptions{MigrationOptions: MigrationOptions{DryRun: true}})
+	if err != nil {
+		t.Fatalf("MigrateFromBoltDB() dry run error = %s", err)
+	}
+	if report.Keys != 3 || report.Objects != 1 {
+		t.Errorf("MigrateFromBoltDB() dry run = %+v, want 3 keys and 1 object", report)
+	}
+	if _, err = r.Load(note.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() after a dry run error = %v, want NotFound", err)
+	}
+
+	calls := 0
+	report, err = r.MigrateFromBoltDB(path, BoltMigrationOptions{MigrationOptions: MigrationOptions{ProgressFn: func(MigrationReport) { calls++ }}})
+	if err != nil {
+		t.Fatalf("MigrateFromBoltDB() error = %s", err)
+	}
+	if report.Keys != 3 || report.Objects != 1 || report.Indexed != 1 {
+		t.Errorf("MigrateFromBoltDB() = %+v, want 3 keys, 1 object and 1 indexed", report)
+	}
+	if calls == 0 {
+		t.Errorf("MigrateFromBoltDB() didn't report its progress")
+	}
+	it, err := r.Load(note.ID)
+	if err != nil {
+		t.Fatalf("Load() after migrating error = %s", err)
+	}
+	if !it.GetLink().Equals(note.ID, false) {
+		t.Errorf("Load() after migrating = %s, want %s", it.GetLink(), note.ID)
+	}
+
+	if _, err = r.MigrateFromBoltDB(path, BoltMigrationOptions{RootBucket: "missing"}); !errors.IsNotFound(err) {
+		t.Errorf("MigrateFromBoltDB() with a missing root bucket error = %v, want NotFound", err)
+	}
+
+	_ = r.Open()
+	defer r.Close()
+	err = r.d.View(func(tx *badger.Txn) error {
+		for _, k := range [][]byte{badgerItemPath(clientsBucket, "app"), getTypeKey([]byte("example.com/objects/1"), vocab.NoteType)} {
+			if _, err := tx.Get(k); err != nil {
+				t.Errorf("key %s was not created: %s", k, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("unable to read the migrated keys: %s", err)
+	}
+}
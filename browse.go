@@ -0,0 +1,261 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+// browseListLimit is the maximum number of entries of a page of the listings of the browse handler.
+const browseListLimit = 500
+
+// browseHandler is the read-only http.Handler returned by NewBrowseHandler.
+type browseHandler struct {
+	r *repo
+}
+
+// NewBrowseHandler returns a read-only http.Handler for browsing the key space of the repository, from a web browser:
+//   - ?prefix=<p> lists the keys starting with p, grouped by their next segment, which ends in "/" or "\x00"
+//   - ?key=<k> shows the value of the key k, pretty-printed when it's JSON, and for the keys of the objects
+//     and of the collections, the decoded item, the members of the collection, and the collections containing it.
+//
+// The values are shown as they are stored, including the private keys of the metadata and the secrets of the OAuth
+// clients, so the handler must be mounted behind the authentication of the admin interface, like:
+//
+//	mux.Handle("/admin/storage/", http.StripPrefix("/admin/storage", auth(badger.NewBrowseHandler(repo))))
+func NewBrowseHandler(r *repo) http.Handler {
+	return browseHandler{r: r}
+}
+
+// browseLink is a link of the pages of the browse handler, to a prefix, or to a key when Key is set.
+type browseLink struct {
+	Name   string
+	Prefix string
+	Key    string
+}
+
+type browsePage struct {
+	Prefix  string
+	Parents []browseLink
+	// Entries and More are the links of a listing, and the name of its last entry when it has more pages.
+	Entries []browseLink
+	More    string
+	// The rest are for the page of a key.
+	Key         string
+	Size        int
+	Raw         string
+	Binary      bool
+	Item        string
+	DecodeError string
+	Members     []browseLink
+	MemberOf    []browseLink
+}
+
+func (b browseHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := b.r.Open(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer b.r.Close()
+
+	q := req.URL.Query()
+	page := browsePage{}
+	tpl := "list"
+	err := b.r.d.View(func(tx *badger.Txn) error {
+		if key := q.Get("key"); key != "" {
+			tpl = "key"
+			return browseKey(tx, key, &page)
+		}
+		browseList(tx, q.Get("prefix"), q.Get("after"), &page)
+		return nil
+	})
+	if err == badger.ErrKeyNotFound {
+		http.Error(w, "key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err = browseTemplates.ExecuteTemplate(w, tpl, page); err != nil {
+		b.r.errFn("unable to render the browse page: %s", err)
+	}
+}
+
+// browseList lists the keys starting with prefix, and the prefixes of the groups of keys having another segment
+// after it. The groups are skipped over, so a listing reads a key per entry.
+func browseList(tx dbTxn, prefix, after string, page *browsePage) {
+	page.Prefix = prefix
+	page.Parents = browseParents(prefix)
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = []byte(prefix)
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	start := opt.Prefix
+	if after != "" {
+		start = browseAfter(prefix + after)
+	}
+	for it.Seek(start); it.ValidForPrefix(opt.Prefix); {
+		rest := string(it.Item().Key()[len(prefix):])
+		if len(page.Entries) == browseListLimit {
+			page.More = page.Entries[len(page.Entries)-1].Prefix[len(prefix):]
+			if page.More == "" {
+				page.More = page.Entries[len(page.Entries)-1].Key[len(prefix):]
+			}
+			return
+		}
+		if i := strings.IndexAny(rest, "/\x00"); i >= 0 {
+			name := rest[:i+1]
+			page.Entries = append(page.Entries, browseLink{Name: browseName(name), Prefix: prefix + name})
+			it.Seek(browseAfter(prefix + name))
+			continue
+		}
+		page.Entries = append(page.Entries, browseLink{Name: browseName(rest), Key: prefix + rest})
+		it.Next()
+	}
+}
+
+// browseAfter returns the first key after the ones starting with k, when it ends with a separator, or after k.
+func browseAfter(k string) []byte {
+	if last := k[len(k)-1]; last == '/' || last == 0 {
+		return append([]byte(k[:len(k)-1]), last+1)
+	}
+	return append([]byte(k), 0)
+}
+
+// browseParents returns the links to the prefixes of k.
+func browseParents(k string) []browseLink {
+	parents := []browseLink{{Name: "/", Prefix: ""}}
+	for i := 0; i < len(k); i++ {
+		if k[i] == '/' || k[i] == 0 {
+			parents = append(parents, browseLink{Name: browseName(k[len(parents[len(parents)-1].Prefix) : i+1]), Prefix: k[:i+1]})
+		}
+	}
+	return parents
+}
+
+// browseName returns the printable form of a key, where the bytes which are not printable are escaped.
+func browseName(k string) string {
+	q := strconv.Quote(k)
+	return q[1 : len(q)-1]
+}
+
+func browseKey(tx dbTxn, key string, page *browsePage) error {
+	i, err := tx.Get([]byte(key))
+	if err != nil {
+		return err
+	}
+	raw, err := i.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	page.Key = key
+	page.Size = len(raw)
+	page.Parents = browseParents(key)
+	page.Raw, page.Binary = browseRaw(raw)
+
+	k := []byte(key)
+	if !isObjectKey(k) || bytes.HasPrefix(k, []byte("__")) {
+		return nil
+	}
+	it, err := decodeItemFn(raw)
+	if err != nil {
+		page.DecodeError = err.Error()
+		return nil
+	}
+	var iri vocab.IRI
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte{'['}) {
+		// NOTE(marius): the collections without a parent object don't have a known IRI, nor can they be found
+		// in the membership keys.
+		iri, _ = collectionIRI(tx, bytes.TrimSuffix(k, append(sep, objectKey...)))
+		_ = vocab.OnIRIs(it, func(members *vocab.IRIs) error {
+			for _, m := range *members {
+				page.Members = append(page.Members, browseLink{Name: m.String(), Key: string(getObjectKey(itemPath(m)))})
+			}
+			return nil
+		})
+	} else {
+		iri = it.GetLink()
+		if enc, err := encodeItemFn(it); err == nil {
+			page.Item, _ = browseRaw(enc)
+		}
+	}
+	if len(iri) == 0 {
+		return nil
+	}
+	for _, col := range collectionsContaining(tx, iri) {
+		page.MemberOf = append(page.MemberOf, browseLink{Name: col.String(), Key: string(getObjectKey(itemPath(col)))})
+	}
+	return nil
+}
+
+// browseRaw returns the printable form of a value: indented when it's JSON, as it is when it's text,
+// and hex dumped otherwise.
+func browseRaw(raw []byte) (string, bool) {
+	if json.Valid(raw) {
+		buf := bytes.Buffer{}
+		if err := json.Indent(&buf, raw, "", "  "); err == nil {
+			return buf.String(), false
+		}
+	}
+	if utf8.Valid(raw) {
+		return string(raw), false
+	}
+	return hex.Dump(raw), true
+}
+
+var browseTemplates = template.Must(template.New("browse").Parse(`
+{{- define "header" -}}
+<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{ . }}</title>
+<style>body{font-family:sans-serif}pre{background:#f4f4f4;padding:.5em;overflow:auto}li{font-family:monospace}</style>
+</head>
+<body>
+{{- end -}}
+{{- define "parents" -}}
+<p>{{ range . }}<a href="?prefix={{ .Prefix }}">{{ .Name }}</a> {{ end }}</p>
+{{- end -}}
+{{- define "links" -}}
+<ul>{{ range . }}<li>{{ if .Key }}<a href="?key={{ .Key }}">{{ .Name }}</a>{{ else }}<a href="?prefix={{ .Prefix }}">{{ .Name }}</a>{{ end }}</li>{{ end }}</ul>
+{{- end -}}
+{{- define "list" -}}
+{{ template "header" (printf "Keys %s" .Prefix) }}
+{{ template "parents" .Parents }}
+{{ template "links" .Entries }}
+{{ if .More }}<p><a href="?prefix={{ .Prefix }}&amp;after={{ .More }}">More</a></p>{{ end }}
+</body>
+</html>
+{{- end -}}
+{{- define "key" -}}
+{{ template "header" .Key }}
+{{ template "parents" .Parents }}
+<h2>Value</h2>
+<p>{{ .Size }} bytes{{ if .Binary }}, binary{{ end }}</p>
+<pre>{{ .Raw }}</pre>
+{{ if .DecodeError }}<p>Unable to decode the item: {{ .DecodeError }}</p>{{ end }}
+{{ if .Item }}<h2>Item</h2><pre>{{ .Item }}</pre>{{ end }}
+{{ if .Members }}<h2>Members</h2>{{ template "links" .Members }}{{ end }}
+{{ if .MemberOf }}<h2>Member of</h2>{{ template "links" .MemberOf }}{{ end }}
+</body>
+</html>
+{{- end -}}
+`))
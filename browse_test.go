@@ -0,0 +1,114 @@
+package badger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_browseHandler(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+
+	h := NewBrowseHandler(r)
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil))
+		return w
+	}
+	tests := []struct {
+		name     string
+		query    url.Values
+		status   int
+		contains []string
+	}{
+		{name: "root", query: url.Values{}, status: http.StatusOK, contains: []string{"example.com/", "__member_of/"}},
+		{name: "prefix", query: url.Values{"prefix": {"example.com/actors/jdoe/"}}, status: http.StatusOK, contains: []string{"outbox/", "__raw"}},
+		{
+			name:     "object",
+			query:    url.Values{"key": {"example.com/objects/1/__raw"}},
+			status:   http.StatusOK,
+			contains: []string{"https://example.com/objects/1", "Member of", "https://example.com/actors/jdoe/outbox"},
+		},
+		{
+			name:     "collection",
+			query:    url.Values{"key": {"example.com/actors/jdoe/outbox/__raw"}},
+			status:   http.StatusOK,
+			contains: []string{"Members", "?key=example.com%2fobjects%2f1%2f__raw"},
+		},
+		{name: "missing", query: url.Values{"key": {"example.com/objects/2/__raw"}}, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(tt.query)
+			if w.Code != tt.status {
+				t.Fatalf("ServeHTTP() status = %d, want %d", w.Code, tt.status)
+			}
+			body := w.Body.String()
+			for _, s := range tt.contains {
+				if !strings.Contains(body, s) {
+					t.Errorf("ServeHTTP() body doesn't contain %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?key=example.com/objects/1/__raw", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("ServeHTTP() of a POST request status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func Test_browseList_pages(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	_ = r.Open()
+	defer r.Close()
+	tx := r.d.NewTransaction(true)
+	for i := 0; i < browseListLimit+10; i++ {
+		_ = tx.Set([]byte("test/"+strconv.Itoa(100000+i)), []byte("{}"))
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatalf("unable to set the keys: %s", err)
+	}
+	txn := r.d.NewTransaction(false)
+	defer txn.Discard()
+
+	page := browsePage{}
+	browseList(txn, "", "", &page)
+	if len(page.Entries) != 1 || page.Entries[0].Prefix != "test/" || page.More != "" {
+		t.Errorf("browseList() of the root = %+v, want the test/ group", page.Entries)
+	}
+	page = browsePage{}
+	browseList(txn, "test/", "", &page)
+	if len(page.Entries) != browseListLimit || page.More == "" {
+		t.Fatalf("browseList() = %d entries, more %q, want %d and more", len(page.Entries), page.More, browseListLimit)
+	}
+	next := browsePage{}
+	browseList(txn, "test/", page.More, &next)
+	if len(next.Entries) != 10 || next.More != "" || next.Entries[0].Key != "test/"+strconv.Itoa(100000+browseListLimit) {
+		t.Errorf("browseList() after %s = %d entries starting at %v, want 10", page.More, len(next.Entries), next.Entries)
+	}
+}
@@ -0,0 +1,83 @@
+package badger
+
+import (
+	"sync/atomic"
+
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// CacheStats contains the counters of the cache for the results of Load, for sizing its limits.
+type CacheStats struct {
+	// Hits is the number of loads served from the cache.
+	Hits uint64
+	// Misses is the number of loads which weren't found in the cache.
+	Misses uint64
+	// Evictions is the number of results removed from the cache for making room for other ones.
+	// It is always zero for the caches which don't evict their results.
+	Evictions uint64
+	// Entries is the number of results currently cached.
+	Entries int
+	// Bytes is the approximate memory used by the cached results.
+	Bytes int64
+	// MaxEntries and MaxBytes are the limits of the cache, which are zero when not enforced.
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// Occupancy returns the proportion of the most constraining limit of the cache which is used, between 0 and 1,
+// or 0 when the cache has no limits.
+func (s CacheStats) Occupancy() float64 {
+	o := 0.0
+	if s.MaxEntries > 0 {
+		o = float64(s.Entries) / float64(s.MaxEntries)
+	}
+	if s.MaxBytes > 0 {
+		o = max(o, float64(s.Bytes)/float64(s.MaxBytes))
+	}
+	return min(o, 1)
+}
+
+// cacheCounters are shared between a repository and its clones.
+type cacheCounters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// evictionCounter is implemented by the caches which evict results when reaching their limits.
+type evictionCounter interface {
+	Evictions() uint64
+}
+
+// usageReporter is implemented by the caches which report their occupancy.
+type usageReporter interface {
+	Usage() cache.Usage
+}
+
+// CacheStats returns the current counters of the cache for the results of Load.
+func (r *repo) CacheStats() CacheStats {
+	stats := CacheStats{}
+	if r.cacheCounters != nil {
+		stats.Hits = r.cacheCounters.hits.Load()
+		stats.Misses = r.cacheCounters.misses.Load()
+	}
+	if ec, ok := r.cache.(evictionCounter); ok {
+		stats.Evictions = ec.Evictions()
+	}
+	if ur, ok := r.cache.(usageReporter); ok {
+		u := ur.Usage()
+		stats.Entries, stats.Bytes = u.Entries, u.Bytes
+		stats.MaxEntries, stats.MaxBytes = u.MaxEntries, u.MaxBytes
+	}
+	return stats
+}
+
+func (r *repo) countCacheLookup(hit bool) {
+	if r.cacheCounters == nil {
+		return
+	}
+	if hit {
+		r.cacheCounters.hits.Add(1)
+	} else {
+		r.cacheCounters.misses.Add(1)
+	}
+}
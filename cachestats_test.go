@@ -0,0 +1,93 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_CacheStats(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.NewLRU(1, 0)
+	r.cacheCounters = new(cacheCounters)
+
+	first := vocab.ObjectNew(vocab.NoteType)
+	first.ID = "http://example.com/objects/1"
+	second := vocab.ObjectNew(vocab.NoteType)
+	second.ID = "http://example.com/objects/2"
+	for _, it := range []vocab.Item{first, second} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	// NOTE(marius): the cache holds only one result, so loading the second object evicts the first one.
+	for _, iri := range []vocab.IRI{first.ID, first.ID, second.ID, first.ID} {
+		_ = mustLoad(t, r, iri)
+	}
+	counters := func(s CacheStats) CacheStats {
+		return CacheStats{Hits: s.Hits, Misses: s.Misses, Evictions: s.Evictions}
+	}
+	want := CacheStats{Hits: 1, Misses: 3, Evictions: 2}
+	if got := counters(r.CacheStats()); got != want {
+		t.Errorf("CacheStats() = %+v, want %+v", got, want)
+	}
+
+	clone := r.Clone().(*repo)
+	_ = mustLoad(t, clone, first.ID)
+	want.Hits++
+	if got := counters(r.CacheStats()); got != want {
+		t.Errorf("CacheStats() after loading from a clone = %+v, want %+v", got, want)
+	}
+}
+
+func Test_repo_Stats(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.NewLRU(4, 0)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+	_ = mustLoad(t, r, ob.ID)
+
+	s, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %s", err)
+	}
+	if s.Cache.Entries != 1 || s.Cache.MaxEntries != 4 || s.Cache.Bytes <= 0 {
+		t.Errorf("Stats() cache = %+v, want 1 entry of the 4 allowed", s.Cache)
+	}
+	if o := s.Cache.Occupancy(); o != 0.25 {
+		t.Errorf("Occupancy() = %f, want 0.25", o)
+	}
+}
+
+func TestCacheStats_Occupancy(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats CacheStats
+		want  float64
+	}{
+		{name: "no limits", stats: CacheStats{Entries: 10, Bytes: 1024}, want: 0},
+		{name: "entries", stats: CacheStats{Entries: 5, MaxEntries: 10}, want: 0.5},
+		{name: "bytes", stats: CacheStats{Bytes: 768, MaxBytes: 1024}, want: 0.75},
+		{name: "most constraining", stats: CacheStats{Entries: 1, MaxEntries: 10, Bytes: 512, MaxBytes: 1024}, want: 0.5},
+		{name: "over the limit", stats: CacheStats{Bytes: 2048, MaxBytes: 1024}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.Occupancy(); got != tt.want {
+				t.Errorf("Occupancy() = %f, want %f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package badger
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// Canary is the interface of the full storage backends which can be set in the Config for de-risking
+// a migration to them: they receive all the changes made to the repository, like a Mirror, and a part
+// of the results of Load are compared to the ones they return for the same IRIs.
+//
+// The metadata of the actors, like their passwords and keys, and the OAuth2 data aren't part of the Mirror
+// interface, so they aren't sent to the Canary, and they aren't compared either, as Load doesn't return them.
+type Canary interface {
+	Mirror
+	Load(vocab.IRI, ...filters.Check) (vocab.Item, error)
+}
+
+// CanaryStats contains the counters of the comparisons of the results of Load with the ones of the Canary.
+type CanaryStats struct {
+	// Compared is the number of results compared.
+	Compared int64
+	// Mismatches is the number of compared results which were different.
+	Mismatches int64
+}
+
+// canary compares the sampled results of Load with the ones of the Canary. The comparisons are run by the goroutine
+// sending the changes to it, after the changes made before the Load, so they don't report the changes still queued.
+type canary struct {
+	c          Canary
+	percent    float64
+	compared   atomic.Int64
+	mismatches atomic.Int64
+}
+
+// compareWithCanary queues the comparison of the result of loading iri with the one of the Canary, for the sampled loads.
+// The comparisons are dropped when the queue is full.
+func (r *repo) compareWithCanary(iri vocab.IRI, checks filters.Checks, it vocab.Item, err error) {
+	c := r.canary
+	if c == nil || r.mirror == nil || rand.Float64()*100 >= c.percent {
+		return
+	}
+	it = cache.Copy(it)
+	_, checks = bypassCache(checks)
+	op := mirrorOp{fn: func() {
+		got, gotErr := c.c.Load(iri, checks...)
+		c.compared.Add(1)
+		if diff := canaryDiff(it, err, got, gotErr); diff != "" {
+			c.mismatches.Add(1)
+			r.errFn("canary mismatch for %s: %s", iri, diff)
+		}
+	}}
+	select {
+	case r.mirror.ops <- op:
+	default:
+	}
+}
+
+// canaryDiff describes the difference between the result of Load and the one of the Canary, or returns an empty
+// string when they match. The collections match when they contain the same IRIs, in any order.
+func canaryDiff(want vocab.Item, wantErr error, got vocab.Item, gotErr error) string {
+	if wantErr != nil || gotErr != nil {
+		switch {
+		case wantErr == nil:
+			return fmt.Sprintf("canary error %s", gotErr)
+		case gotErr == nil:
+			return fmt.Sprintf("badger error %s", wantErr)
+		case errors.IsNotFound(wantErr) != errors.IsNotFound(gotErr):
+			return fmt.Sprintf("badger error %s, canary error %s", wantErr, gotErr)
+		}
+		return ""
+	}
+	if vocab.IsNil(want) || vocab.IsNil(got) {
+		if vocab.IsNil(want) != vocab.IsNil(got) {
+			return "only one of the results is empty"
+		}
+		return ""
+	}
+	if want.IsCollection() || got.IsCollection() {
+		wantIRIs, gotIRIs := comparedIRIs(want), comparedIRIs(got)
+		if len(wantIRIs) != len(gotIRIs) {
+			return fmt.Sprintf("%d items, canary %d items", len(wantIRIs), len(gotIRIs))
+		}
+		for _, iri := range wantIRIs {
+			if !gotIRIs.Contains(iri) {
+				return fmt.Sprintf("canary is missing %s", iri)
+			}
+		}
+		return ""
+	}
+	wantRaw, _ := encodeItemFn(want)
+	gotRaw, _ := encodeItemFn(got)
+	if !bytes.Equal(wantRaw, gotRaw) {
+		return "the documents differ"
+	}
+	return ""
+}
+
+// comparedIRIs returns the IRIs of the items of the collection it, or its own IRI when it's not a collection.
+func comparedIRIs(it vocab.Item) vocab.IRIs {
+	iris := make(vocab.IRIs, 0)
+	if !it.IsCollection() {
+		return append(iris, it.GetLink())
+	}
+	_ = vocab.OnCollectionIntf(it, func(col vocab.CollectionInterface) error {
+		for _, it := range col.Collection() {
+			iris = append(iris, it.GetLink())
+		}
+		return nil
+	})
+	return iris
+}
+
+// CanaryStats returns the counters of the comparisons with the Canary of the Config.
+func (r *repo) CanaryStats() CanaryStats {
+	if r.canary == nil {
+		return CanaryStats{}
+	}
+	return CanaryStats{Compared: r.canary.compared.Load(), Mismatches: r.canary.mismatches.Load()}
+}
@@ -0,0 +1,125 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// loadingCanary is a Canary returning the items it has been sent.
+type loadingCanary struct {
+	recordingMirror
+	items map[vocab.IRI]vocab.Item
+}
+
+func (c *loadingCanary) Save(it vocab.Item) (vocab.Item, error) {
+	c.items[it.GetLink()] = it
+	return c.recordingMirror.Save(it)
+}
+
+func (c *loadingCanary) Load(iri vocab.IRI, _ ...filters.Check) (vocab.Item, error) {
+	if it, ok := c.items[iri]; ok {
+		return it, nil
+	}
+	return nil, errors.NotFoundf("%s not found", iri)
+}
+
+func Test_repo_Canary(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	// NOTE(marius): the mismatches are logged as errors.
+	r.errFn = t.Logf
+	c := loadingCanary{items: make(map[vocab.IRI]vocab.Item)}
+	r.mirror = newMirror(&c, 0, t.Logf)
+	r.canary = &canary{c: &c, percent: 100}
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	if _, err = r.Load(ob.ID); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if _, err = r.Load("https://example.com/objects/missing"); !errors.IsNotFound(err) {
+		t.Fatalf("Load() of a missing object error = %v, want NotFound", err)
+	}
+	r.FlushMirror()
+	if s := r.CanaryStats(); s.Compared != 2 || s.Mismatches != 0 {
+		t.Errorf("CanaryStats() = %+v, want 2 matching comparisons", s)
+	}
+
+	// NOTE(marius): the canary doesn't receive the changes made directly to it.
+	changed := vocab.ObjectNew(vocab.ArticleType)
+	changed.ID = ob.ID
+	c.items[ob.ID] = changed
+	if _, err = r.Load(ob.ID, BypassCache()); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	r.FlushMirror()
+	if s := r.CanaryStats(); s.Compared != 3 || s.Mismatches != 1 {
+		t.Errorf("CanaryStats() = %+v, want 3 comparisons with 1 mismatch", s)
+	}
+}
+
+func Test_repo_Canary_Create(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.errFn = t.Logf
+	c := loadingCanary{items: make(map[vocab.IRI]vocab.Item)}
+	r.mirror = newMirror(&c, 0, t.Logf)
+	r.canary = &canary{c: &c, percent: 100}
+
+	col := vocab.OrderedCollectionNew("https://example.com/~jdoe/followers")
+	if _, err = r.Create(col); err != nil {
+		t.Fatalf("Create() error = %s", err)
+	}
+	service := vocab.ServiceNew("https://example.com")
+	if err = r.CreateService(service); err != nil {
+		t.Fatalf("CreateService() error = %s", err)
+	}
+	if _, err = r.Load(service.ID); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	r.FlushMirror()
+	for _, iri := range []vocab.IRI{col.ID, service.ID} {
+		if _, ok := c.items[iri]; !ok {
+			t.Errorf("the canary didn't receive %s", iri)
+		}
+	}
+	if s := r.CanaryStats(); s.Compared != 1 || s.Mismatches != 0 {
+		t.Errorf("CanaryStats() = %+v, want 1 matching comparison", s)
+	}
+}
+
+func Test_canaryDiff(t *testing.T) {
+	col := vocab.ItemCollection{vocab.IRI("https://example.com/1"), vocab.IRI("https://example.com/2")}
+	reordered := vocab.ItemCollection{vocab.IRI("https://example.com/2"), vocab.IRI("https://example.com/1")}
+	tests := []struct {
+		name     string
+		want     vocab.Item
+		wantErr  error
+		got      vocab.Item
+		gotErr   error
+		mismatch bool
+	}{
+		{name: "same collections in another order", want: col, got: reordered},
+		{name: "missing collection item", want: col, got: col[:1], mismatch: true},
+		{name: "both not found", wantErr: errors.NotFoundf("x"), gotErr: errors.NotFoundf("y")},
+		{name: "only canary not found", want: col, gotErr: errors.NotFoundf("y"), mismatch: true},
+		{name: "same IRIs", want: vocab.IRI("https://example.com/1"), got: vocab.IRI("https://example.com/1")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := canaryDiff(tt.want, tt.wantErr, tt.got, tt.gotErr); (diff != "") != tt.mismatch {
+				t.Errorf("canaryDiff() = %q, want mismatch %t", diff, tt.mismatch)
+			}
+		})
+	}
+}
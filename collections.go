@@ -0,0 +1,303 @@
+package badger
+
+import (
+	"bytes"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// ListCollections returns the IRIs of all the collections stored under the actor.
+// It iterates only over the keys, so the values of the collections are not loaded.
+func (r *repo) ListCollections(actor vocab.IRI) (vocab.IRIs, error) {
+	base := itemPath(actor)
+	if len(base) == 0 {
+		return nil, errors.Newf("invalid actor IRI %s", actor)
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	iris := make(vocab.IRIs, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		iris, err = listCollectionsInTxn(tx, actor)
+		return err
+	})
+	return iris, err
+}
+
+func listCollectionsInTxn(tx dbTxn, actor vocab.IRI) (vocab.IRIs, error) {
+	base := itemPath(actor)
+	prefix := append(append([]byte{}, base...), sep...)
+
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	iris := make(vocab.IRIs, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		if !isObjectKey(k) || iterKeyIsTooDeep(base, k, 1) {
+			continue
+		}
+		name := bytes.TrimSuffix(bytes.TrimPrefix(k, prefix), append(sep, objectKey...))
+		if !allStorageCollections.Contains(vocab.CollectionPath(name)) {
+			continue
+		}
+		if iri := vocab.CollectionPath(name).IRI(actor); !iris.Contains(iri) {
+			iris = append(iris, iri)
+		}
+	}
+	return iris, nil
+}
+
+// autoCreatedCollections are the collections that get created by the storage without being
+// explicitly requested: the hidden ones when they're first added to, and replies, likes and shares
+// when saving their parent object.
+var autoCreatedCollections = vocab.CollectionPaths{
+	vocab.Replies,
+	vocab.Likes,
+	vocab.Shares,
+	filters.BlockedType,
+	filters.IgnoredType,
+}
+
+// RemoveEmptyCollections removes the automatically created collections that have no items
+// and whose parent object has been published more than maxAge ago. The properties of the parent objects
+// referencing the removed collections are cleared.
+// It returns the IRIs of the collections that have been removed.
+func (r *repo) RemoveEmptyCollections(maxAge time.Duration) (vocab.IRIs, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	olderThan := time.Now().UTC().Add(-maxAge)
+	removed := make(vocab.IRIs, 0)
+	owners := make(vocab.IRIs, 0)
+	err = r.update(func(tx dbTxn) error {
+		toRemove := emptyCollections(tx, olderThan)
+
+		for k, iri := range toRemove {
+			if err := tx.Delete([]byte(k)); err != nil {
+				return errors.Annotatef(err, "unable to remove empty collection %s", iri)
+			}
+			owner, err := unsetCollection(r, tx, iri)
+			if err != nil {
+				return errors.Annotatef(err, "unable to clear the empty collection %s of its object", iri)
+			}
+			if len(owner) > 0 {
+				owners = append(owners, owner)
+			}
+			removed = append(removed, iri)
+			r.logFn("Removed empty collection %s", iri)
+		}
+		return nil
+	})
+	if len(removed) > 0 {
+		r.invalidateResults(removed...)
+	}
+	for _, owner := range owners {
+		r.invalidateItem(owner)
+	}
+	return removed, err
+}
+
+// unsetCollection clears the replies, likes or shares property of the object the col collection belongs to,
+// when it references the collection, and saves the object. It returns the IRI of the changed object, if any.
+func unsetCollection(r *repo, tx dbTxn, col vocab.IRI) (vocab.IRI, error) {
+	ob, _ := allStorageCollections.Split(col)
+	owner, err := loadRawItem(tx, itemPath(ob))
+	if err != nil || vocab.IsNil(owner) {
+		return "", nil
+	}
+	changed := false
+	unset := func(prop vocab.Item) vocab.Item {
+		if vocab.IsNil(prop) || !prop.GetLink().Equals(col, false) {
+			return prop
+		}
+		changed = true
+		return nil
+	}
+	if vocab.ActorTypes.Contains(owner.GetType()) {
+		err = vocab.OnActor(owner, func(a *vocab.Actor) error {
+			a.Replies, a.Likes, a.Shares = unset(a.Replies), unset(a.Likes), unset(a.Shares)
+			return nil
+		})
+	} else {
+		err = vocab.OnObject(owner, func(o *vocab.Object) error {
+			o.Replies, o.Likes, o.Shares = unset(o.Replies), unset(o.Likes), unset(o.Shares)
+			return nil
+		})
+	}
+	if err != nil || !changed {
+		return "", err
+	}
+	if _, err = saveItem(r, tx, owner); err != nil {
+		return "", err
+	}
+	return owner.GetLink(), nil
+}
+
+// emptyCollections returns the keys, and the IRIs, of the automatically created collections that have no items
+// and whose parent object has been published before olderThan.
+func emptyCollections(tx dbTxn, olderThan time.Time) map[string]vocab.IRI {
+	found := make(map[string]vocab.IRI)
+
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		k := it.Item().KeyCopy(nil)
+		if !isObjectKey(k) {
+			continue
+		}
+		colPath := string(bytes.TrimSuffix(k, append(sep, objectKey...)))
+		typ := vocab.CollectionPath(filepath.Base(colPath))
+		if !autoCreatedCollections.Contains(typ) {
+			continue
+		}
+		if !isEmptyCollection(it.Item()) {
+			continue
+		}
+		parent, err := loadRawItem(tx, []byte(filepath.Dir(colPath)))
+		if err != nil {
+			continue
+		}
+		if published := publishedTime(parent); published.IsZero() || published.After(olderThan) {
+			continue
+		}
+		found[string(k)] = typ.IRI(parent)
+	}
+	return found
+}
+
+func isEmptyCollection(i *badger.Item) bool {
+	empty := false
+	_ = i.Value(func(raw []byte) error {
+		it, err := decodeItemFn(raw)
+		if err != nil {
+			return err
+		}
+		if vocab.IsNil(it) {
+			empty = true
+			return nil
+		}
+		return vocab.OnIRIs(it, func(col *vocab.IRIs) error {
+			empty = len(*col) == 0
+			return nil
+		})
+	})
+	return empty
+}
+
+// loadRawItem loads and decodes the item stored at path, without dereferencing any of its properties.
+func loadRawItem(tx dbTxn, path []byte) (vocab.Item, error) {
+	return loadRawKey(tx, getObjectKey(path))
+}
+
+func loadRawKey(tx dbTxn, k []byte) (vocab.Item, error) {
+	i, err := tx.Get(k)
+	if err != nil {
+		return nil, errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load key %s", k)
+	}
+	var it vocab.Item
+	err = i.Value(func(raw []byte) error {
+		it, err = loadItem(raw)
+		return err
+	})
+	return it, err
+}
+
+// publishedTime returns the published time of the object, falling back to its updated time
+// when it doesn't have one.
+func publishedTime(it vocab.Item) time.Time {
+	var published time.Time
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		published = o.Published
+		if published.IsZero() {
+			published = o.Updated
+		}
+		return nil
+	})
+	return published
+}
+
+type collectionRef struct {
+	col vocab.IRI
+	it  vocab.IRI
+}
+
+// appreciationReferences keeps the liked collection of an actor and the likes collection of an object in sync.
+//
+// The actor's liked collection contains the objects that were liked, and the object's likes collection contains
+// the Like activities. When operating on one of them with a Like activity, it returns the item that belongs
+// in the col collection, and the corresponding entry that needs to be updated in the other collection.
+// When removing an object from an actor's liked collection, the references are the actor's Like activities
+// from the object's likes collection.
+func appreciationReferences(tx dbTxn, col vocab.IRI, it vocab.Item, removing bool) (vocab.IRI, []collectionRef) {
+	iri := it.GetLink()
+	owner, typ := vocab.Split(col)
+	if typ != vocab.Likes && typ != vocab.Liked {
+		return iri, nil
+	}
+
+	ob := it
+	if !ob.IsObject() {
+		var err error
+		if ob, err = loadRawItem(tx, itemPath(iri)); err != nil {
+			return iri, nil
+		}
+	}
+
+	refs := make([]collectionRef, 0)
+	if ob.GetType() == vocab.LikeType {
+		_ = vocab.OnActivity(ob, func(a *vocab.Activity) error {
+			if vocab.IsNil(a.Actor) || vocab.IsNil(a.Object) {
+				return nil
+			}
+			if typ == vocab.Likes {
+				refs = append(refs, collectionRef{col: vocab.Liked.IRI(a.Actor), it: a.Object.GetLink()})
+			} else {
+				iri = a.Object.GetLink()
+				refs = append(refs, collectionRef{col: vocab.Likes.IRI(a.Object), it: a.GetLink()})
+			}
+			return nil
+		})
+		return iri, refs
+	}
+
+	if typ == vocab.Liked && removing {
+		likes := vocab.Likes.IRI(ob)
+		activities, err := loadRawItem(tx, itemPath(likes))
+		if err != nil {
+			return iri, nil
+		}
+		_ = vocab.OnIRIs(activities, func(col *vocab.IRIs) error {
+			for _, act := range *col {
+				a, err := loadRawItem(tx, itemPath(act))
+				if err != nil || a.GetType() != vocab.LikeType {
+					continue
+				}
+				_ = vocab.OnActivity(a, func(a *vocab.Activity) error {
+					if !vocab.IsNil(a.Actor) && a.Actor.GetLink().Equals(owner, false) {
+						refs = append(refs, collectionRef{col: likes, it: a.GetLink()})
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	}
+	return iri, refs
+}
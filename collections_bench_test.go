@@ -0,0 +1,79 @@
+package badger
+
+import (
+	"fmt"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func initBenchmarkCollection(b *testing.B, count int) (*repo, vocab.IRI, vocab.ItemCollection) {
+	r, err := initBadgerForTesting(b)
+	if err != nil {
+		b.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.logFn = emptyLogFn
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		b.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	if err = r.Open(); err != nil {
+		b.Fatalf("Open() error = %s", err)
+	}
+	defer r.Close()
+
+	// NOTE(marius): the members are saved in a different order than their keys sort in.
+	iris := make(vocab.ItemCollection, 0, count)
+	for i := 0; i < count; i++ {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%d", (i*7919)%count))
+		ob.AttributedTo = jdoe.ID
+		if _, err = save(r, ob); err != nil {
+			b.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+		iris = append(iris, ob.ID)
+	}
+	raw, _ := encodeItemFn(iris.IRIs())
+	wb := r.d.NewWriteBatch()
+	if err = wb.Set(getObjectKey(itemPath(jdoe.Outbox.GetLink())), raw); err != nil {
+		b.Fatalf("unable to store the collection: %s", err)
+	}
+	if err = wb.Flush(); err != nil {
+		b.Fatalf("unable to store the collection: %s", err)
+	}
+	return r, jdoe.Outbox.GetLink(), iris
+}
+
+func Benchmark_repo_Load_Collection10k(b *testing.B) {
+	r, col, _ := initBenchmarkCollection(b, 10_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		res, err := r.Load(col)
+		if err != nil {
+			b.Fatalf("Load() error = %s", err)
+		}
+		if cnt := len(collectionItemIRIs(res)); cnt != 10_000 {
+			b.Fatalf("Load() returned %d items, want %d", cnt, 10_000)
+		}
+	}
+}
+
+func Benchmark_repo_loadItemsElements10k(b *testing.B) {
+	r, _, items := initBenchmarkCollection(b, 10_000)
+	if err := r.Open(); err != nil {
+		b.Fatalf("Open() error = %s", err)
+	}
+	defer r.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		col, err := r.loadItemsElements(nil, 0, nil, items...)
+		if err != nil {
+			b.Fatalf("loadItemsElements() error = %s", err)
+		}
+		if len(col) != len(items) {
+			b.Fatalf("loadItemsElements() returned %d items, want %d", len(col), len(items))
+		}
+	}
+}
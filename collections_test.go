@@ -0,0 +1,203 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_ListCollections(t *testing.T) {
+	actor := vocab.IRI("http://example.com/jdoe")
+
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	act := vocab.PersonNew(actor)
+	act.Inbox = vocab.Inbox.IRI(actor)
+	act.Outbox = vocab.Outbox.IRI(act)
+	act.Liked = vocab.Liked.IRI(act)
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save actor %s: %s", actor, err)
+	}
+	if _, err = r.Save(vocab.PersonNew("http://example.com/jdoe2")); err != nil {
+		t.Fatalf("unable to save sibling actor: %s", err)
+	}
+
+	got, err := r.ListCollections(actor)
+	if err != nil {
+		t.Fatalf("ListCollections() error = %s", err)
+	}
+	want := vocab.IRIs{vocab.Inbox.IRI(actor), vocab.Outbox.IRI(actor), vocab.Liked.IRI(actor)}
+	if len(got) != len(want) {
+		t.Errorf("ListCollections() = %v, want %v", got, want)
+	}
+	for _, iri := range want {
+		if !got.Contains(iri) {
+			t.Errorf("ListCollections() result is missing %s", iri)
+		}
+	}
+}
+
+func Test_repo_RemoveEmptyCollections(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	old := vocab.ObjectNew(vocab.NoteType)
+	old.ID = "http://example.com/objects/old"
+	old.Published = time.Now().UTC().Add(-48 * time.Hour)
+	old.Replies = vocab.Replies.IRI(old)
+	old.Likes = vocab.Likes.IRI(old)
+
+	recent := vocab.ObjectNew(vocab.NoteType)
+	recent.ID = "http://example.com/objects/recent"
+	recent.Published = time.Now().UTC()
+	recent.Replies = vocab.Replies.IRI(recent)
+
+	for _, ob := range []*vocab.Object{old, recent} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save object %s: %s", ob.ID, err)
+		}
+	}
+	if err = r.AddTo(vocab.Likes.IRI(old), vocab.IRI("http://example.com/activities/1")); err != nil {
+		t.Fatalf("unable to add to likes: %s", err)
+	}
+
+	removed, err := r.RemoveEmptyCollections(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("RemoveEmptyCollections() error = %s", err)
+	}
+	want := vocab.IRIs{vocab.Replies.IRI(old)}
+	if len(removed) != len(want) || !removed.Contains(want[0]) {
+		t.Errorf("RemoveEmptyCollections() = %v, want %v", removed, want)
+	}
+	if _, err = r.Load(vocab.Likes.IRI(old)); err != nil {
+		t.Errorf("non empty collection should not have been removed: %s", err)
+	}
+	if _, err = r.Load(vocab.Replies.IRI(recent)); err != nil {
+		t.Errorf("recent collection should not have been removed: %s", err)
+	}
+	it, err := r.Load(old.ID)
+	if err != nil {
+		t.Fatalf("unable to load %s: %s", old.ID, err)
+	}
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		if o.Replies != nil {
+			t.Errorf("%s still references the removed collection %s", old.ID, o.Replies.GetLink())
+		}
+		if o.Likes == nil || o.Likes.GetLink() != vocab.Likes.IRI(old) {
+			t.Errorf("%s likes = %v, want the non empty collection kept", old.ID, o.Likes)
+		}
+		return nil
+	})
+}
+
+func loadIRIs(t *testing.T, r *repo, col vocab.IRI) vocab.IRIs {
+	res, err := r.Load(col)
+	if err != nil {
+		t.Fatalf("unable to load %s: %s", col, err)
+	}
+	iris := make(vocab.IRIs, 0)
+	_ = vocab.OnCollectionIntf(res, func(c vocab.CollectionInterface) error {
+		for _, it := range c.Collection() {
+			iris = append(iris, it.GetLink())
+		}
+		return nil
+	})
+	return iris
+}
+
+func Test_repo_AddTo_RemoveFrom_Likes(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	actor := vocab.PersonNew("http://example.com/jdoe")
+	actor.Liked = vocab.Liked.IRI(actor)
+	object := vocab.ObjectNew(vocab.NoteType)
+	object.ID = "http://example.com/objects/1"
+	object.Likes = vocab.Likes.IRI(object)
+	like := vocab.ActivityNew("http://example.com/activities/1", vocab.LikeType, object.GetLink())
+	like.Actor = actor.GetLink()
+	for _, it := range []vocab.Item{actor, object, like} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	if err = r.AddTo(object.Likes.GetLink(), like.GetLink()); err != nil {
+		t.Fatalf("AddTo() error = %s", err)
+	}
+	if liked := loadIRIs(t, r, actor.Liked.GetLink()); !liked.Contains(object.GetLink()) {
+		t.Errorf("liked collection %v should contain %s", liked, object.GetLink())
+	}
+
+	if err = r.RemoveFrom(actor.Liked.GetLink(), like.GetLink()); err != nil {
+		t.Fatalf("RemoveFrom() error = %s", err)
+	}
+	if liked := loadIRIs(t, r, actor.Liked.GetLink()); liked.Contains(object.GetLink()) {
+		t.Errorf("liked collection %v should not contain %s", liked, object.GetLink())
+	}
+	if likes := loadIRIs(t, r, object.Likes.GetLink()); likes.Contains(like.GetLink()) {
+		t.Errorf("likes collection %v should not contain %s", likes, like.GetLink())
+	}
+
+	if err = r.AddTo(object.Likes.GetLink(), like.GetLink()); err != nil {
+		t.Fatalf("AddTo() error = %s", err)
+	}
+	if err = r.RemoveFrom(actor.Liked.GetLink(), object.GetLink()); err != nil {
+		t.Fatalf("RemoveFrom() error = %s", err)
+	}
+	if likes := loadIRIs(t, r, object.Likes.GetLink()); likes.Contains(like.GetLink()) {
+		t.Errorf("likes collection %v should not contain %s", likes, like.GetLink())
+	}
+}
+
+func Test_repo_Save_CollectionPages(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	archive := vocab.OrderedCollectionNew("http://example.com/jdoe/outbox")
+	if _, err = r.Save(archive); err != nil {
+		t.Fatalf("unable to save collection %s: %s", archive.ID, err)
+	}
+	notes := make(vocab.ItemCollection, 0)
+	for _, id := range []vocab.IRI{"http://example.com/objects/1", "http://example.com/objects/2", "http://example.com/objects/3"} {
+		n := vocab.ObjectNew(vocab.NoteType)
+		n.ID = id
+		if _, err = r.Save(n); err != nil {
+			t.Fatalf("unable to save object %s: %s", n.ID, err)
+		}
+		notes = append(notes, n.GetLink())
+	}
+
+	first := vocab.OrderedCollectionPageNew(archive)
+	first.ID = "http://example.com/pages/1"
+	first.OrderedItems = notes[:2]
+	second := vocab.OrderedCollectionPageNew(archive)
+	second.ID = "http://example.com/pages/2"
+	second.OrderedItems = notes[2:]
+	for _, p := range []*vocab.OrderedCollectionPage{first, second} {
+		if _, err = r.Save(p); err != nil {
+			t.Fatalf("unable to save page %s: %s", p.ID, err)
+		}
+	}
+
+	if items := loadIRIs(t, r, archive.GetLink()); len(items) != len(notes) {
+		t.Errorf("Load() returned %v, want %v", items, notes)
+	}
+
+	if err = r.Delete(second); err != nil {
+		t.Fatalf("unable to delete page %s: %s", second.ID, err)
+	}
+	if items := loadIRIs(t, r, archive.GetLink()); len(items) != 2 || items.Contains(notes[2].GetLink()) {
+		t.Errorf("Load() after deleting page returned %v, want %v", items, notes[:2])
+	}
+}
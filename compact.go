@@ -0,0 +1,110 @@
+package badger
+
+import (
+	"io/fs"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// DefaultCompactDiscardRatio is the ratio of obsolete values above which Compact rewrites a value log file.
+const DefaultCompactDiscardRatio = 0.5
+
+// CompactOptions configures Compact.
+type CompactOptions struct {
+	// Workers is the number of goroutines compacting the levels of the LSM tree. When zero, 1 is used.
+	Workers int
+	// DiscardRatio is the ratio of the obsolete values of a value log file, above which it gets rewritten.
+	// When zero, DefaultCompactDiscardRatio is used.
+	DiscardRatio float64
+	// ProgressFn is called with the report after the LSM tree is compacted, and after each rewritten
+	// value log file.
+	ProgressFn func(CompactReport)
+}
+
+// CompactReport is the result of Compact.
+type CompactReport struct {
+	// SizeBefore and SizeAfter are the sizes, in bytes, of the files of the database directory.
+	SizeBefore int64
+	SizeAfter  int64
+	// Flattened is set when the LSM tree was compacted into a single level.
+	Flattened bool
+	// Rewritten is the number of value log files rewritten by the garbage collection.
+	Rewritten int
+}
+
+// Compact reclaims the space used by the deleted and the overwritten values: it compacts the levels of
+// the LSM tree into one, and then runs the garbage collection of the value log until no file can be rewritten.
+// It opens the database like the other operations, so it fails when another process has it open.
+//
+// It's meant to be run during maintenance, as it's slow on large databases, and the writes wait for it.
+// The repositories returned by DryRun only report the size of the database, without compacting it.
+func (r *repo) Compact(opt CompactOptions) (CompactReport, error) {
+	report := CompactReport{}
+	if opt.Workers <= 0 {
+		opt.Workers = 1
+	}
+	if opt.DiscardRatio <= 0 || opt.DiscardRatio >= 1 {
+		opt.DiscardRatio = DefaultCompactDiscardRatio
+	}
+	progress := func() {
+		if opt.ProgressFn != nil {
+			opt.ProgressFn(report)
+		}
+	}
+
+	report.SizeBefore = dirSize(r.path)
+	if r.dryRun != nil {
+		report.SizeAfter = report.SizeBefore
+		return report, nil
+	}
+	err := r.Open()
+	if err != nil {
+		return report, err
+	}
+	err = r.compact(opt, &report, progress)
+	r.Close()
+	report.SizeAfter = dirSize(r.path)
+	return report, err
+}
+
+func (r *repo) compact(opt CompactOptions, report *CompactReport, progress func()) error {
+	if err := r.d.Flatten(opt.Workers); err != nil {
+		return errors.Annotatef(err, "unable to compact the LSM tree")
+	}
+	report.Flattened = true
+	progress()
+	if r.path == "" {
+		return nil
+	}
+	for {
+		err := r.d.RunValueLogGC(opt.DiscardRatio)
+		if err == badger.ErrNoRewrite || err == badger.ErrRejected {
+			return nil
+		}
+		if err != nil {
+			return errors.Annotatef(err, "unable to collect the value log")
+		}
+		report.Rewritten++
+		progress()
+	}
+}
+
+// dirSize returns the size of the files under path.
+func dirSize(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
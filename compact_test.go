@@ -0,0 +1,40 @@
+package badger
+
+import (
+	"fmt"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Compact(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	for i := 0; i < 50; i++ {
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = vocab.IRI(fmt.Sprintf("https://example.com/objects/%d", i))
+		if _, err = r.Save(note); err != nil {
+			t.Fatalf("unable to save %s: %s", note.ID, err)
+		}
+		if err = r.Delete(note); err != nil {
+			t.Fatalf("unable to delete %s: %s", note.ID, err)
+		}
+	}
+
+	calls := 0
+	report, err := r.Compact(CompactOptions{ProgressFn: func(CompactReport) { calls++ }})
+	if err != nil {
+		t.Fatalf("Compact() error = %s", err)
+	}
+	if !report.Flattened || calls != report.Rewritten+1 {
+		t.Errorf("Compact() = %+v, with %d progress calls, want flattened, and a call per step", report, calls)
+	}
+	if report.SizeBefore == 0 || report.SizeAfter == 0 {
+		t.Errorf("Compact() sizes = %d, %d, want the sizes of the database directory", report.SizeBefore, report.SizeAfter)
+	}
+	if _, err = r.Load("https://example.com/objects/1"); err == nil {
+		t.Errorf("Load() of a deleted object after Compact() succeeded")
+	}
+}
@@ -0,0 +1,56 @@
+package badger
+
+import (
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// SaveIfUpdated saves the object, like Save, only when the stored one has the updated timestamp, which is the one
+// the caller loaded it with, so the concurrent changes of the object aren't overwritten. A zero updated time
+// matches the stored objects without one, and the missing objects.
+//
+// When the stored object was changed in the meantime, the returned error is a Conflict, wrapping ErrConflict,
+// and the caller can load it again, and retry.
+func (r *repo) SaveIfUpdated(it vocab.Item, updated time.Time) (vocab.Item, error) {
+	if vocab.IsNil(it) {
+		return nil, errors.NotValidf("Unable to save a nil element")
+	}
+	err := r.WithTx(func(s Store) error {
+		old, err := s.Load(it.GetLink())
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if vocab.IsNil(old) && !updated.IsZero() {
+			return errors.NewNotFound(ErrNotFound, "%s does not exist anymore", it.GetLink())
+		}
+		if stored := updatedTime(old); !sameTime(stored, updated) {
+			return errors.NewConflict(ErrConflict, "%s was updated at %s, after %s", it.GetLink(), stored, updated)
+		}
+		it, err = s.Save(it)
+		return err
+	})
+	return it, err
+}
+
+// updatedTime returns the updated timestamp of the object, or the zero time for the items without one.
+func updatedTime(it vocab.Item) time.Time {
+	var updated time.Time
+	if vocab.IsNil(it) || !it.IsObject() {
+		return updated
+	}
+	_ = vocab.OnObject(it, func(ob *vocab.Object) error {
+		updated = ob.Updated
+		return nil
+	})
+	return updated
+}
+
+// sameTime returns whether the timestamps are the same, to the second.
+//
+// NOTE(marius): the timestamps are stored with a precision of a second, so the ones the callers still have
+// from before saving the objects can have more.
+func sameTime(t1, t2 time.Time) bool {
+	return t1.Truncate(time.Second).Equal(t2.Truncate(time.Second))
+}
@@ -0,0 +1,50 @@
+package badger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_SaveIfUpdated(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	note.Updated = time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	if _, err = r.SaveIfUpdated(note, time.Time{}); err != nil {
+		t.Fatalf("SaveIfUpdated() of a new object error = %s", err)
+	}
+
+	seen := note.Updated
+	first := *note
+	first.Updated = seen.Add(time.Minute)
+	first.Content = vocab.DefaultNaturalLanguageValue("first")
+	if _, err = r.SaveIfUpdated(&first, seen); err != nil {
+		t.Fatalf("SaveIfUpdated() with the stored updated time error = %s", err)
+	}
+
+	second := *note
+	second.Updated = seen.Add(2 * time.Minute)
+	second.Content = vocab.DefaultNaturalLanguageValue("second")
+	if _, err = r.SaveIfUpdated(&second, seen); !errors.Is(err, ErrConflict) {
+		t.Errorf("SaveIfUpdated() with a stale updated time error = %v, want %s", err, ErrConflict)
+	}
+	it, err := r.Load(note.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if got := updatedTime(it); !sameTime(got, first.Updated) {
+		t.Errorf("Load() updated = %s, want the one of the first save %s", got, first.Updated)
+	}
+
+	missing := vocab.ObjectNew(vocab.NoteType)
+	missing.ID = "https://example.com/objects/2"
+	if _, err = r.SaveIfUpdated(missing, seen); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SaveIfUpdated() of a missing object with an updated time error = %v, want %s", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,520 @@
+package badger
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// BadgerOptions tunes the badger database. The zero values keep the badger defaults.
+type BadgerOptions struct {
+	// MemTableSize is the size, in bytes, of each of the tables kept in memory before being written to disk.
+	MemTableSize int64
+	// ValueLogFileSize is the maximum size, in bytes, of the value log files.
+	ValueLogFileSize int64
+	// ValueThreshold is the size, in bytes, above which the values are kept in the value log,
+	// instead of together with their keys.
+	ValueThreshold int64
+	// BlockCacheSize is the size, in bytes, of the cache of the decompressed and decrypted table blocks.
+	BlockCacheSize int64
+	// IndexCacheSize is the size, in bytes, of the cache of the table indexes. When zero, they are kept in memory.
+	IndexCacheSize int64
+	// NumVersionsToKeep is the number of versions of each key kept until the compactions remove them.
+	NumVersionsToKeep int
+	// NumCompactors is the number of goroutines compacting the tables.
+	NumCompactors int
+	// SyncWrites syncs the writes to disk before returning from them.
+	SyncWrites bool
+}
+
+// apply returns the badger options tuned with the non zero values of o.
+func (o BadgerOptions) apply(c badger.Options) badger.Options {
+	if o.MemTableSize > 0 {
+		c = c.WithMemTableSize(o.MemTableSize)
+	}
+	if o.ValueLogFileSize > 0 {
+		c = c.WithValueLogFileSize(o.ValueLogFileSize)
+	}
+	if o.ValueThreshold > 0 {
+		c = c.WithValueThreshold(o.ValueThreshold)
+	}
+	if o.BlockCacheSize > 0 {
+		c = c.WithBlockCacheSize(o.BlockCacheSize)
+	}
+	if o.IndexCacheSize > 0 {
+		c = c.WithIndexCacheSize(o.IndexCacheSize)
+	}
+	if o.NumVersionsToKeep > 0 {
+		c = c.WithNumVersionsToKeep(o.NumVersionsToKeep)
+	}
+	if o.NumCompactors > 0 {
+		c = c.WithNumCompactors(o.NumCompactors)
+	}
+	if o.SyncWrites {
+		c = c.WithSyncWrites(true)
+	}
+	return c
+}
+
+func checkEncryptionKey(key []byte) error {
+	switch len(key) {
+	case 0, 16, 24, 32:
+		return nil
+	}
+	return errors.NotValidf("the encryption key must be 16, 24 or 32 bytes long, not %d", len(key))
+}
+
+// ConfigSection is the name of the section of the config files read by LoadConfig. In the env files
+// it is the prefix of the variables, as STORAGE_.
+const ConfigSection = "storage"
+
+// configKeys are the settings which can be read by LoadConfig, and the functions setting them on the Config.
+var configKeys = map[string]func(*Config, string) error{
+	"path":                  func(c *Config, v string) error { c.Path = v; return nil },
+	"cache_enable":          func(c *Config, v string) error { return parseConfigBool(&c.CacheEnable, v) },
+	"cache_backend":         func(c *Config, v string) error { c.CacheBackend = CacheBackend(v); return nil },
+	"cache_max_entries":     func(c *Config, v string) error { return parseConfigInt(&c.CacheMaxEntries, v) },
+	"cache_max_bytes":       func(c *Config, v string) error { return parseConfigSize(&c.CacheMaxBytes, v) },
+	"cache_collection_ttl":  func(c *Config, v string) error { return parseConfigDuration(&c.CacheCollectionTTL, v) },
+	"cache_object_ttl":      func(c *Config, v string) error { return parseConfigDuration(&c.CacheObjectTTL, v) },
+	"decoded_cache_size":    func(c *Config, v string) error { return parseConfigInt(&c.DecodedCacheSize, v) },
+	"serialized_cache_size": func(c *Config, v string) error { return parseConfigInt(&c.SerializedCacheSize, v) },
+	"not_found_ttl":         func(c *Config, v string) error { return parseConfigDuration(&c.NotFoundTTL, v) },
+	"remote_ttl":            func(c *Config, v string) error { return parseConfigDuration(&c.RemoteTTL, v) },
+	"indexes":               parseConfigIndexes,
+	"skip_indexing":         func(c *Config, v string) error { return parseConfigBool(&c.SkipIndexing, v) },
+	"scan_workers":          func(c *Config, v string) error { return parseConfigInt(&c.ScanWorkers, v) },
+	"max_load_items":        func(c *Config, v string) error { return parseConfigInt(&c.MaxLoadItems, v) },
+	"mirror_queue_size":     func(c *Config, v string) error { return parseConfigInt(&c.MirrorQueueSize, v) },
+	"encryption_key":        parseConfigEncryptionKey,
+	"retry_attempts":        func(c *Config, v string) error { return parseConfigInt(&c.RetryAttempts, v) },
+	"retry_backoff":         func(c *Config, v string) error { return parseConfigDuration(&c.RetryBackoff, v) },
+	"write_rate":            func(c *Config, v string) error { return parseConfigFloat(&c.WriteRate, v) },
+	"write_burst":           func(c *Config, v string) error { return parseConfigInt(&c.WriteBurst, v) },
+	"max_pending_writes":    func(c *Config, v string) error { return parseConfigInt(&c.MaxPendingWrites, v) },
+	"max_item_size":         func(c *Config, v string) error { return parseConfigSize(&c.MaxItemSize, v) },
+	"validate":              parseConfigValidate,
+	"badger_mem_table_size": func(c *Config, v string) error { return parseConfigSize(&c.Badger.MemTableSize, v) },
+	"badger_value_log_file_size": func(c *Config, v string) error {
+		return parseConfigSize(&c.Badger.ValueLogFileSize, v)
+	},
+	"badger_value_threshold": func(c *Config, v string) error {
+		return parseConfigSize(&c.Badger.ValueThreshold, v)
+	},
+	"badger_block_cache_size": func(c *Config, v string) error {
+		return parseConfigSize(&c.Badger.BlockCacheSize, v)
+	},
+	"badger_index_cache_size": func(c *Config, v string) error {
+		return parseConfigSize(&c.Badger.IndexCacheSize, v)
+	},
+	"badger_num_versions_to_keep": func(c *Config, v string) error {
+		return parseConfigInt(&c.Badger.NumVersionsToKeep, v)
+	},
+	"badger_num_compactors": func(c *Config, v string) error { return parseConfigInt(&c.Badger.NumCompactors, v) },
+	"badger_sync_writes":    func(c *Config, v string) error { return parseConfigBool(&c.Badger.SyncWrites, v) },
+}
+
+// LoadConfig returns the Config described by the storage section of the config file at path, which is chosen
+// by its extension:
+//
+//   - .toml files contain a [storage] table,
+//   - .yaml and .yml files contain a storage mapping,
+//   - .env files contain STORAGE_ prefixed variables, like STORAGE_CACHE_ENABLE=true.
+//
+// The settings are named like the fields of the Config, in snake case, as cache_max_bytes, and the ones of the
+// BadgerOptions are prefixed with badger_, as badger_mem_table_size. Only scalar values are supported, besides
+// the list of index names, which can also be a comma separated string. The sizes can have a KB, MB or GB suffix,
+// the durations are written like 12h30m, and the encryption key is hex encoded.
+//
+// The other sections of the file are ignored, while the unknown settings of the storage section are errors, as
+// is any syntax outside this flat subset, like the sub-tables or nested mappings of the storage section, the
+// inline tables and the multi-line strings.
+func LoadConfig(path string) (Config, error) {
+	c := Config{}
+	f, err := os.Open(path)
+	if err != nil {
+		return c, errors.Annotatef(err, "unable to open config file %s", path)
+	}
+	defer f.Close()
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		values, err = readTOMLSection(bufio.NewScanner(f), ConfigSection)
+	case ".yaml", ".yml":
+		values, err = readYAMLSection(bufio.NewScanner(f), ConfigSection)
+	case ".env":
+		values, err = readEnvSection(bufio.NewScanner(f), ConfigSection)
+	default:
+		return c, errors.NotValidf("unknown config file format %q", ext)
+	}
+	if err != nil {
+		return c, errors.Annotatef(err, "unable to read config file %s", path)
+	}
+	for k, v := range values {
+		set, ok := configKeys[k]
+		if !ok {
+			return c, errors.NotValidf("unknown %s setting %q in %s", ConfigSection, k, path)
+		}
+		if err = set(&c, v); err != nil {
+			return c, errors.NewNotValid(err, "invalid %s setting %q in %s", ConfigSection, k, path)
+		}
+	}
+	return c, nil
+}
+
+// readTOMLSection returns the key = value pairs of the [section] table. The sub-tables of the section, its dotted
+// keys and the values which aren't strings, scalars or flat lists are errors.
+func readTOMLSection(s *bufio.Scanner, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			header := cutComment(line)
+			if !strings.HasSuffix(header, "]") {
+				return nil, errors.NotValidf("invalid table %q", line)
+			}
+			table = strings.TrimSpace(strings.Trim(header, "[]"))
+			if strings.HasPrefix(table, section+".") || (table == section && strings.HasPrefix(header, "[[")) {
+				return nil, errors.NotValidf("unsupported %s table %q", section, line)
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.NotValidf("invalid line %q", line)
+		}
+		k = strings.TrimSpace(k)
+		if table != section {
+			// NOTE(marius): the storage settings can also be set from the root table, as dotted keys or as an
+			// inline table, which we don't support, so we don't want to ignore them silently.
+			if table == "" && (k == section || strings.HasPrefix(k, section+".")) {
+				return nil, errors.NotValidf("unsupported %s setting %q", section, line)
+			}
+			continue
+		}
+		if !isBareKey(k) {
+			return nil, errors.NotValidf("unsupported key %q", k)
+		}
+		v, err := configValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+	return values, s.Err()
+}
+
+// readYAMLSection returns the key: value pairs of the section mapping, and the items of the block lists
+// under its keys joined with commas. The nested mappings of the section, and the values which aren't
+// strings, scalars or flat lists are errors.
+func readYAMLSection(s *bufio.Scanner, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	in := false
+	indent := 0
+	last := ""
+	list := false
+	for s.Scan() {
+		text := strings.TrimRight(s.Text(), " \t")
+		line := strings.TrimSpace(text)
+		if len(line) == 0 || line[0] == '#' || line == "---" {
+			continue
+		}
+		if text[0] != ' ' && text[0] != '\t' {
+			k, v, _ := strings.Cut(line, ":")
+			if in = strings.TrimSpace(k) == section; in && cutComment(v) != "" {
+				return nil, errors.NotValidf("unsupported %s value %q", section, line)
+			}
+			indent, last, list = 0, "", false
+			continue
+		}
+		if !in {
+			continue
+		}
+		depth := len(text) - len(strings.TrimLeft(text, " \t"))
+		if indent == 0 {
+			indent = depth
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok && depth >= indent {
+			if !list {
+				return nil, errors.NotValidf("unexpected list item %q", line)
+			}
+			if k, _, ok := strings.Cut(item, ": "); ok && isBareKey(strings.TrimSpace(k)) {
+				return nil, errors.NotValidf("unsupported mapping in list %q", line)
+			}
+			v, err := configValue(item)
+			if err != nil {
+				return nil, err
+			}
+			if values[last] != "" {
+				v = values[last] + "," + v
+			}
+			values[last] = v
+			continue
+		}
+		if depth != indent {
+			return nil, errors.NotValidf("unsupported nested mapping %q", line)
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.NotValidf("invalid line %q", line)
+		}
+		if last = strings.TrimSpace(k); !isBareKey(last) {
+			return nil, errors.NotValidf("unsupported key %q", last)
+		}
+		v, err := configValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[last] = v
+		list = v == ""
+	}
+	return values, s.Err()
+}
+
+// readEnvSection returns the values of the SECTION_ prefixed variables, keyed by the lower cased rest of their names.
+func readEnvSection(s *bufio.Scanner, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	prefix := strings.ToUpper(section) + "_"
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.NotValidf("invalid line %q", line)
+		}
+		k, ok = strings.CutPrefix(strings.TrimSpace(k), prefix)
+		if !ok {
+			continue
+		}
+		v, err := configValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[strings.ToLower(k)] = v
+	}
+	return values, s.Err()
+}
+
+// configValue returns the unquoted value, without its trailing comment, and the values of a [a, b] list
+// joined with commas. Anything else after the value, and the multi-line strings, the nested lists and
+// the mappings are errors.
+func configValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if len(v) == 0 || v[0] == '#' {
+		return "", nil
+	}
+	var (
+		val  string
+		rest string
+		err  error
+	)
+	switch v[0] {
+	case '"', '\'':
+		val, rest, err = cutQuoted(v)
+	case '[':
+		val, rest, err = cutList(v)
+	case '{', '|', '>', '&', '*', '!':
+		return "", errors.NotValidf("unsupported value %s", v)
+	default:
+		return cutComment(v), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if rest = strings.TrimSpace(rest); len(rest) > 0 && rest[0] != '#' {
+		return "", errors.NotValidf("unexpected %q after the value %s", rest, v)
+	}
+	return val, nil
+}
+
+// cutQuoted returns the unquoted string at the start of v, and the rest of v after its closing quote.
+// The double quoted strings can contain escapes, while the single quoted ones are literal.
+func cutQuoted(v string) (string, string, error) {
+	q := v[0]
+	for i := 1; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && q == '"':
+			i++
+		case v[i] == q && q == '\'':
+			return v[1:i], v[i+1:], nil
+		case v[i] == q:
+			s, err := strconv.Unquote(v[:i+1])
+			if err != nil {
+				return "", "", errors.NewNotValid(err, "invalid string %s", v[:i+1])
+			}
+			return s, v[i+1:], nil
+		}
+	}
+	return "", "", errors.NotValidf("unterminated string %s", v)
+}
+
+// cutList returns the items of the list at the start of v joined with commas, and the rest of v after its
+// closing bracket.
+func cutList(v string) (string, string, error) {
+	items := make([]string, 0)
+	rest := v[1:]
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		if len(rest) == 0 {
+			return "", "", errors.NotValidf("unterminated list %s", v)
+		}
+		var (
+			it  string
+			err error
+		)
+		switch rest[0] {
+		case ']':
+			return strings.Join(items, ","), rest[1:], nil
+		case ',':
+			rest = rest[1:]
+			continue
+		case '"', '\'':
+			if it, rest, err = cutQuoted(rest); err != nil {
+				return "", "", err
+			}
+		case '[', '{':
+			return "", "", errors.NotValidf("unsupported nested value in list %s", v)
+		default:
+			end := strings.IndexAny(rest, ",]")
+			if end < 0 {
+				return "", "", errors.NotValidf("unterminated list %s", v)
+			}
+			it, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		}
+		if rest = strings.TrimLeft(rest, " \t"); len(rest) > 0 && rest[0] != ',' && rest[0] != ']' {
+			return "", "", errors.NotValidf("unexpected %q in list %s", rest, v)
+		}
+		items = append(items, it)
+	}
+}
+
+// cutComment returns the bare value v without its trailing comment.
+func cutComment(v string) string {
+	if strings.HasPrefix(v, "#") {
+		return ""
+	}
+	for i := 1; i < len(v); i++ {
+		if v[i] == '#' && (v[i-1] == ' ' || v[i-1] == '\t') {
+			return strings.TrimSpace(v[:i])
+		}
+	}
+	return strings.TrimSpace(v)
+}
+
+// isBareKey returns if k is made only of the letters, digits, dashes and underscores allowed in the bare keys.
+func isBareKey(k string) bool {
+	if len(k) == 0 {
+		return false
+	}
+	for _, c := range k {
+		if !(c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseConfigBool(b *bool, v string) error {
+	var err error
+	*b, err = strconv.ParseBool(v)
+	return err
+}
+
+func parseConfigInt(i *int, v string) error {
+	var err error
+	*i, err = strconv.Atoi(v)
+	return err
+}
+
+func parseConfigFloat(f *float64, v string) error {
+	var err error
+	*f, err = strconv.ParseFloat(v, 64)
+	return err
+}
+
+func parseConfigDuration(d *time.Duration, v string) error {
+	var err error
+	*d, err = time.ParseDuration(v)
+	return err
+}
+
+// parseConfigSize parses a number of bytes, which can have a KB, MB or GB suffix, as multiples of 1024.
+func parseConfigSize(size *int64, v string) error {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	mul := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			v, mul = strings.TrimSpace(n), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	*size = n * mul
+	return nil
+}
+
+// parseConfigIndexes sets the indexes named in the comma separated list, out of the DefaultIndexes.
+// An empty list sets no indexes, instead of the DefaultIndexes of a Config without Indexes.
+func parseConfigIndexes(c *Config, v string) error {
+	c.Indexes = make([]Indexer, 0)
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); len(name) == 0 {
+			continue
+		}
+		found := false
+		for _, idx := range DefaultIndexes {
+			if idx.Name() == name {
+				c.Indexes = append(c.Indexes, idx)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.NotValidf("unknown index %q", name)
+		}
+	}
+	return nil
+}
+
+// parseConfigValidate sets ValidateItem as the Validator, when v is true.
+func parseConfigValidate(c *Config, v string) error {
+	validate := false
+	if err := parseConfigBool(&validate, v); err != nil {
+		return err
+	}
+	c.Validator = nil
+	if validate {
+		c.Validator = ValidateItem
+	}
+	return nil
+}
+
+func parseConfigEncryptionKey(c *Config, v string) error {
+	key, err := hex.DecodeString(v)
+	if err != nil {
+		return errors.NewNotValid(err, "the encryption key is not hex encoded")
+	}
+	if err = checkEncryptionKey(key); err != nil {
+		return err
+	}
+	c.EncryptionKey = key
+	return nil
+}
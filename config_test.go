@@ -0,0 +1,202 @@
+package badger
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ap/errors"
+)
+
+func TestLoadConfig(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	want := Config{
+		Path:          "/var/lib/fedbox",
+		CacheEnable:   true,
+		CacheMaxBytes: 64 << 20,
+		RemoteTTL:     12 * time.Hour,
+		Indexes:       []Indexer{TypeIndex{}, ActorIndex{}},
+		EncryptionKey: key,
+		Badger:        BadgerOptions{MemTableSize: 32 << 20, NumVersionsToKeep: 2, SyncWrites: true},
+	}
+	files := map[string]string{
+		"fedbox.toml": `[server]
+path = "/srv"
+
+[storage]
+path = "/var/lib/fedbox"
+cache_enable = true # enabled
+cache_max_bytes = "64MB"
+remote_ttl = "12h"
+indexes = ["type", "actor"]
+encryption_key = "30313233343536373839616263646566"
+badger_mem_table_size = "32MB"
+badger_num_versions_to_keep = 2
+badger_sync_writes = true
+`,
+		"fedbox.yaml": `server:
+  path: /srv
+storage:
+  path: /var/lib/fedbox
+  cache_enable: true
+  cache_max_bytes: 64MB
+  remote_ttl: 12h
+  indexes:
+    - type
+    - actor
+  encryption_key: '30313233343536373839616263646566'
+  badger_mem_table_size: 32MB
+  badger_num_versions_to_keep: 2
+  badger_sync_writes: true
+`,
+		"fedbox.env": `SERVER_PATH=/srv
+STORAGE_PATH=/var/lib/fedbox
+export STORAGE_CACHE_ENABLE=true
+STORAGE_CACHE_MAX_BYTES=64MB
+STORAGE_REMOTE_TTL=12h
+STORAGE_INDEXES=type,actor
+STORAGE_ENCRYPTION_KEY=30313233343536373839616263646566
+STORAGE_BADGER_MEM_TABLE_SIZE=32MB
+STORAGE_BADGER_NUM_VERSIONS_TO_KEEP=2
+STORAGE_BADGER_SYNC_WRITES=true
+`,
+	}
+	dir := t.TempDir()
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+				t.Fatalf("unable to write %s: %s", path, err)
+			}
+			got, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	invalid := map[string]string{
+		"unknown.toml": "[storage]\ncache_size = 10\n",
+		"index.env":    "STORAGE_INDEXES=type,color\n",
+		"key.yaml":     "storage:\n  encryption_key: 0011\n",
+		"config.json":  "{}",
+	}
+	for name, contents := range invalid {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatalf("unable to write %s: %s", path, err)
+		}
+		if _, err := LoadConfig(path); !errors.IsNotValid(err) {
+			t.Errorf("LoadConfig(%s) error = %v, want NotValid", name, err)
+		}
+	}
+}
+
+func Test_parseConfigIndexes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"none.toml": "[storage]\nindexes = []\n",
+		"none.env":  "STORAGE_INDEXES=\n",
+	}
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+				t.Fatalf("unable to write %s: %s", path, err)
+			}
+			c, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %s", err)
+			}
+			if c.Indexes == nil || len(c.Indexes) > 0 {
+				t.Fatalf("LoadConfig() indexes = %#v, want an empty list", c.Indexes)
+			}
+			c.Path = filepath.Join(dir, "db")
+			r, err := New(c)
+			if err != nil {
+				t.Fatalf("New() error = %s", err)
+			}
+			if len(r.indexes) > 0 {
+				t.Errorf("New() indexes = %v, want none", r.indexes)
+			}
+		})
+	}
+
+	r, err := New(Config{Path: filepath.Join(dir, "default")})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	if len(r.indexes) != len(DefaultIndexes) {
+		t.Errorf("New() without indexes = %v, want the DefaultIndexes", r.indexes)
+	}
+}
+
+func Test_configValue(t *testing.T) {
+	values := map[string]string{
+		`"a" # "b"`:            "a",
+		`'a#b' # c`:            "a#b",
+		`"a\"b"`:               `a"b`,
+		`64MB # size`:          "64MB",
+		`a#b`:                  "a#b",
+		`["type", "a,b"] # x`:  "type,a,b",
+		`[type, actor,]`:       "type,actor",
+		`# only a comment`:     "",
+		`"ends with a quote"`:  "ends with a quote",
+		`'single' `:            "single",
+		`["a" , 'b' ] # ["c"]`: "a,b",
+	}
+	for v, want := range values {
+		got, err := configValue(v)
+		if err != nil {
+			t.Errorf("configValue(%s) error = %s", v, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("configValue(%s) = %q, want %q", v, got, want)
+		}
+	}
+
+	invalid := []string{`"a" "b"`, `"a`, `"""a"""`, `[a, [b]]`, `[a, b`, `{path = "/srv"}`, `|`, `'a' b`}
+	for _, v := range invalid {
+		if _, err := configValue(v); !errors.IsNotValid(err) {
+			t.Errorf("configValue(%s) error = %v, want NotValid", v, err)
+		}
+	}
+}
+
+func Test_readSection(t *testing.T) {
+	invalid := map[string]string{
+		"sub-table.toml":     "[storage]\npath = \"/srv\"\n[storage.badger]\nsync_writes = true\n",
+		"array-table.toml":   "[[storage]]\npath = \"/srv\"\n",
+		"dotted-key.toml":    "[storage]\nbadger.sync_writes = true\n",
+		"root-dotted.toml":   "storage.path = \"/srv\"\n",
+		"inline-table.toml":  "storage = { path = \"/srv\" }\n",
+		"multi-line.toml":    "[storage]\nindexes = [\n  \"type\",\n]\n",
+		"nested.yaml":        "storage:\n  badger:\n    sync_writes: true\n",
+		"flow-mapping.yaml":  "storage: {path: /srv}\n",
+		"block-scalar.yaml":  "storage:\n  path: |\n    /srv\n",
+		"list-mapping.yaml":  "storage:\n  indexes:\n    - name: type\n",
+		"scalar-list.yaml":   "storage:\n  path: /srv\n    - /var\n",
+		"quoted-key.yaml":    "storage:\n  \"path\": /srv\n",
+		"trailing-data.yaml": "storage:\n  path: \"/srv\" /var\n",
+	}
+	for name, contents := range invalid {
+		s := bufio.NewScanner(strings.NewReader(contents))
+		var err error
+		if filepath.Ext(name) == ".toml" {
+			_, err = readTOMLSection(s, ConfigSection)
+		} else {
+			_, err = readYAMLSection(s, ConfigSection)
+		}
+		if !errors.IsNotValid(err) {
+			t.Errorf("%s: error = %v, want NotValid", name, err)
+		}
+	}
+}
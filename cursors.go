@@ -0,0 +1,221 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// The members of a collection get a sequence number in the order they have been added, which is stored in two keys:
+// __cursor/<collection path>\x00<sequence> with the IRI of the member as value, used for iterating from a position,
+// and __cursor_pos/<collection path>\x00<member IRI> with the sequence as value, used for finding the position of a cursor.
+const (
+	cursorKey    = "__cursor"
+	cursorPosKey = "__cursor_pos"
+)
+
+func getCursorPrefix(colPath []byte) []byte {
+	return append(bytes.Join([][]byte{[]byte(cursorKey), colPath}, sep), indexValueSep)
+}
+
+func getCursorKey(colPath []byte, seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(getCursorPrefix(colPath), seq)
+}
+
+func getCursorPosKey(colPath []byte, iri vocab.IRI) []byte {
+	k := append(bytes.Join([][]byte{[]byte(cursorPosKey), colPath}, sep), indexValueSep)
+	return append(k, iri...)
+}
+
+type keySetter interface {
+	Set(k, v []byte) error
+	Delete(k []byte) error
+}
+
+func setCursorKeys(b keySetter, colPath []byte, seq uint64, iri vocab.IRI) error {
+	if err := b.Set(getCursorKey(colPath, seq), []byte(iri)); err != nil {
+		return err
+	}
+	return b.Set(getCursorPosKey(colPath, iri), binary.BigEndian.AppendUint64(nil, seq))
+}
+
+// updateCursorKeys assigns sequence numbers to the IRIs added to the collection, and removes the ones of the IRIs
+// removed from it. The members of collections stored before the sequence numbers existed get them assigned here.
+func updateCursorKeys(tx dbTxn, colPath []byte, old, new vocab.IRIs) error {
+	if len(old) > 0 && lastCursorSeq(tx, colPath) == 0 {
+		old = nil
+	}
+	for _, iri := range old {
+		if new.Contains(iri) {
+			continue
+		}
+		if seq, ok := cursorSeq(tx, colPath, iri); ok {
+			if err := tx.Delete(getCursorKey(colPath, seq)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(getCursorPosKey(colPath, iri)); err != nil {
+			return err
+		}
+	}
+	next := lastCursorSeq(tx, colPath) + 1
+	for _, iri := range new {
+		if old.Contains(iri) {
+			continue
+		}
+		if err := setCursorKeys(tx, colPath, next, iri); err != nil {
+			return err
+		}
+		next++
+	}
+	return nil
+}
+
+// cursorSeq returns the sequence number of the iri in the collection.
+func cursorSeq(tx dbTxn, colPath []byte, iri vocab.IRI) (uint64, bool) {
+	i, err := tx.Get(getCursorPosKey(colPath, iri))
+	if err != nil {
+		return 0, false
+	}
+	var seq uint64
+	err = i.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return fmt.Errorf("invalid cursor sequence %x", val)
+		}
+		seq = binary.BigEndian.Uint64(val)
+		return nil
+	})
+	return seq, err == nil
+}
+
+// lastCursorSeq returns the largest sequence number used in the collection, or 0 when it doesn't have any.
+func lastCursorSeq(tx dbTxn, colPath []byte) uint64 {
+	prefix := getCursorPrefix(colPath)
+	opt := badger.DefaultIteratorOptions
+	opt.Reverse = true
+	opt.PrefetchValues = false
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	it.Seek(append(append([]byte{}, prefix...), 0xff))
+	if !it.ValidForPrefix(prefix) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(bytes.TrimPrefix(it.Item().Key(), prefix))
+}
+
+// idCheck is implemented by the checks matching the ID of the items, through which the cursors can expose
+// the item to seek to.
+// NOTE(marius): the checks of the filters package don't implement it yet, so their cursors are applied as
+// filters on the loaded members.
+type idCheck interface {
+	ID() vocab.IRI
+}
+
+// pageCursor returns the IRI of the After or Before cursor of the checks, and whether it is an After cursor.
+// Only cursors matching on the ID of the item, which is how the pagination links are built, can be used for seeking.
+func pageCursor(checks filters.Checks) (vocab.IRI, bool, bool) {
+	cursorIRI := func(cc filters.Checks) (vocab.IRI, bool) {
+		if len(cc) != 1 {
+			return "", false
+		}
+		// NOTE(marius): the cursors which don't expose their ID are applied as filters on the loaded members,
+		// instead of being used for seeking.
+		if id, ok := cc[0].(idCheck); ok {
+			return id.ID(), true
+		}
+		return "", false
+	}
+	after, before := filters.AfterChecks(checks...), filters.BeforeChecks(checks...)
+	if len(after) > 0 && len(before) > 0 {
+		return "", false, false
+	}
+	if iri, ok := cursorIRI(after); ok {
+		return iri, true, true
+	}
+	if iri, ok := cursorIRI(before); ok {
+		return iri, false, true
+	}
+	return "", false, false
+}
+
+// loadFromCursor loads the page of the collection at colPath delimited by the cursor of the checks, seeking
+// to the position of the cursor instead of loading all the members of the collection.
+// As the pagination orders the items from the newest to the oldest, the members are iterated in reverse order of
+// their sequence: an After page starts at the cursor, which is kept so the pagination can find it, and a Before page
+// starts at the newest member and ends at the cursor. One more item than the MaxCount is loaded, for the pagination
+// to know there's a next page.
+//
+// It returns false when the cursor can't be resolved this way, and the caller needs to load the whole collection.
+func (r *repo) loadFromCursor(tx dbTxn, col *vocab.ItemCollection, colPath []byte, f Filterable, checks filters.Checks) bool {
+	maxCount := filters.MaxCount(checks...)
+	if maxCount < 0 {
+		return false
+	}
+	cursor, isAfter, ok := pageCursor(checks)
+	if !ok {
+		return false
+	}
+	if _, err := tx.Get(getPagesKey(colPath)); err == nil {
+		// NOTE(marius): the members of the collection pages don't have sequence numbers.
+		return false
+	}
+	seq, ok := cursorSeq(tx, colPath, cursor)
+	if !ok {
+		return false
+	}
+
+	prefix := getCursorPrefix(colPath)
+	opt := badger.DefaultIteratorOptions
+	opt.Reverse = true
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	start := append(append([]byte{}, prefix...), 0xff)
+	if isAfter {
+		start = getCursorKey(colPath, seq)
+	}
+	auth := filters.AuthorizedChecks(checks...)
+	found := 0
+	for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+		i := it.Item()
+		if !isAfter && bytes.Equal(i.Key(), getCursorKey(colPath, seq)) {
+			break
+		}
+		var iri vocab.IRI
+		_ = i.Value(func(val []byte) error {
+			iri = vocab.IRI(val)
+			return nil
+		})
+		ob, err := r.loadItem(tx, itemPath(iri), f)
+		if err != nil || vocab.IsNil(ob) || col.Contains(ob.GetLink()) || !checksMatch(checks, ob) {
+			continue
+		}
+		*col = append(*col, scopeToRequester(auth, ob))
+		if isAfter && ob.GetLink().Equals(cursor, false) {
+			continue
+		}
+		if found++; found > maxCount {
+			break
+		}
+	}
+	return true
+}
+
+// collectionIRIs returns the IRIs stored as the value of a collection.
+func collectionIRIs(it vocab.Item) (vocab.IRIs, bool) {
+	if vocab.IsNil(it) || it.IsObject() {
+		return nil, false
+	}
+	var iris vocab.IRIs
+	err := vocab.OnIRIs(it, func(col *vocab.IRIs) error {
+		iris = *col
+		return nil
+	})
+	return iris, err == nil
+}
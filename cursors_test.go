@@ -0,0 +1,156 @@
+package badger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// testID is a check matching the ID of the items like filters.SameID, which also exposes it, so the cursors
+// using it can be sought to.
+type testID vocab.IRI
+
+func (t testID) ID() vocab.IRI            { return vocab.IRI(t) }
+func (t testID) Match(it vocab.Item) bool { return filters.SameID(vocab.IRI(t)).Match(it) }
+
+func Test_repo_Load_CursorSeek(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	colIRI := vocab.IRI("http://example.com/~jdoe/outbox")
+	if _, err = r.Create(vocab.OrderedCollectionNew(colIRI)); err != nil {
+		t.Fatalf("unable to create collection %s: %s", colIRI, err)
+	}
+	published := time.Now().UTC().Truncate(time.Second)
+	iris := make(vocab.IRIs, 0)
+	for i := 0; i < 10; i++ {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%02d", i))
+		ob.Published = published.Add(time.Duration(i) * time.Minute)
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+		if err = r.AddTo(colIRI, ob); err != nil {
+			t.Fatalf("unable to add %s to %s: %s", ob.ID, colIRI, err)
+		}
+		iris = append(iris, ob.ID)
+	}
+	if err = r.RemoveFrom(colIRI, iris[5]); err != nil {
+		t.Fatalf("unable to remove %s from %s: %s", iris[5], colIRI, err)
+	}
+
+	tests := []struct {
+		name       string
+		checks     filters.Checks
+		wantLoaded vocab.IRIs
+		wantPage   vocab.IRIs
+	}{
+		{
+			name:       "after",
+			checks:     filters.Checks{filters.After(testID(iris[7])), filters.WithMaxCount(2)},
+			wantLoaded: vocab.IRIs{iris[7], iris[6], iris[4], iris[3]},
+			wantPage:   vocab.IRIs{iris[6], iris[4]},
+		},
+		{
+			name:       "after, close to the end",
+			checks:     filters.Checks{filters.After(testID(iris[1])), filters.WithMaxCount(2)},
+			wantLoaded: vocab.IRIs{iris[1], iris[0]},
+			wantPage:   vocab.IRIs{iris[0]},
+		},
+		{
+			name:       "before",
+			checks:     filters.Checks{filters.Before(testID(iris[3])), filters.WithMaxCount(2)},
+			wantLoaded: vocab.IRIs{iris[9], iris[8], iris[7]},
+			wantPage:   vocab.IRIs{iris[9], iris[8]},
+		},
+		{
+			name:       "unknown cursor",
+			checks:     filters.Checks{filters.After(testID("http://example.com/objects/missing")), filters.WithMaxCount(2)},
+			wantLoaded: vocab.IRIs{iris[0], iris[1], iris[2], iris[3], iris[4], iris[6], iris[7], iris[8], iris[9]},
+			wantPage:   vocab.IRIs{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := r.Load(colIRI, tt.checks...)
+			if err != nil {
+				t.Fatalf("Load() error = %s", err)
+			}
+			loaded := collectionItemIRIs(res)
+			if !reflect.DeepEqual(loaded, tt.wantLoaded) {
+				t.Errorf("Load() = %v, want %v", loaded, tt.wantLoaded)
+			}
+			page := collectionItemIRIs(filters.PaginateCollection(res, tt.checks...))
+			if !reflect.DeepEqual(page, tt.wantPage) {
+				t.Errorf("PaginateCollection() = %v, want %v", page, tt.wantPage)
+			}
+		})
+	}
+}
+
+func Test_repo_ReindexAll_Cursors(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	colIRI := vocab.IRI("http://example.com/~jdoe/outbox")
+	iris := vocab.IRIs{"http://example.com/objects/1", "http://example.com/objects/2", "http://example.com/objects/3"}
+	for _, iri := range iris {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = iri
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", iri, err)
+		}
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open badger: %s", err)
+	}
+	// NOTE(marius): we store the collection directly, as it would have been before the sequence numbers existed.
+	raw, _ := encodeItemFn(iris)
+	if err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getObjectKey(itemPath(colIRI)), raw)
+	}); err != nil {
+		t.Fatalf("unable to store collection %s: %s", colIRI, err)
+	}
+	r.Close()
+
+	if _, err = r.ReindexAll(); err != nil {
+		t.Fatalf("ReindexAll() error = %s", err)
+	}
+
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open badger: %s", err)
+	}
+	defer r.Close()
+	_ = r.d.View(func(tx *badger.Txn) error {
+		for i, iri := range iris {
+			seq, ok := cursorSeq(tx, itemPath(colIRI), iri)
+			if !ok || seq != uint64(i+1) {
+				t.Errorf("cursorSeq(%s) = %d, %t, want %d", iri, seq, ok, i+1)
+			}
+		}
+		if last := lastCursorSeq(tx, itemPath(colIRI)); last != uint64(len(iris)) {
+			t.Errorf("lastCursorSeq() = %d, want %d", last, len(iris))
+		}
+		return nil
+	})
+}
+
+func collectionItemIRIs(it vocab.Item) vocab.IRIs {
+	iris := make(vocab.IRIs, 0)
+	_ = vocab.OnCollectionIntf(it, func(col vocab.CollectionInterface) error {
+		for _, it := range col.Collection() {
+			iris = append(iris, it.GetLink())
+		}
+		return nil
+	})
+	return iris
+}
@@ -0,0 +1,65 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_Load_DecodedCache(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.decoded = cache.NewDecoded(8)
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("John Doe"))
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+
+	decoded := 0
+	oldDecode := decodeItemFn
+	decodeItemFn = func(raw []byte) (vocab.Item, error) {
+		decoded++
+		return oldDecode(raw)
+	}
+	defer func() {
+		decodeItemFn = oldDecode
+	}()
+
+	for i := 0; i < 5; i++ {
+		it, err := r.Load(jdoe.ID)
+		if err != nil {
+			t.Fatalf("Load() error = %s", err)
+		}
+		_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+			if a.Name.First().String() != "John Doe" {
+				t.Errorf("Load() returned the name %q, want %q", a.Name.First(), "John Doe")
+			}
+			// NOTE(marius): the loaded item can be modified without affecting the following loads.
+			a.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("Jane Doe"))
+			return nil
+		})
+	}
+	if decoded != 2 {
+		t.Errorf("Load() decoded the actor %d times, want 2", decoded)
+	}
+
+	jdoe.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("Johnny Doe"))
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	it, err := r.Load(jdoe.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+		if a.Name.First().String() != "Johnny Doe" {
+			t.Errorf("Load() after Save() returned the name %q, want %q", a.Name.First(), "Johnny Doe")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,154 @@
+package badger
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// DerefMode controls if the properties of the loaded items, which are stored as IRIs, get replaced by the items they
+// point to.
+type DerefMode uint8
+
+const (
+	// DerefDefault keeps the default behaviour: the objects of Create activities, and the tags of objects, get always
+	// dereferenced, while the actors, objects and targets of the other activities only when the load filters on them.
+	DerefDefault DerefMode = iota
+	// DerefAlways dereferences the property every time.
+	DerefAlways
+	// DerefNever returns the property as it was stored.
+	DerefNever
+	// DerefWhenFiltered dereferences the property only when the load filters on it.
+	DerefWhenFiltered
+)
+
+// DerefProperty is a property of the loaded items which can be dereferenced.
+type DerefProperty string
+
+const (
+	DerefActor  DerefProperty = "actor"
+	DerefObject DerefProperty = "object"
+	DerefTarget DerefProperty = "target"
+	DerefTag    DerefProperty = "tag"
+)
+
+// DerefOptions configures the dereferencing of the properties of the loaded items.
+// It can be set in the Config, and it can be passed to Load together with the other checks, in which case it
+// overrides the Config for that load. As a check, it matches all the items.
+type DerefOptions struct {
+	// Mode applies to the properties which don't have a mode of their own.
+	Mode DerefMode
+	// Properties sets the modes of individual properties.
+	Properties map[DerefProperty]DerefMode
+}
+
+// Dereference returns the Load option which sets the mode for the props, or for all the properties when none are passed.
+func Dereference(mode DerefMode, props ...DerefProperty) filters.Check {
+	if len(props) == 0 {
+		return DerefOptions{Mode: mode}
+	}
+	o := DerefOptions{Properties: make(map[DerefProperty]DerefMode, len(props))}
+	for _, p := range props {
+		o.Properties[p] = mode
+	}
+	return o
+}
+
+func (DerefOptions) Match(_ vocab.Item) bool {
+	return true
+}
+
+func (o DerefOptions) mode(p DerefProperty) DerefMode {
+	if m, ok := o.Properties[p]; ok && m != DerefDefault {
+		return m
+	}
+	return o.Mode
+}
+
+// deref returns if the property needs to be dereferenced, depending on whether the load filters on it,
+// and on the default behaviour for it.
+func (o DerefOptions) deref(p DerefProperty, filtered, byDefault bool) bool {
+	switch o.mode(p) {
+	case DerefAlways:
+		return true
+	case DerefNever:
+		return false
+	case DerefWhenFiltered:
+		return filtered
+	}
+	return byDefault
+}
+
+// derefOptions returns the options of the Config, overridden by the ones passed to the load in the checks.
+func derefOptions(config DerefOptions, checks filters.Checks) DerefOptions {
+	var load *DerefOptions
+	for _, c := range checks {
+		if o, ok := c.(DerefOptions); ok {
+			load = &o
+			break
+		}
+	}
+	if load == nil {
+		return config
+	}
+	o := DerefOptions{Mode: config.Mode, Properties: make(map[DerefProperty]DerefMode)}
+	for _, p := range []DerefProperty{DerefActor, DerefObject, DerefTarget, DerefTag} {
+		if m := config.mode(p); m != DerefDefault {
+			o.Properties[p] = m
+		}
+		if load.Mode != DerefDefault {
+			o.Properties[p] = load.Mode
+		}
+		if m, ok := load.Properties[p]; ok && m != DerefDefault {
+			o.Properties[p] = m
+		}
+	}
+	return o
+}
+
+// derefs resolves the IRIs of the nested properties of the items loaded in a transaction, reading and decoding
+// each of them only once, as the items of a collection usually reference the same few actors and objects.
+type derefs struct {
+	r     *repo
+	tx    dbTxn
+	items map[vocab.IRI]vocab.Item
+}
+
+func newDerefs(r *repo, tx dbTxn) *derefs {
+	return &derefs{r: r, tx: tx, items: make(map[vocab.IRI]vocab.Item)}
+}
+
+// load returns the item stored at iri, like loadOneFromPath does, but from the transaction of the load.
+func (d *derefs) load(iri vocab.IRI) (vocab.Item, error) {
+	if it, ok := d.items[iri]; ok {
+		if vocab.IsNil(it) {
+			return nil, errors.NewNotFound(ErrNotFound, "%s not found", iri)
+		}
+		return it, nil
+	}
+	// NOTE(marius): the IRI is marked as not found while it gets resolved, so items referencing each other
+	// don't get dereferenced in a loop.
+	d.items[iri] = nil
+
+	if d.r.isNotFound(iri) {
+		return nil, errors.NewNotFound(ErrNotFound, "%s not found", iri)
+	}
+	k := getObjectKey(itemPath(iri))
+	i, err := d.tx.Get(k)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			d.r.setNotFound(iri)
+		}
+		return nil, errors.NewNotFound(wrapErr(ErrNotFound, err), "unable to load %s", iri)
+	}
+	col := make(vocab.ItemCollection, 0, 1)
+	if err = i.Value(d.r.loadFromIterator(d, k, &col, 1, iri)); err != nil {
+		return nil, err
+	}
+	if len(col) == 0 {
+		return nil, errors.NewNotFound(ErrNotFound, "%s not found", iri)
+	}
+	d.items[iri] = col.First()
+	return col.First(), nil
+}
@@ -0,0 +1,161 @@
+package badger
+
+import (
+	"fmt"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+func Test_repo_Load_Dereference(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	create := vocab.ActivityNew("http://example.com/activities/1", vocab.CreateType, note.ID)
+	create.Actor = jdoe.ID
+	like := vocab.ActivityNew("http://example.com/activities/2", vocab.LikeType, note.ID)
+	like.Actor = jdoe.ID
+	for _, it := range (vocab.ItemCollection{jdoe, note, create, like}) {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	type derefed struct {
+		createObject bool
+		createActor  bool
+		likeObject   bool
+	}
+	tests := []struct {
+		name   string
+		config DerefOptions
+		checks filters.Checks
+		want   derefed
+	}{
+		{
+			name: "default",
+			want: derefed{createObject: true},
+		},
+		{
+			name:   "never",
+			checks: filters.Checks{Dereference(DerefNever)},
+			want:   derefed{},
+		},
+		{
+			name:   "always",
+			checks: filters.Checks{Dereference(DerefAlways)},
+			want:   derefed{createObject: true, createActor: true, likeObject: true},
+		},
+		{
+			name:   "always for actors",
+			checks: filters.Checks{Dereference(DerefAlways, DerefActor)},
+			want:   derefed{createObject: true, createActor: true},
+		},
+		{
+			name:   "when filtered",
+			checks: filters.Checks{Dereference(DerefWhenFiltered)},
+			want:   derefed{},
+		},
+		{
+			name:   "load option overrides config",
+			config: DerefOptions{Mode: DerefNever},
+			checks: filters.Checks{Dereference(DerefAlways, DerefObject)},
+			want:   derefed{createObject: true, likeObject: true},
+		},
+		{
+			name:   "config",
+			config: DerefOptions{Mode: DerefNever, Properties: map[DerefProperty]DerefMode{DerefActor: DerefAlways}},
+			want:   derefed{createActor: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.deref = tt.config
+			res, err := r.Load("http://example.com/activities", tt.checks...)
+			if err != nil {
+				t.Fatalf("Load() error = %s", err)
+			}
+			got := derefed{}
+			_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+				for _, it := range col.Collection() {
+					_ = vocab.OnActivity(it, func(a *vocab.Activity) error {
+						switch a.Type {
+						case vocab.CreateType:
+							got.createObject = a.Object.IsObject()
+							got.createActor = a.Actor.IsObject()
+						case vocab.LikeType:
+							got.likeObject = a.Object.IsObject()
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			if got != tt.want {
+				t.Errorf("Load() dereferenced %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_repo_Load_DereferencesOnce(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	count := 20
+	for i := 0; i < count; i++ {
+		like := vocab.ActivityNew(vocab.IRI(fmt.Sprintf("http://example.com/activities/%d", i)), vocab.LikeType, jdoe.ID)
+		like.Actor = jdoe.ID
+		if _, err = r.Save(like); err != nil {
+			t.Fatalf("unable to save %s: %s", like.ID, err)
+		}
+	}
+
+	decoded := 0
+	oldDecode := decodeItemFn
+	decodeItemFn = func(raw []byte) (vocab.Item, error) {
+		it, err := oldDecode(raw)
+		if err == nil && !vocab.IsNil(it) && it.GetLink().Equals(jdoe.ID, false) {
+			decoded++
+		}
+		return it, err
+	}
+	defer func() {
+		decodeItemFn = oldDecode
+	}()
+
+	res, err := r.Load("http://example.com/activities", Dereference(DerefAlways))
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	derefed := 0
+	_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		for _, it := range col.Collection() {
+			_ = vocab.OnActivity(it, func(a *vocab.Activity) error {
+				if a.Actor.IsObject() && a.Object.IsObject() {
+					derefed++
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if derefed != count {
+		t.Errorf("Load() dereferenced the actor and object of %d activities, want %d", derefed, count)
+	}
+	if decoded != 1 {
+		t.Errorf("Load() decoded %s %d times, want once", jdoe.ID, decoded)
+	}
+}
@@ -0,0 +1,143 @@
+package badger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ap/errors"
+)
+
+// storageDirPerm is the mode of the storage directory, which holds the private keys of the actors,
+// so only its owner can access it.
+const storageDirPerm = 0o700
+
+// DefaultDoctorMinFreeSpace is the free space, in bytes, under which Doctor reports the disk as full.
+const DefaultDoctorMinFreeSpace = 100 << 20
+
+// The files badger creates in the storage directory.
+const (
+	badgerLockFile     = "LOCK"
+	badgerManifestFile = "MANIFEST"
+)
+
+// DoctorCheck is the name of a check done by Doctor.
+type DoctorCheck string
+
+const (
+	DoctorPath        DoctorCheck = "path"
+	DoctorPermissions DoctorCheck = "permissions"
+	DoctorFreeSpace   DoctorCheck = "free-space"
+	DoctorLock        DoctorCheck = "lock"
+	DoctorManifest    DoctorCheck = "manifest"
+)
+
+// DoctorIssue is a problem found by Doctor.
+type DoctorIssue struct {
+	Check   DoctorCheck
+	Problem string
+	// Fixable is set for the problems Doctor can fix safely, and Fixed when it did.
+	Fixable bool
+	Fixed   bool
+}
+
+// DoctorOptions configures Doctor.
+type DoctorOptions struct {
+	// Fix fixes the problems which can be fixed safely.
+	Fix bool
+	// MinFreeSpace is the free space, in bytes, under which the disk is reported as full.
+	// When it is 0, DefaultDoctorMinFreeSpace is used.
+	MinFreeSpace uint64
+}
+
+// DoctorReport is the result of Doctor.
+type DoctorReport struct {
+	Path   string
+	Issues []DoctorIssue
+}
+
+// Doctor checks the storage directory of the configuration for the problems which prevent opening it: a missing
+// directory, wrong permissions, a full disk, a stale lock file left by a process which crashed, and missing
+// metadata of the badger tables. It doesn't open the database, so it can be run for a storage which fails to open.
+//
+// With the Fix option, it creates the missing directory, restricts its permissions, and removes the stale lock file.
+func Doctor(conf Config, opt DoctorOptions) (DoctorReport, error) {
+	report := DoctorReport{Path: conf.Path, Issues: make([]DoctorIssue, 0)}
+	if conf.Path == "" {
+		return report, errors.NotValidf("an in-memory storage has no directory to check")
+	}
+	if opt.MinFreeSpace == 0 {
+		opt.MinFreeSpace = DefaultDoctorMinFreeSpace
+	}
+	issue := func(check DoctorCheck, fix func() error, format string, args ...interface{}) {
+		i := DoctorIssue{Check: check, Problem: fmt.Sprintf(format, args...), Fixable: fix != nil}
+		if fix != nil && opt.Fix {
+			if err := fix(); err != nil {
+				i.Problem += fmt.Sprintf(", and it couldn't be fixed: %s", err)
+			} else {
+				i.Fixed = true
+			}
+		}
+		report.Issues = append(report.Issues, i)
+	}
+
+	p, err := filepath.Abs(conf.Path)
+	if err != nil {
+		return report, errors.Annotatef(err, "invalid storage path %s", conf.Path)
+	}
+	report.Path = p
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		issue(DoctorPath, func() error { return os.MkdirAll(p, storageDirPerm) }, "the directory %s doesn't exist", p)
+		return report, nil
+	}
+	if err != nil {
+		issue(DoctorPath, nil, "the directory %s can't be accessed: %s", p, err)
+		return report, nil
+	}
+	if !fi.IsDir() {
+		issue(DoctorPath, nil, "%s is not a directory", p)
+		return report, nil
+	}
+
+	if perm := fi.Mode().Perm(); perm != storageDirPerm {
+		issue(DoctorPermissions, func() error { return os.Chmod(p, storageDirPerm) },
+			"the directory has mode %#o, instead of %#o", perm, storageDirPerm)
+	}
+	if free, ok := freeSpace(p); ok && free < opt.MinFreeSpace {
+		issue(DoctorFreeSpace, nil, "only %d bytes are free on the disk", free)
+	}
+
+	lock := filepath.Join(p, badgerLockFile)
+	if _, err = os.Stat(lock); err == nil {
+		if held, err := lockHeld(lock); err != nil {
+			issue(DoctorLock, nil, "the lock file can't be checked: %s", err)
+		} else if held {
+			// NOTE(marius): the storage is in use, so its files are not checked, as they are being changed.
+			issue(DoctorLock, nil, "the storage is in use by another process")
+			return report, nil
+		} else {
+			issue(DoctorLock, func() error { return os.Remove(lock) }, "the lock file is stale")
+		}
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		issue(DoctorPath, nil, "the directory can't be read: %s", err)
+		return report, nil
+	}
+	tables, manifest := 0, false
+	for _, e := range entries {
+		switch {
+		case e.Name() == badgerManifestFile:
+			manifest = true
+		case strings.HasSuffix(e.Name(), ".sst"), strings.HasSuffix(e.Name(), ".vlog"):
+			tables++
+		}
+	}
+	if tables > 0 && !manifest {
+		issue(DoctorManifest, nil, "the directory has %d data files, and no %s", tables, badgerManifestFile)
+	}
+	return report, nil
+}
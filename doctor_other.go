@@ -0,0 +1,25 @@
+//go:build !linux && !darwin && !freebsd
+
+package badger
+
+import "github.com/go-ap/errors"
+
+// freeSpace is not supported on this platform.
+func freeSpace(_ string) (uint64, bool) {
+	return 0, false
+}
+
+// lockHeld is not supported on this platform.
+func lockHeld(_ string) (bool, error) {
+	return false, errors.NotImplementedf("checking the lock file is not supported on this platform")
+}
+
+// isLockErr is not supported on this platform.
+func isLockErr(_ error) bool {
+	return false
+}
+
+// isReadOnlyErr is not supported on this platform.
+func isReadOnlyErr(_ error) bool {
+	return false
+}
@@ -0,0 +1,56 @@
+package badger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoctor(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "storage")
+	report, err := Doctor(Config{Path: p}, DoctorOptions{Fix: true})
+	if err != nil {
+		t.Fatalf("Doctor() error = %s", err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Check != DoctorPath || !report.Issues[0].Fixed {
+		t.Fatalf("Doctor() = %+v, want the missing directory created", report)
+	}
+	if fi, err := os.Stat(p); err != nil || fi.Mode().Perm() != storageDirPerm {
+		t.Fatalf("Doctor() created %s with %v, %v, want mode %#o", p, fi, err, storageDirPerm)
+	}
+
+	if err = os.Chmod(p, 0o755); err != nil {
+		t.Fatalf("unable to change the mode of %s: %s", p, err)
+	}
+	if err = os.WriteFile(filepath.Join(p, badgerLockFile), []byte("1\n"), 0o600); err != nil {
+		t.Fatalf("unable to write the lock file: %s", err)
+	}
+	if err = os.WriteFile(filepath.Join(p, "000001.sst"), nil, 0o600); err != nil {
+		t.Fatalf("unable to write the table file: %s", err)
+	}
+	if report, err = Doctor(Config{Path: p}, DoctorOptions{}); err != nil {
+		t.Fatalf("Doctor() error = %s", err)
+	}
+	checks := make(map[DoctorCheck]DoctorIssue)
+	for _, i := range report.Issues {
+		checks[i.Check] = i
+	}
+	for _, c := range []DoctorCheck{DoctorPermissions, DoctorLock, DoctorManifest} {
+		if i, ok := checks[c]; !ok || i.Fixed {
+			t.Errorf("Doctor() = %+v, want an unfixed %s issue", report, c)
+		}
+	}
+	if checks[DoctorManifest].Fixable {
+		t.Errorf("Doctor() reported the missing manifest as fixable")
+	}
+
+	if report, err = Doctor(Config{Path: p}, DoctorOptions{Fix: true}); err != nil {
+		t.Fatalf("Doctor() error = %s", err)
+	}
+	if fi, _ := os.Stat(p); fi.Mode().Perm() != storageDirPerm {
+		t.Errorf("Doctor() left the mode %#o, want %#o", fi.Mode().Perm(), storageDirPerm)
+	}
+	if _, err = os.Stat(filepath.Join(p, badgerLockFile)); !os.IsNotExist(err) {
+		t.Errorf("Doctor() didn't remove the stale lock file")
+	}
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin || freebsd
+
+package badger
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// freeSpace returns the number of bytes available to unprivileged users on the file system of path.
+func freeSpace(path string) (uint64, bool) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), true
+}
+
+// lockHeld returns whether a process holds the lock on the badger lock file.
+func lockHeld(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// isLockErr returns whether err is the failure to acquire the lock held by another process.
+func isLockErr(err error) bool {
+	return errors.Is(err, syscall.EWOULDBLOCK)
+}
+
+// isReadOnlyErr returns whether err is the failure to write to a read only file system.
+func isReadOnlyErr(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
@@ -0,0 +1,192 @@
+package badger
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// KeyWrite is a key which an operation run in dry run mode would have set, or deleted.
+type KeyWrite struct {
+	Key     string
+	Deleted bool
+	// Size is the size of the value set.
+	Size int
+}
+
+// DryRunLog records the keys written by the operations of a repository returned by DryRun.
+type DryRunLog struct {
+	mu     sync.Mutex
+	writes []KeyWrite
+}
+
+func (l *DryRunLog) record(writes ...KeyWrite) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writes = append(l.writes, writes...)
+}
+
+// Writes returns the keys written so far, in the order the operations would have written them.
+func (l *DryRunLog) Writes() []KeyWrite {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]KeyWrite{}, l.writes...)
+}
+
+// writeBatch is the interface of the batches of writes, implemented by badger.WriteBatch, and by recordingBatch.
+type writeBatch interface {
+	keySetter
+	Flush() error
+	Cancel()
+}
+
+// recordingBatch is the writeBatch used in dry run mode, which records the writes instead of applying them.
+// Like for badger.WriteBatch, the writes of a cancelled batch are dropped.
+type recordingBatch struct {
+	log    *DryRunLog
+	writes []KeyWrite
+}
+
+func (b *recordingBatch) Set(k, v []byte) error {
+	b.writes = append(b.writes, KeyWrite{Key: string(k), Size: len(v)})
+	return nil
+}
+
+func (b *recordingBatch) Delete(k []byte) error {
+	b.writes = append(b.writes, KeyWrite{Key: string(k), Deleted: true})
+	return nil
+}
+
+func (b *recordingBatch) Flush() error {
+	b.log.record(b.writes...)
+	b.writes = nil
+	return nil
+}
+
+func (b *recordingBatch) Cancel() {
+	b.writes = nil
+}
+
+// dbTxn is the transaction the operations read and write through, implemented by badger.Txn, and by recordingTxn.
+type dbTxn interface {
+	keySetter
+	SetEntry(e *badger.Entry) error
+	Get(key []byte) (*badger.Item, error)
+	NewIterator(opt badger.IteratorOptions) *badger.Iterator
+}
+
+// recordingTxn is the dbTxn used in dry run mode, which records the writes made in the transaction it wraps.
+// The writes are applied to the transaction too, so the operation can read them back, but the transaction is
+// discarded afterwards. Like for badger.Txn, only the last write of a key is kept.
+type recordingTxn struct {
+	*badger.Txn
+	writes map[string]KeyWrite
+}
+
+func (t *recordingTxn) Set(k, v []byte) error {
+	t.writes[string(k)] = KeyWrite{Key: string(k), Size: len(v)}
+	return t.Txn.Set(k, v)
+}
+
+func (t *recordingTxn) SetEntry(e *badger.Entry) error {
+	t.writes[string(e.Key)] = KeyWrite{Key: string(e.Key), Size: len(e.Value)}
+	return t.Txn.SetEntry(e)
+}
+
+func (t *recordingTxn) Delete(k []byte) error {
+	t.writes[string(k)] = KeyWrite{Key: string(k), Deleted: true}
+	return t.Txn.Delete(k)
+}
+
+// Writes returns the keys written in the transaction, sorted.
+func (t *recordingTxn) Writes() []KeyWrite {
+	writes := make([]KeyWrite, 0, len(t.writes))
+	for _, w := range t.writes {
+		writes = append(writes, w)
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		return writes[i].Key < writes[j].Key
+	})
+	return writes
+}
+
+// DryRun returns a copy of the repository whose operations record the keys they would write in the returned log,
+// instead of writing them, so the destructive operations can be previewed. The copy shares the caches of r,
+// and doesn't send its changes to the Mirror, to the running Watch and Subscribe calls, or to the hooks.
+//
+// The operations which read the keys they wrote earlier, in a separate transaction, don't see them in dry run
+// mode, so they can record fewer writes than they would make.
+func (r *repo) DryRun() (*repo, *DryRunLog) {
+	log := DryRunLog{writes: make([]KeyWrite, 0)}
+	c := r.copy()
+	c.mirror = nil
+	c.watchers = nil
+	c.hooks = nil
+	c.events = nil
+	c.dryRun = &log
+	return c, &log
+}
+
+// newWriteBatch returns a batch writing to the database, or recording the writes in dry run mode.
+func (r *repo) newWriteBatch() writeBatch {
+	if r.dryRun != nil {
+		return &recordingBatch{log: r.dryRun}
+	}
+	return r.d.NewWriteBatch()
+}
+
+// update runs fn in a read-write transaction, which in dry run mode is discarded, after recording its writes.
+// The transactions failing with transient errors are retried, so fn can be called multiple times.
+func (r *repo) update(fn func(tx dbTxn) error) error {
+	if r.d == nil {
+		return ErrNotOpen
+	}
+	if r.dryRun == nil {
+		release, err := r.throttle.acquire()
+		if err != nil {
+			return err
+		}
+		defer release()
+		return storageErr(r.retry(func() error {
+			return r.d.Update(func(tx *badger.Txn) error {
+				return fn(tx)
+			})
+		}))
+	}
+	tx := recordingTxn{Txn: r.d.NewTransaction(true), writes: make(map[string]KeyWrite)}
+	defer tx.Discard()
+	if err := fn(&tx); err != nil {
+		return storageErr(err)
+	}
+	r.dryRun.record(tx.Writes()...)
+	return nil
+}
+
+// dropPrefix removes the keys having any of the prefixes, which in dry run mode are recorded as deleted instead.
+func (r *repo) dropPrefix(prefixes ...[]byte) error {
+	if r.d == nil {
+		return ErrNotOpen
+	}
+	if r.dryRun == nil {
+		return storageErr(r.d.DropPrefix(prefixes...))
+	}
+	writes := make([]KeyWrite, 0)
+	err := r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for _, prefix := range prefixes {
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				writes = append(writes, KeyWrite{Key: string(it.Item().Key()), Deleted: true})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return storageErr(err)
+	}
+	r.dryRun.record(writes...)
+	return nil
+}
@@ -0,0 +1,87 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_DryRun(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+
+	dry, log := r.DryRun()
+	if err = dry.Delete(note); err != nil {
+		t.Fatalf("Delete() in dry run mode error = %s", err)
+	}
+	other := vocab.ObjectNew(vocab.NoteType)
+	other.ID = "https://example.com/objects/2"
+	if _, err = dry.Save(other); err != nil {
+		t.Fatalf("Save() in dry run mode error = %s", err)
+	}
+	if err = dry.AddTo("https://example.com/objects", other.ID); err != nil {
+		t.Fatalf("AddTo() in dry run mode error = %s", err)
+	}
+
+	writes := make(map[string]KeyWrite)
+	for _, w := range log.Writes() {
+		writes[w.Key] = w
+	}
+	if w, ok := writes["example.com/objects/1/__raw"]; !ok || !w.Deleted {
+		t.Errorf("DryRun() recorded %v, want the deletion of the note", log.Writes())
+	}
+	if w, ok := writes["example.com/objects/2/__raw"]; !ok || w.Deleted || w.Size == 0 {
+		t.Errorf("DryRun() recorded %v, want the saved note", log.Writes())
+	}
+	if _, ok := writes["example.com/objects/__raw"]; !ok {
+		t.Errorf("DryRun() recorded %v, want the collection written by AddTo", log.Writes())
+	}
+
+	if _, err = r.Load(note.ID); err != nil {
+		t.Errorf("Load() after a dry run Delete() error = %s", err)
+	}
+	if _, err = r.Load(other.ID); err == nil {
+		t.Errorf("Load() found the object saved in dry run mode")
+	}
+}
+
+func Test_repo_DryRun_Reindex(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+	before, _ := r.Keys(string(getIndexPrefix("type")), 0)
+	if len(before) == 0 {
+		t.Fatalf("no type index keys were stored for %s", note.ID)
+	}
+
+	dry, log := r.DryRun()
+	if _, err = dry.Reindex("type"); err != nil {
+		t.Fatalf("Reindex() in dry run mode error = %s", err)
+	}
+	deleted := 0
+	for _, w := range log.Writes() {
+		if w.Deleted {
+			deleted++
+		}
+	}
+	if deleted < len(before) {
+		t.Errorf("DryRun() recorded %v, want the deletion of the index keys %v", log.Writes(), before)
+	}
+	if after, _ := r.Keys(string(getIndexPrefix("type")), 0); len(after) != len(before) {
+		t.Errorf("Reindex() in dry run mode removed the index keys: %v, want %v", after, before)
+	}
+}
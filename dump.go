@@ -0,0 +1,123 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// DumpRecordType is the kind of data stored at the key of a DumpRecord.
+type DumpRecordType string
+
+const (
+	// DumpObject is an object, stored at the __raw key of the path of its IRI.
+	DumpObject DumpRecordType = "object"
+	// DumpCollection is the list of the IRIs of the members of a collection, stored at the __raw key of its path.
+	DumpCollection DumpRecordType = "collection"
+	// DumpMetadata is the metadata of an actor, like its password hash and its private key.
+	DumpMetadata DumpRecordType = "metadata"
+	// DumpOAuth is an OAuth client, authorization, access or refresh token.
+	DumpOAuth DumpRecordType = "oauth"
+)
+
+// DumpRecord is a line of the stream written by Dump.
+type DumpRecord struct {
+	Key   string          `json:"key"`
+	Type  DumpRecordType  `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Dump writes to w the objects, the collections, the metadata and the OAuth data of the repository, as a
+// JSON Lines stream, with one DumpRecord per line, which can be processed with jq and other tools, and read
+// back by LoadDump. Like for ExportToFS, the keys which badger derives from them are not written.
+func (r *repo) Dump(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return r.dumpRecords(func(rec DumpRecord) error {
+		return enc.Encode(rec)
+	})
+}
+
+// dumpRecords calls fn for the records of all the keys written by Dump. The values are valid only during the call.
+func (r *repo) dumpRecords(fn func(DumpRecord) error) error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.d.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			i := it.Item()
+			k := i.Key()
+			if !isExportedKey(k) {
+				continue
+			}
+			rec := DumpRecord{Key: string(k)}
+			err := i.Value(func(raw []byte) error {
+				rec.Type = dumpRecordType(k, raw)
+				rec.Value = bytes.TrimSpace(raw)
+				return fn(rec)
+			})
+			if err != nil {
+				return errors.Annotatef(err, "unable to dump %s", k)
+			}
+		}
+		return nil
+	})
+}
+
+func dumpRecordType(k, raw []byte) DumpRecordType {
+	switch {
+	case isObjectKey(k) && bytes.HasPrefix(bytes.TrimSpace(raw), []byte{'['}):
+		return DumpCollection
+	case isObjectKey(k):
+		return DumpObject
+	case bytes.HasSuffix(k, []byte(metaDataKey)):
+		return DumpMetadata
+	}
+	return DumpOAuth
+}
+
+// LoadDump copies the records of a stream written by Dump, replacing the values stored at the same keys.
+// Like for the other imports, the type keys, the filter indexes, the collection cursors and the membership
+// keys are rebuilt for the copied objects.
+//
+// A checkpoint is stored after every thousand records, so an interrupted import continues from where it stopped
+// when run again with the same stream.
+func (r *repo) LoadDump(rd io.Reader, opt MigrationOptions) (MigrationReport, error) {
+	imp, err := r.newImporter("dump", "", opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	skip := imp.resumeRecords()
+	dec := json.NewDecoder(rd)
+	for read := 1; ; read++ {
+		rec := DumpRecord{}
+		if err = dec.Decode(&rec); err != nil {
+			break
+		}
+		if rec.Key == "" || !isExportedKey([]byte(rec.Key)) {
+			err = errors.NotValidf("invalid dump record key %q", rec.Key)
+			break
+		}
+		if read <= skip {
+			continue
+		}
+		if err = imp.set([]byte(rec.Key), rec.Value); err != nil {
+			break
+		}
+		if err = imp.recordCheckpoint(read); err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return imp.finish(err)
+}
@@ -0,0 +1,87 @@
+package badger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+func Test_repo_Dump(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+	if err = r.SaveMetadata(processing.Metadata{Pw: []byte("hash")}, jdoe.ID); err != nil {
+		t.Fatalf("unable to save the metadata of %s: %s", jdoe.ID, err)
+	}
+	if err = r.CreateClient(&osin.DefaultClient{Id: "app", Secret: "s3cr3t"}); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+
+	buf := bytes.Buffer{}
+	if err = r.Dump(&buf); err != nil {
+		t.Fatalf("Dump() error = %s", err)
+	}
+	types := make(map[DumpRecordType]int)
+	s := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for s.Scan() {
+		rec := DumpRecord{}
+		if err = json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("Dump() wrote an invalid line %s: %s", s.Bytes(), err)
+		}
+		if strings.Contains(rec.Key, "/__") && !isObjectKey([]byte(rec.Key)) && rec.Type != DumpMetadata {
+			t.Errorf("Dump() wrote the internal key %s", rec.Key)
+		}
+		types[rec.Type]++
+	}
+	for _, typ := range []DumpRecordType{DumpObject, DumpCollection, DumpMetadata, DumpOAuth} {
+		if types[typ] == 0 {
+			t.Errorf("Dump() wrote no %s records", typ)
+		}
+	}
+
+	loaded, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = loaded.LoadDump(&buf, MigrationOptions{}); err != nil {
+		t.Fatalf("LoadDump() error = %s", err)
+	}
+	col, err := loaded.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the loaded outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(note.ID) {
+		t.Errorf("Load() of the loaded outbox = %v, doesn't contain %s", col, note.ID)
+	}
+	if m, err := loaded.LoadMetadata(jdoe.ID); err != nil || string(m.Pw) != "hash" {
+		t.Errorf("LoadMetadata() of the loaded actor = %v, %v", m, err)
+	}
+	if _, err = loaded.GetClient("app"); err != nil {
+		t.Errorf("GetClient() of the loaded client error = %s", err)
+	}
+
+	_, err = loaded.LoadDump(strings.NewReader(`{"key":"__index/type/Note","type":"object","value":{}}`), MigrationOptions{})
+	if !errors.IsNotValid(err) {
+		t.Errorf("LoadDump() of an internal key error = %v, want NotValid", err)
+	}
+}
@@ -0,0 +1,60 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The errors returned by the repository wrap these, so the callers can check them with errors.Is, besides
+// using the checks of the go-ap/errors package, like errors.IsNotFound.
+var (
+	// ErrNotOpen is wrapped by the errors of the operations on a database which is not open.
+	ErrNotOpen = errors.New("storage is not open")
+	// ErrNotFound is wrapped by the errors for the missing objects, collections, keys and OAuth data.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is wrapped by the errors of the writes which conflict with concurrent ones, or with the
+	// objects which are already stored.
+	ErrConflict = errors.New("conflict")
+	// ErrReadOnly is wrapped by the errors of the writes to a storage opened read only.
+	ErrReadOnly = errors.New("storage is read only")
+	// ErrLocked is wrapped by the error of Open when another process is using the storage.
+	ErrLocked = errors.New("storage is locked by another process")
+	// ErrOverloaded is wrapped by the errors of the writes rejected because the MaxPendingWrites of the Config
+	// are already waiting.
+	ErrOverloaded = errors.New("storage is overloaded with writes")
+)
+
+// wrapErr returns err wrapping sentinel, unless it does already. A nil err returns the sentinel.
+func wrapErr(sentinel, err error) error {
+	if err == nil {
+		return sentinel
+	}
+	if errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// storageErr returns the badger err wrapping the matching error of the package, or err when none matches.
+//
+// NOTE(marius): the badger errors are compared directly, as they're returned, so the errors of the package,
+// which can have them as causes, keep their types.
+func storageErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == badger.ErrKeyNotFound:
+		return wrapErr(ErrNotFound, err)
+	case err == badger.ErrConflict:
+		return wrapErr(ErrConflict, err)
+	case err == badger.ErrReadOnlyTxn, isReadOnlyErr(err):
+		return wrapErr(ErrReadOnly, err)
+	case err == badger.ErrDBClosed:
+		return wrapErr(ErrNotOpen, err)
+	case isLockErr(err):
+		return wrapErr(ErrLocked, err)
+	}
+	return err
+}
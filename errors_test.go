@@ -0,0 +1,50 @@
+package badger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func Test_storageErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{err: badger.ErrKeyNotFound, want: ErrNotFound},
+		{err: badger.ErrConflict, want: ErrConflict},
+		{err: badger.ErrReadOnlyTxn, want: ErrReadOnly},
+		{err: badger.ErrDBClosed, want: ErrNotOpen},
+	}
+	for _, tt := range tests {
+		got := storageErr(tt.err)
+		if !errors.Is(got, tt.want) || !errors.Is(got, tt.err) {
+			t.Errorf("storageErr(%v) = %v, want it to wrap %v", tt.err, got, tt.want)
+		}
+	}
+	other := errors.New("other")
+	if got := storageErr(other); got != other {
+		t.Errorf("storageErr(%v) = %v, want it unchanged", other, got)
+	}
+}
+
+func Test_repo_Errors(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	_, err = r.Load("http://example.com/objects/missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() of a missing object error = %v, want ErrNotFound", err)
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(r.path).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("unable to open %s: %s", r.path, err)
+	}
+	defer db.Close()
+	if err = r.Open(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Open() of a storage used by another process error = %v, want ErrLocked", err)
+	}
+}
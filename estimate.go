@@ -0,0 +1,81 @@
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+// estimateSampleSize is the number of keys iterated for estimating the proportion of object keys among the keys
+// stored under a path. Paths having fewer keys than this get counted exactly.
+const estimateSampleSize = 4096
+
+// EstimateCount returns an approximation of the number of objects stored directly under the iri,
+// like the ones of the /actors, /activities and /objects storage collections.
+//
+// It is meant for statistics, where exact counts over millions of keys are too expensive: the number of keys
+// is taken from the metadata of badger's tables, and the proportion of them belonging to objects is estimated
+// from a sample. As the tables keep the older versions of the keys until they get compacted, the result
+// can be larger than the real count.
+func (r *repo) EstimateCount(iri vocab.IRI) (uint64, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	base := itemPath(iri)
+	prefix := append(append([]byte{}, base...), sep...)
+
+	sampled, objects := uint64(0), uint64(0)
+	complete := true
+	err = r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = prefix
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if sampled == estimateSampleSize {
+				complete = false
+				break
+			}
+			sampled++
+			if k := it.Item().Key(); isObjectKey(k) && !iterKeyIsTooDeep(base, k, 1) {
+				objects++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if complete {
+		return objects, nil
+	}
+
+	keys := tablesKeyCount(r.d.Tables(), prefix)
+	if keys < sampled {
+		// NOTE(marius): the keys which are still in the memtables are not part of the table metadata.
+		return objects, nil
+	}
+	return keys * objects / sampled, nil
+}
+
+// tablesKeyCount returns the number of keys with the prefix, as found in the metadata of the tables.
+// The tables which contain only keys with the prefix are counted fully, and the ones which contain
+// other keys as well are counted as having half of their keys with the prefix.
+func tablesKeyCount(tables []badger.TableInfo, prefix []byte) uint64 {
+	count := uint64(0)
+	for _, t := range tables {
+		left, right := bytes.HasPrefix(t.Left, prefix), bytes.HasPrefix(t.Right, prefix)
+		switch {
+		case left && right:
+			count += uint64(t.KeyCount)
+		case left || right || (bytes.Compare(t.Left, prefix) < 0 && bytes.Compare(t.Right, prefix) > 0):
+			count += uint64(t.KeyCount) / 2
+		}
+	}
+	return count
+}
@@ -0,0 +1,62 @@
+package badger
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_EstimateCount(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%d", i))
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+	act := vocab.PersonNew("http://example.com/actors/jdoe")
+	act.Outbox = vocab.IRI("http://example.com/actors/jdoe/outbox")
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save %s: %s", act.ID, err)
+	}
+
+	tests := []struct {
+		iri  vocab.IRI
+		want uint64
+	}{
+		{iri: "http://example.com/objects", want: 10},
+		{iri: "http://example.com/actors", want: 1},
+		{iri: "http://example.com/activities", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.iri.String(), func(t *testing.T) {
+			got, err := r.EstimateCount(tt.iri)
+			if err != nil {
+				t.Fatalf("EstimateCount() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("EstimateCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tablesKeyCount(t *testing.T) {
+	prefix := []byte("example.com/objects/")
+	tables := []badger.TableInfo{
+		{Left: []byte("example.com/objects/a"), Right: []byte("example.com/objects/z"), KeyCount: 100},
+		{Left: []byte("example.com/activities/a"), Right: []byte("example.com/objects/b"), KeyCount: 10},
+		{Left: []byte("example.com/actors/a"), Right: []byte("example.com/zzz"), KeyCount: 20},
+		{Left: []byte("example.com/actors/a"), Right: []byte("example.com/actors/z"), KeyCount: 1000},
+	}
+	if got := tablesKeyCount(tables, prefix); got != 115 {
+		t.Errorf("tablesKeyCount() = %d, want %d", got, 115)
+	}
+}
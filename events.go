@@ -0,0 +1,158 @@
+package badger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// eventQueueSize is the number of events waiting for a Subscribe function, the ones emitted while it is full
+// are dropped.
+const eventQueueSize = 256
+
+// Event is one of the ItemSaved, ItemDeleted, CollectionUpdated and MetadataChanged events, received by the
+// functions passed to Subscribe.
+type Event interface {
+	// When returns the time of the change.
+	When() time.Time
+}
+
+// ItemSaved is emitted after an item is stored, by Save, or by an Update transaction.
+type ItemSaved struct {
+	Item vocab.Item
+	Time time.Time
+}
+
+// ItemDeleted is emitted after an item is removed.
+type ItemDeleted struct {
+	Item vocab.Item
+	Time time.Time
+}
+
+// CollectionUpdated is emitted after an item is added to, or removed from, a collection.
+type CollectionUpdated struct {
+	Collection vocab.IRI
+	Item       vocab.Item
+	// Removed is true when the item was removed from the collection.
+	Removed bool
+	Time    time.Time
+}
+
+// MetadataChanged is emitted after the metadata of an actor, like its password or its private key, is changed.
+// The metadata isn't part of the event, as it contains secrets.
+type MetadataChanged struct {
+	IRI  vocab.IRI
+	Time time.Time
+}
+
+func (e ItemSaved) When() time.Time         { return e.Time }
+func (e ItemDeleted) When() time.Time       { return e.Time }
+func (e CollectionUpdated) When() time.Time { return e.Time }
+func (e MetadataChanged) When() time.Time   { return e.Time }
+
+// EventFn is called by Subscribe for every event. Returning an error stops the subscription.
+type EventFn func(Event) error
+
+// bus fans out the events of the repository to the running Subscribe calls.
+type bus struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func (b *bus) add() chan Event {
+	events := make(chan Event, eventQueueSize)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[chan Event]struct{})
+	}
+	b.subs[events] = struct{}{}
+	return events
+}
+
+func (b *bus) remove(events chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	subs := make(map[chan Event]struct{}, len(b.subs))
+	for o := range b.subs {
+		if o != events {
+			subs[o] = struct{}{}
+		}
+	}
+	b.subs = subs
+}
+
+// emit sends the event to the subscribers, dropping it for the ones whose queues are full.
+func (b *bus) emit(e Event, errFn loggerFn) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subs {
+		select {
+		case events <- e:
+		default:
+			errFn("unable to emit the %T event: the subscriber queue is full", e)
+		}
+	}
+}
+
+// emitChange emits the event of a change sent to the Mirror, with a copy of the item.
+func (b *bus) emitChange(op MirrorOp, col vocab.IRI, it vocab.Item, errFn loggerFn) {
+	if b == nil || vocab.IsNil(it) {
+		return
+	}
+	b.mu.Lock()
+	subscribed := len(b.subs) > 0
+	b.mu.Unlock()
+	if !subscribed {
+		return
+	}
+	now := time.Now().UTC()
+	it = cache.Copy(it)
+	switch op {
+	case MirrorSave:
+		b.emit(ItemSaved{Item: it, Time: now}, errFn)
+	case MirrorDelete:
+		b.emit(ItemDeleted{Item: it, Time: now}, errFn)
+	case MirrorAddTo, MirrorRemoveFrom:
+		b.emit(CollectionUpdated{Collection: col, Item: it, Removed: op == MirrorRemoveFrom, Time: now}, errFn)
+	}
+}
+
+// metadataChanged emits the MetadataChanged event for the actor.
+func (r *repo) metadataChanged(iri vocab.IRI) {
+	r.events.emit(MetadataChanged{IRI: iri, Time: time.Now().UTC()}, r.errFn)
+}
+
+// Subscribe calls fn for the events of the repository, until ctx is done, or fn returns an error.
+// Unlike Watch, the events are typed, and include the changes of the metadata of the actors.
+//
+// The events are received in the order they were emitted, and the ones emitted while fn can't keep up
+// are dropped.
+func (r *repo) Subscribe(ctx context.Context, fn EventFn) error {
+	if fn == nil {
+		return errors.NotValidf("nil event function")
+	}
+	if r.events == nil {
+		return errors.NotValidf("the repository doesn't support subscribing to events")
+	}
+	events := r.events.add()
+	defer r.events.remove(events)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-events:
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package badger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Subscribe(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.events = new(bus)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := make([]Event, 0)
+	done := make(chan error)
+	go func() {
+		done <- r.Subscribe(ctx, func(e Event) error {
+			events = append(events, e)
+			if _, ok := e.(ItemDeleted); ok {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	for subscribed := 0; subscribed == 0; {
+		r.events.mu.Lock()
+		subscribed = len(r.events.subs)
+		r.events.mu.Unlock()
+	}
+
+	actor := vocab.PersonNew("https://example.com/actors/jdoe")
+	if _, err = r.Save(actor); err != nil {
+		t.Fatalf("unable to save %s: %s", actor.ID, err)
+	}
+	if _, err = r.ResetPassword(actor.ID, []byte("dsa")); err != nil {
+		t.Fatalf("unable to set the password of %s: %s", actor.ID, err)
+	}
+	col := vocab.IRI("https://example.com/actors/jdoe/outbox")
+	if err = r.AddTo(col, actor.ID); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", actor.ID, col, err)
+	}
+	if err = r.Delete(actor); err != nil {
+		t.Fatalf("unable to delete %s: %s", actor.ID, err)
+	}
+
+	if err = <-done; err != nil {
+		t.Fatalf("Subscribe() error = %s", err)
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("Subscribe() didn't receive the events before timing out")
+	}
+	if len(events) != 4 {
+		t.Fatalf("Subscribe() received %+v, want 4 events", events)
+	}
+	if e, ok := events[0].(ItemSaved); !ok || e.Item.GetLink() != actor.ID {
+		t.Errorf("Subscribe() event #0 = %+v, want ItemSaved for %s", events[0], actor.ID)
+	}
+	if e, ok := events[1].(MetadataChanged); !ok || e.IRI != actor.ID {
+		t.Errorf("Subscribe() event #1 = %+v, want MetadataChanged for %s", events[1], actor.ID)
+	}
+	if e, ok := events[2].(CollectionUpdated); !ok || e.Collection != col || e.Removed {
+		t.Errorf("Subscribe() event #2 = %+v, want CollectionUpdated for %s", events[2], col)
+	}
+	if len(r.events.subs) != 0 {
+		t.Errorf("Subscribe() left %d subscribers after returning", len(r.events.subs))
+	}
+}
@@ -0,0 +1,216 @@
+package badger
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// exportMediaDir is the directory of the archive containing the media embedded in the exported objects.
+const exportMediaDir = "media_attachments"
+
+// ExportActor writes to w a gzipped tarball with the data of the actor at iri, in the layout of the archives
+// exported by other ActivityPub servers:
+//
+//	actor.json                     the actor document
+//	outbox.json                    the activities of the actor, with their objects
+//	followers.json, following.json the IRIs of the actors following, and followed by, the actor
+//	likes.json                     the IRIs of the objects liked by the actor
+//	media_attachments/             the media embedded as data URIs in the actor and in its objects
+//
+// The embedded media are replaced in the documents by the paths of their files in the archive.
+// The media hosted elsewhere are not fetched, they remain referenced by their IRIs.
+func (r *repo) ExportActor(iri vocab.IRI, w io.Writer) error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var actor vocab.Item
+	var followers, following, liked vocab.IRIs
+	err = r.d.View(func(tx *badger.Txn) error {
+		var err error
+		if actor, err = loadRawItem(tx, itemPath(iri)); err != nil {
+			return err
+		}
+		if !vocab.ActorTypes.Contains(actor.GetType()) {
+			return errors.NotValidf("%s is not an actor", iri)
+		}
+		followers = exportedIRIs(tx, vocab.Followers.IRI(actor))
+		following = exportedIRIs(tx, vocab.Following.IRI(actor))
+		liked = exportedIRIs(tx, vocab.Liked.IRI(actor))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	outboxIRI := vocab.Outbox.IRI(actor)
+	f, err := filters.FiltersFromIRI(outboxIRI)
+	if err != nil {
+		return err
+	}
+	activities, err := r.loadFromPath(f, 0)
+	if err != nil && !errors.IsNotFound(err) {
+		return errors.Annotatef(err, "unable to load the outbox of %s", iri)
+	}
+
+	outbox := vocab.OrderedCollectionNew(outboxIRI)
+	outbox.OrderedItems = activities
+	outbox.TotalItems = uint(len(activities))
+
+	media := make(map[string][]byte)
+	docs := []struct {
+		name string
+		it   vocab.Item
+	}{
+		{name: "actor.json", it: actor},
+		{name: "outbox.json", it: outbox},
+		{name: "followers.json", it: exportedCollection(vocab.Followers.IRI(actor), followers)},
+		{name: "following.json", it: exportedCollection(vocab.Following.IRI(actor), following)},
+		{name: "likes.json", it: exportedCollection(vocab.Liked.IRI(actor), liked)},
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now().UTC()
+	for _, doc := range docs {
+		raw, err := encodeItemFn(doc.it)
+		if err != nil {
+			return errors.Annotatef(err, "unable to encode %s", doc.name)
+		}
+		raw = extractMedia(raw, media)
+		if err = writeTarFile(tw, doc.name, withActivityStreamsContext(raw), now); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(media))
+	for name := range media {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err = writeTarFile(tw, exportMediaDir+"/"+name, media[name], now); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// exportedIRIs returns the IRIs stored in the collection col, or none when it doesn't exist.
+func exportedIRIs(tx dbTxn, col vocab.IRI) vocab.IRIs {
+	it, err := loadRawItem(tx, itemPath(col))
+	if err != nil {
+		return nil
+	}
+	iris, _ := collectionIRIs(it)
+	return iris
+}
+
+func exportedCollection(iri vocab.IRI, iris vocab.IRIs) vocab.Item {
+	col := vocab.OrderedCollectionNew(iri)
+	for _, it := range iris {
+		col.OrderedItems = append(col.OrderedItems, it)
+	}
+	col.TotalItems = uint(len(iris))
+	return col
+}
+
+func withActivityStreamsContext(raw []byte) []byte {
+	if !bytes.HasPrefix(raw, []byte("{")) {
+		return raw
+	}
+	ctx := fmt.Sprintf(`{"@context":%q`, vocab.ActivityBaseURI.String())
+	if !bytes.HasPrefix(raw, []byte("{}")) {
+		ctx += ","
+	}
+	return append([]byte(ctx), raw[1:]...)
+}
+
+// extractMedia replaces the data URIs found in the raw JSON document by the paths of the files they get written to
+// in the archive, and adds their decoded content to media, keyed by the names of the files.
+func extractMedia(raw []byte, media map[string][]byte) []byte {
+	const prefix = `"data:`
+	out := make([]byte, 0, len(raw))
+	for {
+		start := bytes.Index(raw, []byte(prefix))
+		if start < 0 {
+			return append(out, raw...)
+		}
+		end := bytes.IndexByte(raw[start+1:], '"')
+		if end < 0 {
+			return append(out, raw...)
+		}
+		uri := string(raw[start+1 : start+1+end])
+		out = append(out, raw[:start+1]...)
+		if name, data, ok := decodeDataURI(uri); ok {
+			media[name] = data
+			out = append(out, "/"+exportMediaDir+"/"+name...)
+		} else {
+			out = append(out, uri...)
+		}
+		raw = raw[start+1+end:]
+	}
+}
+
+// decodeDataURI returns the content of a data URI, and a file name for it, derived from its hash
+// and its media type.
+func decodeDataURI(uri string) (string, []byte, bool) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return "", nil, false
+	}
+	typ, isBase64 := strings.CutSuffix(header, ";base64")
+	var data []byte
+	if isBase64 {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(payload); err != nil {
+			return "", nil, false
+		}
+	} else {
+		s, err := url.PathUnescape(payload)
+		if err != nil {
+			return "", nil, false
+		}
+		data = []byte(s)
+	}
+	name := fmt.Sprintf("%x", sha1.Sum(data))
+	if mt, _, err := mime.ParseMediaType(typ); err == nil {
+		if ext, _ := mime.ExtensionsByType(mt); len(ext) > 0 {
+			name += ext[0]
+		}
+	}
+	return name, data, true
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(&hdr); err != nil {
+		return errors.Annotatef(err, "unable to write %s", name)
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,102 @@
+package badger
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func readArchive(t *testing.T, raw []byte) map[string][]byte {
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("the archive is not gzipped: %s", err)
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("unable to read the archive: %s", err)
+		}
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+}
+
+func Test_repo_ExportActor(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	jdoe.Followers = vocab.Followers.IRI(jdoe)
+	alice := vocab.PersonNew("http://example.com/actors/alice")
+
+	pixel := []byte("not really a png")
+	image := vocab.ObjectNew(vocab.ImageType)
+	image.URL = vocab.IRI("data:image/png;base64," + base64.StdEncoding.EncodeToString(pixel))
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	note.Attachment = image
+	create := vocab.ActivityNew("http://example.com/activities/1", vocab.CreateType, note)
+	create.Actor = jdoe.ID
+	for _, it := range []vocab.Item{jdoe, alice, note, create} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), create); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+	if err = r.AddTo(jdoe.Followers.GetLink(), alice); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Followers.GetLink(), err)
+	}
+
+	buf := bytes.Buffer{}
+	if err = r.ExportActor(jdoe.ID, &buf); err != nil {
+		t.Fatalf("ExportActor() error = %s", err)
+	}
+	files := readArchive(t, buf.Bytes())
+	for _, name := range []string{"actor.json", "outbox.json", "followers.json", "following.json", "likes.json"} {
+		if !bytes.HasPrefix(files[name], []byte(`{"@context":"https://www.w3.org/ns/activitystreams"`)) {
+			t.Errorf("%s = %s, want an ActivityStreams document", name, files[name])
+		}
+	}
+	if !bytes.Contains(files["actor.json"], []byte(jdoe.ID)) {
+		t.Errorf("actor.json doesn't contain %s", jdoe.ID)
+	}
+	if !bytes.Contains(files["outbox.json"], []byte(create.ID)) || !bytes.Contains(files["outbox.json"], []byte(note.ID)) {
+		t.Errorf("outbox.json = %s, doesn't contain %s and its object", files["outbox.json"], create.ID)
+	}
+	if !bytes.Contains(files["followers.json"], []byte(alice.ID)) {
+		t.Errorf("followers.json = %s, doesn't contain %s", files["followers.json"], alice.ID)
+	}
+
+	var media string
+	for name, data := range files {
+		if strings.HasPrefix(name, exportMediaDir+"/") && bytes.Equal(data, pixel) {
+			media = name
+		}
+	}
+	if media == "" || !strings.HasSuffix(media, ".png") {
+		t.Fatalf("the archive doesn't contain the embedded image: %v", files)
+	}
+	if bytes.Contains(files["outbox.json"], []byte("data:")) || !bytes.Contains(files["outbox.json"], []byte("/"+media)) {
+		t.Errorf("outbox.json = %s, the data URI was not replaced by %s", files["outbox.json"], media)
+	}
+
+	if err = r.ExportActor(note.ID, io.Discard); !errors.IsNotValid(err) {
+		t.Errorf("ExportActor() for an object error = %v, want NotValid", err)
+	}
+}
@@ -0,0 +1,136 @@
+package badger
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// ImportFromFS copies the data of the storage-fs directory at root: the objects and the collections, stored in
+// the __raw files of the folders of their IRIs, their metadata and the OAuth data. The paths of the files,
+// relative to root, become the badger keys, which is the layout both storages use for the same data.
+//
+// The type keys get created while copying, and the filter indexes, the collection cursors and the membership keys
+// are rebuilt after, by ReindexAll, so the collections keep their members. The directory is left unchanged.
+// A checkpoint is stored after every thousand files, so an interrupted import continues from where it stopped.
+func (r *repo) ImportFromFS(root string, opt MigrationOptions) (MigrationReport, error) {
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return MigrationReport{}, errors.NewNotFound(ErrNotFound, "storage-fs directory %s not found", root)
+	}
+	imp, err := r.newImporter("fs", root, opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	// NOTE(marius): WalkDir visits the files in the same order every time, so resuming skips the files
+	// up to, and including, the one of the last checkpoint.
+	resumeAfter := string(imp.resumePosition())
+	read := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		k := filepath.ToSlash(rel)
+		if resumeAfter != "" {
+			if k == resumeAfter {
+				resumeAfter = ""
+			}
+			return nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Annotatef(err, "unable to read %s", path)
+		}
+		if err = imp.set([]byte(k), raw); err != nil {
+			return err
+		}
+		if read++; read%importCheckpointInterval == 0 {
+			return imp.checkpoint([]byte(k))
+		}
+		return nil
+	})
+	return imp.finish(err)
+}
+
+// ExportToFS writes the data of the repository in the directory layout of storage-fs, under root: the objects and
+// the collections in the __raw files of the folders of their IRIs, their metadata and the OAuth data.
+// The keys which badger derives from them, like the type keys, the indexes and the cursors, are not written,
+// as ImportFromFS rebuilds them.
+//
+// The existing files at the same paths are replaced. With the DryRun option, the keys are only counted.
+func (r *repo) ExportToFS(root string, opt MigrationOptions) (MigrationReport, error) {
+	report := MigrationReport{}
+	err := r.Open()
+	if err != nil {
+		return report, err
+	}
+	defer r.Close()
+
+	progress := func() {
+		if opt.ProgressFn != nil {
+			opt.ProgressFn(report)
+		}
+	}
+	err = r.d.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			i := it.Item()
+			k := i.KeyCopy(nil)
+			if !isExportedKey(k) {
+				continue
+			}
+			report.Keys++
+			if isObjectKey(k) {
+				report.Objects++
+			}
+			if report.Keys%importProgressInterval == 0 {
+				progress()
+			}
+			if opt.DryRun {
+				continue
+			}
+			raw, err := i.ValueCopy(nil)
+			if err != nil {
+				return errors.Annotatef(err, "unable to read %s", k)
+			}
+			path := filepath.Join(root, filepath.FromSlash(string(k)))
+			if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return errors.Annotatef(err, "unable to create %s", filepath.Dir(path))
+			}
+			if err = os.WriteFile(path, raw, 0o600); err != nil {
+				return errors.Annotatef(err, "unable to write %s", path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	progress()
+	return report, nil
+}
+
+// isExportedKey returns if the key stores data which the fs storage keeps too: the keys of the objects and
+// of their metadata, and the OAuth keys. The internal keys are the ones having a segment starting with "__".
+func isExportedKey(k []byte) bool {
+	if isObjectKey(k) || bytes.HasSuffix(k, append(append([]byte{}, sep...), metaDataKey...)) {
+		return !bytes.HasPrefix(k, []byte("__"))
+	}
+	for _, segment := range bytes.Split(k, sep) {
+		if bytes.HasPrefix(segment, []byte("__")) {
+			return false
+		}
+	}
+	return bytes.HasPrefix(k, append([]byte(folder), sep...))
+}
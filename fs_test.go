@@ -0,0 +1,164 @@
+package badger
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/openshift/osin"
+)
+
+// initFSForTesting creates a storage-fs directory with the files at the paths.
+func initFSForTesting(t *testing.T, files map[string][]byte) string {
+	root := t.TempDir()
+	for p, raw := range files {
+		path := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("unable to create %s: %s", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, raw, 0o600); err != nil {
+			t.Fatalf("unable to write %s: %s", path, err)
+		}
+	}
+	return root
+}
+
+func Test_repo_ImportFromFS(t *testing.T) {
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	rawActor, _ := encodeItemFn(jdoe)
+	rawNote, _ := encodeItemFn(note)
+	rawOutbox, _ := encodeItemFn(vocab.IRIs{note.ID})
+	root := initFSForTesting(t, map[string][]byte{
+		"example.com/actors/jdoe/__raw":        rawActor,
+		"example.com/actors/jdoe/outbox/__raw": rawOutbox,
+		"example.com/objects/1/__raw":          rawNote,
+		"example.com/objects/1/__meta_data":    []byte(`{}`),
+	})
+
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	report, err := r.ImportFromFS(root, MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportFromFS() dry run error = %s", err)
+	}
+	if report.Keys != 4 || report.Objects != 3 {
+		t.Errorf("ImportFromFS() dry run = %+v, want 4 keys and 3 objects", report)
+	}
+	if _, err = r.Load(note.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() after a dry run error = %v, want NotFound", err)
+	}
+
+	if report, err = r.ImportFromFS(root, MigrationOptions{}); err != nil {
+		t.Fatalf("ImportFromFS() error = %s", err)
+	}
+	if report.Keys != 4 || report.Objects != 3 {
+		t.Errorf("ImportFromFS() = %+v, want 4 keys and 3 objects", report)
+	}
+	col, err := r.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the imported outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(note.ID) {
+		t.Errorf("Load() of the imported outbox = %v, doesn't contain %s", col, note.ID)
+	}
+	var cols vocab.IRIs
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	_ = r.d.View(func(tx *badger.Txn) error {
+		cols = collectionsContaining(tx, note.ID)
+		return nil
+	})
+	r.Close()
+	if !cols.Contains(jdoe.Outbox.GetLink()) {
+		t.Errorf("the membership of %s in %s was not preserved: %v", note.ID, jdoe.Outbox.GetLink(), cols)
+	}
+
+	if _, err = r.ImportFromFS(filepath.Join(root, "missing"), MigrationOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("ImportFromFS() of a missing directory error = %v, want NotFound", err)
+	}
+}
+
+func Test_repo_ExportToFS(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+	if err = r.CreateClient(&osin.DefaultClient{Id: "app", Secret: "s3cr3t"}); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+
+	root := t.TempDir()
+	report, err := r.ExportToFS(root, MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ExportToFS() dry run error = %s", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) > 0 {
+		t.Errorf("ExportToFS() dry run wrote %d entries", len(entries))
+	}
+	if report.Objects == 0 {
+		t.Errorf("ExportToFS() dry run counted no objects")
+	}
+	exported, err := r.ExportToFS(root, MigrationOptions{})
+	if err != nil {
+		t.Fatalf("ExportToFS() error = %s", err)
+	}
+	if exported != report {
+		t.Errorf("ExportToFS() = %+v, want the %+v counted by the dry run", exported, report)
+	}
+	for _, p := range []string{"example.com/actors/jdoe/__raw", "example.com/actors/jdoe/outbox/__raw", "example.com/objects/1/__raw", "oauth/clients/app"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(p))); err != nil {
+			t.Errorf("ExportToFS() didn't write %s: %s", p, err)
+		}
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasPrefix(d.Name(), "__") && d.Name() != objectKey && d.Name() != metaDataKey {
+			t.Errorf("ExportToFS() wrote the internal key %s", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Errorf("unable to walk %s: %s", root, err)
+	}
+
+	imported, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = imported.ImportFromFS(root, MigrationOptions{}); err != nil {
+		t.Fatalf("ImportFromFS() of the exported directory error = %s", err)
+	}
+	col, err := imported.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the exported outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(note.ID) {
+		t.Errorf("Load() of the exported outbox = %v, doesn't contain %s", col, note.ID)
+	}
+	if _, err = imported.GetClient("app"); err != nil {
+		t.Errorf("GetClient() of the exported client error = %s", err)
+	}
+}
@@ -0,0 +1,278 @@
+package badger
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// IssueType describes the kind of integrity problem found for a collection.
+type IssueType string
+
+const (
+	// MissingItems signals a collection that references objects which are not present in the storage.
+	MissingItems IssueType = "missing-items"
+	// TotalItemsMismatch signals a collection object whose TotalItems doesn't match its number of items.
+	TotalItemsMismatch IssueType = "total-items-mismatch"
+	// MissingCollection signals a collection which is referenced by an object, but is not present in the storage.
+	MissingCollection IssueType = "missing-collection"
+)
+
+// CollectionIssue is an integrity problem found for a collection.
+type CollectionIssue struct {
+	Collection vocab.IRI  `json:"collection"`
+	Type       IssueType  `json:"type"`
+	Items      vocab.IRIs `json:"items,omitempty"`
+	Expected   uint       `json:"expected,omitempty"`
+	Found      uint       `json:"found,omitempty"`
+	Fixed      bool       `json:"fixed"`
+}
+
+// RepairReport is the result of a collection integrity check.
+type RepairReport struct {
+	Checked int               `json:"checked"`
+	Issues  []CollectionIssue `json:"issues"`
+	// Corrupted are the keys of the values which could not be decoded, and can't be repaired.
+	Corrupted []string `json:"corrupted,omitempty"`
+}
+
+// CheckCollections verifies the integrity of the stored collections:
+// that their items exist in storage, that the TotalItems of collection objects matches their items,
+// and that the collections referenced by objects and actors are present.
+// When fix is true, the problems found are repaired, and the issues are marked accordingly.
+func (r *repo) CheckCollections(fix bool) (*RepairReport, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	report := RepairReport{Issues: make([]CollectionIssue, 0)}
+	existing := make(map[string]struct{})
+	collections := make(map[vocab.IRI]vocab.IRIs)
+	expected := make(vocab.IRIs, 0)
+
+	err = r.d.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			i := it.Item()
+			k := i.Key()
+			if !isObjectKey(k) {
+				continue
+			}
+			p := bytes.TrimSuffix(k, append(sep, objectKey...))
+			existing[string(p)] = struct{}{}
+
+			err := i.Value(func(raw []byte) error {
+				ob, err := loadItem(raw)
+				if err != nil {
+					return err
+				}
+				switch {
+				case ob.GetType() == vocab.CollectionOfIRIs || ob.GetType() == vocab.CollectionOfItems:
+					iri, err := collectionIRI(tx, p)
+					if err != nil {
+						return err
+					}
+					return vocab.OnIRIs(ob, func(col *vocab.IRIs) error {
+						collections[iri] = *col
+						return nil
+					})
+				case ob.GetType() == vocab.CollectionType || ob.GetType() == vocab.OrderedCollectionType:
+					report.Checked++
+					if issue := checkTotalItems(r, tx, ob); issue != nil {
+						report.Issues = append(report.Issues, *issue)
+					}
+				default:
+					expected = append(expected, referencedCollections(ob)...)
+				}
+				return nil
+			})
+			if err != nil {
+				r.errFn("unable to check %s: %+s", k, err)
+				report.Corrupted = append(report.Corrupted, string(k))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE(marius): the members on other hosts than the ones of the stored collections, like the remote followers,
+	// are not expected to be stored, so only the missing members on the local hosts are dangling.
+	local := make(map[string]struct{})
+	for col := range collections {
+		local[iriHost(col)] = struct{}{}
+	}
+	for p, iris := range collections {
+		report.Checked++
+		missing := make(vocab.IRIs, 0)
+		for _, iri := range iris {
+			if _, ok := local[iriHost(iri)]; !ok {
+				continue
+			}
+			if _, ok := existing[string(itemPath(iri))]; !ok {
+				missing = append(missing, iri)
+			}
+		}
+		if len(missing) > 0 {
+			report.Issues = append(report.Issues, CollectionIssue{Collection: p, Type: MissingItems, Items: missing})
+		}
+	}
+	for _, col := range expected {
+		if _, ok := existing[string(itemPath(col))]; !ok {
+			report.Issues = append(report.Issues, CollectionIssue{Collection: col, Type: MissingCollection})
+		}
+	}
+
+	if !fix {
+		return &report, nil
+	}
+	defer r.invalidateResults()
+	for i, issue := range report.Issues {
+		if err = r.update(repairCollection(issue)); err != nil {
+			r.errFn("unable to repair collection %s: %+s", issue.Collection, err)
+			continue
+		}
+		report.Issues[i].Fixed = true
+	}
+	return &report, nil
+}
+
+// checkTotalItems verifies that the TotalItems value of the collection object matches its items,
+// including the ones found in its stored pages.
+func checkTotalItems(r *repo, tx dbTxn, ob vocab.Item) *CollectionIssue {
+	var issue *CollectionIssue
+	_ = vocab.OnCollectionIntf(ob, func(col vocab.CollectionInterface) error {
+		items := r.collectionMembers(tx, col)
+		if total := totalItems(ob); total != uint(len(items)) {
+			issue = &CollectionIssue{
+				Collection: col.GetLink(),
+				Type:       TotalItemsMismatch,
+				Expected:   uint(len(items)),
+				Found:      total,
+			}
+		}
+		return nil
+	})
+	return issue
+}
+
+func totalItems(ob vocab.Item) uint {
+	var total uint
+	switch ob.GetType() {
+	case vocab.OrderedCollectionType:
+		_ = vocab.OnOrderedCollection(ob, func(col *vocab.OrderedCollection) error {
+			total = col.TotalItems
+			return nil
+		})
+	case vocab.CollectionType:
+		_ = vocab.OnCollection(ob, func(col *vocab.Collection) error {
+			total = col.TotalItems
+			return nil
+		})
+	}
+	return total
+}
+
+func setTotalItems(ob vocab.Item, total uint) error {
+	switch ob.GetType() {
+	case vocab.OrderedCollectionType:
+		return vocab.OnOrderedCollection(ob, func(col *vocab.OrderedCollection) error {
+			col.TotalItems = total
+			return nil
+		})
+	case vocab.CollectionType:
+		return vocab.OnCollection(ob, func(col *vocab.Collection) error {
+			col.TotalItems = total
+			return nil
+		})
+	}
+	return errors.Newf("invalid collection type %s", ob.GetType())
+}
+
+// collectionIRI builds the IRI of the collection stored at path p, based on the IRI of its parent object.
+// If there's no parent object in storage, the scheme of the IRI can't be known, and it returns an error.
+func collectionIRI(tx dbTxn, p []byte) (vocab.IRI, error) {
+	parent, err := loadRawItem(tx, []byte(filepath.Dir(string(p))))
+	if err != nil || vocab.IsNil(parent) {
+		return "", errors.NewNotFound(wrapErr(ErrNotFound, err), "unable to find the parent object of the collection %s", p)
+	}
+	return vocab.CollectionPath(filepath.Base(string(p))).IRI(parent), nil
+}
+
+// iriHost returns the lower cased host of the IRI, or an empty string for invalid IRIs.
+func iriHost(iri vocab.IRI) string {
+	u, err := iri.URL()
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// referencedCollections returns the collection IRIs set on the properties of an object or actor.
+func referencedCollections(it vocab.Item) vocab.IRIs {
+	cols := make(vocab.IRIs, 0)
+	appendCol := func(col vocab.Item) {
+		if !vocab.IsNil(col) && vocab.IsIRI(col) {
+			cols = append(cols, col.GetLink())
+		}
+	}
+	if vocab.ActorTypes.Contains(it.GetType()) {
+		_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+			appendCol(a.Inbox)
+			appendCol(a.Outbox)
+			appendCol(a.Followers)
+			appendCol(a.Following)
+			appendCol(a.Liked)
+			return nil
+		})
+	}
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		appendCol(o.Replies)
+		appendCol(o.Likes)
+		appendCol(o.Shares)
+		return nil
+	})
+	return cols
+}
+
+func repairCollection(issue CollectionIssue) func(tx dbTxn) error {
+	return func(tx dbTxn) error {
+		switch issue.Type {
+		case MissingItems:
+			return onCollectionIRIs(tx, issue.Collection, func(iris vocab.IRIs) (vocab.IRIs, error) {
+				valid := make(vocab.IRIs, 0, len(iris))
+				for _, iri := range iris {
+					if !issue.Items.Contains(iri) {
+						valid = append(valid, iri)
+					}
+				}
+				return valid, nil
+			})
+		case MissingCollection:
+			return tx.Set(getObjectKey(itemPath(issue.Collection)), emptyCollection)
+		case TotalItemsMismatch:
+			k := getObjectKey(itemPath(issue.Collection))
+			ob, err := loadRawKey(tx, k)
+			if err != nil {
+				return err
+			}
+			if err = setTotalItems(ob, issue.Expected); err != nil {
+				return err
+			}
+			raw, err := encodeItemFn(ob)
+			if err != nil {
+				return err
+			}
+			return tx.Set(k, raw)
+		}
+		return errors.Newf("unknown collection issue %s", issue.Type)
+	}
+}
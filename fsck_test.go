@@ -0,0 +1,99 @@
+package badger
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_CheckCollections(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	actor := vocab.PersonNew("http://example.com/jdoe")
+	actor.Inbox = vocab.Inbox.IRI(actor)
+	actor.Outbox = vocab.Outbox.IRI(actor)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	col := vocab.OrderedCollectionNew("http://example.com/jdoe/followers")
+	col.OrderedItems = vocab.ItemCollection{note.GetLink()}
+	col.TotalItems = 5
+	for _, it := range []vocab.Item{actor, note, col} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(actor.Outbox.GetLink(), vocab.IRI("http://example.com/objects/missing")); err != nil {
+		t.Fatalf("unable to add to outbox: %s", err)
+	}
+	if err = r.AddTo(actor.Outbox.GetLink(), note.GetLink()); err != nil {
+		t.Fatalf("unable to add to outbox: %s", err)
+	}
+	remote := vocab.IRI("https://social.example.org/actors/alice")
+	if err = r.AddTo(actor.Outbox.GetLink(), remote); err != nil {
+		t.Fatalf("unable to add to outbox: %s", err)
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Delete(getObjectKey(itemPath(actor.Inbox.GetLink())))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to remove inbox: %s", err)
+	}
+
+	report, err := r.CheckCollections(false)
+	if err != nil {
+		t.Fatalf("CheckCollections() error = %s", err)
+	}
+	want := map[IssueType]vocab.IRI{
+		MissingItems:       actor.Outbox.GetLink(),
+		TotalItemsMismatch: col.GetLink(),
+		MissingCollection:  actor.Inbox.GetLink(),
+	}
+	if len(report.Issues) != len(want) {
+		t.Errorf("CheckCollections() found %d issues, want %d: %v", len(report.Issues), len(want), report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if iri, ok := want[issue.Type]; !ok || !iri.Equals(issue.Collection, false) {
+			t.Errorf("CheckCollections() unexpected issue %s for %s", issue.Type, issue.Collection)
+		}
+		if issue.Fixed {
+			t.Errorf("CheckCollections() issue %s for %s should not be fixed", issue.Type, issue.Collection)
+		}
+		if issue.Items.Contains(remote) {
+			t.Errorf("CheckCollections() reported the remote member %s as missing", remote)
+		}
+	}
+
+	if report, err = r.CheckCollections(true); err != nil {
+		t.Fatalf("CheckCollections() error = %s", err)
+	}
+	for _, issue := range report.Issues {
+		if !issue.Fixed {
+			t.Errorf("CheckCollections() issue %s for %s was not fixed", issue.Type, issue.Collection)
+		}
+	}
+	if report, err = r.CheckCollections(false); err != nil {
+		t.Fatalf("CheckCollections() error = %s", err)
+	}
+	if len(report.Issues) > 0 {
+		t.Errorf("CheckCollections() found issues after repair: %v", report.Issues)
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	defer r.Close()
+	_ = r.d.View(func(tx *badger.Txn) error {
+		outbox, _ := loadRawItem(tx, itemPath(actor.Outbox.GetLink()))
+		if iris, _ := collectionIRIs(outbox); !iris.Contains(remote) {
+			t.Errorf("CheckCollections() repair removed the remote member %s from %v", remote, iris)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,310 @@
+package badger
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// GarbageType describes the kind of data removed by CollectGarbage.
+type GarbageType string
+
+const (
+	// OrphanedObject is an object which is not an actor, nor a collection, and which is not contained
+	// in any collection, nor is the object, or the target, of a stored activity.
+	OrphanedObject GarbageType = "orphaned-object"
+	// ExpiredToken is an OAuth2 authorization code, or an access token without a refresh token, which expired.
+	ExpiredToken GarbageType = "expired-token"
+	// EmptyCollection is an automatically created collection with no items, see RemoveEmptyCollections.
+	EmptyCollection GarbageType = "empty-collection"
+)
+
+// Garbage is a key removed by CollectGarbage, or which would be removed in a dry run.
+type Garbage struct {
+	Type GarbageType `json:"type"`
+	Key  string      `json:"key"`
+	// IRI is the IRI of the orphaned object, or of the empty collection.
+	IRI vocab.IRI `json:"iri,omitempty"`
+}
+
+// GarbageOptions configures CollectGarbage.
+type GarbageOptions struct {
+	// DryRun only reports what would be removed.
+	DryRun bool
+	// Types are the types of garbage removed, all of them when empty.
+	Types []GarbageType
+	// OrphansAge is the age under which the orphaned objects are kept, as they can be freshly delivered objects
+	// which are not yet added to a collection. The orphaned objects without a published time are kept too.
+	// When it is 0, all the orphaned objects are removed.
+	OrphansAge time.Duration
+	// EmptyCollectionsAge is the maxAge used for removing the empty collections, like in RemoveEmptyCollections.
+	EmptyCollectionsAge time.Duration
+	// GarbageFn is called for each of the removed keys.
+	GarbageFn func(Garbage)
+}
+
+// GarbageReport is the result of CollectGarbage.
+type GarbageReport struct {
+	DryRun           bool `json:"dryRun"`
+	OrphanedObjects  int  `json:"orphanedObjects"`
+	ExpiredTokens    int  `json:"expiredTokens"`
+	EmptyCollections int  `json:"emptyCollections"`
+}
+
+// CollectGarbage removes in one pass the orphaned objects, the expired OAuth2 authorization codes and access tokens,
+// and the empty automatically created collections.
+// The orphaned objects get removed together with their indexes and their replies, likes and shares collections.
+func (r *repo) CollectGarbage(opt GarbageOptions) (GarbageReport, error) {
+	report := GarbageReport{DryRun: opt.DryRun}
+	err := r.Open()
+	if err != nil {
+		return report, err
+	}
+	defer r.Close()
+
+	collects := func(typ GarbageType) bool {
+		if len(opt.Types) == 0 {
+			return true
+		}
+		for _, t := range opt.Types {
+			if t == typ {
+				return true
+			}
+		}
+		return false
+	}
+	now := time.Now().UTC()
+	orphans := make(map[string]vocab.Item)
+	expired := make([]string, 0)
+	empty := make(map[string]vocab.IRI)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if collects(OrphanedObject) {
+			if orphans, err = orphanedObjects(tx); err != nil {
+				return err
+			}
+		}
+		if collects(ExpiredToken) {
+			expired = expiredTokens(tx, now)
+		}
+		if collects(EmptyCollection) {
+			empty = emptyCollections(tx, now.Add(-opt.EmptyCollectionsAge))
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if opt.OrphansAge > 0 {
+		orphans = olderOrphans(orphans, now.Add(-opt.OrphansAge))
+	}
+
+	garbage := make([]Garbage, 0, len(orphans)+len(expired)+len(empty))
+	for _, p := range sortedKeys(orphans) {
+		garbage = append(garbage, Garbage{Type: OrphanedObject, Key: string(getObjectKey([]byte(p))), IRI: orphans[p].GetLink()})
+	}
+	for _, k := range expired {
+		garbage = append(garbage, Garbage{Type: ExpiredToken, Key: k})
+	}
+	for _, k := range sortedKeys(empty) {
+		// NOTE(marius): the collections of the orphaned objects get removed together with them
+		if _, ok := orphans[filepath.Dir(garbagePath(k))]; ok {
+			continue
+		}
+		garbage = append(garbage, Garbage{Type: EmptyCollection, Key: k, IRI: empty[k]})
+	}
+
+	b := r.newWriteBatch()
+	for _, g := range garbage {
+		if !opt.DryRun {
+			if err = removeGarbage(r, b, g, orphans); err != nil {
+				b.Cancel()
+				return report, errors.Annotatef(err, "unable to remove %s %s", g.Type, g.Key)
+			}
+		}
+		switch g.Type {
+		case OrphanedObject:
+			report.OrphanedObjects++
+		case ExpiredToken:
+			report.ExpiredTokens++
+		case EmptyCollection:
+			report.EmptyCollections++
+		}
+		if opt.GarbageFn != nil {
+			opt.GarbageFn(g)
+		}
+	}
+	if opt.DryRun {
+		b.Cancel()
+		return report, nil
+	}
+	if err = b.Flush(); err != nil {
+		return report, err
+	}
+
+	removed := make(vocab.IRIs, 0)
+	for _, g := range garbage {
+		if g.Type == OrphanedObject {
+			r.invalidateItem(g.IRI)
+		}
+		if len(g.IRI) > 0 {
+			removed = append(removed, g.IRI)
+		}
+	}
+	if len(removed) > 0 {
+		r.invalidateResults(removed...)
+	}
+	r.logFn("Removed %d orphaned objects, %d expired tokens and %d empty collections",
+		report.OrphanedObjects, report.ExpiredTokens, report.EmptyCollections)
+	return report, nil
+}
+
+func removeGarbage(r *repo, b keySetter, g Garbage, orphans map[string]vocab.Item) error {
+	if g.Type != OrphanedObject {
+		return b.Delete([]byte(g.Key))
+	}
+	ob := orphans[garbagePath(g.Key)]
+	for _, col := range autoCreatedCollections {
+		if err := deleteFromPath(r, b, col.IRI(ob)); err != nil {
+			return err
+		}
+	}
+	return deleteFromPath(r, b, ob)
+}
+
+// olderOrphans returns the orphaned objects published before olderThan.
+func olderOrphans(orphans map[string]vocab.Item, olderThan time.Time) map[string]vocab.Item {
+	older := make(map[string]vocab.Item, len(orphans))
+	for p, ob := range orphans {
+		if published := publishedTime(ob); !published.IsZero() && published.Before(olderThan) {
+			older[p] = ob
+		}
+	}
+	return older
+}
+
+func garbagePath(k string) string {
+	return strings.TrimSuffix(k, string(sep)+objectKey)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orphanedObjects returns the objects, by their paths, which are not actors, nor collections, and which are
+// neither contained in a collection, nor referenced as the object or the target of a stored activity.
+func orphanedObjects(tx dbTxn) (map[string]vocab.Item, error) {
+	candidates := make(map[string]vocab.Item)
+	referenced := make(map[string]struct{})
+	reference := func(it vocab.Item) {
+		if vocab.IsNil(it) {
+			return
+		}
+		if it.IsCollection() {
+			_ = vocab.OnCollectionIntf(it, func(col vocab.CollectionInterface) error {
+				for _, m := range col.Collection() {
+					referenced[string(itemPath(m.GetLink()))] = struct{}{}
+				}
+				return nil
+			})
+			return
+		}
+		referenced[string(itemPath(it.GetLink()))] = struct{}{}
+	}
+
+	opt := badger.DefaultIteratorOptions
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		k := i.Key()
+		if !isObjectKey(k) {
+			continue
+		}
+		p := string(bytes.TrimSuffix(k, append(sep, objectKey...)))
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil || vocab.IsNil(ob) {
+				return err
+			}
+			typ := ob.GetType()
+			switch {
+			case ob.IsCollection():
+				reference(ob)
+				return nil
+			case vocab.ActorTypes.Contains(typ):
+				return nil
+			case vocab.ActivityTypes.Contains(typ):
+				_ = vocab.OnActivity(ob, func(a *vocab.Activity) error {
+					reference(a.Object)
+					reference(a.Target)
+					return nil
+				})
+			case vocab.IntransitiveActivityTypes.Contains(typ):
+				_ = vocab.OnIntransitiveActivity(ob, func(a *vocab.IntransitiveActivity) error {
+					reference(a.Target)
+					return nil
+				})
+			}
+			candidates[p] = ob
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Annotatef(err, "unable to decode %s", k)
+		}
+	}
+
+	orphans := make(map[string]vocab.Item)
+	for p, ob := range candidates {
+		if _, ok := referenced[p]; !ok {
+			orphans[p] = ob
+		}
+	}
+	return orphans, nil
+}
+
+// expiredTokens returns the keys of the authorization codes, and of the access tokens without a refresh token,
+// which expired before now.
+func expiredTokens(tx dbTxn, now time.Time) []string {
+	expired := make([]string, 0)
+	scan := func(bucket string, fn func(raw []byte) bool) {
+		prefix := append(badgerItemPath(bucket), sep...)
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = prefix
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			_ = it.Item().Value(func(raw []byte) error {
+				if fn(raw) {
+					expired = append(expired, string(it.Item().Key()))
+				}
+				return nil
+			})
+		}
+	}
+	scan(authorizeBucket, func(raw []byte) bool {
+		a := auth{}
+		return decodeFn(raw, &a) == nil && tokenExpired(a.CreatedAt, a.ExpiresIn, now)
+	})
+	scan(accessBucket, func(raw []byte) bool {
+		a := acc{}
+		return decodeFn(raw, &a) == nil && a.RefreshToken == "" && tokenExpired(a.CreatedAt, a.ExpiresIn, now)
+	})
+	return expired
+}
+
+// tokenExpired returns if an authorization code, or an access token, created at createdAt expired before now.
+func tokenExpired(createdAt time.Time, expiresIn time.Duration, now time.Time) bool {
+	// NOTE(marius): the expiration is stored in seconds, as osin has it
+	return expiresIn > 0 && createdAt.Add(expiresIn*time.Second).Before(now)
+}
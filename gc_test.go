@@ -0,0 +1,155 @@
+package badger
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/openshift/osin"
+)
+
+func Test_repo_CollectGarbage(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	old := vocab.ObjectNew(vocab.NoteType)
+	old.ID = "http://example.com/objects/old"
+	old.Published = time.Now().UTC().Add(-48 * time.Hour)
+	old.Replies = vocab.Replies.IRI(old)
+	created := vocab.ObjectNew(vocab.NoteType)
+	created.ID = "http://example.com/objects/created"
+	create := vocab.CreateNew("http://example.com/activities/1", created.GetLink())
+	create.Actor = jdoe.GetLink()
+	orphan := vocab.ObjectNew(vocab.NoteType)
+	orphan.ID = "http://example.com/objects/orphan"
+	orphan.Published = old.Published
+	orphan.Replies = vocab.Replies.IRI(orphan)
+	for _, it := range []vocab.Item{jdoe, old, created, create, orphan} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	for _, it := range []vocab.Item{old, create} {
+		if err = r.AddTo(jdoe.Outbox.GetLink(), it.GetLink()); err != nil {
+			t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+		}
+	}
+
+	client := &osin.DefaultClient{Id: "client"}
+	if err = r.CreateClient(client); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+	for _, a := range []*osin.AuthorizeData{
+		{Client: client, Code: "expired", ExpiresIn: 60, CreatedAt: time.Now().Add(-time.Hour)},
+		{Client: client, Code: "valid", ExpiresIn: 3600, CreatedAt: time.Now()},
+	} {
+		if err = r.SaveAuthorize(a); err != nil {
+			t.Fatalf("unable to save authorization %s: %s", a.Code, err)
+		}
+	}
+	access := &osin.AccessData{Client: client, AccessToken: "expired", ExpiresIn: 60, CreatedAt: time.Now().Add(-time.Hour)}
+	if err = r.SaveAccess(access); err != nil {
+		t.Fatalf("unable to save access: %s", err)
+	}
+
+	want := []Garbage{
+		{Type: OrphanedObject, Key: "example.com/objects/orphan/__raw", IRI: orphan.ID},
+		{Type: ExpiredToken, Key: "oauth/authorize/expired"},
+		{Type: ExpiredToken, Key: "oauth/access/expired"},
+		{Type: EmptyCollection, Key: "example.com/objects/old/replies/__raw", IRI: old.Replies.GetLink()},
+	}
+	wantReport := GarbageReport{DryRun: true, OrphanedObjects: 1, ExpiredTokens: 2, EmptyCollections: 1}
+
+	garbage := make([]Garbage, 0)
+	report, err := r.CollectGarbage(GarbageOptions{DryRun: true, EmptyCollectionsAge: 24 * time.Hour, GarbageFn: func(g Garbage) {
+		garbage = append(garbage, g)
+	}})
+	if err != nil {
+		t.Fatalf("CollectGarbage() dry run error = %s", err)
+	}
+	if report != wantReport || !reflect.DeepEqual(garbage, want) {
+		t.Errorf("CollectGarbage() dry run = %+v, %+v, want %+v, %+v", report, garbage, wantReport, want)
+	}
+	if _, err = r.Inspect(orphan.ID); err != nil {
+		t.Errorf("CollectGarbage() dry run removed %s: %s", orphan.ID, err)
+	}
+
+	wantReport.DryRun = false
+	if report, err = r.CollectGarbage(GarbageOptions{EmptyCollectionsAge: 24 * time.Hour}); err != nil {
+		t.Fatalf("CollectGarbage() error = %s", err)
+	}
+	if report != wantReport {
+		t.Errorf("CollectGarbage() = %+v, want %+v", report, wantReport)
+	}
+	for _, iri := range (vocab.IRIs{orphan.ID, orphan.Replies.GetLink(), old.Replies.GetLink()}) {
+		if _, err = r.Inspect(iri); err == nil {
+			t.Errorf("CollectGarbage() didn't remove %s", iri)
+		}
+	}
+	for _, iri := range (vocab.IRIs{old.ID, created.ID, create.ID, jdoe.Outbox.GetLink()}) {
+		if _, err = r.Inspect(iri); err != nil {
+			t.Errorf("CollectGarbage() removed %s: %s", iri, err)
+		}
+	}
+	keys, err := r.Keys("oauth/", 0)
+	if err != nil {
+		t.Fatalf("Keys() error = %s", err)
+	}
+	if wantKeys := []string{"oauth/authorize/valid", "oauth/clients/client"}; !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("CollectGarbage() left the OAuth2 keys %v, want %v", keys, wantKeys)
+	}
+}
+
+func Test_repo_CollectGarbage_OrphansAge(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	old := vocab.ObjectNew(vocab.NoteType)
+	old.ID = "http://example.com/objects/old"
+	old.Published = time.Now().UTC().Add(-48 * time.Hour)
+	fresh := vocab.ObjectNew(vocab.NoteType)
+	fresh.ID = "http://example.com/objects/fresh"
+	fresh.Published = time.Now().UTC()
+	undated := vocab.ObjectNew(vocab.NoteType)
+	undated.ID = "http://example.com/objects/undated"
+	for _, it := range []vocab.Item{old, fresh, undated} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	client := &osin.DefaultClient{Id: "client"}
+	if err = r.CreateClient(client); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+	expired := &osin.AuthorizeData{Client: client, Code: "expired", ExpiresIn: 60, CreatedAt: time.Now().Add(-time.Hour)}
+	if err = r.SaveAuthorize(expired); err != nil {
+		t.Fatalf("unable to save authorization: %s", err)
+	}
+
+	garbage := make([]Garbage, 0)
+	opt := GarbageOptions{Types: []GarbageType{OrphanedObject}, OrphansAge: 24 * time.Hour, GarbageFn: func(g Garbage) {
+		garbage = append(garbage, g)
+	}}
+	report, err := r.CollectGarbage(opt)
+	if err != nil {
+		t.Fatalf("CollectGarbage() error = %s", err)
+	}
+	want := []Garbage{{Type: OrphanedObject, Key: "example.com/objects/old/__raw", IRI: old.ID}}
+	if report.OrphanedObjects != 1 || report.ExpiredTokens != 0 || !reflect.DeepEqual(garbage, want) {
+		t.Errorf("CollectGarbage() = %+v, %+v, want only %+v", report, garbage, want)
+	}
+	for _, iri := range []vocab.IRI{fresh.ID, undated.ID} {
+		if _, err = r.Load(iri); err != nil {
+			t.Errorf("CollectGarbage() removed %s: %s", iri, err)
+		}
+	}
+	if _, err = r.LoadAuthorize(expired.Code); err != nil {
+		t.Errorf("CollectGarbage() of the orphaned objects removed the expired authorization: %s", err)
+	}
+}
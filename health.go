@@ -0,0 +1,211 @@
+package badger
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// healthKey is the prefix of the scratch keys written by the canary of Health.
+const healthKey = "__health"
+
+// healthCanaryTTL is the time after which a canary key is expired, if Health fails to remove it.
+const healthCanaryTTL = time.Minute
+
+// DefaultHealthWarnFreeSpace is the free space, in bytes, under which Health reports a warning.
+const DefaultHealthWarnFreeSpace = 1 << 30
+
+// HealthStatus is the result of a check done by Health, ordered by severity.
+type HealthStatus int
+
+const (
+	HealthOK HealthStatus = iota
+	HealthWarn
+	HealthCrit
+	HealthUnknown
+)
+
+func (s HealthStatus) String() string {
+	switch s {
+	case HealthOK:
+		return "OK"
+	case HealthWarn:
+		return "WARN"
+	case HealthCrit:
+		return "CRIT"
+	}
+	return "UNKNOWN"
+}
+
+// ExitCode returns the exit code of the status, following the conventions of the Nagios plugins:
+// 0 for OK, 1 for WARN, 2 for CRIT and 3 for UNKNOWN.
+func (s HealthStatus) ExitCode() int {
+	if s < HealthOK || s > HealthUnknown {
+		return int(HealthUnknown)
+	}
+	return int(s)
+}
+
+// HealthCheck is the name of a check done by Health.
+type HealthCheck string
+
+const (
+	HealthOpen      HealthCheck = "open"
+	HealthCanary    HealthCheck = "canary"
+	HealthFreeSpace HealthCheck = "free-space"
+)
+
+// HealthResult is the result of one of the checks done by Health.
+type HealthResult struct {
+	Check   HealthCheck
+	Status  HealthStatus
+	Message string
+}
+
+// HealthOptions configures Health.
+type HealthOptions struct {
+	// ReadOnly opens the database read only, and the canary only reads its scratch key, without writing it.
+	// It fails while the storage is open in another process, or through the handle of the repository.
+	ReadOnly bool
+	// WarnFreeSpace is the free space, in bytes, under which a warning is reported.
+	// When it is 0, DefaultHealthWarnFreeSpace is used.
+	WarnFreeSpace uint64
+	// CritFreeSpace is the free space, in bytes, under which the disk is reported as full.
+	// When it is 0, DefaultDoctorMinFreeSpace is used.
+	CritFreeSpace uint64
+	// WarnLatency is the duration of the canary above which a warning is reported. When zero, it is not checked.
+	WarnLatency time.Duration
+}
+
+// HealthReport is the result of Health.
+type HealthReport struct {
+	// Status is the most severe status of the checks.
+	Status  HealthStatus
+	Results []HealthResult
+	// Latency is the duration of the canary read and write.
+	Latency time.Duration
+	// FreeSpace is the free space, in bytes, on the disk of the storage, when it could be found.
+	FreeSpace uint64
+}
+
+// Health checks that the storage can be opened, that a canary value can be written in a scratch key, read back
+// and removed, and that the disk has free space left. The status of the report, and its exit code, are suitable
+// for the service watchdogs and for monitoring systems.
+func (r *repo) Health(opt HealthOptions) HealthReport {
+	report := HealthReport{Results: make([]HealthResult, 0)}
+	if opt.WarnFreeSpace == 0 {
+		opt.WarnFreeSpace = DefaultHealthWarnFreeSpace
+	}
+	if opt.CritFreeSpace == 0 {
+		opt.CritFreeSpace = DefaultDoctorMinFreeSpace
+	}
+	result := func(check HealthCheck, status HealthStatus, format string, args ...interface{}) {
+		res := HealthResult{Check: check, Status: status, Message: fmt.Sprintf(format, args...)}
+		report.Results = append(report.Results, res)
+		if status > report.Status {
+			report.Status = status
+		}
+	}
+
+	if r.path != "" {
+		free, ok := freeSpace(r.path)
+		report.FreeSpace = free
+		switch {
+		case !ok:
+			result(HealthFreeSpace, HealthUnknown, "the free space can't be found on this platform")
+		case free < opt.CritFreeSpace:
+			result(HealthFreeSpace, HealthCrit, "only %d bytes are free on the disk", free)
+		case free < opt.WarnFreeSpace:
+			result(HealthFreeSpace, HealthWarn, "only %d bytes are free on the disk", free)
+		default:
+			result(HealthFreeSpace, HealthOK, "%d bytes are free on the disk", free)
+		}
+	}
+
+	db, closeFn, err := r.healthOpen(opt.ReadOnly)
+	if err != nil {
+		result(HealthOpen, HealthCrit, "the storage can't be opened: %s", err)
+		return report
+	}
+	defer closeFn()
+	result(HealthOpen, HealthOK, "the storage was opened")
+
+	start := time.Now()
+	// NOTE(marius): the repositories returned by DryRun don't write the canary key, only read it.
+	if opt.ReadOnly || r.dryRun != nil {
+		err = healthRead(db, []byte(healthKey), nil)
+	} else {
+		err = healthCanary(db)
+	}
+	report.Latency = time.Since(start)
+	switch {
+	case err != nil:
+		result(HealthCanary, HealthCrit, "the canary failed: %s", err)
+	case opt.WarnLatency > 0 && report.Latency > opt.WarnLatency:
+		result(HealthCanary, HealthWarn, "the canary took %s", report.Latency)
+	default:
+		result(HealthCanary, HealthOK, "the canary took %s", report.Latency)
+	}
+	return report
+}
+
+// healthOpen opens the database read only, or through the handle of r, which can be already open.
+func (r *repo) healthOpen(readOnly bool) (*badger.DB, func(), error) {
+	if readOnly && r.path != "" {
+		db, err := badger.Open(r.options().WithReadOnly(true))
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { _ = db.Close() }, nil
+	}
+	if err := r.Open(); err != nil {
+		return nil, nil, err
+	}
+	return r.d, r.Close, nil
+}
+
+// healthCanary writes a value in a scratch key, reads it back, and removes it.
+func healthCanary(db *badger.DB) error {
+	now := time.Now().UTC().UnixNano()
+	k := append(append([]byte(healthKey), sep...), strconv.FormatInt(now, 10)...)
+	v := []byte(strconv.FormatInt(now, 36))
+	err := db.Update(func(tx *badger.Txn) error {
+		return tx.SetEntry(badger.NewEntry(k, v).WithTTL(healthCanaryTTL))
+	})
+	if err != nil {
+		return errors.Annotatef(err, "unable to write %s", k)
+	}
+	if err = healthRead(db, k, v); err != nil {
+		return err
+	}
+	err = db.Update(func(tx *badger.Txn) error {
+		return tx.Delete(k)
+	})
+	if err != nil {
+		return errors.Annotatef(err, "unable to remove %s", k)
+	}
+	return nil
+}
+
+// healthRead reads the key, which must have the value v, or be missing when v is nil.
+func healthRead(db *badger.DB, k, v []byte) error {
+	return db.View(func(tx *badger.Txn) error {
+		i, err := tx.Get(k)
+		if err != nil {
+			if v == nil && err == badger.ErrKeyNotFound {
+				return nil
+			}
+			return errors.Annotatef(err, "unable to read %s", k)
+		}
+		return i.Value(func(raw []byte) error {
+			if !bytes.Equal(raw, v) {
+				return errors.Errorf("%s has the value %q, instead of %q", k, raw, v)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package badger
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func Test_repo_Health(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	for _, readOnly := range []bool{false, true} {
+		report := r.Health(HealthOptions{ReadOnly: readOnly, WarnFreeSpace: 1, CritFreeSpace: 1})
+		if report.Status != HealthOK || report.Status.ExitCode() != 0 || len(report.Results) != 3 {
+			t.Errorf("Health() read only %t = %+v, want OK", readOnly, report)
+		}
+	}
+
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(healthKey)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			t.Errorf("Health() left the canary key %s", it.Item().Key())
+		}
+		return nil
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to iterate: %s", err)
+	}
+
+	report := r.Health(HealthOptions{CritFreeSpace: math.MaxUint64})
+	if report.Status != HealthCrit || report.Status.ExitCode() != 2 {
+		t.Errorf("Health() with a full disk = %+v, want CRIT", report)
+	}
+}
@@ -0,0 +1,91 @@
+package badger
+
+import (
+	"sync"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// BeforeSaveFn is called with the items before Save stores them. Returning an error aborts the Save, which
+// returns it.
+type BeforeSaveFn func(it vocab.Item) error
+
+// AfterFn is called with the changes made to the repository, after they're stored. The col is empty,
+// besides for the AddTo changes.
+type AfterFn func(col vocab.IRI, it vocab.Item)
+
+// hooks are the functions registered on the repository, which are called synchronously, in the order of their
+// registration, by the goroutines making the changes.
+type hooks struct {
+	mu         sync.RWMutex
+	beforeSave []BeforeSaveFn
+	after      map[MirrorOp][]AfterFn
+}
+
+func (h *hooks) addAfter(op MirrorOp, fn AfterFn) {
+	if h == nil || fn == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.after == nil {
+		h.after = make(map[MirrorOp][]AfterFn)
+	}
+	h.after[op] = append(h.after[op], fn)
+}
+
+// runBeforeSave returns the error of the first BeforeSave function failing for the item.
+func (h *hooks) runBeforeSave(it vocab.Item) error {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	fns := h.beforeSave
+	h.mu.RUnlock()
+	for _, fn := range fns {
+		if err := fn(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *hooks) runAfter(op MirrorOp, col vocab.IRI, it vocab.Item) {
+	if h == nil || vocab.IsNil(it) {
+		return
+	}
+	h.mu.RLock()
+	fns := h.after[op]
+	h.mu.RUnlock()
+	for _, fn := range fns {
+		fn(col, it)
+	}
+}
+
+// BeforeSave registers fn to be called with the items before they're stored by Save, and by the Save of the
+// Update transactions, for enforcing policies, like spam checks, or size quotas.
+func (r *repo) BeforeSave(fn BeforeSaveFn) {
+	if r.hooks == nil || fn == nil {
+		return
+	}
+	r.hooks.mu.Lock()
+	defer r.hooks.mu.Unlock()
+	r.hooks.beforeSave = append(r.hooks.beforeSave, fn)
+}
+
+// AfterSave registers fn to be called with the items stored by Save, and by the Update transactions,
+// after they're committed.
+func (r *repo) AfterSave(fn AfterFn) {
+	r.hooks.addAfter(MirrorSave, fn)
+}
+
+// AfterDelete registers fn to be called with the items removed by Delete, and with the ones removed together
+// with them.
+func (r *repo) AfterDelete(fn AfterFn) {
+	r.hooks.addAfter(MirrorDelete, fn)
+}
+
+// AfterAddTo registers fn to be called with the collections, and the items added to them by AddTo.
+func (r *repo) AfterAddTo(fn AfterFn) {
+	r.hooks.addAfter(MirrorAddTo, fn)
+}
@@ -0,0 +1,69 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_hooks(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.hooks = new(hooks)
+
+	spam := errors.Forbiddenf("spam")
+	r.BeforeSave(func(it vocab.Item) error {
+		if it.GetType() == vocab.PageType {
+			return spam
+		}
+		return nil
+	})
+	calls := make([]string, 0)
+	r.AfterSave(func(_ vocab.IRI, it vocab.Item) {
+		calls = append(calls, "save "+it.GetLink().String())
+	})
+	r.AfterAddTo(func(col vocab.IRI, it vocab.Item) {
+		calls = append(calls, "add "+it.GetLink().String()+" to "+col.String())
+	})
+	r.AfterDelete(func(_ vocab.IRI, it vocab.Item) {
+		calls = append(calls, "delete "+it.GetLink().String())
+	})
+
+	page := vocab.ObjectNew(vocab.PageType)
+	page.ID = "https://example.com/objects/0"
+	if _, err = r.Save(page); err != spam {
+		t.Errorf("Save() rejected by BeforeSave error = %v, want %s", err, spam)
+	}
+	if _, err = r.Load(page.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() of the rejected item error = %v, want NotFound", err)
+	}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	col := vocab.IRI("https://example.com/~jdoe/outbox")
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	if err = r.AddTo(col, note); err != nil {
+		t.Fatalf("AddTo() error = %s", err)
+	}
+	if err = r.Delete(note); err != nil {
+		t.Fatalf("Delete() error = %s", err)
+	}
+	want := []string{
+		"save https://example.com/objects/1",
+		"add https://example.com/objects/1 to https://example.com/~jdoe/outbox",
+		"delete https://example.com/objects/1",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("the hooks were called for %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("hook call #%d = %s, want %s", i, calls[i], want[i])
+		}
+	}
+}
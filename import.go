@@ -0,0 +1,207 @@
+package badger
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// importProgressInterval is the number of keys copied between the calls of the progress function of an import.
+const importProgressInterval = 1000
+
+// MigrationOptions configures the imports of the data of the other storage backends.
+type MigrationOptions struct {
+	// DryRun reads the other storage and counts the keys which would be copied, without writing anything.
+	DryRun bool
+	// ProgressFn is called every thousand keys, and once more at the end, with the report of the import so far.
+	ProgressFn func(MigrationReport)
+	// CheckpointFile is the path of the file storing the position reached by a resumable import, instead of
+	// the database. It gets removed when the import completes.
+	CheckpointFile string
+	// Source names the imported data in the key of the checkpoint stored in the database, so the imports of
+	// different sources don't resume from each other's checkpoints. When empty, the storage-fs directories and
+	// the boltdb files are named by their path, while the imports of the other sources of the same kind, like
+	// all the dumps, share their checkpoint.
+	Source string
+}
+
+// MigrationReport counts the keys found during an import.
+type MigrationReport struct {
+	// Keys is the number of keys copied, including the ones of the objects, their metadata and the OAuth data.
+	Keys int
+	// Objects is the number of objects and collections copied.
+	Objects int
+	// Indexed is the number of objects added to the filter indexes after copying.
+	Indexed int
+}
+
+// The positions reached by the resumable imports are stored in __import_checkpoint/<kind>/<source> keys, which
+// get removed when the imports complete.
+const importCheckpointKey = "__import_checkpoint"
+
+func getImportCheckpointKey(kind, source string) []byte {
+	k := []byte(importCheckpointKey + string(sep) + kind)
+	if source == "" {
+		return k
+	}
+	return append(append(k, sep...), source...)
+}
+
+// importCheckpointInterval is the number of records of a dump, an archive or a storage-fs directory imported
+// between two checkpoints.
+const importCheckpointInterval = 1000
+
+// importer copies into badger the keys read from another storage, which uses the same paths for the same data.
+type importer struct {
+	r             *repo
+	opt           MigrationOptions
+	report        MigrationReport
+	b             writeBatch
+	checkpointKey []byte
+	checkpointed  bool
+}
+
+// newImporter opens the database for writing the imported keys, unless the import is a dry run.
+// The kind of the import, and its source, when the MigrationOptions don't name it, make the key of its checkpoint.
+func (r *repo) newImporter(kind, source string, opt MigrationOptions) (*importer, error) {
+	if opt.Source != "" {
+		source = opt.Source
+	}
+	i := importer{r: r, opt: opt, checkpointKey: getImportCheckpointKey(kind, source)}
+	if opt.DryRun {
+		return &i, nil
+	}
+	if err := r.Open(); err != nil {
+		return nil, err
+	}
+	i.b = r.newWriteBatch()
+	return &i, nil
+}
+
+func (i *importer) progress() {
+	if i.opt.ProgressFn != nil {
+		i.opt.ProgressFn(i.report)
+	}
+}
+
+// set copies the value at key k, and for the objects, creates their type key too.
+func (i *importer) set(k, v []byte) error {
+	i.report.Keys++
+	if isObjectKey(k) {
+		i.report.Objects++
+	}
+	if i.report.Keys%importProgressInterval == 0 {
+		i.progress()
+	}
+	if i.b == nil {
+		return nil
+	}
+	if err := i.b.Set(append([]byte{}, k...), append([]byte{}, v...)); err != nil {
+		return errors.Annotatef(err, "unable to copy %s", k)
+	}
+	if !isObjectKey(k) {
+		return nil
+	}
+	it, err := loadItem(v)
+	if err != nil || vocab.IsNil(it) {
+		return nil
+	}
+	return setTypeKey(i.b, bytes.TrimSuffix(k, append(sep, objectKey...)), nil, it)
+}
+
+// resumePosition returns the position stored by the last checkpoint of an import which didn't complete.
+func (i *importer) resumePosition() []byte {
+	if i.b == nil {
+		return nil
+	}
+	var pos []byte
+	if i.opt.CheckpointFile != "" {
+		pos, _ = os.ReadFile(i.opt.CheckpointFile)
+		i.checkpointed = len(pos) > 0
+		return pos
+	}
+	_ = i.r.d.View(func(tx *badger.Txn) error {
+		it, err := tx.Get(i.checkpointKey)
+		if err != nil {
+			return err
+		}
+		pos, err = it.ValueCopy(nil)
+		return err
+	})
+	// NOTE(marius): the checkpoint of a resumed import needs removing when it completes.
+	i.checkpointed = len(pos) > 0
+	return pos
+}
+
+// checkpoint writes the keys copied so far, and then stores pos, so an interrupted import can be resumed from it.
+func (i *importer) checkpoint(pos []byte) error {
+	if i.b == nil {
+		return nil
+	}
+	if err := i.b.Flush(); err != nil {
+		return err
+	}
+	i.b = i.r.newWriteBatch()
+	i.checkpointed = true
+	if i.opt.CheckpointFile != "" {
+		return os.WriteFile(i.opt.CheckpointFile, pos, 0o600)
+	}
+	return i.r.update(func(tx dbTxn) error {
+		return tx.Set(i.checkpointKey, pos)
+	})
+}
+
+func (i *importer) removeCheckpoint() error {
+	if i.opt.CheckpointFile != "" {
+		return os.Remove(i.opt.CheckpointFile)
+	}
+	return i.r.update(func(tx dbTxn) error {
+		return tx.Delete(i.checkpointKey)
+	})
+}
+
+// recordCheckpoint stores the number of records of a sequential import read so far, after every
+// importCheckpointInterval of them.
+func (i *importer) recordCheckpoint(read int) error {
+	if read%importCheckpointInterval != 0 {
+		return nil
+	}
+	return i.checkpoint([]byte(strconv.Itoa(read)))
+}
+
+// resumeRecords returns the number of records of a sequential import which were copied before its last checkpoint.
+func (i *importer) resumeRecords() int {
+	n, _ := strconv.Atoi(string(i.resumePosition()))
+	return n
+}
+
+// finish writes the keys copied when the import succeeded, rebuilds the filter indexes, the collection cursors
+// and the membership keys for them, and clears the caches, which can't tell which of the keys were replaced.
+func (i *importer) finish(err error) (MigrationReport, error) {
+	if i.b != nil {
+		if err == nil {
+			err = i.b.Flush()
+		} else {
+			i.b.Cancel()
+		}
+		if err == nil && i.checkpointed {
+			err = i.removeCheckpoint()
+		}
+		i.r.Close()
+	}
+	if err != nil {
+		return i.report, err
+	}
+	if !i.opt.DryRun {
+		i.r.clearCaches()
+		if i.report.Indexed, err = i.r.ReindexAll(); err != nil {
+			return i.report, errors.Annotatef(err, "unable to rebuild the indexes")
+		}
+	}
+	i.progress()
+	return i.report, nil
+}
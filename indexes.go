@@ -0,0 +1,513 @@
+package badger
+
+import (
+	"bytes"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// Indexer is a secondary index over the stored objects, which is maintained on every Save and Delete,
+// and which Load consults to avoid iterating over all the items of a storage collection.
+type Indexer interface {
+	// Name identifies the index, and it's used as part of its storage keys.
+	Name() string
+	// Values returns the values under which the item gets indexed.
+	Values(it vocab.Item) []string
+	// Lookup returns the ranges of index values that can satisfy the filter.
+	// When the index can not be used to answer the filter it returns false.
+	Lookup(f *filters.Filters) ([]ValueRange, bool)
+}
+
+// ValueRange is an interval of index values. Start is inclusive, End is exclusive.
+// A range with an empty End matches only the Start value.
+type ValueRange struct {
+	Start string
+	End   string
+}
+
+// DefaultIndexes is the list of indexes that get maintained when the Config doesn't specify one.
+var DefaultIndexes = []Indexer{
+	TypeIndex{},
+	ActorIndex{},
+	RecipientIndex{},
+	PublicIndex{},
+	TagIndex{},
+	InReplyToIndex{},
+	PublishedIndex{},
+	UpdatedIndex{},
+	ActorNameIndex{},
+}
+
+// indexKey is the prefix for the index keys, which have the form: __index/<name>/<value>\x00<item path>
+const indexKey = "__index"
+
+// indexValueSep separates the indexed value from the path of the object in an index key.
+const indexValueSep = 0x00
+
+func getIndexPrefix(name string) []byte {
+	return []byte(indexKey + string(sep) + name + string(sep))
+}
+
+func getIndexKey(name, value string, p []byte) []byte {
+	k := append(getIndexPrefix(name), value...)
+	k = append(k, indexValueSep)
+	return append(k, p...)
+}
+
+// indexKeyPath returns the object path encoded in an index key.
+func indexKeyPath(k []byte) []byte {
+	i := bytes.IndexByte(k, indexValueSep)
+	if i < 0 {
+		return nil
+	}
+	return k[i+1:]
+}
+
+// updateIndexes stores the index keys for the item, removing the ones corresponding to the old version
+// which are no longer valid. Any of old and it can be nil, for new or deleted objects.
+func updateIndexes(b keySetter, indexes []Indexer, p []byte, old, it vocab.Item) error {
+	for _, idx := range indexes {
+		var oldValues, newValues []string
+		if !vocab.IsNil(old) {
+			oldValues = idx.Values(old)
+		}
+		if !vocab.IsNil(it) {
+			newValues = idx.Values(it)
+		}
+		for _, v := range oldValues {
+			if stringsContain(newValues, v) {
+				continue
+			}
+			if err := b.Delete(getIndexKey(idx.Name(), v, p)); err != nil {
+				return errors.Annotatef(err, "unable to remove %s index value for %s", idx.Name(), p)
+			}
+		}
+		for _, v := range newValues {
+			if err := b.Set(getIndexKey(idx.Name(), v, p), nil); err != nil {
+				return errors.Annotatef(err, "unable to set %s index value for %s", idx.Name(), p)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedPaths(found map[string]struct{}) [][]byte {
+	result := make([][]byte, 0, len(found))
+	for p := range found {
+		result = append(result, []byte(p))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return bytes.Compare(result[i], result[j]) < 0
+	})
+	return result
+}
+
+func hasIndex(indexes []Indexer, name string) bool {
+	return findIndex(indexes, name) != nil
+}
+
+// scanIndex returns the paths, direct children of the base path, found in the value ranges of the index.
+// An empty base path returns all the paths found.
+func scanIndex(tx dbTxn, name string, base []byte, ranges []ValueRange) map[string]struct{} {
+	paths := make(map[string]struct{})
+	prefix := getIndexPrefix(name)
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for _, rng := range ranges {
+		start := append(append([]byte{}, prefix...), rng.Start...)
+		var end []byte
+		if rng.End == "" {
+			start = append(start, indexValueSep)
+		} else {
+			end = append(append([]byte{}, prefix...), rng.End...)
+		}
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			k := it.Item().Key()
+			if end == nil && !bytes.HasPrefix(k, start) {
+				break
+			}
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+			p := indexKeyPath(k)
+			if len(base) > 0 && (!bytes.HasPrefix(p, append(append([]byte{}, base...), sep...)) || iterKeyIsTooDeep(base, p, 0)) {
+				continue
+			}
+			paths[string(p)] = struct{}{}
+		}
+	}
+	return paths
+}
+
+// ReindexAll removes all the index keys and rebuilds them from the objects in storage.
+// It returns the number of indexed objects.
+func (r *repo) ReindexAll() (int, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	prefixes := [][]byte{
+		[]byte(indexKey + string(sep)),
+		[]byte(cursorKey + string(sep)),
+		[]byte(cursorPosKey + string(sep)),
+		[]byte(memberOfKey + string(sep)),
+	}
+	if err = r.dropPrefix(prefixes...); err != nil {
+		return 0, errors.Annotatef(err, "unable to remove the existing indexes")
+	}
+
+	return r.rebuildIndexes(r.indexes, true)
+}
+
+// Reindex removes the index keys of the named indexes and rebuilds them from the objects in storage, which is
+// needed after enabling an index on an existing database. Without names, it behaves like ReindexAll.
+// It returns the number of indexed objects.
+func (r *repo) Reindex(names ...string) (int, error) {
+	if len(names) == 0 {
+		return r.ReindexAll()
+	}
+	indexes := make([]Indexer, 0, len(names))
+	prefixes := make([][]byte, 0, len(names))
+	for _, name := range names {
+		idx := findIndex(r.indexes, name)
+		if idx == nil {
+			return 0, errors.NotValidf("the %q index is not enabled", name)
+		}
+		indexes = append(indexes, idx)
+		prefixes = append(prefixes, getIndexPrefix(name))
+	}
+
+	err := r.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	if err = r.dropPrefix(prefixes...); err != nil {
+		return 0, errors.Annotatef(err, "unable to remove the existing indexes")
+	}
+	return r.rebuildIndexes(indexes, false)
+}
+
+func findIndex(indexes []Indexer, name string) Indexer {
+	for _, idx := range indexes {
+		if idx.Name() == name {
+			return idx
+		}
+	}
+	return nil
+}
+
+// rebuildIndexes adds the index keys of the stored objects for the indexes, and when collections is set,
+// the cursor and the membership keys of the stored collections. The database needs to be open.
+func (r *repo) rebuildIndexes(indexes []Indexer, collections bool) (int, error) {
+	count := 0
+	b := r.newWriteBatch()
+	err := r.d.View(func(tx *badger.Txn) error {
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			i := it.Item()
+			k := i.Key()
+			if !isObjectKey(k) {
+				continue
+			}
+			p := bytes.TrimSuffix(i.KeyCopy(nil), append(sep, objectKey...))
+			err := i.Value(func(raw []byte) error {
+				ob, err := loadItem(raw)
+				if err != nil || vocab.IsNil(ob) {
+					return err
+				}
+				if iris, ok := collectionIRIs(ob); ok {
+					if !collections {
+						return nil
+					}
+					// NOTE(marius): the collections without a parent object get only their cursor keys.
+					col, colErr := collectionIRI(tx, p)
+					for i, iri := range iris {
+						if err = setCursorKeys(b, p, uint64(i+1), iri); err != nil {
+							return err
+						}
+						if colErr != nil {
+							continue
+						}
+						if err = setMemberOfKey(b, col, iri); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+				if !ob.IsObject() {
+					return nil
+				}
+				if err = updateIndexes(b, indexes, p, nil, ob); err != nil {
+					return err
+				}
+				count++
+				return nil
+			})
+			if err != nil {
+				r.errFn("unable to index %s: %+s", k, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Cancel()
+		return 0, err
+	}
+	if err = b.Flush(); err != nil {
+		return 0, err
+	}
+	r.logFn("Indexed %d objects", count)
+	return count, nil
+}
+
+// TypeIndex indexes objects by their type. Tombstones are indexed by their former type as well.
+type TypeIndex struct{}
+
+func (TypeIndex) Name() string {
+	return "type"
+}
+
+func (TypeIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0, 2)
+	if typ := it.GetType(); len(typ) > 0 {
+		values = append(values, string(typ))
+	}
+	if it.GetType() == vocab.TombstoneType {
+		_ = vocab.OnTombstone(it, func(t *vocab.Tombstone) error {
+			if len(t.FormerType) > 0 && t.FormerType != vocab.TombstoneType {
+				values = append(values, string(t.FormerType))
+			}
+			return nil
+		})
+	}
+	return values
+}
+
+func (TypeIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	types := typesFilter(f)
+	if len(types) == 0 {
+		return nil, false
+	}
+	return exactValues(types...), true
+}
+
+// ActorIndex indexes activities by their actor.
+type ActorIndex struct{}
+
+func (ActorIndex) Name() string {
+	return "actor"
+}
+
+func (ActorIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0)
+	if !vocab.ActivityTypes.Contains(it.GetType()) && !vocab.IntransitiveActivityTypes.Contains(it.GetType()) {
+		return values
+	}
+	_ = vocab.OnIntransitiveActivity(it, func(a *vocab.IntransitiveActivity) error {
+		if !vocab.IsNil(a.Actor) {
+			values = append(values, a.Actor.GetLink().String())
+		}
+		return nil
+	})
+	return values
+}
+
+func (ActorIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	if f.Actor == nil || len(f.Actor.Key) == 0 {
+		return nil, false
+	}
+	values := make([]string, 0, len(f.Actor.Key))
+	for _, k := range f.Actor.Key {
+		if !isAbsoluteIRI(k.String()) {
+			return nil, false
+		}
+		values = append(values, k.String())
+	}
+	return exactValues(values...), true
+}
+
+// TagIndex indexes objects by the IRIs and the lower-cased names of their tags.
+type TagIndex struct{}
+
+func (TagIndex) Name() string {
+	return "tag"
+}
+
+func (TagIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0)
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		for _, tag := range o.Tag {
+			if vocab.IsNil(tag) {
+				continue
+			}
+			if v := tag.GetLink().String(); len(v) > 0 && !stringsContain(values, v) {
+				values = append(values, v)
+			}
+			if vocab.IsIRI(tag) {
+				continue
+			}
+			appendNames := func(names vocab.NaturalLanguageValues) {
+				for _, n := range names {
+					if v := strings.ToLower(n.String()); len(v) > 0 && !stringsContain(values, v) {
+						values = append(values, v)
+					}
+				}
+			}
+			if vocab.LinkTypes.Contains(tag.GetType()) {
+				_ = vocab.OnLink(tag, func(l *vocab.Link) error {
+					appendNames(l.Name)
+					return nil
+				})
+				continue
+			}
+			_ = vocab.OnObject(tag, func(t *vocab.Object) error {
+				appendNames(t.Name)
+				return nil
+			})
+		}
+		return nil
+	})
+	return values
+}
+
+func (TagIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	if f.Tag == nil {
+		return nil, false
+	}
+	values, ok := equalityValues(f.Tag.Name)
+	if !ok {
+		return nil, false
+	}
+	for i, v := range values {
+		values[i] = strings.ToLower(v)
+	}
+	return exactValues(values...), true
+}
+
+// InReplyToIndex indexes objects by the objects they are replies to.
+type InReplyToIndex struct{}
+
+func (InReplyToIndex) Name() string {
+	return "inReplyTo"
+}
+
+func (InReplyToIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0)
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		if vocab.IsNil(o.InReplyTo) {
+			return nil
+		}
+		if o.InReplyTo.IsCollection() {
+			return vocab.OnCollectionIntf(o.InReplyTo, func(col vocab.CollectionInterface) error {
+				for _, r := range col.Collection() {
+					values = append(values, r.GetLink().String())
+				}
+				return nil
+			})
+		}
+		values = append(values, o.InReplyTo.GetLink().String())
+		return nil
+	})
+	return values
+}
+
+func (InReplyToIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	values, ok := equalityValues(f.InReplTo)
+	if !ok {
+		return nil, false
+	}
+	for _, v := range values {
+		if !isAbsoluteIRI(v) {
+			return nil, false
+		}
+	}
+	return exactValues(values...), true
+}
+
+// PublishedIndex indexes objects by their published time, in a format that sorts chronologically.
+// It answers the NewerThan and OlderThan filters with range scans.
+type PublishedIndex struct{}
+
+const publishedIndexFormat = "2006-01-02T15:04:05.000000000Z"
+
+func (PublishedIndex) Name() string {
+	return "published"
+}
+
+func (PublishedIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0, 1)
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		if !o.Published.IsZero() {
+			values = append(values, o.Published.UTC().Format(publishedIndexFormat))
+		}
+		return nil
+	})
+	return values
+}
+
+func (PublishedIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	if f.NewerThan.IsZero() && f.OlderThan.IsZero() {
+		return nil, false
+	}
+	rng := ValueRange{Start: time.Time{}.Format(publishedIndexFormat), End: "\xff"}
+	if !f.NewerThan.IsZero() {
+		rng.Start = f.NewerThan.UTC().Format(publishedIndexFormat)
+	}
+	if !f.OlderThan.IsZero() {
+		rng.End = f.OlderThan.UTC().Format(publishedIndexFormat)
+	}
+	return []ValueRange{rng}, true
+}
+
+func exactValues[T ~string](values ...T) []ValueRange {
+	ranges := make([]ValueRange, 0, len(values))
+	for _, v := range values {
+		ranges = append(ranges, ValueRange{Start: string(v)})
+	}
+	return ranges
+}
+
+// equalityValues returns the values of the filters, when all of them are simple equality checks.
+func equalityValues(ff filters.CompStrs) ([]string, bool) {
+	if len(ff) == 0 {
+		return nil, false
+	}
+	values := make([]string, 0, len(ff))
+	for _, f := range ff {
+		if (f.Operator != "" && f.Operator != "=") || len(f.Str) == 0 {
+			return nil, false
+		}
+		values = append(values, f.Str)
+	}
+	return values, true
+}
+
+func isAbsoluteIRI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func stringsContain(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
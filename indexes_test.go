@@ -0,0 +1,142 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func loadIRIsFromIndexes(t *testing.T, r *repo, iri vocab.IRI) vocab.IRIs {
+	res, err := r.Load(iri)
+	if err != nil {
+		t.Fatalf("Load(%s) error = %s", iri, err)
+	}
+	iris := make(vocab.IRIs, 0)
+	_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		for _, it := range col.Collection() {
+			iris = append(iris, it.GetLink())
+		}
+		return nil
+	})
+	return iris
+}
+
+func Test_repo_Load_Indexes(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+
+	op := vocab.ObjectNew(vocab.NoteType)
+	op.ID = "http://example.com/objects/1"
+	op.To = vocab.ItemCollection{vocab.PublicNS}
+	op.Published = time.Now().Add(-time.Hour).UTC()
+
+	reply := vocab.ObjectNew(vocab.NoteType)
+	reply.ID = "http://example.com/objects/2"
+	reply.InReplyTo = op.ID
+	reply.To = vocab.ItemCollection{vocab.IRI("http://example.com/actors/jdoe")}
+	reply.Published = time.Now().UTC()
+
+	article := vocab.ObjectNew(vocab.ArticleType)
+	article.ID = "http://example.com/objects/3"
+	article.To = vocab.ItemCollection{vocab.PublicNS}
+
+	for _, ob := range []*vocab.Object{op, reply, article} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+
+	if iris := loadIRIsFromIndexes(t, r, "http://example.com/objects?type=Note"); len(iris) != 2 || iris.Contains(article.ID) {
+		t.Errorf("Load() by type = %v, want %s and %s", iris, op.ID, reply.ID)
+	}
+	if iris := loadIRIsFromIndexes(t, r, "http://example.com/objects?inReplyTo=http://example.com/objects/1"); len(iris) != 1 || !iris.Contains(reply.ID) {
+		t.Errorf("Load() by inReplyTo = %v, want only %s", iris, reply.ID)
+	}
+	if iris := loadIRIsFromIndexes(t, r, "http://example.com/objects?type=Note&recipients=http://example.com/actors/jdoe"); len(iris) != 2 {
+		t.Errorf("Load() by recipient = %v, want %s and the public %s", iris, reply.ID, op.ID)
+	}
+
+	reply.InReplyTo = nil
+	if _, err = r.Save(reply); err != nil {
+		t.Fatalf("unable to save %s: %s", reply.ID, err)
+	}
+	if iris := loadIRIsFromIndexes(t, r, "http://example.com/objects?inReplyTo=http://example.com/objects/1"); len(iris) != 0 {
+		t.Errorf("Load() by stale inReplyTo = %v, want none", iris)
+	}
+
+	if err = r.Delete(article); err != nil {
+		t.Fatalf("unable to delete %s: %s", article.ID, err)
+	}
+	_ = r.Open()
+	_ = r.d.View(func(tx *badger.Txn) error {
+		paths := scanIndex(tx, TypeIndex{}.Name(), []byte("example.com/objects"), exactValues(vocab.ArticleType))
+		if len(paths) != 0 {
+			t.Errorf("index keys were not removed for deleted object: %v", paths)
+		}
+		return nil
+	})
+	r.Close()
+}
+
+func Test_repo_ReindexAll(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+
+	r.indexes = DefaultIndexes
+	count, err := r.ReindexAll()
+	if err != nil {
+		t.Fatalf("ReindexAll() error = %s", err)
+	}
+	if count != 1 {
+		t.Errorf("ReindexAll() = %d, want 1", count)
+	}
+	if iris := loadIRIsFromIndexes(t, r, "http://example.com/objects?type=Note"); len(iris) != 1 || !iris.Contains(note.ID) {
+		t.Errorf("Load() after reindexing = %v, want only %s", iris, note.ID)
+	}
+}
+
+func Test_repo_Reindex(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	note.Published = time.Now().UTC()
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+
+	r.indexes = DefaultIndexes
+	count, err := r.Reindex("type")
+	if err != nil {
+		t.Fatalf("Reindex() error = %s", err)
+	}
+	if count != 1 {
+		t.Errorf("Reindex() = %d, want 1", count)
+	}
+	if keys, _ := r.Keys(string(getIndexPrefix("type")), 0); len(keys) != 1 {
+		t.Errorf("Reindex() type index keys = %v, want 1", keys)
+	}
+	if keys, _ := r.Keys(string(getIndexPrefix("published")), 0); len(keys) != 0 {
+		t.Errorf("Reindex() of the type index created published index keys %v", keys)
+	}
+	if _, err = r.Reindex("unknown"); !errors.IsNotValid(err) {
+		t.Errorf("Reindex() of an unknown index error = %v, want NotValid", err)
+	}
+}
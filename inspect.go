@@ -0,0 +1,181 @@
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// The functions in this file are the ones used by the storage inspection commands of the control CLI,
+// for debugging the contents of the storage.
+
+// KeyInfo is a key and its stored value.
+type KeyInfo struct {
+	Key string
+	Raw []byte
+	// Item is the decoded value, for the keys of the objects and the collections.
+	Item vocab.Item
+}
+
+// CollectionInfo is a collection stored under the path of an actor, with its number of items.
+type CollectionInfo struct {
+	IRI   vocab.IRI
+	Key   string
+	Count int
+}
+
+// Inspect returns the raw and the decoded values stored for the object, or the collection, at the IRI,
+// without dereferencing any of their properties.
+func (r *repo) Inspect(iri vocab.IRI) (KeyInfo, error) {
+	info := KeyInfo{Key: string(getObjectKey(itemPath(iri)))}
+	err := r.Open()
+	if err != nil {
+		return info, err
+	}
+	defer r.Close()
+
+	err = r.d.View(func(tx *badger.Txn) error {
+		i, err := tx.Get([]byte(info.Key))
+		if err != nil {
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load key %s", info.Key)
+		}
+		if info.Raw, err = i.ValueCopy(nil); err != nil {
+			return err
+		}
+		info.Item, err = decodeItemFn(info.Raw)
+		return err
+	})
+	return info, err
+}
+
+// Keys returns the keys starting with prefix, in order, including the internal ones. When limit is positive,
+// at most limit keys are returned.
+func (r *repo) Keys(prefix string, limit int) ([]string, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	keys := make([]string, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = []byte(prefix)
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(opt.Prefix); it.ValidForPrefix(opt.Prefix); it.Next() {
+			if limit > 0 && len(keys) == limit {
+				break
+			}
+			keys = append(keys, string(it.Item().Key()))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// KeyFilter selects the keys streamed by StreamKeys.
+type KeyFilter struct {
+	Prefix string
+	// Suffix is the suffix of the keys, like the __raw of the objects and the collections, or the __meta_data of
+	// the metadata.
+	Suffix string
+	// Sizes loads the sizes of the values, which are otherwise left at 0.
+	Sizes bool
+}
+
+// KeySize is a key streamed by StreamKeys, with the size of its value.
+type KeySize struct {
+	Key  string
+	Size int64
+}
+
+// StreamKeys calls fn for the keys matching the filter, in order, including the internal ones, without loading
+// their values. Returning an error from fn stops the iteration.
+func (r *repo) StreamKeys(f KeyFilter, fn func(KeySize) error) error {
+	if fn == nil {
+		return errors.NotValidf("nil stream function")
+	}
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = []byte(f.Prefix)
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(opt.Prefix); it.ValidForPrefix(opt.Prefix); it.Next() {
+			i := it.Item()
+			if !bytes.HasSuffix(i.Key(), []byte(f.Suffix)) {
+				continue
+			}
+			k := KeySize{Key: string(i.Key())}
+			if f.Sizes {
+				k.Size = i.ValueSize()
+			}
+			if err := fn(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Collections returns the collections stored under the path of the actor, like their inbox and their outbox,
+// in the order of their keys.
+func (r *repo) Collections(actor vocab.IRI) ([]CollectionInfo, error) {
+	p := itemPath(actor)
+	if len(p) == 0 {
+		return nil, errors.NotValidf("invalid actor IRI %s", actor)
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cols := make([]CollectionInfo, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if _, err := tx.Get(getObjectKey(p)); err != nil {
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "%s does not exist", actor)
+		}
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = append(append([]byte{}, p...), sep...)
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(opt.Prefix); it.ValidForPrefix(opt.Prefix); it.Next() {
+			i := it.Item()
+			k := i.KeyCopy(nil)
+			if !isObjectKey(k) {
+				continue
+			}
+			err := i.Value(func(raw []byte) error {
+				if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte{'['}) {
+					return nil
+				}
+				col, err := decodeItemFn(raw)
+				if err != nil {
+					return err
+				}
+				iris, _ := collectionIRIs(col)
+				colPath := bytes.TrimSuffix(k, append(sep, objectKey...))
+				// NOTE(marius): the collections without a parent object are listed without their IRI.
+				iri, _ := collectionIRI(tx, colPath)
+				cols = append(cols, CollectionInfo{IRI: iri, Key: string(k), Count: len(iris)})
+				return nil
+			})
+			if err != nil {
+				return errors.Annotatef(err, "unable to decode %s", k)
+			}
+		}
+		return nil
+	})
+	return cols, err
+}
@@ -0,0 +1,106 @@
+package badger
+
+import (
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_inspect(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+
+	info, err := r.Inspect(note.ID)
+	if err != nil {
+		t.Fatalf("Inspect() error = %s", err)
+	}
+	if info.Key != "example.com/objects/1/__raw" || len(info.Raw) == 0 || info.Item.GetLink() != note.ID {
+		t.Errorf("Inspect() = %+v, want the value of %s", info, note.ID)
+	}
+	if _, err = r.Inspect("https://example.com/objects/2"); !errors.IsNotFound(err) {
+		t.Errorf("Inspect() of a missing object error = %v, want NotFound", err)
+	}
+
+	keys, err := r.Keys("example.com/", 0)
+	if err != nil {
+		t.Fatalf("Keys() error = %s", err)
+	}
+	objects := make([]string, 0)
+	for _, k := range keys {
+		if strings.HasSuffix(k, objectKey) {
+			objects = append(objects, k)
+		}
+	}
+	want := []string{"example.com/actors/jdoe/__raw", "example.com/actors/jdoe/outbox/__raw", "example.com/objects/1/__raw"}
+	if strings.Join(objects, " ") != strings.Join(want, " ") {
+		t.Errorf("Keys() of the objects = %v, want %v", objects, want)
+	}
+	if keys, _ = r.Keys("__index/", 2); len(keys) != 2 {
+		t.Errorf("Keys() with a limit = %v, want 2 keys", keys)
+	}
+
+	cols, err := r.Collections(jdoe.ID)
+	if err != nil {
+		t.Fatalf("Collections() error = %s", err)
+	}
+	if len(cols) != 1 || cols[0].IRI != jdoe.Outbox.GetLink() || cols[0].Count != 1 {
+		t.Errorf("Collections() = %+v, want the outbox with 1 item", cols)
+	}
+	if _, err = r.Collections("https://example.com/actors/missing"); !errors.IsNotFound(err) {
+		t.Errorf("Collections() of a missing actor error = %v, want NotFound", err)
+	}
+}
+
+func Test_repo_StreamKeys(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+
+	keys := make([]KeySize, 0)
+	err = r.StreamKeys(KeyFilter{Prefix: "example.com/actors/", Suffix: objectKey, Sizes: true}, func(k KeySize) error {
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamKeys() error = %s", err)
+	}
+	if len(keys) != 2 || keys[0].Key != "example.com/actors/jdoe/__raw" || keys[1].Key != "example.com/actors/jdoe/outbox/__raw" {
+		t.Fatalf("StreamKeys() = %+v, want the keys of jdoe and of the outbox", keys)
+	}
+	if keys[0].Size == 0 {
+		t.Errorf("StreamKeys() = %+v, want the size of the value", keys[0])
+	}
+
+	stop := errors.Newf("stop")
+	count := 0
+	err = r.StreamKeys(KeyFilter{}, func(KeySize) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("StreamKeys() = %v after %d keys, want to stop after the first one", err, count)
+	}
+}
@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	vocab "github.com/go-ap/activitypub"
@@ -19,28 +21,39 @@ type (
 		Get(iri vocab.IRI) vocab.Item
 		Remove(iris ...vocab.IRI) bool
 	}
+	// Usage is the occupancy of a store. The limits which are not enforced are zero.
+	Usage struct {
+		Entries    int
+		Bytes      int64
+		MaxEntries int
+		MaxBytes   int64
+	}
 )
 
 func New(enabled bool) *store {
 	return &store{enabled: enabled, c: make(iriMap)}
 }
 
+// Get returns a copy of the item stored for iri, so the callers can't modify the stored one.
 func (r *store) Get(iri vocab.IRI) vocab.Item {
 	if r == nil || !r.enabled {
 		return nil
 	}
 	r.w.RLock()
-	defer r.w.RUnlock()
-	if it, ok := r.c[iri]; ok {
-		return it
+	it, ok := r.c[iri]
+	r.w.RUnlock()
+	if !ok {
+		return nil
 	}
-	return nil
+	return copyItem(it)
 }
 
+// Set stores a copy of it for iri, so the caller can keep modifying it.
 func (r *store) Set(iri vocab.IRI, it vocab.Item) {
 	if r == nil || !r.enabled {
 		return
 	}
+	it = copyItem(it)
 	r.w.Lock()
 	defer r.w.Unlock()
 	if r.c == nil {
@@ -49,10 +62,27 @@ func (r *store) Set(iri vocab.IRI, it vocab.Item) {
 	r.c[iri] = it
 }
 
+// Usage returns the number of items stored, and their approximate size, which is computed on each call.
+func (r *store) Usage() Usage {
+	if r == nil || !r.enabled {
+		return Usage{}
+	}
+	r.w.RLock()
+	defer r.w.RUnlock()
+	u := Usage{Entries: len(r.c)}
+	for _, it := range r.c {
+		u.Bytes += itemSize(it)
+	}
+	return u
+}
+
 func (r *store) Clear() {
 	if r == nil || !r.enabled {
 		return
 	}
+	r.w.Lock()
+	defer r.w.Unlock()
+	r.c = make(iriMap)
 }
 
 func (r *store) Remove(iris ...vocab.IRI) bool {
@@ -60,32 +90,75 @@ func (r *store) Remove(iris ...vocab.IRI) bool {
 		return true
 	}
 	if len(iris) == 0 {
+		r.w.Lock()
+		defer r.w.Unlock()
 		for key := range r.c {
 			delete(r.c, key)
 		}
 		return true
 	}
+	toInvalidate := invalidatedIRIs(iris)
+	r.w.Lock()
+	defer r.w.Unlock()
+	for key := range r.c {
+		if isInvalidated(key, toInvalidate) {
+			delete(r.c, key)
+		}
+	}
+	return true
+}
+
+// invalidatedIRIs returns the iris, together with the collections containing them, for which the cached entries
+// need to be removed.
+func invalidatedIRIs(iris []vocab.IRI) vocab.IRIs {
 	toInvalidate := vocab.IRIs(iris)
 	for _, iri := range iris {
 		if vocab.ValidCollectionIRI(iri) {
 			continue
 		}
-		c := vocab.IRI(filepath.Dir(iri.String()))
+		c := parentIRI(iri)
+		if isRootIRI(c) {
+			// NOTE(marius): the root contains all the cached IRIs, we don't want to invalidate everything
+			continue
+		}
 		if !toInvalidate.Contains(c) {
 			toInvalidate = append(toInvalidate, c)
 		}
 	}
-	r.w.Lock()
-	defer r.w.Unlock()
+	return toInvalidate
+}
+
+// isInvalidated returns whether the key is the one of an invalidated IRI, or of one of its children, or of its
+// loads with a query or with checks, without matching the siblings sharing its prefix (eg: "/objects/1" vs "/objects/10").
+func isInvalidated(key vocab.IRI, toInvalidate vocab.IRIs) bool {
+	k := key.String()
 	for _, iri := range toInvalidate {
-		for key := range r.c {
-			// TODO(marius): I need to play around with this a bit
-			if key.Contains(iri, false) {
-				delete(r.c, key)
-			}
+		s := iri.String()
+		if len(s) == 0 || len(k) < len(s) || !strings.EqualFold(k[:len(s)], s) {
+			continue
+		}
+		if rest := k[len(s):]; len(rest) == 0 || s[len(s)-1] == '/' || strings.ContainsRune("/?#;", rune(rest[0])) {
+			return true
 		}
 	}
-	return true
+	return false
+}
+
+// parentIRI returns the IRI having the last path segment of iri removed.
+func parentIRI(iri vocab.IRI) vocab.IRI {
+	u, err := iri.URL()
+	if err != nil {
+		return vocab.IRI(filepath.Dir(iri.String()))
+	}
+	u.Path = path.Dir(u.Path)
+	u.RawQuery = ""
+	u.Fragment = ""
+	return vocab.IRI(u.String())
+}
+
+func isRootIRI(iri vocab.IRI) bool {
+	u, err := iri.URL()
+	return err == nil && (u.Path == "" || u.Path == "/")
 }
 
 func removeAccum(toRemove *vocab.IRIs, iri vocab.IRI, col vocab.CollectionPath) {
@@ -151,8 +224,7 @@ func aggregateActivityIRIs(toRemove *vocab.IRIs, a *vocab.Activity, typ vocab.Co
 
 	withSideEffects := vocab.ActivityVocabularyTypes{vocab.UpdateType, vocab.UndoType, vocab.DeleteType}
 	if withSideEffects.Contains(a.GetType()) {
-		base := filepath.Dir(a.Object.GetLink().String())
-		*toRemove = append(*toRemove, vocab.IRI(base))
+		*toRemove = append(*toRemove, parentIRI(a.Object.GetLink()))
 		*toRemove = append(*toRemove, a.Object.GetLink())
 	}
 
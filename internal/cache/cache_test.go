@@ -13,13 +13,13 @@ func Test_reqCache_get(t *testing.T) {
 	}
 	tests := []struct {
 		name string
-		r    store
+		r    *store
 		args args
 		want vocab.Item
 	}{
 		{
 			name: "",
-			r:    store{},
+			r:    &store{},
 			args: args{},
 			want: nil,
 		},
@@ -39,14 +39,14 @@ func Test_reqCache_remove(t *testing.T) {
 	}
 	tests := []struct {
 		name      string
-		r         store
+		r         *store
 		args      args
 		want      bool
 		leftovers vocab.IRIs
 	}{
 		{
 			name: "simple",
-			r: store{
+			r: &store{
 				enabled: true,
 				c:       iriMap{vocab.IRI("example1"): &vocab.Object{ID: vocab.IRI("example1")}},
 			},
@@ -56,7 +56,7 @@ func Test_reqCache_remove(t *testing.T) {
 		},
 		{
 			name: "same_url",
-			r: store{
+			r: &store{
 				enabled: true,
 				c:       iriMap{vocab.IRI("http://example.com"): &vocab.Actor{ID: vocab.IRI("http://example.com")}},
 			},
@@ -66,7 +66,7 @@ func Test_reqCache_remove(t *testing.T) {
 		},
 		{
 			name: "different_urls",
-			r: store{
+			r: &store{
 				enabled: true,
 				c:       iriMap{vocab.IRI("http://example.com/inbox"): &vocab.Actor{ID: vocab.IRI("http://example.com")}},
 			},
@@ -76,7 +76,7 @@ func Test_reqCache_remove(t *testing.T) {
 		},
 		{
 			name: "with_replies",
-			r: store{
+			r: &store{
 				enabled: true,
 				c: iriMap{
 					vocab.IRI("http://example.com/elefant"): vocab.IRI("http://example.com/elefant"),
@@ -124,12 +124,12 @@ func Test_reqCache_set(t *testing.T) {
 	}
 	tests := []struct {
 		name string
-		r    store
+		r    *store
 		args args
 	}{
 		{
 			name: "",
-			r:    store{},
+			r:    &store{},
 			args: args{},
 		},
 	}
@@ -138,3 +138,36 @@ func Test_reqCache_set(t *testing.T) {
 		})
 	}
 }
+
+func Test_store_Clear(t *testing.T) {
+	r := New(true)
+	iri := vocab.IRI("http://example.com/objects/1")
+	r.Set(iri, vocab.ObjectNew(vocab.NoteType))
+	if r.Get(iri) == nil {
+		t.Fatalf("Get() returned nothing after Set()")
+	}
+	r.Clear()
+	if r.Get(iri) != nil {
+		t.Errorf("Get() returned an item after Clear()")
+	}
+}
+
+func Test_isInvalidated(t *testing.T) {
+	toInvalidate := vocab.IRIs{"http://example.com/objects/1"}
+	tests := []struct {
+		key  vocab.IRI
+		want bool
+	}{
+		{key: "http://example.com/objects/1", want: true},
+		{key: "http://example.com/objects/1/replies", want: true},
+		{key: "http://example.com/objects/1#checks=1f", want: true},
+		{key: "http://example.com/objects/1?type=Note", want: true},
+		{key: "http://example.com/objects/10", want: false},
+		{key: "http://example.com/objects", want: false},
+	}
+	for _, tt := range tests {
+		if got := isInvalidated(tt.key, toInvalidate); got != tt.want {
+			t.Errorf("isInvalidated(%s) = %t, want %t", tt.key, got, tt.want)
+		}
+	}
+}
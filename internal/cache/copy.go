@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"reflect"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// Copy returns a deep copy of it, which can be modified without changing it.
+func Copy(it vocab.Item) vocab.Item {
+	return copyItem(it)
+}
+
+// copyItem returns a deep copy of it, sharing no pointers, slices or maps with it.
+func copyItem(it vocab.Item) vocab.Item {
+	if vocab.IsNil(it) {
+		return it
+	}
+	v := reflect.ValueOf(it)
+	c := reflect.New(v.Type()).Elem()
+	deepCopy(c, v)
+	return c.Interface().(vocab.Item)
+}
+
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.New(src.Elem().Type()))
+		deepCopy(dst.Elem(), src.Elem())
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		c := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(c, src.Elem())
+		dst.Set(c)
+	case reflect.Struct:
+		// NOTE(marius): the unexported fields, like the ones of time.Time, can only be copied as they are.
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeSlice(src.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(reflect.MakeMapWithSize(src.Type(), src.Len()))
+		for _, k := range src.MapKeys() {
+			c := reflect.New(src.MapIndex(k).Type()).Elem()
+			deepCopy(c, src.MapIndex(k))
+			dst.SetMapIndex(k, c)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// itemSize returns an approximation of the memory used by it, for bounding the size of the caches.
+func itemSize(it vocab.Item) int64 {
+	if vocab.IsNil(it) {
+		return 0
+	}
+	return valueSize(reflect.ValueOf(it))
+}
+
+func valueSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		return int64(v.Type().Size()) + valueSize(v.Elem())
+	case reflect.Struct:
+		size := int64(0)
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				// NOTE(marius): the unexported fields, like the location of time.Time, are usually shared.
+				size += int64(v.Field(i).Type().Size())
+				continue
+			}
+			size += valueSize(v.Field(i))
+		}
+		return size
+	case reflect.Slice:
+		size := int64(v.Type().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += valueSize(v.Index(i))
+		}
+		return size
+	case reflect.Map:
+		size := int64(v.Type().Size())
+		for _, k := range v.MapKeys() {
+			size += valueSize(k) + valueSize(v.MapIndex(k))
+		}
+		return size
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	default:
+		return int64(v.Type().Size())
+	}
+}
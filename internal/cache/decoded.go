@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"hash/fnv"
+	"sync"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+type (
+	decodedEntry struct {
+		hash uint64
+		raw  []byte
+		it   vocab.Item
+	}
+	decoded struct {
+		size int
+		w    sync.Mutex
+		c    map[uint64]*list.Element
+		lru  *list.List
+		seen map[uint64]struct{}
+	}
+	CanStoreDecoded interface {
+		Get(raw []byte) vocab.Item
+		Set(raw []byte, it vocab.Item)
+		Clear()
+	}
+)
+
+// NewDecoded returns a store for at most size decoded items, keyed by their encoded value, so an item that
+// gets updated simply stops being found under its new value. Only the values which get decoded repeatedly
+// are stored, and the least recently used ones get evicted first. When size is not larger than zero,
+// nothing gets stored.
+func NewDecoded(size int) *decoded {
+	return &decoded{
+		size: size,
+		c:    make(map[uint64]*list.Element),
+		lru:  list.New(),
+		seen: make(map[uint64]struct{}),
+	}
+}
+
+func hashRaw(raw []byte) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(raw)
+	return h.Sum64()
+}
+
+// Get returns a copy of the item decoded from raw, which the caller can modify, or nil when it isn't stored.
+func (d *decoded) Get(raw []byte) vocab.Item {
+	if d == nil || d.size <= 0 {
+		return nil
+	}
+	h := hashRaw(raw)
+	d.w.Lock()
+	el, ok := d.c[h]
+	if !ok || !bytes.Equal(el.Value.(*decodedEntry).raw, raw) {
+		d.w.Unlock()
+		return nil
+	}
+	d.lru.MoveToFront(el)
+	it := el.Value.(*decodedEntry).it
+	d.w.Unlock()
+	return copyItem(it)
+}
+
+// Set stores a copy of the item decoded from raw, when raw has been seen before.
+func (d *decoded) Set(raw []byte, it vocab.Item) {
+	if d == nil || d.size <= 0 || vocab.IsNil(it) {
+		return
+	}
+	h := hashRaw(raw)
+	d.w.Lock()
+	defer d.w.Unlock()
+	if _, ok := d.c[h]; ok {
+		return
+	}
+	if _, ok := d.seen[h]; !ok {
+		if len(d.seen) >= 4*d.size {
+			d.seen = make(map[uint64]struct{})
+		}
+		d.seen[h] = struct{}{}
+		return
+	}
+	delete(d.seen, h)
+	entry := decodedEntry{hash: h, raw: append([]byte{}, raw...), it: copyItem(it)}
+	d.c[h] = d.lru.PushFront(&entry)
+	if d.lru.Len() > d.size {
+		last := d.lru.Back()
+		d.lru.Remove(last)
+		delete(d.c, last.Value.(*decodedEntry).hash)
+	}
+}
+
+// Clear removes all the decoded items.
+func (d *decoded) Clear() {
+	if d == nil {
+		return
+	}
+	d.w.Lock()
+	defer d.w.Unlock()
+	d.c = make(map[uint64]*list.Element)
+	d.lru.Init()
+	d.seen = make(map[uint64]struct{})
+}
@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_decoded(t *testing.T) {
+	d := NewDecoded(1)
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("John Doe"))
+	jdoe.Tag = vocab.ItemCollection{vocab.IRI("http://example.com/tags/1")}
+	raw := []byte(`{"id":"http://example.com/actors/jdoe"}`)
+
+	d.Set(raw, jdoe)
+	if d.Get(raw) != nil {
+		t.Fatalf("Get() returned an item decoded only once")
+	}
+	d.Set(raw, jdoe)
+	it := d.Get(raw)
+	if it == nil {
+		t.Fatalf("Get() returned nothing for an item decoded twice")
+	}
+
+	_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+		a.Name = nil
+		a.Tag[0] = vocab.IRI("http://example.com/tags/2")
+		return nil
+	})
+	if jdoe.Name.First().String() != "John Doe" || jdoe.Tag[0] != vocab.IRI("http://example.com/tags/1") {
+		t.Errorf("modifying the result of Get() modified the stored item")
+	}
+	again := d.Get(raw)
+	_ = vocab.OnActor(again, func(a *vocab.Actor) error {
+		if a.Name.First().String() != "John Doe" || a.Tag[0] != vocab.IRI("http://example.com/tags/1") {
+			t.Errorf("modifying the result of Get() modified the cached item")
+		}
+		return nil
+	})
+
+	other := []byte(`{"id":"http://example.com/actors/other"}`)
+	d.Set(other, vocab.PersonNew("http://example.com/actors/other"))
+	d.Set(other, vocab.PersonNew("http://example.com/actors/other"))
+	if d.Get(raw) != nil {
+		t.Errorf("Get() returned an evicted item")
+	}
+	if d.Get(other) == nil {
+		t.Errorf("Get() returned nothing for the most recent item")
+	}
+
+	d.Clear()
+	if d.Get(other) != nil {
+		t.Errorf("Get() returned an item after Clear()")
+	}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"sync"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// Embedded tracks the cached results in which items were embedded, as the dereferenced properties of other items,
+// so the results can be invalidated when the embedded items change.
+type Embedded struct {
+	w sync.Mutex
+	c map[vocab.IRI]map[vocab.IRI]struct{}
+}
+
+func NewEmbedded() *Embedded {
+	return &Embedded{c: make(map[vocab.IRI]map[vocab.IRI]struct{})}
+}
+
+// Add records that the result cached under key embeds the iris.
+func (e *Embedded) Add(key vocab.IRI, iris ...vocab.IRI) {
+	if e == nil || len(iris) == 0 {
+		return
+	}
+	e.w.Lock()
+	defer e.w.Unlock()
+	for _, iri := range iris {
+		keys, ok := e.c[iri]
+		if !ok {
+			keys = make(map[vocab.IRI]struct{})
+			e.c[iri] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+// Take returns the keys of the results which embed iri, and forgets them.
+func (e *Embedded) Take(iri vocab.IRI) vocab.IRIs {
+	if e == nil {
+		return nil
+	}
+	e.w.Lock()
+	defer e.w.Unlock()
+	keys := make(vocab.IRIs, 0, len(e.c[iri]))
+	for key := range e.c[iri] {
+		keys = append(keys, key)
+	}
+	delete(e.c, iri)
+	return keys
+}
+
+// Clear forgets all the results.
+func (e *Embedded) Clear() {
+	if e == nil {
+		return
+	}
+	e.w.Lock()
+	defer e.w.Unlock()
+	e.c = make(map[vocab.IRI]map[vocab.IRI]struct{})
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// expiringPruneSize is the number of tracked entries from which the expired ones get forgotten when adding new ones.
+const expiringPruneSize = 1024
+
+type (
+	// TTLFn returns the time for which the item it, stored for iri, is kept. When not larger than zero,
+	// the item is kept until removed.
+	TTLFn    func(iri vocab.IRI, it vocab.Item) time.Duration
+	expiring struct {
+		CanStore
+		ttl TTLFn
+		w   sync.Mutex
+		exp map[vocab.IRI]time.Time
+		now func() time.Time
+	}
+)
+
+// NewExpiring returns a store which wraps c, and for which the items expire after the time returned by ttl for them.
+// The expired items are not removed from c when requested, as that would also remove the collections containing them,
+// they get replaced when loaded again, evicted by c, or removed together once many expiration times are tracked.
+func NewExpiring(c CanStore, ttl TTLFn) *expiring {
+	return &expiring{CanStore: c, ttl: ttl, exp: make(map[vocab.IRI]time.Time), now: time.Now}
+}
+
+// Get returns a copy of the item stored for iri, if it didn't expire.
+func (e *expiring) Get(iri vocab.IRI) vocab.Item {
+	if e == nil {
+		return nil
+	}
+	e.w.Lock()
+	exp, ok := e.exp[iri]
+	e.w.Unlock()
+	if ok && !e.now().Before(exp) {
+		return nil
+	}
+	return e.CanStore.Get(iri)
+}
+
+func (e *expiring) Set(iri vocab.IRI, it vocab.Item) {
+	if e == nil {
+		return
+	}
+	ttl := e.ttl(iri, it)
+	expired := make(vocab.IRIs, 0)
+	e.w.Lock()
+	now := e.now()
+	if len(e.exp) >= expiringPruneSize {
+		// NOTE(marius): the expiration times can be forgotten only together with the expired items,
+		// otherwise they would be served again from the wrapped store.
+		for key, exp := range e.exp {
+			if !now.Before(exp) {
+				expired = append(expired, key)
+				delete(e.exp, key)
+			}
+		}
+	}
+	if ttl > 0 {
+		e.exp[iri] = now.Add(ttl)
+	} else {
+		delete(e.exp, iri)
+	}
+	e.w.Unlock()
+	if len(expired) > 0 {
+		e.CanStore.Remove(expired...)
+	}
+	e.CanStore.Set(iri, it)
+}
+
+// Remove removes the items stored for the iris from the wrapped store, or all of them when called without any.
+func (e *expiring) Remove(iris ...vocab.IRI) bool {
+	if e == nil {
+		return true
+	}
+	if len(iris) == 0 {
+		e.w.Lock()
+		e.exp = make(map[vocab.IRI]time.Time)
+		e.w.Unlock()
+	}
+	return e.CanStore.Remove(iris...)
+}
+
+// Evictions returns the number of items evicted by the wrapped store, if it counts them.
+func (e *expiring) Evictions() uint64 {
+	if e == nil {
+		return 0
+	}
+	if ec, ok := e.CanStore.(interface{ Evictions() uint64 }); ok {
+		return ec.Evictions()
+	}
+	return 0
+}
+
+// Usage returns the occupancy of the wrapped store, if it reports it, including the expired items it still keeps.
+func (e *expiring) Usage() Usage {
+	if e == nil {
+		return Usage{}
+	}
+	if u, ok := e.CanStore.(interface{ Usage() Usage }); ok {
+		return u.Usage()
+	}
+	return Usage{}
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_expiring(t *testing.T) {
+	now := time.Now()
+	e := NewExpiring(New(true), func(_ vocab.IRI, it vocab.Item) time.Duration {
+		if it.IsCollection() {
+			return time.Minute
+		}
+		return 0
+	})
+	e.now = func() time.Time { return now }
+
+	colIRI := vocab.IRI("https://example.com/objects")
+	obIRI := vocab.IRI("https://example.com/actors/1")
+	e.Set(colIRI, vocab.ItemCollection{vocab.IRI("https://example.com/objects/1")})
+	e.Set(obIRI, vocab.ObjectNew(vocab.NoteType))
+	if e.Get(colIRI) == nil {
+		t.Errorf("Get() = nil for the collection before its ttl expired")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if e.Get(colIRI) != nil {
+		t.Errorf("Get() = %v for the collection after its ttl expired, want nil", e.Get(colIRI))
+	}
+	if e.Get(obIRI) == nil {
+		t.Errorf("Get() = nil for the object without a ttl")
+	}
+
+	e.Set(colIRI, vocab.ItemCollection{})
+	if e.Get(colIRI) == nil {
+		t.Errorf("Get() = nil for the collection after being stored again")
+	}
+}
+
+func Test_expiring_prune(t *testing.T) {
+	now := time.Now()
+	wrapped := New(true)
+	e := NewExpiring(wrapped, func(vocab.IRI, vocab.Item) time.Duration { return time.Minute })
+	e.now = func() time.Time { return now }
+
+	for i := 0; i < expiringPruneSize; i++ {
+		e.Set(vocab.IRI(fmt.Sprintf("https://example.com/objects/%d", i)), vocab.ObjectNew(vocab.NoteType))
+	}
+	now = now.Add(2 * time.Minute)
+	e.Set("https://example.com/actors/1", vocab.ObjectNew(vocab.NoteType))
+	if len(e.exp) != 1 {
+		t.Errorf("%d expiration times tracked after pruning, want 1", len(e.exp))
+	}
+	if len(wrapped.c) != 1 {
+		t.Errorf("%d items kept by the wrapped store after pruning, want 1", len(wrapped.c))
+	}
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+type (
+	lruEntry struct {
+		key  vocab.IRI
+		it   vocab.Item
+		size int64
+	}
+	lru struct {
+		maxEntries int
+		maxBytes   int64
+		bytes      int64
+		evictions  atomic.Uint64
+		w          sync.Mutex
+		c          map[vocab.IRI]*list.Element
+		l          *list.List
+	}
+)
+
+// NewLRU returns a store which keeps at most maxEntries items, using approximately at most maxBytes of memory,
+// evicting the least recently used ones first. A limit that is not larger than zero is not enforced.
+func NewLRU(maxEntries int, maxBytes int64) *lru {
+	return &lru{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		c:          make(map[vocab.IRI]*list.Element),
+		l:          list.New(),
+	}
+}
+
+// Get returns a copy of the item stored for iri, so the callers can't modify the stored one.
+func (r *lru) Get(iri vocab.IRI) vocab.Item {
+	if r == nil {
+		return nil
+	}
+	r.w.Lock()
+	el, ok := r.c[iri]
+	if !ok {
+		r.w.Unlock()
+		return nil
+	}
+	r.l.MoveToFront(el)
+	it := el.Value.(*lruEntry).it
+	r.w.Unlock()
+	return copyItem(it)
+}
+
+// Set stores a copy of it for iri, and evicts the least recently used items which exceed the limits.
+func (r *lru) Set(iri vocab.IRI, it vocab.Item) {
+	if r == nil {
+		return
+	}
+	entry := lruEntry{key: iri, it: copyItem(it), size: itemSize(it)}
+	if r.maxBytes > 0 && entry.size > r.maxBytes {
+		return
+	}
+	r.w.Lock()
+	defer r.w.Unlock()
+	if el, ok := r.c[iri]; ok {
+		r.remove(el)
+	}
+	r.c[iri] = r.l.PushFront(&entry)
+	r.bytes += entry.size
+	for (r.maxEntries > 0 && r.l.Len() > r.maxEntries) || (r.maxBytes > 0 && r.bytes > r.maxBytes) {
+		r.remove(r.l.Back())
+		r.evictions.Add(1)
+	}
+}
+
+// Evictions returns the number of items removed for making room for other ones.
+func (r *lru) Evictions() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.evictions.Load()
+}
+
+// Usage returns the number of items stored, and their approximate size.
+func (r *lru) Usage() Usage {
+	if r == nil {
+		return Usage{}
+	}
+	r.w.Lock()
+	defer r.w.Unlock()
+	return Usage{Entries: r.l.Len(), Bytes: r.bytes, MaxEntries: r.maxEntries, MaxBytes: r.maxBytes}
+}
+
+func (r *lru) remove(el *list.Element) {
+	entry := r.l.Remove(el).(*lruEntry)
+	r.bytes -= entry.size
+	delete(r.c, entry.key)
+}
+
+// Remove removes the items stored for the iris, and for the collections containing them,
+// or all of them when called without any.
+func (r *lru) Remove(iris ...vocab.IRI) bool {
+	if r == nil {
+		return true
+	}
+	r.w.Lock()
+	defer r.w.Unlock()
+	if len(iris) == 0 {
+		r.c = make(map[vocab.IRI]*list.Element)
+		r.l.Init()
+		r.bytes = 0
+		return true
+	}
+	toInvalidate := invalidatedIRIs(iris)
+	for key, el := range r.c {
+		if isInvalidated(key, toInvalidate) {
+			r.remove(el)
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func noteWithID(id string) vocab.Item {
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = vocab.IRI(id)
+	return ob
+}
+
+func Test_lru_MaxEntries(t *testing.T) {
+	r := NewLRU(2, 0)
+	first, second, third := vocab.IRI("http://example.com/objects/1"), vocab.IRI("http://example.com/objects/2"), vocab.IRI("http://example.com/objects/3")
+	r.Set(first, noteWithID(first.String()))
+	r.Set(second, noteWithID(second.String()))
+	// NOTE(marius): getting the first item makes the second one the least recently used.
+	if r.Get(first) == nil {
+		t.Fatalf("Get() returned nothing for %s", first)
+	}
+	r.Set(third, noteWithID(third.String()))
+	if r.Get(second) != nil {
+		t.Errorf("Get() returned the least recently used %s, which should have been evicted", second)
+	}
+	if r.Get(first) == nil || r.Get(third) == nil {
+		t.Errorf("Get() returned nothing for the recently used items")
+	}
+}
+
+func Test_lru_MaxBytes(t *testing.T) {
+	size := itemSize(noteWithID("http://example.com/objects/0"))
+	r := NewLRU(0, 3*size)
+	for i := 0; i < 10; i++ {
+		iri := vocab.IRI(fmt.Sprintf("http://example.com/objects/%d", i))
+		r.Set(iri, noteWithID(iri.String()))
+	}
+	if r.l.Len() != 3 || r.bytes > 3*size {
+		t.Errorf("the store has %d items of %d bytes, want 3 items of at most %d bytes", r.l.Len(), r.bytes, 3*size)
+	}
+	if r.Get("http://example.com/objects/9") == nil {
+		t.Errorf("Get() returned nothing for the most recent item")
+	}
+}
+
+func Test_lru_Remove(t *testing.T) {
+	r := NewLRU(0, 0)
+	ob := vocab.IRI("http://example.com/objects/1")
+	objects := vocab.IRI("http://example.com/objects")
+	other := vocab.IRI("http://example.com/actors/jdoe")
+	r.Set(ob, noteWithID(ob.String()))
+	r.Set(objects, vocab.ItemCollection{ob})
+	r.Set(other, vocab.PersonNew(other))
+
+	r.Remove(ob)
+	if r.Get(ob) != nil || r.Get(objects) != nil {
+		t.Errorf("Remove() didn't remove %s and the collection containing it", ob)
+	}
+	if r.Get(other) == nil {
+		t.Errorf("Remove() removed the unrelated %s", other)
+	}
+	r.Remove()
+	if r.Get(other) != nil || r.bytes != 0 {
+		t.Errorf("Remove() without IRIs didn't remove everything")
+	}
+}
+
+func Test_ristrettoStore(t *testing.T) {
+	if _, err := NewRistretto(0, 0); err == nil {
+		t.Errorf("NewRistretto() without limits didn't return an error")
+	}
+	r, err := NewRistretto(100, 0)
+	if err != nil {
+		t.Fatalf("NewRistretto() error = %s", err)
+	}
+	defer r.Close()
+
+	ob := vocab.IRI("http://example.com/objects/1")
+	objects := vocab.IRI("http://example.com/objects")
+	r.Set(ob, noteWithID(ob.String()))
+	r.Set(objects, vocab.ItemCollection{ob})
+	if r.Get(ob) == nil || r.Get(objects) == nil {
+		t.Fatalf("Get() returned nothing after Set()")
+	}
+	r.Remove(ob)
+	if r.Get(ob) != nil || r.Get(objects) != nil {
+		t.Errorf("Remove() didn't remove %s and the collection containing it", ob)
+	}
+}
+
+func Test_Usage(t *testing.T) {
+	ob := vocab.IRI("http://example.com/objects/1")
+	size := itemSize(noteWithID(ob.String()))
+
+	rs, err := NewRistretto(10, 0)
+	if err != nil {
+		t.Fatalf("NewRistretto() error = %s", err)
+	}
+	defer rs.Close()
+
+	tests := []struct {
+		name  string
+		store interface {
+			CanStore
+			Usage() Usage
+		}
+		want Usage
+	}{
+		{name: "map", store: New(true), want: Usage{Entries: 1, Bytes: size}},
+		{name: "lru", store: NewLRU(10, 0), want: Usage{Entries: 1, Bytes: size, MaxEntries: 10}},
+		{name: "ristretto", store: rs, want: Usage{Entries: 1, Bytes: size, MaxEntries: 10}},
+		{name: "expiring", store: NewExpiring(NewLRU(0, 1<<20), func(vocab.IRI, vocab.Item) time.Duration { return 0 }), want: Usage{Entries: 1, Bytes: size, MaxBytes: 1 << 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NOTE(marius): storing the same item twice must not count it twice.
+			tt.store.Set(ob, noteWithID(ob.String()))
+			tt.store.Set(ob, noteWithID(ob.String()))
+			if got := tt.store.Usage(); got != tt.want {
+				t.Errorf("Usage() = %+v, want %+v", got, tt.want)
+			}
+			tt.store.Remove()
+			if got := tt.store.Usage(); got.Entries != 0 || got.Bytes != 0 {
+				t.Errorf("Usage() after Remove() = %+v, want no entries", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// notFoundPruneSize is the number of entries from which the expired ones get removed when adding new ones.
+const notFoundPruneSize = 1024
+
+type (
+	notFound struct {
+		ttl time.Duration
+		w   sync.RWMutex
+		c   map[vocab.IRI]time.Time
+		now func() time.Time
+	}
+	CanStoreMissing interface {
+		Set(iri vocab.IRI)
+		Has(iri vocab.IRI) bool
+		Remove(iris ...vocab.IRI)
+	}
+)
+
+// NewNotFound returns a store which remembers for ttl the IRIs at which nothing was found.
+// When ttl is not larger than zero, nothing gets remembered.
+func NewNotFound(ttl time.Duration) *notFound {
+	return &notFound{ttl: ttl, c: make(map[vocab.IRI]time.Time), now: time.Now}
+}
+
+func (n *notFound) Has(iri vocab.IRI) bool {
+	if n == nil || n.ttl <= 0 {
+		return false
+	}
+	n.w.RLock()
+	defer n.w.RUnlock()
+	exp, ok := n.c[iri]
+	return ok && n.now().Before(exp)
+}
+
+func (n *notFound) Set(iri vocab.IRI) {
+	if n == nil || n.ttl <= 0 {
+		return
+	}
+	n.w.Lock()
+	defer n.w.Unlock()
+	now := n.now()
+	if len(n.c) >= notFoundPruneSize {
+		for key, exp := range n.c {
+			if !now.Before(exp) {
+				delete(n.c, key)
+			}
+		}
+	}
+	n.c[iri] = now.Add(n.ttl)
+}
+
+// Remove forgets the iris, or all of them when called without any.
+func (n *notFound) Remove(iris ...vocab.IRI) {
+	if n == nil || n.ttl <= 0 {
+		return
+	}
+	n.w.Lock()
+	defer n.w.Unlock()
+	if len(iris) == 0 {
+		n.c = make(map[vocab.IRI]time.Time)
+		return
+	}
+	for _, iri := range iris {
+		delete(n.c, iri)
+	}
+}
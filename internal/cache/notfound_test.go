@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_notFound(t *testing.T) {
+	now := time.Now()
+	n := NewNotFound(time.Minute)
+	n.now = func() time.Time { return now }
+
+	iri := vocab.IRI("example.com/objects/1")
+	if n.Has(iri) {
+		t.Errorf("Has() = true before Set()")
+	}
+	n.Set(iri)
+	if !n.Has(iri) {
+		t.Errorf("Has() = false after Set()")
+	}
+	now = now.Add(2 * time.Minute)
+	if n.Has(iri) {
+		t.Errorf("Has() = true after the ttl expired")
+	}
+	n.Set(iri)
+	n.Remove(iri)
+	if n.Has(iri) {
+		t.Errorf("Has() = true after Remove()")
+	}
+
+	disabled := NewNotFound(0)
+	disabled.Set(iri)
+	if disabled.Has(iri) {
+		t.Errorf("Has() = true with a zero ttl")
+	}
+	var nilStore *notFound
+	nilStore.Set(iri)
+	if nilStore.Has(iri) {
+		t.Errorf("Has() = true on a nil store")
+	}
+}
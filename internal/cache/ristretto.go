@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgraph-io/ristretto/v2"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+type (
+	ristrettoEntry struct {
+		key vocab.IRI
+		it  vocab.Item
+	}
+	ristrettoStore struct {
+		c          *ristretto.Cache[string, ristrettoEntry]
+		bySize     bool
+		maxEntries int
+		maxBytes   int64
+		evictions  atomic.Uint64
+		w          sync.Mutex
+		keys       map[vocab.IRI]int64
+		bytes      int64
+	}
+)
+
+// NewRistretto returns a store backed by a ristretto cache, bounded by the approximate memory used by its items
+// when maxBytes is larger than zero, or else by their number. Ristretto can decline storing new items,
+// depending on how often they were requested compared to the ones already stored.
+func NewRistretto(maxEntries int, maxBytes int64) (*ristrettoStore, error) {
+	r := ristrettoStore{
+		bySize:     maxBytes > 0,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		keys:       make(map[vocab.IRI]int64),
+	}
+	maxCost, counters := int64(maxEntries), int64(maxEntries)*10
+	if r.bySize {
+		// NOTE(marius): ristretto recommends ten counters for each item stored when full,
+		// for which we assume an average size of 1KB.
+		maxCost, counters = maxBytes, maxBytes/1024*10
+	}
+	if maxCost <= 0 {
+		return nil, errors.Newf("the ristretto cache needs a maximum number of entries or of bytes")
+	}
+	if counters < 1000 {
+		counters = 1000
+	}
+	c, err := ristretto.NewCache(&ristretto.Config[string, ristrettoEntry]{
+		NumCounters:        counters,
+		MaxCost:            maxCost,
+		BufferItems:        64,
+		IgnoreInternalCost: true,
+		OnEvict: func(i *ristretto.Item[ristrettoEntry]) {
+			r.evictions.Add(1)
+			r.forget(i.Value.key)
+		},
+		OnReject: func(i *ristretto.Item[ristrettoEntry]) { r.forget(i.Value.key) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.c = c
+	return &r, nil
+}
+
+func (r *ristrettoStore) forget(iri vocab.IRI) {
+	r.w.Lock()
+	defer r.w.Unlock()
+	r.bytes -= r.keys[iri]
+	delete(r.keys, iri)
+}
+
+// Usage returns the number of items stored, and their approximate size.
+func (r *ristrettoStore) Usage() Usage {
+	if r == nil {
+		return Usage{}
+	}
+	r.w.Lock()
+	defer r.w.Unlock()
+	u := Usage{Entries: len(r.keys), Bytes: r.bytes, MaxBytes: r.maxBytes}
+	if !r.bySize {
+		u.MaxEntries = r.maxEntries
+	}
+	return u
+}
+
+// Evictions returns the number of items removed for making room for other ones.
+func (r *ristrettoStore) Evictions() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.evictions.Load()
+}
+
+// Get returns a copy of the item stored for iri, so the callers can't modify the stored one.
+func (r *ristrettoStore) Get(iri vocab.IRI) vocab.Item {
+	if r == nil {
+		return nil
+	}
+	entry, ok := r.c.Get(iri.String())
+	if !ok {
+		return nil
+	}
+	return copyItem(entry.it)
+}
+
+// Set stores a copy of it for iri, if ristretto accepts it.
+func (r *ristrettoStore) Set(iri vocab.IRI, it vocab.Item) {
+	if r == nil {
+		return
+	}
+	size, cost := itemSize(it), int64(1)
+	if r.bySize {
+		cost = size
+	}
+	// NOTE(marius): the keys are tracked before storing, so an item rejected right away gets forgotten after.
+	r.w.Lock()
+	r.bytes += size - r.keys[iri]
+	r.keys[iri] = size
+	r.w.Unlock()
+	if !r.c.Set(iri.String(), ristrettoEntry{key: iri, it: copyItem(it)}, cost) {
+		r.forget(iri)
+		return
+	}
+	r.c.Wait()
+}
+
+// Remove removes the items stored for the iris, and for the collections containing them,
+// or all of them when called without any.
+func (r *ristrettoStore) Remove(iris ...vocab.IRI) bool {
+	if r == nil {
+		return true
+	}
+	if len(iris) == 0 {
+		r.c.Clear()
+		r.w.Lock()
+		r.keys = make(map[vocab.IRI]int64)
+		r.bytes = 0
+		r.w.Unlock()
+		return true
+	}
+	toInvalidate := invalidatedIRIs(iris)
+	removed := make(vocab.IRIs, 0)
+	r.w.Lock()
+	for key := range r.keys {
+		if isInvalidated(key, toInvalidate) {
+			removed = append(removed, key)
+			r.bytes -= r.keys[key]
+			delete(r.keys, key)
+		}
+	}
+	r.w.Unlock()
+	for _, key := range removed {
+		r.c.Del(key.String())
+	}
+	return true
+}
+
+// Close stops the goroutines of the ristretto cache.
+func (r *ristrettoStore) Close() {
+	if r == nil {
+		return
+	}
+	r.c.Close()
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+type (
+	serializedEntry struct {
+		key vocab.IRI
+		raw []byte
+	}
+	serialized struct {
+		size int
+		w    sync.Mutex
+		c    map[vocab.IRI]*list.Element
+		lru  *list.List
+		seen map[vocab.IRI]struct{}
+	}
+	CanStoreSerialized interface {
+		Get(iri vocab.IRI) []byte
+		Set(iri vocab.IRI, raw []byte)
+		Remove(iris ...vocab.IRI)
+	}
+)
+
+// NewSerialized returns a store for at most size encoded responses. Only the responses which get requested
+// repeatedly are stored, and the least recently used ones get evicted first. When size is not larger than zero,
+// nothing gets stored.
+func NewSerialized(size int) *serialized {
+	return &serialized{
+		size: size,
+		c:    make(map[vocab.IRI]*list.Element),
+		lru:  list.New(),
+		seen: make(map[vocab.IRI]struct{}),
+	}
+}
+
+// Get returns a copy of the response stored for iri, or nil when it isn't stored.
+func (s *serialized) Get(iri vocab.IRI) []byte {
+	if s == nil || s.size <= 0 {
+		return nil
+	}
+	s.w.Lock()
+	defer s.w.Unlock()
+	el, ok := s.c[iri]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(el)
+	return append([]byte{}, el.Value.(*serializedEntry).raw...)
+}
+
+// Set stores a copy of the response raw for iri, when iri has been requested before.
+func (s *serialized) Set(iri vocab.IRI, raw []byte) {
+	if s == nil || s.size <= 0 || len(raw) == 0 {
+		return
+	}
+	s.w.Lock()
+	defer s.w.Unlock()
+	if el, ok := s.c[iri]; ok {
+		el.Value.(*serializedEntry).raw = append([]byte{}, raw...)
+		s.lru.MoveToFront(el)
+		return
+	}
+	if _, ok := s.seen[iri]; !ok {
+		if len(s.seen) >= 4*s.size {
+			s.seen = make(map[vocab.IRI]struct{})
+		}
+		s.seen[iri] = struct{}{}
+		return
+	}
+	delete(s.seen, iri)
+	entry := serializedEntry{key: iri, raw: append([]byte{}, raw...)}
+	s.c[iri] = s.lru.PushFront(&entry)
+	if s.lru.Len() > s.size {
+		last := s.lru.Back()
+		s.lru.Remove(last)
+		delete(s.c, last.Value.(*serializedEntry).key)
+	}
+}
+
+// Remove removes the responses stored for the iris, and for the collections containing them,
+// or all of them when called without any.
+func (s *serialized) Remove(iris ...vocab.IRI) {
+	if s == nil || s.size <= 0 {
+		return
+	}
+	s.w.Lock()
+	defer s.w.Unlock()
+	if len(iris) == 0 {
+		s.c = make(map[vocab.IRI]*list.Element)
+		s.lru.Init()
+		return
+	}
+	toInvalidate := invalidatedIRIs(iris)
+	for key, el := range s.c {
+		if isInvalidated(key, toInvalidate) {
+			s.lru.Remove(el)
+			delete(s.c, key)
+		}
+	}
+}
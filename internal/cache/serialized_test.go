@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_serialized(t *testing.T) {
+	s := NewSerialized(1)
+	outbox := vocab.IRI("https://example.com/actors/1/outbox")
+	inbox := vocab.IRI("https://example.com/actors/1/inbox")
+
+	s.Set(outbox, []byte(`{"id":"outbox"}`))
+	if s.Get(outbox) != nil {
+		t.Errorf("Get() returned a response requested only once")
+	}
+	s.Set(outbox, []byte(`{"id":"outbox"}`))
+	if raw := s.Get(outbox); string(raw) != `{"id":"outbox"}` {
+		t.Errorf("Get() = %s, want the stored response", raw)
+	}
+
+	s.Set(inbox, []byte(`{"id":"inbox"}`))
+	s.Set(inbox, []byte(`{"id":"inbox"}`))
+	if s.Get(outbox) != nil {
+		t.Errorf("Get() returned the least recently used response after exceeding the size")
+	}
+
+	s.Remove("https://example.com/actors/1/inbox")
+	if s.Get(inbox) != nil {
+		t.Errorf("Get() returned a removed response")
+	}
+
+	disabled := NewSerialized(0)
+	disabled.Set(outbox, []byte(`{}`))
+	disabled.Set(outbox, []byte(`{}`))
+	if disabled.Get(outbox) != nil {
+		t.Errorf("Get() returned a response with a zero size")
+	}
+}
@@ -0,0 +1,84 @@
+package badger
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// KeyType is the type of the private keys generated by GenerateKey.
+type KeyType string
+
+const (
+	KeyEd25519 KeyType = "ed25519"
+	KeyRSA     KeyType = "rsa"
+)
+
+// rsaKeyBits is the size of the RSA keys generated by GenerateKey.
+const rsaKeyBits = 2048
+
+// The private keys replaced by GenerateKey are kept in <actor path>/__archived_keys/<time of the rotation> keys,
+// as PEM, so the signatures made with them can still be checked.
+const archivedKeysKey = "__archived_keys"
+
+func getArchivedKeyKey(p []byte, t time.Time) []byte {
+	return bytes.Join([][]byte{p, []byte(archivedKeysKey), []byte(t.UTC().Format(time.RFC3339Nano))}, sep)
+}
+
+// GenerateKey generates a private key of type typ for the actor, and stores it with SaveKey, which sets the
+// public key of the actor. It returns the updated actor.
+//
+// When the actor already has a private key, it fails, unless rotate is set, in which case the previous key
+// is archived before being replaced.
+func (r *repo) GenerateKey(iri vocab.IRI, typ KeyType, rotate bool) (vocab.Item, error) {
+	var key crypto.PrivateKey
+	var err error
+	switch typ {
+	case KeyEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case KeyRSA:
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	default:
+		return nil, errors.NotValidf("unknown key type %q", typ)
+	}
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to generate the %s key", typ)
+	}
+
+	m, err := r.LoadMetadata(iri)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if len(m.PrivateKey) > 0 {
+		if !rotate {
+			return nil, errors.NotValidf("%s already has a private key", iri)
+		}
+		if err = r.archiveKey(iri, m.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+	return r.SaveKey(iri, key)
+}
+
+func (r *repo) archiveKey(iri vocab.IRI, prv []byte) error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	k := getArchivedKeyKey(itemPath(iri), time.Now())
+	return r.update(func(tx dbTxn) error {
+		if err := tx.Set(k, prv); err != nil {
+			return errors.Annotatef(err, "unable to archive the private key of %s", iri)
+		}
+		r.logFn("Archived the private key of %s at %s", iri, k)
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package badger
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_GenerateKey(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+
+	it, err := r.GenerateKey(jdoe.ID, KeyEd25519, false)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %s", err)
+	}
+	var pem string
+	_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+		pem = a.PublicKey.PublicKeyPem
+		return nil
+	})
+	if !strings.HasPrefix(pem, "-----BEGIN PUBLIC KEY-----") {
+		t.Errorf("GenerateKey() public key = %q, want a PEM encoded one", pem)
+	}
+	if key, err := r.LoadKey(jdoe.ID); err != nil {
+		t.Errorf("LoadKey() error = %s", err)
+	} else if _, ok := key.(ed25519.PrivateKey); !ok {
+		t.Errorf("LoadKey() = %T, want an ed25519 key", key)
+	}
+
+	if _, err = r.GenerateKey(jdoe.ID, KeyRSA, false); !errors.IsNotValid(err) {
+		t.Errorf("GenerateKey() for an actor with a key error = %v, want NotValid", err)
+	}
+	if _, err = r.GenerateKey(jdoe.ID, KeyRSA, true); err != nil {
+		t.Fatalf("GenerateKey() rotating error = %s", err)
+	}
+	if key, err := r.LoadKey(jdoe.ID); err != nil {
+		t.Errorf("LoadKey() error = %s", err)
+	} else if _, ok := key.(*rsa.PrivateKey); !ok {
+		t.Errorf("LoadKey() after rotating = %T, want an RSA key", key)
+	}
+	archived, err := r.Keys("example.com/actors/jdoe/"+archivedKeysKey+"/", 0)
+	if err != nil {
+		t.Fatalf("Keys() error = %s", err)
+	}
+	if len(archived) != 1 {
+		t.Errorf("GenerateKey() archived keys = %v, want the previous key", archived)
+	}
+
+	if _, err = r.GenerateKey(jdoe.ID, "dsa", false); !errors.IsNotValid(err) {
+		t.Errorf("GenerateKey() with an unknown type error = %v, want NotValid", err)
+	}
+}
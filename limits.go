@@ -0,0 +1,112 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// Truncated is the error returned by Load, together with the partial collection,
+// when the number of items found exceeds the MaxLoadItems of the Config.
+type Truncated struct {
+	Limit int
+}
+
+func (t Truncated) Error() string {
+	return fmt.Sprintf("result truncated to %d items", t.Limit)
+}
+
+// IsTruncated returns true if the error signals that Load returned a partial collection.
+func IsTruncated(err error) bool {
+	t := Truncated{}
+	return errors.As(err, &t)
+}
+
+// TooLarge is the error returned by the writes of the objects, and of the collections, whose encoded values
+// are larger than the MaxItemSize of the Config.
+type TooLarge struct {
+	IRI   vocab.IRI
+	Size  int64
+	Limit int64
+}
+
+func (t TooLarge) Error() string {
+	return fmt.Sprintf("%s is %d bytes, larger than the maximum of %d", t.IRI, t.Size, t.Limit)
+}
+
+// IsTooLarge returns true if the error signals that a write was rejected for exceeding the MaxItemSize.
+func IsTooLarge(err error) bool {
+	t := TooLarge{}
+	return errors.As(err, &t)
+}
+
+// collectionChunkSize is the number of IRIs added to a collection in each transaction, when adding many of them,
+// as their membership and cursor keys can get over the size limits of the badger transactions.
+const collectionChunkSize = 1000
+
+// checkItemSize returns a TooLarge error when the encoded value of iri is larger than the MaxItemSize.
+func (r *repo) checkItemSize(iri vocab.IRI, raw []byte) error {
+	if r.maxItemSize <= 0 || int64(len(raw)) <= r.maxItemSize {
+		return nil
+	}
+	return TooLarge{IRI: iri, Size: int64(len(raw)), Limit: r.maxItemSize}
+}
+
+// sizeLimitedFn returns fn, failing when the list of IRIs of the col collection it returns is larger than
+// the MaxItemSize, once encoded.
+func (r *repo) sizeLimitedFn(col vocab.IRI, fn func(iris vocab.IRIs) (vocab.IRIs, error)) func(iris vocab.IRIs) (vocab.IRIs, error) {
+	if r.maxItemSize <= 0 {
+		return fn
+	}
+	return func(iris vocab.IRIs) (vocab.IRIs, error) {
+		iris, err := fn(iris)
+		if err != nil {
+			return iris, err
+		}
+		raw, err := encodeItemFn(iris)
+		if err != nil {
+			return iris, err
+		}
+		return iris, r.checkItemSize(col, raw)
+	}
+}
+
+// loadLimit returns the maximum number of items loadFromPath needs to collect for a Load,
+// and if the MaxLoadItems cap applies. In that case one more item than the cap is loaded,
+// so Load can tell whether the result got truncated.
+//
+// When the checks contain a MaxCount, the iteration stops once one more matching item than it
+// has been collected, which is enough for the pagination to know there's a next page.
+// With an After or Before cursor the position of the page isn't known before reaching the cursor,
+// so everything gets loaded.
+func (r *repo) loadLimit(f Filterable, checks filters.Checks) (int, bool) {
+	if ff, ok := f.(*filters.Filters); ok && ff.IsItemIRI() {
+		return 1, false
+	}
+	maxCount := filters.MaxCount(checks...)
+	if maxCount >= 0 {
+		if len(filters.CursorChecks(checks...)) > 0 {
+			return 0, false
+		}
+		return maxCount + 1, false
+	}
+	if r.maxLoadItems > 0 {
+		return r.maxLoadItems + 1, true
+	}
+	return 0, false
+}
+
+// remainingItems returns how many items still need to be loaded into col to reach maxItems.
+func remainingItems(maxItems int, col vocab.ItemCollection) int {
+	if maxItems <= 0 {
+		return 0
+	}
+	if rem := maxItems - len(col); rem > 0 {
+		return rem
+	}
+	// NOTE(marius): a zero value would mean no limit, the callers are expected to stop
+	// before reaching this point.
+	return 1
+}
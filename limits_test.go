@@ -0,0 +1,225 @@
+package badger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+func Test_repo_Load_MaxLoadItems(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	count := 10
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open badger: %s", err)
+	}
+	for i := 0; i < count; i++ {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%02d", i))
+		if _, err = save(r, ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+	r.Close()
+
+	tests := []struct {
+		name          string
+		maxLoadItems  int
+		checks        filters.Checks
+		wantCount     int
+		wantTruncated bool
+	}{
+		{
+			name:      "no cap",
+			wantCount: count,
+		},
+		{
+			name:         "cap larger than collection",
+			maxLoadItems: count,
+			wantCount:    count,
+		},
+		{
+			name:          "cap smaller than collection",
+			maxLoadItems:  3,
+			wantCount:     3,
+			wantTruncated: true,
+		},
+		{
+			name:         "cap ignored with MaxCount",
+			maxLoadItems: 3,
+			checks:       filters.Checks{filters.WithMaxCount(5)},
+			wantCount:    6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r.maxLoadItems = tt.maxLoadItems
+			res, err := r.Load("http://example.com/objects", tt.checks...)
+			if IsTruncated(err) != tt.wantTruncated {
+				t.Fatalf("Load() error = %v, want truncated %t", err, tt.wantTruncated)
+			}
+			if err != nil && !tt.wantTruncated {
+				t.Fatalf("Load() error = %s", err)
+			}
+			var items vocab.ItemCollection
+			_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+				items = col.Collection()
+				return nil
+			})
+			if len(items) != tt.wantCount {
+				t.Errorf("Load() = %d items, want %d", len(items), tt.wantCount)
+			}
+		})
+	}
+}
+
+func Test_repo_Load_MaxCountStopsEarly(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	colIRI := vocab.IRI("http://example.com/~jdoe/outbox")
+	if _, err = r.Create(orderedCollection(colIRI)); err != nil {
+		t.Fatalf("unable to create collection %s: %s", colIRI, err)
+	}
+
+	for i := 0; i < 10; i++ {
+		typ := vocab.NoteType
+		if i%2 == 0 {
+			typ = vocab.ArticleType
+		}
+		ob := vocab.ObjectNew(typ)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%02d", i))
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+		if err = r.AddTo(colIRI, ob); err != nil {
+			t.Fatalf("unable to add %s to %s: %s", ob.ID, colIRI, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		iri    vocab.IRI
+		checks filters.Checks
+		want   int
+	}{
+		{
+			name:   "storage collection",
+			iri:    "http://example.com/objects",
+			checks: filters.Checks{filters.HasType(vocab.NoteType), filters.WithMaxCount(2)},
+			want:   3,
+		},
+		{
+			name:   "collection",
+			iri:    colIRI,
+			checks: filters.Checks{filters.HasType(vocab.NoteType), filters.WithMaxCount(2)},
+			want:   3,
+		},
+		{
+			name:   "more than available",
+			iri:    colIRI,
+			checks: filters.Checks{filters.HasType(vocab.NoteType), filters.WithMaxCount(20)},
+			want:   5,
+		},
+		// NOTE(marius): the page after the cursor contains it, and the older matching members, of which
+		// there are none, as the only older member is an Article.
+		{
+			name:   "with cursor",
+			iri:    colIRI,
+			checks: filters.Checks{filters.HasType(vocab.NoteType), filters.WithMaxCount(2), filters.After(testID("http://example.com/objects/01"))},
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := r.Load(tt.iri, tt.checks...)
+			if err != nil {
+				t.Fatalf("Load() error = %s", err)
+			}
+			var items vocab.ItemCollection
+			_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+				items = col.Collection()
+				return nil
+			})
+			if len(items) != tt.want {
+				t.Fatalf("Load() = %d items, want %d", len(items), tt.want)
+			}
+			for _, it := range items {
+				if it.GetType() != vocab.NoteType {
+					t.Errorf("Load() returned %s of type %s, want %s", it.GetLink(), it.GetType(), vocab.NoteType)
+				}
+			}
+		})
+	}
+}
+
+func Test_repo_MaxItemSize(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.maxItemSize = 512
+
+	small, large := vocab.ObjectNew(vocab.NoteType), vocab.ObjectNew(vocab.NoteType)
+	small.ID, large.ID = "https://example.com/objects/1", "https://example.com/objects/2"
+	large.Content = vocab.DefaultNaturalLanguageValue(strings.Repeat("a", 1024))
+	if _, err = r.Save(small); err != nil {
+		t.Fatalf("Save() of a small object error = %s", err)
+	}
+	if _, err = r.Save(large); !IsTooLarge(err) {
+		t.Errorf("Save() of a large object error = %v, want TooLarge", err)
+	}
+	if _, err = r.Load(large.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() of the rejected object error = %v, want NotFound", err)
+	}
+
+	col := vocab.IRI("https://example.com/~jdoe/inbox")
+	if _, err = r.Create(&vocab.OrderedCollection{ID: col, Type: vocab.OrderedCollectionType}); err != nil {
+		t.Fatalf("unable to create %s: %s", col, err)
+	}
+	for i := 0; ; i++ {
+		err = r.AddTo(col, vocab.IRI(fmt.Sprintf("https://example.com/objects/%02d", i)))
+		if err != nil {
+			break
+		}
+		if i > 100 {
+			t.Fatalf("AddTo() didn't fail after %d items", i)
+		}
+	}
+	if !IsTooLarge(err) {
+		t.Errorf("AddTo() above the MaxItemSize error = %v, want TooLarge", err)
+	}
+}
+
+func Test_repo_addToCollection_Chunks(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open badger: %s", err)
+	}
+	defer r.Close()
+
+	col := vocab.IRI("https://example.com/objects")
+	iris := make(vocab.IRIs, 2*collectionChunkSize+10)
+	for i := range iris {
+		iris[i] = col.AddPath(fmt.Sprintf("%d", i))
+	}
+	added, err := r.addToCollection(col, append(iris, iris[:10]...))
+	if err != nil {
+		t.Fatalf("addToCollection() error = %s", err)
+	}
+	if added != len(iris) {
+		t.Errorf("addToCollection() = %d, want %d", added, len(iris))
+	}
+}
@@ -0,0 +1,65 @@
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+// The collections containing an item are stored in __member_of/<item path>\x00<collection path> keys, having the
+// IRI of the collection as value, so they can be found without loading all the collections.
+// The keys of the collections stored before they existed get created by ReindexAll.
+const memberOfKey = "__member_of"
+
+func getMemberOfPrefix(itPath []byte) []byte {
+	return append(bytes.Join([][]byte{[]byte(memberOfKey), itPath}, sep), indexValueSep)
+}
+
+func getMemberOfKey(itPath, colPath []byte) []byte {
+	return append(getMemberOfPrefix(itPath), colPath...)
+}
+
+func setMemberOfKey(b keySetter, col, iri vocab.IRI) error {
+	return b.Set(getMemberOfKey(itemPath(iri), itemPath(col)), []byte(col))
+}
+
+// updateMemberOfKeys records col as containing the IRIs added to it, and removes it for the ones removed from it.
+func updateMemberOfKeys(tx dbTxn, col vocab.IRI, old, new vocab.IRIs) error {
+	colPath := itemPath(col)
+	for _, iri := range old {
+		if new.Contains(iri) {
+			continue
+		}
+		if err := tx.Delete(getMemberOfKey(itemPath(iri), colPath)); err != nil {
+			return err
+		}
+	}
+	for _, iri := range new {
+		if old.Contains(iri) {
+			continue
+		}
+		if err := setMemberOfKey(tx, col, iri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectionsContaining returns the IRIs of the collections which contain the iri.
+func collectionsContaining(tx dbTxn, iri vocab.IRI) vocab.IRIs {
+	prefix := getMemberOfPrefix(itemPath(iri))
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	cols := make(vocab.IRIs, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		_ = it.Item().Value(func(val []byte) error {
+			cols = append(cols, vocab.IRI(val))
+			return nil
+		})
+	}
+	return cols
+}
@@ -0,0 +1,137 @@
+package badger
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_collectionsContaining(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	jdoe.Liked = vocab.Liked.IRI(jdoe)
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, ob} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	for _, col := range []vocab.IRI{jdoe.Outbox.GetLink(), jdoe.Liked.GetLink()} {
+		if err = r.AddTo(col, ob); err != nil {
+			t.Fatalf("unable to add to %s: %s", col, err)
+		}
+	}
+
+	containing := func() vocab.IRIs {
+		var cols vocab.IRIs
+		if err := r.Open(); err != nil {
+			t.Fatalf("Open() error = %s", err)
+		}
+		defer r.Close()
+		_ = r.d.View(func(tx *badger.Txn) error {
+			cols = collectionsContaining(tx, ob.ID)
+			return nil
+		})
+		return cols
+	}
+	if got := containing(); len(got) != 2 || !got.Contains(jdoe.Outbox.GetLink()) || !got.Contains(jdoe.Liked.GetLink()) {
+		t.Errorf("collectionsContaining() = %v, want %s and %s", got, jdoe.Outbox.GetLink(), jdoe.Liked.GetLink())
+	}
+
+	if err = r.RemoveFrom(jdoe.Liked.GetLink(), ob); err != nil {
+		t.Fatalf("unable to remove from %s: %s", jdoe.Liked.GetLink(), err)
+	}
+	if got := containing(); len(got) != 1 || !got.Contains(jdoe.Outbox.GetLink()) {
+		t.Errorf("collectionsContaining() after RemoveFrom = %v, want %s", got, jdoe.Outbox.GetLink())
+	}
+
+	// NOTE(marius): the keys get rebuilt from the stored collections.
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	err = r.d.DropPrefix([]byte(memberOfKey))
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to remove the keys: %s", err)
+	}
+	if got := containing(); len(got) != 0 {
+		t.Fatalf("collectionsContaining() after removing the keys = %v, want none", got)
+	}
+	if _, err = r.ReindexAll(); err != nil {
+		t.Fatalf("ReindexAll() error = %s", err)
+	}
+	if got := containing(); len(got) != 1 || !got.Contains(jdoe.Outbox.GetLink()) {
+		t.Errorf("collectionsContaining() after ReindexAll = %v, want %s", got, jdoe.Outbox.GetLink())
+	}
+}
+
+func Test_repo_invalidateItem(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(true)
+	r.embedded = cache.NewEmbedded()
+
+	jdoe := vocab.PersonNew("http://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	alice := vocab.PersonNew("http://example.com/actors/alice")
+	alice.Outbox = vocab.Outbox.IRI(alice)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	other := vocab.ObjectNew(vocab.NoteType)
+	other.ID = "http://example.com/objects/2"
+	create := vocab.ActivityNew("http://example.com/activities/1", vocab.CreateType, note.ID)
+	create.Actor = jdoe.ID
+	for _, it := range []vocab.Item{jdoe, alice, note, other, create} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+	if err = r.AddTo(alice.Outbox.GetLink(), other); err != nil {
+		t.Fatalf("unable to add to %s: %s", alice.Outbox.GetLink(), err)
+	}
+
+	activities := vocab.IRI("http://example.com/activities")
+	for _, iri := range []vocab.IRI{jdoe.Outbox.GetLink(), alice.Outbox.GetLink(), activities} {
+		_ = mustLoad(t, r, iri)
+		if r.cache.Get(iri) == nil {
+			t.Fatalf("Load(%s) result was not cached", iri)
+		}
+	}
+
+	note.Content = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("updated"))
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+	if r.cache.Get(jdoe.Outbox.GetLink()) != nil {
+		t.Errorf("the cached %s containing the updated %s was not removed", jdoe.Outbox.GetLink(), note.ID)
+	}
+	if r.cache.Get(activities) != nil {
+		t.Errorf("the cached %s embedding the updated %s was not removed", activities, note.ID)
+	}
+	if r.cache.Get(alice.Outbox.GetLink()) == nil {
+		t.Errorf("the cached %s, unrelated to the updated %s, was removed", alice.Outbox.GetLink(), note.ID)
+	}
+
+	_ = mustLoad(t, r, jdoe.Outbox.GetLink())
+	if err = r.Delete(note); err != nil {
+		t.Fatalf("unable to delete %s: %s", note.ID, err)
+	}
+	if r.cache.Get(jdoe.Outbox.GetLink()) != nil {
+		t.Errorf("the cached %s containing the deleted %s was not removed", jdoe.Outbox.GetLink(), note.ID)
+	}
+	if r.cache.Get(alice.Outbox.GetLink()) == nil {
+		t.Errorf("the cached %s, unrelated to the deleted %s, was removed", alice.Outbox.GetLink(), note.ID)
+	}
+}
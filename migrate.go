@@ -0,0 +1,241 @@
+package badger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// MigrateActor moves the actor found at the from IRI, together with its collections, metadata and keys
+// to the to IRI. The references to the from IRI contained in the moved objects are rewritten to point
+// to the new one, and a Tombstone having its URL set to the to IRI is left in the old location.
+//
+// The keys are moved in chunks of collectionChunkSize, each in its own transaction, and the actor itself
+// is moved last, together with writing the Tombstone, so a migration that failed midway can be run again.
+//
+// This is the storage side of the ActivityPub Move flow.
+func (r *repo) MigrateActor(from, to vocab.IRI) error {
+	if from.Equals(to, false) {
+		return errors.Newf("unable to migrate actor %s onto itself", from)
+	}
+	fromPath := itemPath(from)
+	toPath := itemPath(to)
+	if len(fromPath) == 0 || len(toPath) == 0 {
+		return errors.Newf("invalid IRIs for migration %s -> %s", from, to)
+	}
+
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	act, err := r.loadOneFromPath(from)
+	if err != nil {
+		return errors.Annotatef(err, "unable to load actor %s", from)
+	}
+	if !vocab.ActorTypes.Contains(act.GetType()) {
+		return errors.Newf("unable to migrate invalid actor type %s", act.GetType())
+	}
+
+	actorKey := getObjectKey(fromPath)
+	keys := make([][]byte, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if _, err := tx.Get(getObjectKey(toPath)); err == nil {
+			return errors.NewConflict(ErrConflict, "an object already exists at %s", to)
+		}
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		opt.Prefix = fromPath
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(fromPath); it.ValidForPrefix(fromPath); it.Next() {
+			k := it.Item().KeyCopy(nil)
+			// NOTE(marius): the type keys are not moved, as saveItem stores them again for the moved objects.
+			if tp, _ := splitTypeKey(k); tp != nil || !isPathOrChildKey(fromPath, k) || bytes.Equal(k, actorKey) {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		keys = append(keys, movedMemberOfKeys(tx, fromPath)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	defer r.invalidateResults()
+	// NOTE(marius): the paths under the to IRI could have been looked up before the migration, so we forget
+	// all the failed lookups, not only the one of the actor.
+	defer r.clearNotFound()
+	for len(keys) > 0 {
+		chunk := keys[:min(collectionChunkSize, len(keys))]
+		keys = keys[len(chunk):]
+		err = r.update(func(tx dbTxn) error {
+			for _, k := range chunk {
+				if err := moveKey(r, tx, k, fromPath, toPath, from, to); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Annotatef(err, "unable to migrate %d keys of %s", len(chunk), from)
+		}
+	}
+
+	return r.update(func(tx dbTxn) error {
+		if err := moveKey(r, tx, actorKey, fromPath, toPath, from, to); err != nil {
+			return err
+		}
+		tomb := vocab.Tombstone{
+			ID:         from,
+			Type:       vocab.TombstoneType,
+			FormerType: act.GetType(),
+			Deleted:    time.Now().UTC(),
+			URL:        to,
+		}
+		if _, err := saveItem(r, tx, tomb); err != nil {
+			return errors.Annotatef(err, "unable to save tombstone for %s", from)
+		}
+		r.logFn("Migrated %s %s to %s", act.GetType(), from, to)
+		return nil
+	})
+}
+
+// moveKey moves the k key from under the fromPath to the toPath, rewriting the from IRI in the
+// values of the objects to the to IRI.
+// The objects are stored again through saveItem, so their type keys and indexes are rebuilt for the new path,
+// and the ones of the old path are removed. The membership keys are moved by moveMemberOfKey.
+func moveKey(r *repo, tx dbTxn, k, fromPath, toPath []byte, from, to vocab.IRI) error {
+	if bytes.HasPrefix(k, []byte(memberOfKey+string(sep))) {
+		return moveMemberOfKey(tx, k, fromPath, toPath, from, to)
+	}
+	i, err := tx.Get(k)
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Annotatef(err, "unable to load %s", k)
+	}
+	raw, err := i.ValueCopy(nil)
+	if err != nil {
+		return errors.Annotatef(err, "unable to read value for %s", k)
+	}
+	toKey := movedPath(k, fromPath, toPath)
+	if !isObjectKey(k) {
+		return moveRawKey(tx, k, toKey, raw)
+	}
+	old, err := loadItem(raw)
+	if err != nil || vocab.IsNil(old) || !old.IsObject() {
+		return moveRawKey(tx, k, toKey, rewriteIRIs(raw, from, to))
+	}
+	it, err := loadItem(rewriteIRIs(raw, from, to))
+	if err != nil {
+		return errors.Annotatef(err, "unable to decode the moved %s", k)
+	}
+	if !bytes.Equal(getObjectKey(itemPath(it.GetLink())), toKey) {
+		// NOTE(marius): the objects stored under a path other than the one of their ID, are moved as they are.
+		return moveRawKey(tx, k, toKey, rewriteIRIs(raw, from, to))
+	}
+	if err = tx.Delete(k); err != nil {
+		return errors.Annotatef(err, "unable to remove %s", k)
+	}
+	oldPath := itemPath(old.GetLink())
+	if err = updateIndexes(tx, r.indexes, oldPath, old, nil); err != nil {
+		return errors.Annotatef(err, "unable to remove the indexes of %s", k)
+	}
+	if len(old.GetType()) > 0 {
+		if err = tx.Delete(getTypeKey(oldPath, old.GetType())); err != nil {
+			return errors.Annotatef(err, "unable to remove the type of %s", k)
+		}
+	}
+	if _, err = saveItem(r, tx, it); err != nil {
+		return errors.Annotatef(err, "unable to move %s to %s", k, toKey)
+	}
+	return nil
+}
+
+// moveRawKey stores raw at the toKey, and removes the k key.
+func moveRawKey(tx dbTxn, k, toKey, raw []byte) error {
+	if err := tx.Set(toKey, raw); err != nil {
+		return errors.Annotatef(err, "unable to move %s to %s", k, toKey)
+	}
+	if err := tx.Delete(k); err != nil {
+		return errors.Annotatef(err, "unable to remove %s", k)
+	}
+	return nil
+}
+
+// movedPath returns the p path, or key, rebased from the fromPath onto the toPath, when it is under the fromPath.
+func movedPath(p, fromPath, toPath []byte) []byte {
+	if !isPathOrChildKey(fromPath, p) {
+		return p
+	}
+	return append(append([]byte{}, toPath...), p[len(fromPath):]...)
+}
+
+// movedMemberOfKeys returns the membership keys of the collections found under the fromPath.
+func movedMemberOfKeys(tx dbTxn, fromPath []byte) [][]byte {
+	prefix := []byte(memberOfKey + string(sep))
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	keys := make([][]byte, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		if _, colPath, ok := bytes.Cut(k[len(prefix):], []byte{indexValueSep}); ok && isPathOrChildKey(fromPath, colPath) {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+	}
+	return keys
+}
+
+// moveMemberOfKey replaces the k membership key of a collection moved from the fromPath to the toPath, with
+// the one of the moved collection, for the item, which is moved too when it was under the fromPath.
+func moveMemberOfKey(tx dbTxn, k, fromPath, toPath []byte, from, to vocab.IRI) error {
+	prefix := []byte(memberOfKey + string(sep))
+	itPath, colPath, ok := bytes.Cut(k[len(prefix):], []byte{indexValueSep})
+	if !ok {
+		return nil
+	}
+	i, err := tx.Get(k)
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Annotatef(err, "unable to load %s", k)
+	}
+	val, err := i.ValueCopy(nil)
+	if err != nil {
+		return errors.Annotatef(err, "unable to read value for %s", k)
+	}
+	col := movedCollection(vocab.IRI(val), from, to)
+	toKey := getMemberOfKey(movedPath(itPath, fromPath, toPath), movedPath(colPath, fromPath, toPath))
+	return moveRawKey(tx, k, toKey, []byte(col.GetLink()))
+}
+
+// isPathOrChildKey checks if key k belongs to the base path, and not to a sibling that shares
+// its prefix (eg: "example.com/jdoe" vs "example.com/jdoe2").
+func isPathOrChildKey(base, k []byte) bool {
+	if !bytes.HasPrefix(k, base) {
+		return false
+	}
+	rest := k[len(base):]
+	return len(rest) == 0 || rest[0] == sep[0]
+}
+
+// rewriteIRIs replaces in the raw JSON document the occurrences of the from IRI, or of IRIs
+// having it as a base, with the to one.
+func rewriteIRIs(raw []byte, from, to vocab.IRI) []byte {
+	for _, suffix := range []string{`"`, `/`, `#`} {
+		raw = bytes.ReplaceAll(raw, []byte(`"`+from.String()+suffix), []byte(`"`+to.String()+suffix))
+	}
+	return raw
+}
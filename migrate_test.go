@@ -0,0 +1,111 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_MigrateActor(t *testing.T) {
+	oldIRI := vocab.IRI("http://example.com/jdoe")
+	newIRI := vocab.IRI("http://example.social/jdoe")
+
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	r.indexes = append([]Indexer{ActorNameIndex{}}, DefaultIndexes...)
+	r.notFound = cache.NewNotFound(time.Minute)
+	if _, err = r.LoadOne(newIRI); err == nil {
+		t.Fatalf("nothing should be stored at %s yet", newIRI)
+	}
+
+	act := vocab.PersonNew(oldIRI)
+	act.PreferredUsername = vocab.DefaultNaturalLanguageValue("jdoe")
+	act.Inbox = vocab.Inbox.IRI(oldIRI)
+	act.Outbox = vocab.Outbox.IRI(oldIRI)
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save actor %s: %s", oldIRI, err)
+	}
+	if err = r.AddTo(vocab.Outbox.IRI(oldIRI), vocab.IRI("http://example.com/jdoe/1")); err != nil {
+		t.Fatalf("unable to add to outbox: %s", err)
+	}
+	if err = r.PasswordSet(act, []byte("dsa")); err != nil {
+		t.Fatalf("unable to set password: %s", err)
+	}
+	sibling := vocab.PersonNew("http://example.com/jdoe2")
+	if _, err = r.Save(sibling); err != nil {
+		t.Fatalf("unable to save sibling actor: %s", err)
+	}
+
+	if err = r.MigrateActor(oldIRI, newIRI); err != nil {
+		t.Fatalf("MigrateActor() error = %s", err)
+	}
+
+	moved, err := r.LoadOne(newIRI)
+	if err != nil {
+		t.Fatalf("unable to load migrated actor: %s", err)
+	}
+	err = vocab.OnActor(moved, func(a *vocab.Actor) error {
+		if !a.ID.Equals(newIRI, false) {
+			t.Errorf("migrated actor ID = %s, want %s", a.ID, newIRI)
+		}
+		if !a.Inbox.GetLink().Equals(vocab.Inbox.IRI(newIRI), false) {
+			t.Errorf("migrated actor inbox = %s, want %s", a.Inbox.GetLink(), vocab.Inbox.IRI(newIRI))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("invalid migrated actor: %s", err)
+	}
+	if err = r.PasswordCheck(moved, []byte("dsa")); err != nil {
+		t.Errorf("metadata was not migrated: %s", err)
+	}
+
+	col, err := r.Load(vocab.Outbox.IRI(newIRI))
+	if err != nil {
+		t.Errorf("unable to load migrated outbox: %s", err)
+	}
+	if !vocab.IsNil(col) && !col.IsCollection() {
+		t.Errorf("migrated outbox is not a collection: %T", col)
+	}
+
+	old, err := r.LoadOne(oldIRI)
+	if err != nil {
+		t.Fatalf("unable to load the old actor location: %s", err)
+	}
+	if old.GetType() != vocab.TombstoneType {
+		t.Errorf("old actor location type = %s, want %s", old.GetType(), vocab.TombstoneType)
+	}
+	if _, err = r.LoadOne(sibling.GetLink()); err != nil {
+		t.Errorf("sibling actor should not have been migrated: %s", err)
+	}
+
+	found, err := r.FindActors("jdoe", 0)
+	if err != nil {
+		t.Fatalf("FindActors() error = %s", err)
+	}
+	if len(found) != 1 || !found[0].GetLink().Equals(newIRI, false) {
+		t.Errorf("FindActors() = %v, want only %s", found, newIRI)
+	}
+	typeKeys, _ := r.Keys(string(getTypeKey(itemPath(oldIRI), vocab.PersonType)), 0)
+	if len(typeKeys) > 0 {
+		t.Errorf("the type key of the old actor location was not removed: %v", typeKeys)
+	}
+	_ = r.Open()
+	defer r.Close()
+	_ = r.d.View(func(tx *badger.Txn) error {
+		cols := collectionsContaining(tx, "http://example.social/jdoe/1")
+		if len(cols) != 1 || !cols[0].Equals(vocab.Outbox.IRI(newIRI), false) {
+			t.Errorf("migrated outbox member is contained in %v, want %s", cols, vocab.Outbox.IRI(newIRI))
+		}
+		if cols = collectionsContaining(tx, "http://example.com/jdoe/1"); len(cols) > 0 {
+			t.Errorf("the membership keys of the old outbox were not removed: %v", cols)
+		}
+		return nil
+	})
+}
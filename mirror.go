@@ -0,0 +1,210 @@
+package badger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// Mirror is the interface of the secondary storages, like the other go-ap storage backends, which can be set
+// in the Config to receive the changes made to the repository, for live migrations and hot standbys.
+type Mirror interface {
+	Save(vocab.Item) (vocab.Item, error)
+	Delete(vocab.Item) error
+	AddTo(col vocab.IRI, it vocab.Item) error
+	RemoveFrom(col vocab.IRI, it vocab.Item) error
+}
+
+// MirrorOp is the name of the repository method whose change is sent to the Mirror.
+type MirrorOp string
+
+const (
+	MirrorSave       MirrorOp = "Save"
+	MirrorDelete     MirrorOp = "Delete"
+	MirrorAddTo      MirrorOp = "AddTo"
+	MirrorRemoveFrom MirrorOp = "RemoveFrom"
+)
+
+// DefaultMirrorQueueSize is the number of changes waiting to be sent to the Mirror when the Config doesn't set one.
+const DefaultMirrorQueueSize = 1024
+
+// mirrorFailuresSize is the number of the latest failed changes kept for MirrorFailures.
+const mirrorFailuresSize = 256
+
+// MirrorFailure is a change which couldn't be sent to the Mirror, because it returned an error,
+// or because the queue was full.
+type MirrorFailure struct {
+	Op   MirrorOp
+	Col  vocab.IRI
+	IRI  vocab.IRI
+	Err  error
+	Time time.Time
+}
+
+// MirrorStats contains the state of the queue of changes sent to the Mirror.
+type MirrorStats struct {
+	// Queued is the number of changes waiting to be sent.
+	Queued int
+	// Failed is the number of changes which couldn't be sent since the repository was created.
+	Failed int64
+}
+
+type mirrorOp struct {
+	op  MirrorOp
+	col vocab.IRI
+	it  vocab.Item
+	// fn is run by the mirror goroutine instead of sending a change, after the changes queued before it.
+	fn func()
+}
+
+// mirror sends the changes to the Mirror from a single goroutine, in the order they were made.
+// The changes made while the queue is full are dropped, and recorded as failures.
+// The goroutine runs while the database of the repository is open, and the changes made while it's closed
+// wait in the queue for the next Open.
+type mirror struct {
+	to       Mirror
+	ops      chan mirrorOp
+	failed   atomic.Int64
+	mu       sync.Mutex
+	failures []MirrorFailure
+	errFn    loggerFn
+	// quit and done are the channels of the running goroutine, or nil when it's stopped.
+	// NOTE(marius): they are changed only by start and stop, which are called holding the lock of the
+	// database handle.
+	quit chan struct{}
+	done chan struct{}
+}
+
+func newMirror(to Mirror, size int, errFn loggerFn) *mirror {
+	if size <= 0 {
+		size = DefaultMirrorQueueSize
+	}
+	return &mirror{to: to, ops: make(chan mirrorOp, size), errFn: errFn}
+}
+
+// start starts the goroutine sending the queued changes, if it isn't running already.
+func (m *mirror) start() {
+	if m == nil || m.quit != nil {
+		return
+	}
+	m.quit, m.done = make(chan struct{}), make(chan struct{})
+	go m.run(m.quit, m.done)
+}
+
+// stop stops the goroutine, and waits for it to send the changes queued before it was called.
+func (m *mirror) stop() {
+	if m == nil || m.quit == nil {
+		return
+	}
+	close(m.quit)
+	<-m.done
+	m.quit, m.done = nil, nil
+}
+
+func (m *mirror) run(quit, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case op := <-m.ops:
+			m.handle(op)
+		case <-quit:
+			for {
+				select {
+				case op := <-m.ops:
+					m.handle(op)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *mirror) handle(op mirrorOp) {
+	if op.fn != nil {
+		op.fn()
+		return
+	}
+	if err := m.send(op); err != nil {
+		m.fail(op, err)
+	}
+}
+
+func (m *mirror) send(op mirrorOp) error {
+	var err error
+	switch op.op {
+	case MirrorSave:
+		_, err = m.to.Save(op.it)
+	case MirrorDelete:
+		err = m.to.Delete(op.it)
+	case MirrorAddTo:
+		err = m.to.AddTo(op.col, op.it)
+	case MirrorRemoveFrom:
+		err = m.to.RemoveFrom(op.col, op.it)
+	}
+	return err
+}
+
+// enqueue queues a copy of it, so the caller can keep modifying it while the change waits to be sent.
+func (m *mirror) enqueue(op MirrorOp, col vocab.IRI, it vocab.Item) {
+	if m == nil || vocab.IsNil(it) {
+		return
+	}
+	o := mirrorOp{op: op, col: col, it: cache.Copy(it)}
+	select {
+	case m.ops <- o:
+	default:
+		m.fail(o, errors.Newf("the mirror queue is full"))
+	}
+}
+
+func (m *mirror) fail(op mirrorOp, err error) {
+	m.failed.Add(1)
+	m.errFn("unable to mirror %s %s: %+s", op.op, op.it.GetLink(), err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.failures) == mirrorFailuresSize {
+		m.failures = append(m.failures[:0], m.failures[1:]...)
+	}
+	m.failures = append(m.failures, MirrorFailure{Op: op.op, Col: op.col, IRI: op.it.GetLink(), Err: err, Time: time.Now().UTC()})
+}
+
+// FlushMirror waits until the changes made before calling it have been sent to the Mirror of the Config.
+func (r *repo) FlushMirror() {
+	if r.mirror == nil {
+		return
+	}
+	// NOTE(marius): the database is opened for the goroutine sending the changes to be running.
+	if err := r.Open(); err != nil {
+		r.errFn("unable to flush the mirror: %+s", err)
+		return
+	}
+	defer r.Close()
+	done := make(chan struct{})
+	r.mirror.ops <- mirrorOp{fn: func() { close(done) }}
+	<-done
+}
+
+// MirrorFailures returns the latest changes which couldn't be sent to the Mirror, oldest first, so they can be
+// retried, or the Mirror can be rebuilt from a copy of the repository.
+func (r *repo) MirrorFailures() []MirrorFailure {
+	if r.mirror == nil {
+		return nil
+	}
+	r.mirror.mu.Lock()
+	defer r.mirror.mu.Unlock()
+	return append([]MirrorFailure{}, r.mirror.failures...)
+}
+
+// MirrorStats returns the state of the queue of changes sent to the Mirror.
+func (r *repo) MirrorStats() MirrorStats {
+	if r.mirror == nil {
+		return MirrorStats{}
+	}
+	return MirrorStats{Queued: len(r.mirror.ops), Failed: r.mirror.failed.Load()}
+}
@@ -0,0 +1,164 @@
+package badger
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// recordingMirror records the changes it receives, and fails the ones for the IRIs in fail.
+type recordingMirror struct {
+	mu   sync.Mutex
+	ops  []MirrorOp
+	fail vocab.IRIs
+	wait chan struct{}
+}
+
+func (m *recordingMirror) record(op MirrorOp, it vocab.Item) error {
+	if m.wait != nil {
+		<-m.wait
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = append(m.ops, op)
+	if m.fail.Contains(it.GetLink()) {
+		return errors.Newf("unable to %s %s", op, it.GetLink())
+	}
+	return nil
+}
+
+func (m *recordingMirror) Save(it vocab.Item) (vocab.Item, error) {
+	return it, m.record(MirrorSave, it)
+}
+
+func (m *recordingMirror) Delete(it vocab.Item) error {
+	return m.record(MirrorDelete, it)
+}
+
+func (m *recordingMirror) AddTo(_ vocab.IRI, it vocab.Item) error {
+	return m.record(MirrorAddTo, it)
+}
+
+func (m *recordingMirror) RemoveFrom(_ vocab.IRI, it vocab.Item) error {
+	return m.record(MirrorRemoveFrom, it)
+}
+
+func Test_repo_Mirror(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	m := recordingMirror{fail: vocab.IRIs{"https://example.com/objects/2"}}
+	r.mirror = newMirror(&m, 0, t.Logf)
+
+	outbox := vocab.IRI("https://example.com/actors/jdoe/outbox")
+	ob1 := vocab.ObjectNew(vocab.NoteType)
+	ob1.ID = "https://example.com/objects/1"
+	ob2 := vocab.ObjectNew(vocab.NoteType)
+	ob2.ID = "https://example.com/objects/2"
+	for _, ob := range []*vocab.Object{ob1, ob2} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("Save() error = %s", err)
+		}
+	}
+	if err = r.AddTo(outbox, ob1); err != nil {
+		t.Fatalf("AddTo() error = %s", err)
+	}
+	if err = r.RemoveFrom(outbox, ob1); err != nil {
+		t.Fatalf("RemoveFrom() error = %s", err)
+	}
+	if err = r.Delete(ob1); err != nil {
+		t.Fatalf("Delete() error = %s", err)
+	}
+	r.FlushMirror()
+
+	want := []MirrorOp{MirrorSave, MirrorSave, MirrorAddTo, MirrorRemoveFrom, MirrorDelete}
+	if len(m.ops) != len(want) {
+		t.Fatalf("Mirror received %v, want %v", m.ops, want)
+	}
+	for i, op := range want {
+		if m.ops[i] != op {
+			t.Errorf("Mirror received %v, want %v", m.ops, want)
+			break
+		}
+	}
+	failures := r.MirrorFailures()
+	if len(failures) != 1 || failures[0].Op != MirrorSave || !failures[0].IRI.Equals(ob2.ID, false) {
+		t.Errorf("MirrorFailures() = %v, want the Save of %s", failures, ob2.ID)
+	}
+	if s := r.MirrorStats(); s.Failed != 1 || s.Queued != 0 {
+		t.Errorf("MirrorStats() = %+v, want one failure and no queued changes", s)
+	}
+}
+
+func Test_repo_Mirror_QueueFull(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	m := recordingMirror{wait: make(chan struct{})}
+	r.mirror = newMirror(&m, 1, t.Logf)
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	defer r.Close()
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	// NOTE(marius): the first change is waiting in the mirror, the second one in the queue,
+	// so the third one doesn't fit.
+	r.mirror.enqueue(MirrorSave, "", ob)
+	for len(r.mirror.ops) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	r.mirror.enqueue(MirrorSave, "", ob)
+	r.mirror.enqueue(MirrorDelete, "", ob)
+	close(m.wait)
+	r.FlushMirror()
+
+	if len(m.ops) != 2 {
+		t.Errorf("Mirror received %v, want two Saves", m.ops)
+	}
+	failures := r.MirrorFailures()
+	if len(failures) != 1 || failures[0].Op != MirrorDelete {
+		t.Errorf("MirrorFailures() = %v, want the dropped Delete", failures)
+	}
+}
+
+func Test_repo_Mirror_Close(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	m := recordingMirror{}
+	r.mirror = newMirror(&m, 0, t.Logf)
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	done := r.mirror.done
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	r.Close()
+
+	select {
+	case <-done:
+	default:
+		t.Fatalf("the mirror goroutine is still running after Close()")
+	}
+	if len(m.ops) != 1 || m.ops[0] != MirrorSave {
+		t.Errorf("Mirror received %v, want the Save queued before Close()", m.ops)
+	}
+
+	// NOTE(marius): the changes made while the database is closed are sent after the next Open.
+	r.mirror.enqueue(MirrorDelete, "", ob)
+	r.FlushMirror()
+	if len(m.ops) != 2 || m.ops[1] != MirrorDelete {
+		t.Errorf("Mirror received %v, want the Delete queued while closed", m.ops)
+	}
+}
@@ -0,0 +1,200 @@
+package badger
+
+import (
+	"bytes"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// The moves of the actors are recorded in two keys: <old actor path>/__moved_to with the new IRI as value,
+// and <new actor path>/__also_known_as with the list of the old IRIs as value.
+const (
+	movedToKey     = "__moved_to"
+	alsoKnownAsKey = "__also_known_as"
+)
+
+func getMovedToKey(p []byte) []byte {
+	return bytes.Join([][]byte{p, []byte(movedToKey)}, sep)
+}
+
+func getAlsoKnownAsKey(p []byte) []byte {
+	return bytes.Join([][]byte{p, []byte(alsoKnownAsKey)}, sep)
+}
+
+// MoveOptions configures the storage of the moves of actors done by MoveActor.
+type MoveOptions struct {
+	// Copy stores a copy of the old actor at the new IRI, when nothing is stored there yet.
+	// Otherwise, the new actor is expected to be saved separately, and the old one is only aliased to it.
+	Copy bool
+}
+
+// MoveActor stores the move of the actor from the old IRI to the new one, for a received Move activity:
+// it records the new IRI as the one the old actor moved to, and the old IRI as one the new actor is also known as.
+// In the same transaction, the old IRI is replaced by the new one in the followers and following collections
+// containing it, which are found from their membership keys.
+func (r *repo) MoveActor(from, to vocab.IRI, opt MoveOptions) error {
+	if len(from) == 0 || len(to) == 0 || from.Equals(to, false) {
+		return errors.NotValidf("invalid move from %q to %q", from, to)
+	}
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if opt.Copy {
+		if err = copyMovedActor(r, from, to); err != nil {
+			return err
+		}
+	}
+	changed := vocab.IRIs{from, to}
+	rewritten := make(vocab.IRIs, 0)
+	err = r.update(func(tx dbTxn) error {
+		if err := tx.Set(getMovedToKey(itemPath(from)), []byte(to)); err != nil {
+			return err
+		}
+		if err := onIRIsKey(tx, getAlsoKnownAsKey(itemPath(to)), addIRIFn(from)); err != nil {
+			return err
+		}
+		for _, col := range collectionsContaining(tx, from) {
+			typ := vocab.CollectionPath(filepath.Base(string(col)))
+			if typ != vocab.Followers && typ != vocab.Following {
+				continue
+			}
+			if err := onCollectionIRIs(tx, col, replaceIRIFn(from, to)); err != nil {
+				return err
+			}
+			rewritten = append(rewritten, col)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	r.clearNotFound(to)
+	r.invalidateResults(append(changed, rewritten...)...)
+	for _, col := range rewritten {
+		r.notify(MirrorRemoveFrom, col, from)
+		r.notify(MirrorAddTo, col, to)
+	}
+	r.logFn("Moved %s to %s, in %d collections", from, to, len(rewritten))
+	return nil
+}
+
+// copyMovedActor saves a copy of the actor stored at from, with the IRI to, when nothing is stored at to.
+func copyMovedActor(r *repo, from, to vocab.IRI) error {
+	var old vocab.Item
+	err := r.d.View(func(tx *badger.Txn) error {
+		if _, err := tx.Get(getObjectKey(itemPath(to))); err == nil {
+			return nil
+		}
+		var err error
+		old, err = loadRawItem(tx, itemPath(from))
+		return err
+	})
+	if err != nil {
+		return errors.NewNotFound(wrapErr(ErrNotFound, err), "unable to load the moved actor %s", from)
+	}
+	if vocab.IsNil(old) {
+		return nil
+	}
+	if !vocab.ActorTypes.Contains(old.GetType()) {
+		return errors.NotValidf("%s is not an actor", from)
+	}
+	moved := cache.Copy(old)
+	err = vocab.OnActor(moved, func(a *vocab.Actor) error {
+		a.ID = to
+		// NOTE(marius): the collections of the copy are the ones of the new actor, otherwise saving it would
+		// share the collections of the old actor with it.
+		a.Inbox = movedCollection(a.Inbox, from, to)
+		a.Outbox = movedCollection(a.Outbox, from, to)
+		a.Followers = movedCollection(a.Followers, from, to)
+		a.Following = movedCollection(a.Following, from, to)
+		a.Liked = movedCollection(a.Liked, from, to)
+		a.Replies = movedCollection(a.Replies, from, to)
+		a.Likes = movedCollection(a.Likes, from, to)
+		a.Shares = movedCollection(a.Shares, from, to)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = save(r, moved)
+	return err
+}
+
+// movedCollection returns the IRI of the col collection of the actor from, rebased on the to IRI.
+// The collections which don't belong to the from actor are returned unchanged.
+func movedCollection(col vocab.Item, from, to vocab.IRI) vocab.Item {
+	if vocab.IsNil(col) {
+		return col
+	}
+	iri := col.GetLink()
+	if !isPathOrChildKey([]byte(from), []byte(iri)) {
+		return col
+	}
+	return to + iri[len(from):]
+}
+
+// replaceIRIFn replaces the from IRI with the to one, keeping a single entry when both are present.
+func replaceIRIFn(from, to vocab.IRI) func(iris vocab.IRIs) (vocab.IRIs, error) {
+	return func(iris vocab.IRIs) (vocab.IRIs, error) {
+		iris, _ = removeIRIFn(from)(iris)
+		return addIRIFn(to)(iris)
+	}
+}
+
+// MovedTo returns the IRI the actor at iri moved to, or a NotFound error when it didn't move.
+func (r *repo) MovedTo(iri vocab.IRI) (vocab.IRI, error) {
+	if err := r.Open(); err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var to vocab.IRI
+	err := r.d.View(func(tx *badger.Txn) error {
+		i, err := tx.Get(getMovedToKey(itemPath(iri)))
+		if err != nil {
+			return err
+		}
+		return i.Value(func(val []byte) error {
+			to = vocab.IRI(val)
+			return nil
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return "", errors.NewNotFound(ErrNotFound, "%s didn't move", iri)
+	}
+	return to, err
+}
+
+// AlsoKnownAs returns the IRIs of the actors which moved to the actor at iri.
+func (r *repo) AlsoKnownAs(iri vocab.IRI) (vocab.IRIs, error) {
+	if err := r.Open(); err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	aliases := make(vocab.IRIs, 0)
+	err := r.d.View(func(tx *badger.Txn) error {
+		i, err := tx.Get(getAlsoKnownAsKey(itemPath(iri)))
+		if err != nil {
+			return err
+		}
+		return i.Value(func(val []byte) error {
+			col, _ := decodeIRIList(val)
+			for _, it := range col {
+				aliases = append(aliases, it.GetLink())
+			}
+			return nil
+		})
+	})
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	return aliases, nil
+}
@@ -0,0 +1,102 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_MoveActor(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	old := vocab.PersonNew("https://old.example.com/actors/jdoe")
+	to := vocab.IRI("https://new.example.com/actors/jdoe")
+	follower := vocab.PersonNew("https://example.com/actors/alice")
+	for _, it := range []vocab.Item{old, follower} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	following := vocab.Following.IRI(follower)
+	liked := vocab.Liked.IRI(follower)
+	for _, col := range []vocab.IRI{following, liked} {
+		if err = r.AddTo(col, old.ID); err != nil {
+			t.Fatalf("unable to add to %s: %s", col, err)
+		}
+	}
+
+	if err = r.MoveActor(old.ID, to, MoveOptions{Copy: true}); err != nil {
+		t.Fatalf("MoveActor() error = %s", err)
+	}
+	if moved, err := r.MovedTo(old.ID); err != nil || !moved.Equals(to, false) {
+		t.Errorf("MovedTo() = %s, %v, want %s", moved, err, to)
+	}
+	if aliases, err := r.AlsoKnownAs(to); err != nil || !aliases.Contains(old.ID) {
+		t.Errorf("AlsoKnownAs() = %v, %v, want %s", aliases, err, old.ID)
+	}
+	if it, err := r.Load(to); err != nil || it.GetType() != vocab.PersonType {
+		t.Errorf("Load() of the copied actor = %v, %v", it, err)
+	}
+	col, err := r.Load(following)
+	if err != nil {
+		t.Fatalf("Load() of %s error = %s", following, err)
+	}
+	if items := col.(vocab.ItemCollection); !items.Contains(to) || items.Contains(old.ID) {
+		t.Errorf("Load() of %s = %v, want %s instead of %s", following, items, to, old.ID)
+	}
+	col, err = r.Load(liked)
+	if err != nil {
+		t.Fatalf("Load() of %s error = %s", liked, err)
+	}
+	if items := col.(vocab.ItemCollection); !items.Contains(old.ID) {
+		t.Errorf("Load() of %s = %v, rewritten by MoveActor()", liked, items)
+	}
+
+	if _, err = r.MovedTo(to); !errors.IsNotFound(err) {
+		t.Errorf("MovedTo() of an actor which didn't move error = %v, want NotFound", err)
+	}
+	if err = r.MoveActor(to, to, MoveOptions{}); !errors.IsNotValid(err) {
+		t.Errorf("MoveActor() to the same IRI error = %v, want NotValid", err)
+	}
+}
+
+func Test_repo_MoveActor_Followers(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	old := vocab.PersonNew("https://old.example.com/actors/jdoe")
+	old.Followers = vocab.Followers.IRI(old)
+	to := vocab.IRI("https://new.example.com/actors/jdoe")
+	follower := vocab.IRI("https://example.com/actors/alice")
+	if _, err = r.Save(old); err != nil {
+		t.Fatalf("unable to save %s: %s", old.ID, err)
+	}
+	if err = r.AddTo(old.Followers.GetLink(), follower); err != nil {
+		t.Fatalf("unable to add to %s: %s", old.Followers.GetLink(), err)
+	}
+
+	if err = r.MoveActor(old.ID, to, MoveOptions{Copy: true}); err != nil {
+		t.Fatalf("MoveActor() error = %s", err)
+	}
+	col, err := r.Load(old.Followers.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of %s error = %s", old.Followers.GetLink(), err)
+	}
+	if items := col.(vocab.ItemCollection); !items.Contains(follower) {
+		t.Errorf("Load() of %s = %v, want %s", old.Followers.GetLink(), items, follower)
+	}
+	it, err := r.Load(to)
+	if err != nil {
+		t.Fatalf("Load() of the copied actor error = %s", err)
+	}
+	_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+		if want := vocab.Followers.IRI(to); !a.Followers.GetLink().Equals(want, false) {
+			t.Errorf("copied actor followers = %s, want %s", a.Followers.GetLink(), want)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,119 @@
+package badger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+const (
+	nodeInfoActiveMonth    = 30 * 24 * time.Hour
+	nodeInfoActiveHalfyear = 180 * 24 * time.Hour
+)
+
+// nodeInfoPostTypes are the types of the objects counted as the posts of the local users.
+var nodeInfoPostTypes = vocab.ActivityVocabularyTypes{vocab.ArticleType, vocab.NoteType, vocab.PageType, vocab.QuestionType}
+
+// NodeInfoStats contains the usage statistics of the NodeInfo document of the instance.
+type NodeInfoStats struct {
+	// TotalUsers is the number of local Person actors.
+	TotalUsers int
+	// ActiveMonth is the number of local users who published activities in the last 30 days.
+	ActiveMonth int
+	// ActiveHalfyear is the number of local users who published activities in the last 180 days.
+	ActiveHalfyear int
+	// LocalPosts is the number of local articles, notes, pages and questions.
+	LocalPosts int
+}
+
+// NodeInfoStats computes the usage statistics of the NodeInfo document of the instance from the type,
+// the published and the actor indexes, without loading the objects. The local objects are the ones stored under
+// the hosts of the Service actors, or all of them when there is no Service actor. The deleted objects are
+// not counted.
+func (r *repo) NodeInfoStats() (NodeInfoStats, error) {
+	for _, name := range []string{TypeIndex{}.Name(), PublishedIndex{}.Name(), ActorIndex{}.Name()} {
+		if !hasIndex(r.indexes, name) {
+			return NodeInfoStats{}, errors.NotValidf("the %s index is not maintained", name)
+		}
+	}
+	err := r.Open()
+	if err != nil {
+		return NodeInfoStats{}, err
+	}
+	defer r.Close()
+
+	stats := NodeInfoStats{}
+	err = r.d.View(func(tx *badger.Txn) error {
+		typeName := TypeIndex{}.Name()
+		hosts := make([][]byte, 0)
+		for p := range scanIndex(tx, typeName, nil, exactValues(vocab.ServiceType)) {
+			hosts = append(hosts, []byte(p))
+		}
+		isLocal := func(p string) bool {
+			if len(hosts) == 0 {
+				return true
+			}
+			for _, h := range hosts {
+				if p == string(h) || bytes.HasPrefix([]byte(p), append(append([]byte{}, h...), sep...)) {
+					return true
+				}
+			}
+			return false
+		}
+		deleted := scanIndex(tx, typeName, nil, exactValues(vocab.TombstoneType))
+
+		users := make(map[string]struct{})
+		for p := range scanIndex(tx, typeName, nil, exactValues(vocab.PersonType)) {
+			if _, ok := deleted[p]; !ok && isLocal(p) {
+				users[p] = struct{}{}
+			}
+		}
+		stats.TotalUsers = len(users)
+		for p := range scanIndex(tx, typeName, nil, exactValues(nodeInfoPostTypes...)) {
+			if _, ok := deleted[p]; !ok && isLocal(p) {
+				stats.LocalPosts++
+			}
+		}
+
+		now := time.Now().UTC()
+		month := publishedSince(tx, now.Add(-nodeInfoActiveMonth))
+		halfyear := publishedSince(tx, now.Add(-nodeInfoActiveHalfyear))
+		activeMonth, activeHalfyear := activeActors(tx, users, month), activeActors(tx, users, halfyear)
+		stats.ActiveMonth, stats.ActiveHalfyear = len(activeMonth), len(activeHalfyear)
+		return nil
+	})
+	return stats, err
+}
+
+// publishedSince returns the paths of the objects published after t.
+func publishedSince(tx dbTxn, t time.Time) map[string]struct{} {
+	rng := ValueRange{Start: t.Format(publishedIndexFormat), End: "\xff"}
+	return scanIndex(tx, PublishedIndex{}.Name(), nil, []ValueRange{rng})
+}
+
+// activeActors returns the paths of the users who are the actors of any of the activities at the paths.
+func activeActors(tx dbTxn, users, activities map[string]struct{}) map[string]struct{} {
+	active := make(map[string]struct{})
+	prefix := getIndexPrefix(ActorIndex{}.Name())
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		if _, ok := activities[string(indexKeyPath(k))]; !ok {
+			continue
+		}
+		actor := k[len(prefix):bytes.IndexByte(k, indexValueSep)]
+		p := string(itemPath(vocab.IRI(actor)))
+		if _, ok := users[p]; ok {
+			active[p] = struct{}{}
+		}
+	}
+	return active
+}
@@ -0,0 +1,56 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_NodeInfoStats(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = r.NodeInfoStats(); !errors.IsNotValid(err) {
+		t.Errorf("NodeInfoStats() without indexes error = %v, want NotValid", err)
+	}
+	r.indexes = DefaultIndexes
+
+	now := time.Now().UTC()
+	service := vocab.ServiceNew("https://example.com")
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	idle := vocab.PersonNew("https://example.com/actors/idle")
+	remote := vocab.PersonNew("https://remote.example.com/actors/jdoe")
+	deleted := &vocab.Tombstone{ID: "https://example.com/actors/deleted", Type: vocab.TombstoneType, FormerType: vocab.PersonType}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	remoteNote := vocab.ObjectNew(vocab.NoteType)
+	remoteNote.ID = "https://remote.example.com/objects/1"
+	recent := vocab.CreateNew("https://example.com/activities/1", note.ID)
+	recent.Actor = jdoe.ID
+	recent.Published = now.Add(-time.Hour)
+	old := vocab.CreateNew("https://example.com/activities/2", note.ID)
+	old.Actor = idle.ID
+	old.Published = now.Add(-365 * 24 * time.Hour)
+	remoteRecent := vocab.CreateNew("https://remote.example.com/activities/1", remoteNote.ID)
+	remoteRecent.Actor = remote.ID
+	remoteRecent.Published = now.Add(-time.Hour)
+
+	for _, it := range []vocab.Item{service, jdoe, idle, remote, deleted, note, remoteNote, recent, old, remoteRecent} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	got, err := r.NodeInfoStats()
+	if err != nil {
+		t.Fatalf("NodeInfoStats() error = %s", err)
+	}
+	want := NodeInfoStats{TotalUsers: 2, ActiveMonth: 1, ActiveHalfyear: 1, LocalPosts: 1}
+	if got != want {
+		t.Errorf("NodeInfoStats() = %+v, want %+v", got, want)
+	}
+}
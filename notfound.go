@@ -0,0 +1,40 @@
+package badger
+
+import (
+	vocab "github.com/go-ap/activitypub"
+)
+
+// notFoundKey returns the IRI under which a failed lookup gets remembered, which is the same for all the
+// IRIs resolving to the same storage path.
+func notFoundKey(iri vocab.IRI) vocab.IRI {
+	return vocab.IRI(itemPath(iri))
+}
+
+// isNotFound returns if a recent lookup of the iri found nothing stored at it.
+func (r *repo) isNotFound(iri vocab.IRI) bool {
+	if r.notFound == nil {
+		return false
+	}
+	return r.notFound.Has(notFoundKey(iri))
+}
+
+// setNotFound remembers, for the NotFoundTTL of the Config, that nothing is stored at iri.
+func (r *repo) setNotFound(iri vocab.IRI) {
+	if r.notFound == nil {
+		return
+	}
+	r.notFound.Set(notFoundKey(iri))
+}
+
+// clearNotFound forgets the failed lookups of the iris, as something was just stored at them, or all the failed
+// lookups when called without any.
+func (r *repo) clearNotFound(iris ...vocab.IRI) {
+	if r.notFound == nil {
+		return
+	}
+	keys := make(vocab.IRIs, 0, len(iris))
+	for _, iri := range iris {
+		keys = append(keys, notFoundKey(iri))
+	}
+	r.notFound.Remove(keys...)
+}
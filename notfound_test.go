@@ -0,0 +1,52 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_Load_NotFoundCache(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.notFound = cache.NewNotFound(time.Minute)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	if _, err = r.Load(ob.ID); !errors.IsNotFound(err) {
+		t.Fatalf("Load() error = %v, want not found", err)
+	}
+
+	// NOTE(marius): the object gets stored bypassing Save, so the failed lookup is still remembered.
+	raw, _ := encodeItemFn(ob)
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getObjectKey(itemPath(ob.ID)), raw)
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store %s: %s", ob.ID, err)
+	}
+	if _, err = r.Load(ob.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() error = %v, want the remembered not found", err)
+	}
+
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("Save() error = %s", err)
+	}
+	it, err := r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load() after Save() error = %s", err)
+	}
+	if !it.GetLink().Equals(ob.ID, false) {
+		t.Errorf("Load() = %s, want %s", it.GetLink(), ob.ID)
+	}
+}
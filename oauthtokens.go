@@ -0,0 +1,194 @@
+package badger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+// TokenType is the kind of the OAuth2 token described by a TokenInfo.
+type TokenType string
+
+const (
+	// AuthorizeToken is an authorization code.
+	AuthorizeToken TokenType = authorizeBucket
+	// AccessToken is an access token.
+	AccessToken TokenType = accessBucket
+	// RefreshToken is a refresh token.
+	RefreshToken TokenType = refreshBucket
+)
+
+// TokenInfo describes an OAuth2 token stored by the osin storage, for listing the sessions.
+type TokenInfo struct {
+	Type   TokenType `json:"type"`
+	Token  string    `json:"token"`
+	Client string    `json:"client,omitempty"`
+	Scope  string    `json:"scope,omitempty"`
+	// Access is the access token of a refresh token.
+	Access    string    `json:"access,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	// ExpiresAt is zero for the tokens which don't expire.
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	Expired   bool      `json:"expired"`
+	UserData  any       `json:"userData,omitempty"`
+}
+
+// ListTokens returns the stored authorization codes, access tokens and refresh tokens, in the order of their keys.
+// When client is not empty, only the tokens of that client are returned.
+func (r *repo) ListTokens(client string) ([]TokenInfo, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, errors.Annotatef(err, "Unable to open badger store")
+	}
+	defer r.Close()
+
+	now := time.Now().UTC()
+	tokens := make([]TokenInfo, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		for _, typ := range []TokenType{AuthorizeToken, AccessToken, RefreshToken} {
+			err := eachToken(tx, typ, func(token string, raw []byte) error {
+				info, err := tokenInfo(tx, typ, token, raw, now)
+				if err != nil {
+					return errors.Annotatef(err, "unable to decode %s token %s", typ, token)
+				}
+				if client == "" || info.Client == client {
+					tokens = append(tokens, info)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return tokens, err
+}
+
+// RevokeToken removes the authorization code, the access token or the refresh token. Revoking an access token
+// removes its refresh token too, and revoking a refresh token removes its access token.
+func (r *repo) RevokeToken(token string) error {
+	if token == "" {
+		return errors.NotValidf("empty token")
+	}
+	err := r.Open()
+	if err != nil {
+		return errors.Annotatef(err, "Unable to open badger store")
+	}
+	defer r.Close()
+
+	return r.update(func(tx dbTxn) error {
+		keys := make([][]byte, 0)
+		if _, err := tx.Get(r.authorizePath(token)); err == nil {
+			keys = append(keys, r.authorizePath(token))
+		}
+		if i, err := tx.Get(r.accessPath(token)); err == nil {
+			keys = append(keys, r.accessPath(token))
+			a := acc{}
+			if err = i.Value(func(raw []byte) error { return decodeFn(raw, &a) }); err == nil && a.RefreshToken != "" {
+				keys = append(keys, r.refreshPath(a.RefreshToken))
+			}
+		}
+		if i, err := tx.Get(r.refreshPath(token)); err == nil {
+			keys = append(keys, r.refreshPath(token))
+			rf := ref{}
+			if err = i.Value(func(raw []byte) error { return decodeFn(raw, &rf) }); err == nil && rf.Access != "" {
+				keys = append(keys, r.accessPath(rf.Access))
+			}
+		}
+		if len(keys) == 0 {
+			return errors.NewNotFound(ErrNotFound, "token %s not found", token)
+		}
+		for _, k := range keys {
+			if err := tx.Delete(k); err != nil {
+				return errors.Annotatef(err, "unable to remove %s", k)
+			}
+		}
+		r.logFn("Revoked token %s", token)
+		return nil
+	})
+}
+
+// PurgeExpiredTokens removes the authorization codes, and the access tokens without a refresh token, which
+// expired. It returns the number of tokens removed.
+func (r *repo) PurgeExpiredTokens() (int, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, errors.Annotatef(err, "Unable to open badger store")
+	}
+	defer r.Close()
+
+	removed := 0
+	err = r.update(func(tx dbTxn) error {
+		for _, k := range expiredTokens(tx, time.Now().UTC()) {
+			if err := tx.Delete([]byte(k)); err != nil {
+				return errors.Annotatef(err, "unable to remove %s", k)
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// eachToken calls fn with the tokens of type typ, and their stored values, which are valid only during the call.
+func eachToken(tx dbTxn, typ TokenType, fn func(token string, raw []byte) error) error {
+	prefix := append(badgerItemPath(string(typ)), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		token := string(bytes.TrimPrefix(it.Item().Key(), prefix))
+		err := it.Item().Value(func(raw []byte) error {
+			return fn(token, raw)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tokenInfo(tx dbTxn, typ TokenType, token string, raw []byte, now time.Time) (TokenInfo, error) {
+	info := TokenInfo{Type: typ, Token: token}
+	expiresAt := func(createdAt time.Time, expiresIn time.Duration) {
+		info.CreatedAt = createdAt
+		if expiresIn > 0 {
+			info.ExpiresAt = createdAt.Add(expiresIn * time.Second)
+		}
+		info.Expired = tokenExpired(createdAt, expiresIn, now)
+	}
+	switch typ {
+	case AuthorizeToken:
+		a := auth{}
+		if err := decodeFn(raw, &a); err != nil {
+			return info, err
+		}
+		info.Client, info.Scope, info.UserData = a.Client, a.Scope, a.Extra
+		expiresAt(a.CreatedAt, a.ExpiresIn)
+	case AccessToken:
+		a := acc{}
+		if err := decodeFn(raw, &a); err != nil {
+			return info, err
+		}
+		info.Client, info.Scope, info.UserData = a.Client, a.Scope, a.Extra
+		expiresAt(a.CreatedAt, a.ExpiresIn)
+	case RefreshToken:
+		rf := ref{}
+		if err := decodeFn(raw, &rf); err != nil {
+			return info, err
+		}
+		info.Access = rf.Access
+		// NOTE(marius): the client of a refresh token is the one of its access token, when it's still stored
+		if i, err := tx.Get(badgerItemPath(accessBucket, rf.Access)); err == nil {
+			a := acc{}
+			if err = i.Value(func(raw []byte) error { return decodeFn(raw, &a) }); err == nil {
+				info.Client, info.Scope, info.UserData = a.Client, a.Scope, a.Extra
+			}
+		}
+	}
+	return info, nil
+}
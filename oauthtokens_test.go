@@ -0,0 +1,84 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ap/errors"
+	"github.com/openshift/osin"
+)
+
+func tokenNames(tokens []TokenInfo) []string {
+	names := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		names = append(names, string(tok.Type)+"/"+tok.Token)
+	}
+	return names
+}
+
+func Test_repo_tokens(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	client := &osin.DefaultClient{Id: "client"}
+	if err = r.CreateClient(client); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+	for _, a := range []*osin.AuthorizeData{
+		{Client: client, Code: "expired", ExpiresIn: 60, CreatedAt: hourAgo},
+		{Client: client, Code: "valid", ExpiresIn: 3600, CreatedAt: time.Now()},
+	} {
+		if err = r.SaveAuthorize(a); err != nil {
+			t.Fatalf("unable to save authorization %s: %s", a.Code, err)
+		}
+	}
+	for _, a := range []*osin.AccessData{
+		{Client: client, AccessToken: "old", ExpiresIn: 60, CreatedAt: hourAgo},
+		{Client: client, AccessToken: "token", ExpiresIn: 3600, CreatedAt: time.Now()},
+		{Client: client, AccessToken: "refreshed", RefreshToken: "refresh", ExpiresIn: 3600, CreatedAt: time.Now()},
+	} {
+		if err = r.SaveAccess(a); err != nil {
+			t.Fatalf("unable to save access %s: %s", a.AccessToken, err)
+		}
+	}
+
+	tokens, err := r.ListTokens("")
+	if err != nil {
+		t.Fatalf("ListTokens() error = %s", err)
+	}
+	want := []string{"authorize/expired", "authorize/valid", "access/old", "access/token", "refresh/refresh"}
+	if got := tokenNames(tokens); len(got) != len(want) {
+		t.Fatalf("ListTokens() = %v, want %v", got, want)
+	}
+	for i, name := range tokenNames(tokens) {
+		if name != want[i] {
+			t.Errorf("ListTokens()[%d] = %s, want %s", i, name, want[i])
+		}
+	}
+	if !tokens[0].Expired || tokens[1].Expired || tokens[1].Client != "client" || tokens[4].Access != "refreshed" {
+		t.Errorf("ListTokens() = %+v, want the expiration, the client and the access token set", tokens)
+	}
+	if tokens, _ = r.ListTokens("other"); len(tokens) != 0 {
+		t.Errorf("ListTokens() of another client = %v, want none", tokenNames(tokens))
+	}
+
+	if err = r.RevokeToken("refresh"); err != nil {
+		t.Errorf("RevokeToken() error = %s", err)
+	}
+	if err = r.RevokeToken("missing"); !errors.IsNotFound(err) {
+		t.Errorf("RevokeToken() of a missing token error = %v, want NotFound", err)
+	}
+	removed, err := r.PurgeExpiredTokens()
+	if err != nil {
+		t.Fatalf("PurgeExpiredTokens() error = %s", err)
+	}
+	if removed != 2 {
+		t.Errorf("PurgeExpiredTokens() = %d, want 2", removed)
+	}
+	tokens, _ = r.ListTokens("")
+	if got := tokenNames(tokens); len(got) != 2 || got[0] != "authorize/valid" || got[1] != "access/token" {
+		t.Errorf("ListTokens() after revoking and purging = %v, want the valid ones", got)
+	}
+}
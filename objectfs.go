@@ -0,0 +1,194 @@
+package badger
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// objectFS is the read-only view of the objects of the repository returned by FS.
+type objectFS struct {
+	r *repo
+}
+
+// FS returns a read-only io/fs.FS of the objects and the collections of the repository, in the directory layout
+// of storage-fs and ExportToFS: the raw JSON of an object is the __raw file of the folder of the path of its IRI,
+// like example.com/actors/jdoe/__raw. It can be used by the tools working with file systems, like tar writers,
+// without knowing badger. The metadata, the OAuth data and the keys which badger derives from the objects are
+// not part of it.
+//
+// The files are read when they are opened, and the folders list the objects stored when they are opened.
+// The modification times are not known, and are zero.
+func (r *repo) FS() fs.FS {
+	return objectFS{r: r}
+}
+
+func (o objectFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := o.r.Open(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer o.r.Close()
+
+	var f fs.File
+	err := o.r.d.View(func(tx *badger.Txn) (err error) {
+		if path.Base(name) == objectKey {
+			f, err = openObjectFile(tx, name)
+		} else {
+			f, err = openObjectDir(tx, name)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func openObjectFile(tx dbTxn, name string) (fs.File, error) {
+	k := []byte(name)
+	if !isExportedKey(k) {
+		return nil, fs.ErrNotExist
+	}
+	i, err := tx.Get(k)
+	if err == badger.ErrKeyNotFound {
+		return nil, fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, err := i.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &objectFile{Reader: bytes.NewReader(raw), info: objectInfo{name: objectKey, size: int64(len(raw))}}, nil
+}
+
+// openObjectDir lists the folders and the __raw file directly under the folder at name, from the keys of the objects
+// stored under it. The folders which don't contain any object don't exist.
+func openObjectDir(tx dbTxn, name string) (fs.File, error) {
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = []byte(prefix)
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	entries := make([]fs.DirEntry, 0)
+	for it.Seek(opt.Prefix); it.ValidForPrefix(opt.Prefix); it.Next() {
+		i := it.Item()
+		k := i.Key()
+		if !isObjectKey(k) || !isExportedKey(k) {
+			continue
+		}
+		child, _, isDir := strings.Cut(string(k[len(prefix):]), "/")
+		if last := len(entries) - 1; last >= 0 && entries[last].Name() == child {
+			continue
+		}
+		if isDir {
+			entries = append(entries, objectInfo{name: child, dir: true})
+			continue
+		}
+		if child != objectKey {
+			continue
+		}
+		size := 0
+		if err := i.Value(func(raw []byte) error { size = len(raw); return nil }); err != nil {
+			return nil, err
+		}
+		entries = append(entries, objectInfo{name: child, size: int64(size)})
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, fs.ErrNotExist
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return &objectDir{info: objectInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// objectInfo describes the files and the folders of the objectFS.
+type objectInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i objectInfo) Name() string       { return i.name }
+func (i objectInfo) Size() int64        { return i.size }
+func (i objectInfo) ModTime() time.Time { return time.Time{} }
+func (i objectInfo) IsDir() bool        { return i.dir }
+func (i objectInfo) Sys() any           { return nil }
+
+func (i objectInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i objectInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i objectInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+// objectFile is an opened __raw file, with the raw JSON of an object.
+type objectFile struct {
+	*bytes.Reader
+	info objectInfo
+}
+
+func (f *objectFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *objectFile) Close() error {
+	return nil
+}
+
+// objectDir is an opened folder.
+type objectDir struct {
+	info    objectInfo
+	entries []fs.DirEntry
+	pos     int
+}
+
+func (d *objectDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *objectDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *objectDir) Close() error {
+	return nil
+}
+
+func (d *objectDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.pos:]
+	if n <= 0 {
+		d.pos = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(rest))
+	d.pos += n
+	return rest[:n], nil
+}
@@ -0,0 +1,67 @@
+package badger
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+func Test_repo_FS(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("unable to add to %s: %s", jdoe.Outbox.GetLink(), err)
+	}
+	if err = r.SaveMetadata(processing.Metadata{Pw: []byte("hash")}, jdoe.ID); err != nil {
+		t.Fatalf("unable to save the metadata of %s: %s", jdoe.ID, err)
+	}
+	if err = r.CreateClient(&osin.DefaultClient{Id: "app", Secret: "s3cr3t"}); err != nil {
+		t.Fatalf("unable to create client: %s", err)
+	}
+
+	fsys := r.FS()
+	files := []string{"example.com/actors/jdoe/__raw", "example.com/actors/jdoe/outbox/__raw", "example.com/objects/1/__raw"}
+	if err = fstest.TestFS(fsys, files...); err != nil {
+		t.Errorf("FS() is not a valid file system: %s", err)
+	}
+
+	raw, err := fs.ReadFile(fsys, "example.com/objects/1/__raw")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %s", err)
+	}
+	it, err := decodeItemFn(raw)
+	if err != nil || it.GetLink() != note.ID {
+		t.Errorf("ReadFile() = %s, want the raw JSON of %s", raw, note.ID)
+	}
+
+	// NOTE(marius): the metadata, the OAuth data and the internal keys are not part of the view
+	for _, name := range []string{"example.com/actors/jdoe/__meta_data", "oauth", "__index", "example.com/actors/missing"} {
+		if _, err = fs.Stat(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Stat(%s) error = %v, want %s", name, err, fs.ErrNotExist)
+		}
+	}
+	root, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		t.Fatalf("ReadDir(.) error = %s", err)
+	}
+	if len(root) != 1 || root[0].Name() != "example.com" || !root[0].IsDir() {
+		t.Errorf("ReadDir(.) = %v, want only the example.com folder", root)
+	}
+}
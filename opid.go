@@ -0,0 +1,45 @@
+package badger
+
+import (
+	"context"
+)
+
+// operationIDKey is the key of the operation ID in the contexts.
+type operationIDKey struct{}
+
+// ContextWithOperationID returns a copy of ctx carrying the ID of the operation, like the ID of an HTTP request,
+// which WithContext attaches to the log lines of the storage.
+func ContextWithOperationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, operationIDKey{}, id)
+}
+
+// OperationID returns the ID of the operation carried by ctx, or an empty string.
+func OperationID(ctx context.Context) string {
+	id, _ := ctx.Value(operationIDKey{}).(string)
+	return id
+}
+
+// WithContext returns a copy of the repository for the operation of ctx, which attaches its ID to the log lines,
+// as the operation_id field for the structured Logger, and as a prefix for LogFn and ErrFn, so the storage logs
+// can be correlated with the HTTP request logs. The copy shares the database handle and the caches with r.
+// When ctx doesn't carry an operation ID, r is returned.
+func (r *repo) WithContext(ctx context.Context) *repo {
+	id := OperationID(ctx)
+	if id == "" {
+		return r
+	}
+	c := r.copy()
+	if c.log != nil {
+		c.log = c.log.With("operation_id", id)
+	}
+	c.logFn = withOperationID(r.logFn, id)
+	c.errFn = withOperationID(r.errFn, id)
+	return c
+}
+
+// withOperationID returns the loggerFn prefixing the messages with the operation ID.
+func withOperationID(fn loggerFn, id string) loggerFn {
+	return func(s string, p ...interface{}) {
+		fn("[%s] "+s, append([]interface{}{id}, p...)...)
+	}
+}
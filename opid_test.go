@@ -0,0 +1,49 @@
+package badger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_WithContext(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r, err := New(Config{Path: t.TempDir(), Logger: log})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	if c := r.WithContext(context.Background()); c != r {
+		t.Errorf("WithContext() without an operation ID = %p, want %p", c, r)
+	}
+
+	ctx := ContextWithOperationID(context.Background(), "req-42")
+	if id := OperationID(ctx); id != "req-42" {
+		t.Errorf("OperationID() = %q, want req-42", id)
+	}
+	if _, err = r.WithContext(ctx).Load("https://example.com/objects/missing"); err == nil {
+		t.Fatalf("Load() of a missing object found it")
+	}
+	if !strings.Contains(buf.String(), `"operation_id":"req-42"`) {
+		t.Errorf("the log lines of the operation %s don't have its ID", buf.String())
+	}
+
+	lines := make([]string, 0)
+	r.logFn = func(s string, p ...interface{}) {
+		lines = append(lines, fmt.Sprintf(s, p...))
+	}
+	r.log = nil
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	if _, err = r.WithContext(ctx).Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[len(lines)-1], "[req-42] ") {
+		t.Errorf("the log lines of the operation are %v, want them prefixed with its ID", lines)
+	}
+}
@@ -76,10 +76,11 @@ func (r *repo) Close() {
 	}
 }
 
-// Clone
+// Clone returns a copy of the repository for osin to use during a request. The copy shares the database handle
+// and the caches with r, which are safe for concurrent use, so the lookups done during authentication
+// use the same warm caches as the ones for loading content.
 func (r *repo) Clone() osin.Storage {
-	r.Close()
-	return r
+	return r.copy()
 }
 
 func badgerItemPath(pieces ...string) []byte {
@@ -97,9 +98,9 @@ func (r *repo) loadTxnClient(c *osin.DefaultClient, id string) func(tx *badger.T
 	return func(tx *badger.Txn) error {
 		it, err := tx.Get(fullPath)
 		if err != nil {
-			return errors.NewNotFound(err, "Invalid path %s", fullPath)
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "Invalid path %s", fullPath)
 		}
-		return it.Value(loadRawClient(c))
+		return it.Value(r.rewrittenFn(loadRawClient(c)))
 	}
 }
 
@@ -120,7 +121,7 @@ func loadRawClient(c *osin.DefaultClient) func(raw []byte) error {
 // GetClient
 func (r *repo) GetClient(id string) (osin.Client, error) {
 	if id == "" {
-		return nil, errors.NotFoundf("Empty client id")
+		return nil, errors.NewNotFound(ErrNotFound, "Empty client id")
 	}
 	if err := r.Open(); err != nil {
 		return nil, err
@@ -148,7 +149,7 @@ func (r *repo) ListClients() ([]osin.Client, error) {
 			item := it.Item()
 
 			c := osin.DefaultClient{}
-			item.Value(loadRawClient(&c))
+			item.Value(r.rewrittenFn(loadRawClient(&c)))
 
 			clients = append(clients, &c)
 		}
@@ -176,7 +177,7 @@ func (r *repo) UpdateClient(c osin.Client) error {
 	if err != nil {
 		return errors.Annotatef(err, "Unable to marshal client object")
 	}
-	return r.d.NewWriteBatch().Set(r.clientPath(c.GetId()), raw)
+	return r.newWriteBatch().Set(r.clientPath(c.GetId()), raw)
 }
 
 // CreateClient stores the client in the database and returns an error, if something went wrong.
@@ -191,7 +192,7 @@ func (r *repo) RemoveClient(id string) error {
 		return errors.Annotatef(err, "Unable to open badger store")
 	}
 	defer r.Close()
-	return r.d.NewWriteBatch().Delete(r.clientPath(id))
+	return r.newWriteBatch().Delete(r.clientPath(id))
 }
 
 func (r *repo) authorizePath(code string) []byte {
@@ -220,7 +221,7 @@ func (r *repo) SaveAuthorize(data *osin.AuthorizeData) error {
 	if err != nil {
 		return errors.Annotatef(err, "Unable to marshal authorization object")
 	}
-	return r.d.NewWriteBatch().Set(r.authorizePath(data.Code), raw)
+	return r.newWriteBatch().Set(r.authorizePath(data.Code), raw)
 }
 
 func (r *repo) loadTxnAuthorize(a *osin.AuthorizeData, code string) func(tx *badger.Txn) error {
@@ -228,9 +229,9 @@ func (r *repo) loadTxnAuthorize(a *osin.AuthorizeData, code string) func(tx *bad
 	return func(tx *badger.Txn) error {
 		it, err := tx.Get(fullPath)
 		if err != nil {
-			return errors.NotFoundf("Invalid path %s", fullPath)
+			return errors.NewNotFound(ErrNotFound, "Invalid path %s", fullPath)
 		}
-		if err := it.Value(loadRawAuthorize(a)); err != nil {
+		if err := it.Value(r.rewrittenFn(loadRawAuthorize(a))); err != nil {
 			return err
 		}
 		if a.Client == nil {
@@ -270,7 +271,7 @@ func loadRawAuthorize(a *osin.AuthorizeData) func(raw []byte) error {
 // LoadAuthorize
 func (r *repo) LoadAuthorize(code string) (*osin.AuthorizeData, error) {
 	if code == "" {
-		return nil, errors.NotFoundf("Empty authorize code")
+		return nil, errors.NewNotFound(ErrNotFound, "Empty authorize code")
 	}
 	data := osin.AuthorizeData{}
 	err := r.Open()
@@ -295,7 +296,7 @@ func (r *repo) RemoveAuthorize(code string) error {
 		return errors.Annotatef(err, "Unable to open badger store")
 	}
 	defer r.Close()
-	return r.d.Update(func(tx *badger.Txn) error {
+	return r.update(func(tx dbTxn) error {
 		return tx.Delete(r.authorizePath(code))
 	})
 }
@@ -328,7 +329,7 @@ func (r *repo) SaveAccess(data *osin.AccessData) error {
 		return err
 	}
 
-	db := r.d.NewWriteBatch()
+	db := r.newWriteBatch()
 	if data.RefreshToken != "" {
 		if err := r.saveRefresh(db, data.RefreshToken, data.AccessToken); err != nil {
 			r.errFn("Failed saving refresh token for client id %s: %+s", data.Client.GetId(), err)
@@ -388,16 +389,16 @@ func (r *repo) loadTxnAccess(a *osin.AccessData, token string) func(tx *badger.T
 	return func(tx *badger.Txn) error {
 		it, err := tx.Get(fullPath)
 		if err != nil {
-			return errors.NewNotFound(err, "Invalid path %s", fullPath)
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "Invalid path %s", fullPath)
 		}
-		return it.Value(loadRawAccess(a))
+		return it.Value(r.rewrittenFn(loadRawAccess(a)))
 	}
 }
 
 // LoadAccess
 func (r *repo) LoadAccess(code string) (*osin.AccessData, error) {
 	if code == "" {
-		return nil, errors.NotFoundf("Empty access code")
+		return nil, errors.NewNotFound(ErrNotFound, "Empty access code")
 	}
 	err := r.Open()
 	if err != nil {
@@ -437,7 +438,7 @@ func (r *repo) RemoveAccess(token string) error {
 		return errors.Annotatef(err, "Unable to open badger store")
 	}
 	defer r.Close()
-	return r.d.NewWriteBatch().Delete(r.accessPath(token))
+	return r.newWriteBatch().Delete(r.accessPath(token))
 }
 
 func (r *repo) refreshPath(refresh string) []byte {
@@ -447,7 +448,7 @@ func (r *repo) refreshPath(refresh string) []byte {
 // LoadRefresh
 func (r *repo) LoadRefresh(token string) (*osin.AccessData, error) {
 	if token == "" {
-		return nil, errors.NotFoundf("Empty refresh token")
+		return nil, errors.NewNotFound(ErrNotFound, "Empty refresh token")
 	}
 	return nil, nil
 }
@@ -459,10 +460,10 @@ func (r *repo) RemoveRefresh(token string) error {
 		return errors.Annotatef(err, "Unable to open badger store")
 	}
 	defer r.Close()
-	return r.d.NewWriteBatch().Delete(r.refreshPath(token))
+	return r.newWriteBatch().Delete(r.refreshPath(token))
 }
 
-func (r *repo) saveRefresh(txn *badger.WriteBatch, refresh, access string) (err error) {
+func (r *repo) saveRefresh(txn keySetter, refresh, access string) (err error) {
 	ref := ref{
 		Access: access,
 	}
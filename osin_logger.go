@@ -1,19 +1,83 @@
 package badger
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
 type logger struct {
 	logFn loggerFn
 	errFn loggerFn
+	// log receives the messages at their levels, when the Config has a Logger.
+	log *slog.Logger
+}
+
+func (l logger) logf(level slog.Level, fn loggerFn, s string, p ...interface{}) {
+	if l.log == nil {
+		fn(s, p...)
+		return
+	}
+	// NOTE(marius): badger ends its messages with new lines, which the structured loggers add themselves.
+	l.log.Log(context.Background(), level, strings.TrimRight(fmt.Sprintf(s, p...), "\n"))
 }
 
 func (l logger) Errorf(s string, p ...interface{}) {
-	l.errFn(s, p...)
+	l.logf(slog.LevelError, l.errFn, s, p...)
 }
 func (l logger) Warningf(s string, p ...interface{}) {
-	l.errFn(s, p...)
+	l.logf(slog.LevelWarn, l.errFn, s, p...)
 }
 func (l logger) Infof(s string, p ...interface{}) {
-	l.logFn(s, p...)
+	l.logf(slog.LevelInfo, l.logFn, s, p...)
 }
 func (l logger) Debugf(s string, p ...interface{}) {
-	l.logFn(s, p...)
+	l.logf(slog.LevelDebug, l.logFn, s, p...)
+}
+
+// slogFn returns the loggerFn sending the formatted messages to the structured logger, at the level.
+func slogFn(log *slog.Logger, level slog.Level) loggerFn {
+	return func(s string, p ...interface{}) {
+		log.Log(context.Background(), level, fmt.Sprintf(s, p...))
+	}
+}
+
+// logOp logs the operation on the iri to the structured logger of the Config, with its duration, and its error,
+// at the error level when it failed, and at the debug level otherwise, or when the iri was not found.
+func (r *repo) logOp(op string, iri vocab.IRI, start time.Time, err error, args ...any) {
+	if r.log == nil {
+		return
+	}
+	args = append([]any{slog.String("operation", op), slog.String("iri", iri.String()),
+		slog.Duration("duration", time.Since(start))}, args...)
+	switch {
+	case err == nil:
+		r.log.Debug(op, args...)
+	case errors.IsNotFound(err):
+		r.log.Debug(op, append(args, slog.Any("error", err))...)
+	default:
+		r.log.Error(op+" failed", append(args, slog.Any("error", err))...)
+	}
+}
+
+// logItemOp logs the change made to the item, and to the collection, when it is set, with the error it points to.
+func (r *repo) logItemOp(op MirrorOp, col vocab.IRI, it vocab.Item, start time.Time, err *error) {
+	if r.log == nil {
+		return
+	}
+	iri := col
+	args := make([]any, 0, 1)
+	if !vocab.IsNil(it) {
+		if len(col) == 0 {
+			iri = it.GetLink()
+		} else {
+			args = append(args, slog.String("item", it.GetLink().String()))
+		}
+	}
+	r.logOp(string(op), iri, start, *err, args...)
 }
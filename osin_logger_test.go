@@ -0,0 +1,47 @@
+package badger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Logger(t *testing.T) {
+	buf := bytes.Buffer{}
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r, err := New(Config{Path: t.TempDir(), Logger: log})
+	if err != nil {
+		t.Fatalf("New() error = %s", err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+	missing := vocab.IRI("https://example.com/objects/missing")
+	if _, err = r.Load(missing); err == nil {
+		t.Fatalf("Load() of %s found it", missing)
+	}
+
+	ops := make(map[string]map[string]any)
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		rec := make(map[string]any)
+		if err = json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("invalid log record %s: %s", s.Bytes(), err)
+		}
+		if op, ok := rec["operation"].(string); ok {
+			ops[op] = rec
+		}
+	}
+	if rec := ops["Save"]; rec == nil || rec["iri"] != note.ID.String() || rec["level"] != "DEBUG" || rec["duration"] == nil {
+		t.Errorf("the Save was logged as %v", rec)
+	}
+	if rec := ops["Load"]; rec == nil || rec["iri"] != missing.String() || rec["level"] != "DEBUG" || rec["error"] == nil {
+		t.Errorf("the Load of a missing object was logged as %v", rec)
+	}
+}
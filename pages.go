@@ -0,0 +1,93 @@
+package badger
+
+import (
+	"bytes"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+const pagesKey = "__pages"
+
+func getPagesKey(p []byte) []byte {
+	return bytes.Join([][]byte{p, []byte(pagesKey)}, sep)
+}
+
+// collectionPageParent returns the IRI of the collection the page is part of,
+// or an empty IRI if the item is not a collection page.
+func collectionPageParent(it vocab.Item) vocab.IRI {
+	if vocab.IsNil(it) {
+		return ""
+	}
+	var partOf vocab.Item
+	switch it.GetType() {
+	case vocab.OrderedCollectionPageType:
+		_ = vocab.OnOrderedCollectionPage(it, func(p *vocab.OrderedCollectionPage) error {
+			partOf = p.PartOf
+			return nil
+		})
+	case vocab.CollectionPageType:
+		_ = vocab.OnCollectionPage(it, func(p *vocab.CollectionPage) error {
+			partOf = p.PartOf
+			return nil
+		})
+	}
+	if vocab.IsNil(partOf) {
+		return ""
+	}
+	return partOf.GetLink()
+}
+
+// updatePageLinks keeps the list of pages of the parent collections in sync when a page gets saved or deleted.
+// The old item is the previously stored version of the page, and the new one is nil when the page is deleted.
+func updatePageLinks(tx dbTxn, old, new vocab.Item) error {
+	oldParent := collectionPageParent(old)
+	newParent := collectionPageParent(new)
+	if len(oldParent) > 0 && !oldParent.Equals(newParent, false) {
+		if err := onIRIsKey(tx, getPagesKey(itemPath(oldParent)), removeIRIFn(old)); err != nil {
+			return err
+		}
+	}
+	if len(newParent) > 0 {
+		return onIRIsKey(tx, getPagesKey(itemPath(newParent)), addIRIFn(new))
+	}
+	return nil
+}
+
+// loadPagesMembers returns the items of all the pages stored as being part of the col collection.
+func (r *repo) loadPagesMembers(tx dbTxn, col vocab.IRI) vocab.ItemCollection {
+	members := make(vocab.ItemCollection, 0)
+	if len(col) == 0 {
+		return members
+	}
+	pages, err := loadRawKey(tx, getPagesKey(itemPath(col)))
+	if err != nil {
+		return members
+	}
+	_ = vocab.OnIRIs(pages, func(iris *vocab.IRIs) error {
+		for _, iri := range *iris {
+			page, err := loadRawItem(tx, itemPath(iri))
+			if err != nil {
+				r.errFn("unable to load page %s of collection %s: %+s", iri, col, err)
+				continue
+			}
+			_ = vocab.OnCollectionIntf(page, func(p vocab.CollectionInterface) error {
+				for _, it := range p.Collection() {
+					if !members.Contains(it.GetLink()) {
+						members = append(members, it)
+					}
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return members
+}
+
+// collectionMembers returns the items of the ci collection, followed by the ones of its stored pages.
+// NOTE(marius): the items are copied, as the collection can be the cached decoded value of its key, which
+// appending to it in place would change.
+func (r *repo) collectionMembers(tx dbTxn, ci vocab.CollectionInterface) vocab.ItemCollection {
+	members := append(vocab.ItemCollection{}, ci.Collection()...)
+	return append(members, r.loadPagesMembers(tx, ci.GetLink())...)
+}
@@ -0,0 +1,73 @@
+package badger
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/processing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// generatedPasswordSize is the number of random bytes of the passwords generated by ResetPassword.
+const generatedPasswordSize = 18
+
+// ResetPassword replaces the password of the actor with pw, or with a random one when pw is empty,
+// and returns the password which was set.
+//
+// Unlike PasswordSet, which replaces the whole metadata of the actor, it keeps the rest of it, like the private key,
+// so it can be used for unlocking the accounts of the existing actors.
+func (r *repo) ResetPassword(iri vocab.IRI, pw []byte) ([]byte, error) {
+	p := itemPath(iri)
+	if len(p) == 0 {
+		return nil, errors.NotValidf("invalid actor IRI %s", iri)
+	}
+	if len(pw) == 0 {
+		raw := make([]byte, generatedPasswordSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, errors.Annotatef(err, "unable to generate the password")
+		}
+		pw = []byte(base64.RawURLEncoding.EncodeToString(raw))
+	}
+	hash, err := bcrypt.GenerateFromPassword(pw, -1)
+	if err != nil {
+		return nil, errors.Annotatef(err, "Could not encrypt the pw")
+	}
+
+	err = r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	err = r.update(func(tx dbTxn) error {
+		ob, err := loadRawItem(tx, p)
+		if err != nil || vocab.IsNil(ob) {
+			return errors.NewNotFound(ErrNotFound, "%s does not exist", iri)
+		}
+		if !vocab.ActorTypes.Contains(ob.GetType()) {
+			return errors.NotValidf("%s is not an actor", iri)
+		}
+		m := processing.Metadata{}
+		if i, err := tx.Get(getMetadataKey(p)); err == nil {
+			err = i.Value(func(raw []byte) error {
+				return decodeFn(raw, &m)
+			})
+			if err != nil {
+				return errors.Annotatef(err, "Could not unmarshal metadata")
+			}
+		}
+		m.Pw = hash
+		raw, err := encodeFn(m)
+		if err != nil {
+			return errors.Annotatef(err, "Could not marshal metadata")
+		}
+		return tx.Set(getMetadataKey(p), raw)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.metadataChanged(iri)
+	return pw, nil
+}
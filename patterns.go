@@ -0,0 +1,204 @@
+package badger
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// anyDepth is the pattern segment which matches all the paths under the preceding ones.
+const anyDepth = "**"
+
+// MatchIRIs returns the IRIs of the objects and collections stored at paths matching the pattern.
+//
+// The pattern is an IRI in which the host, or any of the path segments, can contain the wildcards supported
+// by path.Match, which match inside a single segment, e.g. "https://example.com/actors/*/outbox" or
+// "https://*/objects". A last segment of "**" matches everything stored under the preceding path, at any depth,
+// e.g. "https://example.com/**".
+//
+// The wildcard segments are expanded by iterating over the distinct names found at their level, skipping over
+// the keys stored deeper, so only the prefixes which can match the pattern get visited.
+func (r *repo) MatchIRIs(pattern string) (vocab.IRIs, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var iris vocab.IRIs
+	err = r.d.View(func(tx *badger.Txn) error {
+		iris, err = matchIRIs(tx, pattern)
+		return err
+	})
+	return iris, err
+}
+
+// LoadPattern loads the items found at all the IRIs matching the pattern, as described for MatchIRIs.
+// The IRIs of collections contribute their items, like in Load, and all the items are verified against the checks.
+func (r *repo) LoadPattern(pattern string, checks ...filters.Check) (vocab.ItemCollection, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var iris vocab.IRIs
+	err = r.d.View(func(tx *badger.Txn) error {
+		iris, err = matchIRIs(tx, pattern)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	col := make(vocab.ItemCollection, 0)
+	for _, iri := range iris {
+		f, err := filters.FiltersFromIRI(iri)
+		if err != nil {
+			r.errFn("invalid IRI %s: %+s", iri, err)
+			continue
+		}
+		items, err := r.loadFromPath(f, 0, checks...)
+		if err != nil {
+			r.errFn("unable to load %s: %+s", iri, err)
+			continue
+		}
+		for _, it := range items {
+			if !col.Contains(it.GetLink()) {
+				col = append(col, it)
+			}
+		}
+	}
+	return col, nil
+}
+
+func matchIRIs(tx dbTxn, pattern string) (vocab.IRIs, error) {
+	scheme, rest, ok := strings.Cut(pattern, "://")
+	if !ok {
+		return nil, errors.NotValidf("invalid IRI pattern %q, it needs a scheme", pattern)
+	}
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	for i, seg := range segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, errors.NotValidf("invalid IRI pattern segment %q: %s", seg, err)
+		}
+		if seg == anyDepth && i != len(segments)-1 {
+			return nil, errors.NotValidf("invalid IRI pattern %q, %s can be only the last segment", pattern, anyDepth)
+		}
+	}
+
+	paths := matchPaths(tx, segments)
+	iris := make(vocab.IRIs, 0, len(paths))
+	for _, p := range paths {
+		host, pp, _ := strings.Cut(string(p), string(sep))
+		u := url.URL{Scheme: scheme, Host: host}
+		if len(pp) > 0 {
+			u.Path = string(sep) + pp
+		}
+		iris = append(iris, vocab.IRI(u.String()))
+	}
+	return iris, nil
+}
+
+// matchPaths returns the storage paths, having an object or a collection stored at them, which match the segments.
+func matchPaths(tx dbTxn, segments []string) [][]byte {
+	prefixes := [][]byte{nil}
+	for _, seg := range segments {
+		if seg == anyDepth {
+			paths := make([][]byte, 0)
+			for _, p := range prefixes {
+				paths = append(paths, objectPathsUnder(tx, p)...)
+			}
+			return paths
+		}
+		next := make([][]byte, 0)
+		for _, p := range prefixes {
+			if !hasWildcard(seg) {
+				next = append(next, joinPath(p, []byte(seg)))
+				continue
+			}
+			for _, child := range childNames(tx, p) {
+				if ok, _ := path.Match(seg, string(child)); ok {
+					next = append(next, joinPath(p, child))
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		prefixes = next
+	}
+
+	paths := make([][]byte, 0, len(prefixes))
+	for _, p := range prefixes {
+		if _, err := tx.Get(getObjectKey(p)); err == nil {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+func hasWildcard(seg string) bool {
+	return strings.ContainsAny(seg, `*?[\`)
+}
+
+func joinPath(p, seg []byte) []byte {
+	if len(p) == 0 {
+		return append([]byte{}, seg...)
+	}
+	return bytes.Join([][]byte{p, seg}, sep)
+}
+
+// childNames returns the distinct names of the path segments stored directly under p, or the hosts when p is empty.
+// The names of the internal keys, which start with "__", are skipped.
+func childNames(tx dbTxn, p []byte) [][]byte {
+	prefix := p
+	if len(p) > 0 {
+		prefix = append(append([]byte{}, p...), sep...)
+	}
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	names := make([][]byte, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); {
+		rest := it.Item().Key()[len(prefix):]
+		name := rest
+		if i := bytes.IndexByte(rest, sep[0]); i >= 0 {
+			name = rest[:i]
+		}
+		name = append([]byte{}, name...)
+		if len(name) > 0 && !bytes.HasPrefix(name, []byte("__")) {
+			names = append(names, name)
+		}
+		// NOTE(marius): all the keys stored under the name sort before <name>/\xff, so we skip over them.
+		it.Seek(append(joinPath(p, name), sep[0], 0xff))
+	}
+	return names
+}
+
+// objectPathsUnder returns the paths of all the objects and collections stored under p, at any depth.
+func objectPathsUnder(tx dbTxn, p []byte) [][]byte {
+	prefix := append(append([]byte{}, p...), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	paths := make([][]byte, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if k := it.Item().Key(); isObjectKey(k) {
+			paths = append(paths, bytes.TrimSuffix(it.Item().KeyCopy(nil), append(sep, objectKey...)))
+		}
+	}
+	return paths
+}
@@ -0,0 +1,116 @@
+package badger
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+func Test_repo_MatchIRIs(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	collections := vocab.IRIs{"http://example.com/actors/jdoe/outbox", "http://example.com/actors/jane/outbox", "http://example.com/actors/jane/inbox"}
+	for _, iri := range collections {
+		if _, err = r.Create(vocab.OrderedCollectionNew(iri)); err != nil {
+			t.Fatalf("unable to create collection %s: %s", iri, err)
+		}
+	}
+	objects := vocab.IRIs{
+		"http://example.com/objects/1",
+		"http://example.com/objects/2",
+		"http://example.org/objects/1",
+		"http://example.org/objects/1/replies/1",
+	}
+	for _, iri := range objects {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = iri
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", iri, err)
+		}
+	}
+	for _, ob := range objects[:2] {
+		if err = r.AddTo("http://example.com/actors/jdoe/outbox", ob); err != nil {
+			t.Fatalf("unable to add %s: %s", ob, err)
+		}
+	}
+	if err = r.AddTo("http://example.com/actors/jane/outbox", objects[2]); err != nil {
+		t.Fatalf("unable to add %s: %s", objects[2], err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    vocab.IRIs
+		wantErr bool
+	}{
+		{
+			pattern: "http://example.com/actors/*/outbox",
+			want:    vocab.IRIs{"http://example.com/actors/jane/outbox", "http://example.com/actors/jdoe/outbox"},
+		},
+		{
+			pattern: "http://*/objects/1",
+			want:    vocab.IRIs{"http://example.com/objects/1", "http://example.org/objects/1"},
+		},
+		{
+			pattern: "http://example.org/**",
+			want:    vocab.IRIs{"http://example.org/objects/1", "http://example.org/objects/1/replies/1"},
+		},
+		{
+			pattern: "http://example.com/actors/j?ne/*box",
+			want:    vocab.IRIs{"http://example.com/actors/jane/inbox", "http://example.com/actors/jane/outbox"},
+		},
+		{
+			pattern: "http://example.com/missing/*",
+			want:    vocab.IRIs{},
+		},
+		{
+			pattern: "example.com/actors/*",
+			wantErr: true,
+		},
+		{
+			pattern: "http://example.com/**/outbox",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := r.MatchIRIs(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchIRIs() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchIRIs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	items, err := r.LoadPattern("http://example.com/actors/*/outbox", filters.SameID(objects[0]))
+	if err != nil {
+		t.Fatalf("LoadPattern() error = %s", err)
+	}
+	if len(items) != 1 || !items[0].GetLink().Equals(objects[0], false) {
+		t.Errorf("LoadPattern() = %v, want only %s", items, objects[0])
+	}
+
+	items, err = r.LoadPattern("http://example.com/actors/*/outbox")
+	if err != nil {
+		t.Fatalf("LoadPattern() error = %s", err)
+	}
+	got := make([]string, 0)
+	for _, it := range items {
+		got = append(got, it.GetLink().String())
+	}
+	sort.Strings(got)
+	want := []string{objects[0].String(), objects[1].String(), objects[2].String()}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadPattern() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package badger
+
+import (
+	"sort"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// indexLookup is a lookup of value ranges in one of the indexes.
+type indexLookup struct {
+	index  string
+	ranges []ValueRange
+}
+
+// planQuery returns the index lookups that can answer the filters, and the checks, of a Load.
+// The filters and the top level checks are all required to match, so the results of the lookups
+// get intersected. Checks which don't have a corresponding index are left for the scan to verify.
+func planQuery(indexes []Indexer, f Filterable, checks filters.Checks) []indexLookup {
+	lookups := make([]indexLookup, 0)
+	if ff, ok := f.(*filters.Filters); ok {
+		for _, idx := range indexes {
+			if ranges, ok := idx.Lookup(ff); ok {
+				lookups = append(lookups, indexLookup{index: idx.Name(), ranges: ranges})
+			}
+		}
+	}
+	for _, c := range checks {
+		for _, l := range checkLookups(c) {
+			if hasIndex(indexes, l.index) {
+				lookups = append(lookups, l)
+			}
+		}
+	}
+	return lookups
+}
+
+// The accessors through which the checks expose the values that can be looked up in the indexes, besides
+// the idCheck of the cursors.
+//
+// NOTE(marius): the checks of the filters package don't export their values, and we don't want to depend on
+// their unexported types, so only the checks implementing these get planned. The other ones are verified
+// by scanning the collection, so adding the accessors to the filters package enables their planning.
+type (
+	typesCheck interface {
+		Types() vocab.ActivityVocabularyTypes
+	}
+	inReplyToCheck interface {
+		InReplyTo() vocab.IRI
+	}
+	recipientCheck interface {
+		Recipient() vocab.IRI
+	}
+	requesterCheck interface {
+		Requester() vocab.IRI
+	}
+	actorCheck interface {
+		ActorChecks() filters.Checks
+	}
+	allCheck interface {
+		AllChecks() filters.Checks
+	}
+	anyCheck interface {
+		AnyChecks() filters.Checks
+	}
+)
+
+// checkLookups maps a check to the index lookups that can answer it.
+func checkLookups(c filters.Check) []indexLookup {
+	switch cc := c.(type) {
+	case typesCheck:
+		if types := cc.Types(); len(types) > 0 {
+			return []indexLookup{{index: TypeIndex{}.Name(), ranges: exactValues(types...)}}
+		}
+	case inReplyToCheck:
+		return []indexLookup{{index: InReplyToIndex{}.Name(), ranges: exactValues(cc.InReplyTo().String())}}
+	case recipientCheck:
+		return []indexLookup{{index: RecipientIndex{}.Name(), ranges: exactValues(recipientValue(cc.Recipient()))}}
+	case requesterCheck:
+		// NOTE(marius): an anonymous requester can see only the objects flagged in the public index.
+		// For the other requesters, the objects attributed to them can't be looked up in the indexes.
+		if iri := recipientValue(cc.Requester()); iri == "" || iri == vocab.PublicNS.String() {
+			return []indexLookup{{index: PublicIndex{}.Name(), ranges: exactValues(publicAddressed, publicUnaddressed)}}
+		}
+	case actorCheck:
+		for _, sub := range cc.ActorChecks() {
+			if id, ok := sub.(idCheck); ok {
+				return []indexLookup{{index: ActorIndex{}.Name(), ranges: exactValues(id.ID().String())}}
+			}
+		}
+	case allCheck:
+		lookups := make([]indexLookup, 0)
+		for _, sub := range cc.AllChecks() {
+			lookups = append(lookups, checkLookups(sub)...)
+		}
+		return lookups
+	case anyCheck:
+		// NOTE(marius): alternatives can be answered only when all of them use the same index,
+		// in which case their value ranges get merged.
+		var merged *indexLookup
+		for _, sub := range cc.AnyChecks() {
+			lookups := checkLookups(sub)
+			if len(lookups) != 1 || (merged != nil && merged.index != lookups[0].index) {
+				return nil
+			}
+			if merged == nil {
+				merged = &indexLookup{index: lookups[0].index}
+			}
+			merged.ranges = append(merged.ranges, lookups[0].ranges...)
+		}
+		if merged != nil {
+			return []indexLookup{*merged}
+		}
+	}
+	return nil
+}
+
+// candidatePaths executes the index lookups of the plan for the objects under the base path.
+// The lookups are intersected starting from the most selective one. When the plan doesn't
+// contain any lookups, it returns false, and the caller needs to fall back to scanning.
+func candidatePaths(tx dbTxn, base []byte, plan []indexLookup) ([][]byte, bool) {
+	if len(plan) == 0 {
+		return nil, false
+	}
+	sets := make([]map[string]struct{}, 0, len(plan))
+	for _, l := range plan {
+		paths := scanIndex(tx, l.index, base, l.ranges)
+		if len(paths) == 0 {
+			return nil, true
+		}
+		sets = append(sets, paths)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return len(sets[i]) < len(sets[j])
+	})
+	found := sets[0]
+	for _, paths := range sets[1:] {
+		both := make(map[string]struct{})
+		for p := range found {
+			if _, ok := paths[p]; ok {
+				both[p] = struct{}{}
+			}
+		}
+		if len(both) == 0 {
+			return nil, true
+		}
+		found = both
+	}
+	return sortedPaths(found), true
+}
+
+// checksMatch verifies the item against the checks received by Load.
+// The Authorized checks are applied with the semantics of requesterCanSee, and the pagination checks are ignored.
+func checksMatch(checks filters.Checks, it vocab.Item) bool {
+	if !requesterCanSee(filters.AuthorizedChecks(checks...), it) {
+		return false
+	}
+	items := make(filters.Checks, 0)
+	for _, c := range filters.FilterChecks(checks...) {
+		if len(filters.AuthorizedChecks(c)) == 0 {
+			items = append(items, c)
+		}
+	}
+	return len(items) == 0 || filters.All(items...).Match(it)
+}
@@ -0,0 +1,161 @@
+package badger
+
+import (
+	"reflect"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// The checks implementing the accessors used for planning the queries, matching like their counterparts
+// of the filters package, which don't implement them.
+type (
+	testTypes     vocab.ActivityVocabularyTypes
+	testInReplyTo vocab.IRI
+	testRecipient vocab.IRI
+	testRequester vocab.IRI
+	testActor     filters.Checks
+	testAll       filters.Checks
+	testAny       filters.Checks
+)
+
+func (t testTypes) Types() vocab.ActivityVocabularyTypes { return vocab.ActivityVocabularyTypes(t) }
+func (t testTypes) Match(it vocab.Item) bool             { return filters.HasType(t...).Match(it) }
+
+func (t testInReplyTo) InReplyTo() vocab.IRI { return vocab.IRI(t) }
+func (t testInReplyTo) Match(it vocab.Item) bool {
+	return filters.SameInReplyTo(vocab.IRI(t)).Match(it)
+}
+
+func (t testRecipient) Recipient() vocab.IRI     { return vocab.IRI(t) }
+func (t testRecipient) Match(it vocab.Item) bool { return filters.Recipients(vocab.IRI(t)).Match(it) }
+
+func (t testRequester) Requester() vocab.IRI     { return vocab.IRI(t) }
+func (t testRequester) Match(it vocab.Item) bool { return filters.Authorized(vocab.IRI(t)).Match(it) }
+
+func (t testActor) ActorChecks() filters.Checks { return filters.Checks(t) }
+func (t testActor) Match(it vocab.Item) bool    { return filters.Actor(t...).Match(it) }
+
+func (t testAll) AllChecks() filters.Checks { return filters.Checks(t) }
+func (t testAll) Match(it vocab.Item) bool  { return filters.All(t...).Match(it) }
+
+func (t testAny) AnyChecks() filters.Checks { return filters.Checks(t) }
+func (t testAny) Match(it vocab.Item) bool  { return filters.Any(t...).Match(it) }
+
+func Test_checkLookups(t *testing.T) {
+	jdoe := vocab.IRI("http://example.com/actors/jdoe")
+	tests := []struct {
+		name  string
+		check filters.Check
+		want  []indexLookup
+	}{
+		{
+			name:  "type",
+			check: testTypes{vocab.NoteType},
+			want:  []indexLookup{{index: "type", ranges: []ValueRange{{Start: "Note"}}}},
+		},
+		{
+			name:  "inReplyTo",
+			check: testInReplyTo("http://example.com/objects/1"),
+			want:  []indexLookup{{index: "inReplyTo", ranges: []ValueRange{{Start: "http://example.com/objects/1"}}}},
+		},
+		{
+			name:  "recipients",
+			check: testRecipient(jdoe),
+			want:  []indexLookup{{index: "recipient", ranges: []ValueRange{{Start: jdoe.String()}}}},
+		},
+		{
+			name:  "actor",
+			check: testActor{testID(jdoe)},
+			want:  []indexLookup{{index: "actor", ranges: []ValueRange{{Start: jdoe.String()}}}},
+		},
+		{
+			name:  "all",
+			check: testAll{testTypes{vocab.CreateType}, testActor{testID(jdoe)}},
+			want: []indexLookup{
+				{index: "type", ranges: []ValueRange{{Start: "Create"}}},
+				{index: "actor", ranges: []ValueRange{{Start: jdoe.String()}}},
+			},
+		},
+		{
+			name:  "any on same index",
+			check: testAny{testTypes{vocab.NoteType}, testTypes{vocab.ArticleType}},
+			want:  []indexLookup{{index: "type", ranges: []ValueRange{{Start: "Note"}, {Start: "Article"}}}},
+		},
+		{
+			name:  "any on different indexes",
+			check: testAny{testTypes{vocab.NoteType}, testRecipient(jdoe)},
+		},
+		{
+			name:  "anonymous requester",
+			check: testRequester(vocab.PublicNS),
+			want:  []indexLookup{{index: "public", ranges: []ValueRange{{Start: "addressed"}, {Start: "unaddressed"}}}},
+		},
+		{
+			name:  "authenticated requester",
+			check: testRequester(jdoe),
+		},
+		{
+			name:  "not indexed",
+			check: filters.NameIs("jdoe"),
+		},
+		// NOTE(marius): the checks of the filters package don't expose their values, so they are not planned.
+		{
+			name:  "without accessors",
+			check: filters.All(filters.HasType(vocab.NoteType), filters.Recipients(jdoe)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkLookups(tt.check); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkLookups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_repo_Load_PlannedChecks(t *testing.T) {
+	op := vocab.ObjectNew(vocab.NoteType)
+	op.ID = "http://example.com/objects/1"
+
+	reply := vocab.ObjectNew(vocab.NoteType)
+	reply.ID = "http://example.com/objects/2"
+	reply.InReplyTo = op.ID
+
+	article := vocab.ObjectNew(vocab.ArticleType)
+	article.ID = "http://example.com/objects/3"
+	article.InReplyTo = op.ID
+
+	for _, indexes := range [][]Indexer{DefaultIndexes, nil} {
+		r, err := initBadgerForTesting(t)
+		if err != nil {
+			t.Fatalf("Unable to initialize badger: %s", err)
+		}
+		r.indexes = indexes
+
+		for _, ob := range []*vocab.Object{op, reply, article} {
+			if _, err = r.Save(ob); err != nil {
+				t.Fatalf("unable to save %s: %s", ob.ID, err)
+			}
+		}
+
+		planned := filters.Checks{testTypes{vocab.NoteType}, testInReplyTo(op.ID)}
+		scanned := filters.Checks{filters.HasType(vocab.NoteType), filters.SameInReplyTo(op.ID)}
+		for _, checks := range []filters.Checks{planned, scanned} {
+			res, err := r.Load("http://example.com/objects", checks...)
+			if err != nil {
+				t.Fatalf("Load() error = %s", err)
+			}
+			err = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+				if col.Count() != 1 || !col.Contains(reply.ID) {
+					t.Errorf("Load() with indexes %v = %v, want only %s", indexes != nil, col.Collection(), reply.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Load() returned invalid collection: %s", err)
+			}
+		}
+	}
+}
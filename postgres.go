@@ -0,0 +1,372 @@
+package badger
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// pgRow is a row of a table of the legacy fedbox postgres storage, by column name.
+type pgRow map[string]sql.NullString
+
+// pgTable describes a table of the legacy fedbox postgres storage, and the keys its rows become.
+// The query is used with a database connection, and returns the columns in their order, which are found by name
+// in the COPY blocks of a pg_dump. The arrays of IRIs of the collections are returned as JSON by the query, and
+// they are in the postgres array format in the dumps.
+type pgTable struct {
+	name    string
+	query   string
+	columns []string
+	keys    func(row pgRow) ([]importedKey, error)
+}
+
+var pgTables = []pgTable{
+	{name: "actors", query: `SELECT iri, raw FROM actors`, columns: []string{"iri", "raw"}, keys: pgObjectKeys},
+	{name: "activities", query: `SELECT iri, raw FROM activities`, columns: []string{"iri", "raw"}, keys: pgObjectKeys},
+	{name: "objects", query: `SELECT iri, raw FROM objects`, columns: []string{"iri", "raw"}, keys: pgObjectKeys},
+	{
+		name:    "collections",
+		query:   `SELECT iri, array_to_json(elements) FROM collections`,
+		columns: []string{"iri", "elements"},
+		keys:    pgCollectionKeys,
+	},
+	{
+		name:    "client",
+		query:   `SELECT id, secret, extra, redirect_uri FROM client`,
+		columns: []string{"id", "secret", "extra", "redirect_uri"},
+		keys:    pgClientKeys,
+	},
+	{
+		name:    "authorize",
+		query:   `SELECT client, code, expires_in, scope, redirect_uri, state, extra, created_at FROM authorize`,
+		columns: []string{"client", "code", "expires_in", "scope", "redirect_uri", "state", "extra", "created_at"},
+		keys:    pgAuthorizeKeys,
+	},
+	{
+		name: "access",
+		query: `SELECT client, authorize, previous, access_token, refresh_token, expires_in, scope, redirect_uri, extra,
+created_at FROM access`,
+		columns: []string{"client", "authorize", "previous", "access_token", "refresh_token", "expires_in", "scope",
+			"redirect_uri", "extra", "created_at"},
+		keys: pgAccessKeys,
+	},
+	{name: "refresh", query: `SELECT token, access FROM refresh`, columns: []string{"token", "access"}, keys: pgRefreshKeys},
+}
+
+func findPgTable(name string) (pgTable, bool) {
+	for _, t := range pgTables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return pgTable{}, false
+}
+
+// ImportFromPostgres copies the data of a database of the legacy fedbox postgres storage, which the caller opens
+// with the postgres driver of its choice, like the stdlib one of pgx: the actors, activities, objects and
+// collections, and the OAuth clients, authorizations, access and refresh tokens of the osin postgres storage.
+//
+// The type keys get created while copying, and the filter indexes, the collection cursors and the membership keys
+// are rebuilt after, by ReindexAll.
+func (r *repo) ImportFromPostgres(db *sql.DB, opt MigrationOptions) (MigrationReport, error) {
+	if db == nil {
+		return MigrationReport{}, errors.NotValidf("nil postgres database")
+	}
+	imp, err := r.newImporter("postgres", "", opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	for _, t := range pgTables {
+		if err = importPgTable(db, imp, t); err != nil {
+			break
+		}
+	}
+	return imp.finish(err)
+}
+
+func importPgTable(db *sql.DB, imp *importer, t pgTable) error {
+	rows, err := db.Query(t.query)
+	if err != nil {
+		return errors.Annotatef(err, "unable to read the %s table", t.name)
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(t.columns))
+	dest := make([]any, len(t.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return errors.Annotatef(err, "unable to read a row of the %s table", t.name)
+		}
+		row := make(pgRow, len(t.columns))
+		for i, col := range t.columns {
+			row[col] = values[i]
+		}
+		if err = importPgRow(imp, t, row); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Annotatef(err, "unable to read the %s table", t.name)
+	}
+	return nil
+}
+
+func importPgRow(imp *importer, t pgTable, row pgRow) error {
+	keys, err := t.keys(row)
+	if err != nil {
+		return errors.Annotatef(err, "unable to read a row of the %s table", t.name)
+	}
+	for _, kv := range keys {
+		if err = imp.set(kv.k, kv.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportFromPostgresDump copies the data of a pg_dump, in the plain format, of a database of the legacy fedbox
+// postgres storage, like ImportFromPostgres does for a database connection. The rows are read from the COPY
+// blocks of the tables, and the rest of the dump is ignored, so the dumps made with the --inserts option
+// are not supported.
+func (r *repo) ImportFromPostgresDump(rd io.Reader, opt MigrationOptions) (MigrationReport, error) {
+	imp, err := r.newImporter("pgdump", "", opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	return imp.finish(importPgDump(imp, bufio.NewReader(rd)))
+}
+
+func importPgDump(imp *importer, br *bufio.Reader) error {
+	var t pgTable
+	var columns []string
+	inCopy, known := false, false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return errors.Annotatef(err, "unable to read the dump")
+		}
+		if len(line) == 0 && err == io.EOF {
+			if inCopy {
+				return errors.NotValidf("the COPY block of the %s table isn't terminated", t.name)
+			}
+			return nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		switch {
+		case !inCopy:
+			var name string
+			if name, columns, inCopy = parsePgCopy(line); inCopy {
+				t, known = findPgTable(name)
+			}
+		case line == `\.`:
+			inCopy = false
+		case known:
+			fields := strings.Split(line, "\t")
+			if len(fields) != len(columns) {
+				return errors.NotValidf("invalid row of the %s table, with %d columns instead of %d", t.name, len(fields), len(columns))
+			}
+			row := make(pgRow, len(columns))
+			for i, col := range columns {
+				row[col] = unescapePgCopy(fields[i])
+			}
+			if err := importPgRow(imp, t, row); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parsePgCopy returns the table and the columns of a line starting a COPY block: COPY schema.table (c1, c2) FROM stdin;
+func parsePgCopy(line string) (string, []string, bool) {
+	if !strings.HasPrefix(line, "COPY ") || !strings.HasSuffix(line, " FROM stdin;") {
+		return "", nil, false
+	}
+	def := strings.TrimSuffix(strings.TrimPrefix(line, "COPY "), " FROM stdin;")
+	name, cols, ok := strings.Cut(def, " (")
+	if !ok {
+		return "", nil, false
+	}
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	columns := strings.Split(strings.TrimSuffix(cols, ")"), ",")
+	for i, c := range columns {
+		columns[i] = strings.Trim(strings.TrimSpace(c), `"`)
+	}
+	return strings.Trim(name, `"`), columns, true
+}
+
+// unescapePgCopy decodes a column of the text format of COPY, where \N is the null value.
+func unescapePgCopy(s string) sql.NullString {
+	if s == `\N` {
+		return sql.NullString{}
+	}
+	if !strings.Contains(s, `\`) {
+		return sql.NullString{String: s, Valid: true}
+	}
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return sql.NullString{String: b.String(), Valid: true}
+}
+
+// pgIRIs returns the IRIs of an array column, which is JSON when returned by the queries, and in the postgres
+// array format, like {a,"b c"}, in the dumps.
+func pgIRIs(v sql.NullString) (vocab.IRIs, error) {
+	iris := make(vocab.IRIs, 0)
+	s := strings.TrimSpace(v.String)
+	if !v.Valid || s == "" || s == "{}" || s == "null" {
+		return iris, nil
+	}
+	if strings.HasPrefix(s, "[") {
+		var elements []string
+		if err := json.Unmarshal([]byte(s), &elements); err != nil {
+			return nil, err
+		}
+		for _, e := range elements {
+			iris = append(iris, vocab.IRI(e))
+		}
+		return iris, nil
+	}
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, errors.NotValidf("invalid array %q", s)
+	}
+	e := strings.Builder{}
+	quoted, escaped, wasQuoted := false, false, false
+	add := func() {
+		if v := e.String(); wasQuoted || (v != "" && v != "NULL") {
+			iris = append(iris, vocab.IRI(v))
+		}
+		e.Reset()
+		wasQuoted = false
+	}
+	for _, c := range s[1 : len(s)-1] {
+		switch {
+		case escaped:
+			e.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == ',' && !quoted:
+			add()
+		default:
+			e.WriteRune(c)
+		}
+	}
+	add()
+	return iris, nil
+}
+
+// pgTime returns the time of a timestamp column, in the format of the queries, or in the one of the dumps.
+func pgTime(v sql.NullString) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999-07:00"} {
+		if t, err := time.Parse(layout, v.String); err == nil {
+			return t.UTC()
+		}
+	}
+	return sqliteTime(v.String)
+}
+
+// pgExpiresIn returns the expiration of the OAuth rows, which the osin postgres storage stores as an integer.
+func pgExpiresIn(v sql.NullString) time.Duration {
+	d, _ := strconv.ParseInt(v.String, 10, 64)
+	return time.Duration(d)
+}
+
+func pgObjectKeys(row pgRow) ([]importedKey, error) {
+	iri := row["iri"].String
+	if iri == "" {
+		return nil, errors.NotValidf("empty iri")
+	}
+	return []importedKey{{k: getObjectKey(itemPath(vocab.IRI(iri))), v: []byte(row["raw"].String)}}, nil
+}
+
+func pgCollectionKeys(row pgRow) ([]importedKey, error) {
+	iri := row["iri"].String
+	if iri == "" {
+		return nil, errors.NotValidf("empty iri")
+	}
+	iris, err := pgIRIs(row["elements"])
+	if err != nil {
+		return nil, err
+	}
+	raw, err := encodeItemFn(iris)
+	return []importedKey{{k: getObjectKey(itemPath(vocab.IRI(iri))), v: raw}}, err
+}
+
+func pgClientKeys(row pgRow) ([]importedKey, error) {
+	c := cl{Id: row["id"].String, Secret: row["secret"].String, RedirectUri: row["redirect_uri"].String, Extra: sqliteExtra([]byte(row["extra"].String))}
+	raw, err := encodeFn(c)
+	return []importedKey{{k: badgerItemPath(clientsBucket, c.Id), v: raw}}, err
+}
+
+func pgAuthorizeKeys(row pgRow) ([]importedKey, error) {
+	a := auth{
+		Client:      row["client"].String,
+		Code:        row["code"].String,
+		ExpiresIn:   pgExpiresIn(row["expires_in"]),
+		Scope:       row["scope"].String,
+		RedirectURI: row["redirect_uri"].String,
+		State:       row["state"].String,
+		CreatedAt:   pgTime(row["created_at"]),
+		Extra:       sqliteExtra([]byte(row["extra"].String)),
+	}
+	raw, err := encodeFn(a)
+	return []importedKey{{k: badgerItemPath(authorizeBucket, a.Code), v: raw}}, err
+}
+
+func pgAccessKeys(row pgRow) ([]importedKey, error) {
+	a := acc{
+		Client:       row["client"].String,
+		Authorize:    row["authorize"].String,
+		Previous:     row["previous"].String,
+		AccessToken:  row["access_token"].String,
+		RefreshToken: row["refresh_token"].String,
+		ExpiresIn:    pgExpiresIn(row["expires_in"]),
+		Scope:        row["scope"].String,
+		RedirectURI:  row["redirect_uri"].String,
+		CreatedAt:    pgTime(row["created_at"]),
+		Extra:        sqliteExtra([]byte(row["extra"].String)),
+	}
+	raw, err := encodeFn(a)
+	return []importedKey{{k: badgerItemPath(accessBucket, a.AccessToken), v: raw}}, err
+}
+
+func pgRefreshKeys(row pgRow) ([]importedKey, error) {
+	raw, err := encodeFn(ref{Access: row["access"].String})
+	return []importedKey{{k: badgerItemPath(refreshBucket, row["token"].String), v: raw}}, err
+}
@@ -0,0 +1,139 @@
+package badger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_pgIRIs(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want vocab.IRIs
+	}{
+		{name: "empty", arg: `{}`, want: vocab.IRIs{}},
+		{name: "json", arg: `["https://example.com/1","https://example.com/2"]`, want: vocab.IRIs{"https://example.com/1", "https://example.com/2"}},
+		{name: "array", arg: `{https://example.com/1,https://example.com/2}`, want: vocab.IRIs{"https://example.com/1", "https://example.com/2"}},
+		{name: "quoted", arg: `{"https://example.com/a,b","https://example.com/\"c\""}`, want: vocab.IRIs{"https://example.com/a,b", `https://example.com/"c"`}},
+		{name: "null", arg: `{NULL,https://example.com/1}`, want: vocab.IRIs{"https://example.com/1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pgIRIs(unescapePgCopy(tt.arg))
+			if err != nil {
+				t.Fatalf("pgIRIs() error = %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pgIRIs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_repo_ImportFromPostgresDump(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	jdoe.Summary = vocab.DefaultNaturalLanguageValue("line\ttab")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	rawActor, _ := encodeItemFn(jdoe)
+	rawNote, _ := encodeItemFn(note)
+	now := time.Now().UTC()
+	escape := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`)
+
+	dump := strings.Join([]string{
+		`--`,
+		`-- PostgreSQL database dump`,
+		`--`,
+		`SET client_encoding = 'UTF8';`,
+		`COPY public.actors (iri, raw) FROM stdin;`,
+		string(jdoe.ID) + "\t" + escape.Replace(string(rawActor)),
+		`\.`,
+		`COPY public.objects (iri, raw) FROM stdin;`,
+		string(note.ID) + "\t" + escape.Replace(string(rawNote)),
+		`\.`,
+		`COPY public.collections (iri, elements) FROM stdin;`,
+		string(jdoe.Outbox.GetLink()) + "\t{" + string(note.ID) + "}",
+		`\.`,
+		`COPY public.schema_migrations (version) FROM stdin;`,
+		`1`,
+		`\.`,
+		`COPY public.client (id, secret, extra, redirect_uri) FROM stdin;`,
+		"app\ts3cr3t\t\\N\thttps://example.com/callback",
+		`\.`,
+		`COPY public.authorize (client, code, expires_in, scope, redirect_uri, state, extra, created_at) FROM stdin;`,
+		"app\tcode\t3600\t\t\t\t\\N\t" + now.Format("2006-01-02 15:04:05.999999-07"),
+		`\.`,
+		`COPY public.access (client, authorize, previous, access_token, refresh_token, expires_in, scope, redirect_uri, extra, created_at) FROM stdin;`,
+		"app\tcode\t\ttoken\trefresh\t3600\t\t\t\\N\t2020-01-02 03:04:05+00",
+		`\.`,
+		`COPY public.refresh (token, access) FROM stdin;`,
+		"refresh\ttoken",
+		`\.`,
+		``,
+	}, "\n")
+
+	report, err := r.ImportFromPostgresDump(strings.NewReader(dump), MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportFromPostgresDump() dry run error = %s", err)
+	}
+	if report.Keys != 7 || report.Objects != 3 {
+		t.Errorf("ImportFromPostgresDump() dry run = %+v, want 7 keys and 3 objects", report)
+	}
+
+	if report, err = r.ImportFromPostgresDump(strings.NewReader(dump), MigrationOptions{}); err != nil {
+		t.Fatalf("ImportFromPostgresDump() error = %s", err)
+	}
+	if report.Keys != 7 || report.Objects != 3 {
+		t.Errorf("ImportFromPostgresDump() = %+v, want 7 keys and 3 objects", report)
+	}
+	it, err := r.Load(jdoe.ID)
+	if err != nil {
+		t.Fatalf("Load() of the imported actor error = %s", err)
+	}
+	err = vocab.OnActor(it, func(a *vocab.Actor) error {
+		if got := a.Summary.First().String(); got != "line\ttab" {
+			t.Errorf("Load() of the imported actor summary = %q, want %q", got, "line\ttab")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Load() of the imported actor = %v, is not an actor", it)
+	}
+	col, err := r.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the imported outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(note.ID) {
+		t.Errorf("Load() of the imported outbox = %v, doesn't contain the imported note", col)
+	}
+	c, err := r.GetClient("app")
+	if err != nil {
+		t.Fatalf("GetClient() of the imported client error = %s", err)
+	}
+	if c.GetSecret() != "s3cr3t" {
+		t.Errorf("GetClient() secret = %q, want %q", c.GetSecret(), "s3cr3t")
+	}
+	a, err := r.LoadAccess("token")
+	if err != nil {
+		t.Fatalf("LoadAccess() of the imported token error = %s", err)
+	}
+	if a.CreatedAt.Year() != 2020 {
+		t.Errorf("LoadAccess() created at = %s, want 2020-01-02", a.CreatedAt)
+	}
+
+	truncated := strings.Join([]string{`COPY public.actors (iri, raw) FROM stdin;`, "https://example.com/actors/x\t{}", ``}, "\n")
+	if _, err = r.ImportFromPostgresDump(strings.NewReader(truncated), MigrationOptions{DryRun: true}); !errors.IsNotValid(err) {
+		t.Errorf("ImportFromPostgresDump() of a truncated dump error = %v, want NotValid", err)
+	}
+}
@@ -0,0 +1,763 @@
+package badger
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+// The badger directory can be opened by a single process, so the storage proxy lets others use it: the process
+// owning the directory serves the repository with NewProxyHandler, and the other ones, like the front-end processes
+// and the sidecar tools, use a ProxyClient in its place.
+//
+// The calls are POST requests to <url>/<method>, like <url>/Load, having the token shared by the handler and its
+// clients as their bearer token, a proxyRequest as their JSON body, and a proxyResponse as their JSON response.
+// The items are encoded like in storage, and the OAuth data like their values in storage. The failed calls get
+// the status matching the kind of their error, and its message.
+
+// proxyMaxRequestSize is the maximum size of the body of a request of the storage proxy.
+const proxyMaxRequestSize = 32 << 20
+
+type proxyRequest struct {
+	IRI        vocab.IRI            `json:"iri,omitempty"`
+	Col        vocab.IRI            `json:"col,omitempty"`
+	Item       json.RawMessage      `json:"item,omitempty"`
+	Authorized []vocab.IRI          `json:"authorized,omitempty"`
+	Bypass     bool                 `json:"bypass,omitempty"`
+	Password   []byte               `json:"password,omitempty"`
+	Key        []byte               `json:"key,omitempty"`
+	Metadata   *processing.Metadata `json:"metadata,omitempty"`
+	ID         string               `json:"id,omitempty"`
+	Client     *cl                  `json:"client,omitempty"`
+	Authorize  *auth                `json:"authorize,omitempty"`
+	Access     *acc                 `json:"access,omitempty"`
+}
+
+type proxyResponse struct {
+	Item      json.RawMessage      `json:"item,omitempty"`
+	Truncated int                  `json:"truncated,omitempty"`
+	Metadata  *processing.Metadata `json:"metadata,omitempty"`
+	Client    *cl                  `json:"client,omitempty"`
+	Clients   []cl                 `json:"clients,omitempty"`
+	Authorize *auth                `json:"authorize,omitempty"`
+	Access    *acc                 `json:"access,omitempty"`
+	Previous  *acc                 `json:"previous,omitempty"`
+	Error     string               `json:"error,omitempty"`
+}
+
+type proxyMethod func(r *repo, req proxyRequest) (proxyResponse, error)
+
+var proxyMethods = map[string]proxyMethod{
+	"Load":            proxyLoad,
+	"Create":          proxyCreate,
+	"Save":            proxySave,
+	"Delete":          proxyDelete,
+	"AddTo":           proxyAddTo,
+	"RemoveFrom":      proxyRemoveFrom,
+	"PasswordSet":     proxyPasswordSet,
+	"PasswordCheck":   proxyPasswordCheck,
+	"LoadMetadata":    proxyLoadMetadata,
+	"SaveMetadata":    proxySaveMetadata,
+	"SaveKey":         proxySaveKey,
+	"CreateService":   proxyCreateService,
+	"GetClient":       proxyGetClient,
+	"ListClients":     proxyListClients,
+	"UpdateClient":    proxyUpdateClient,
+	"RemoveClient":    proxyRemoveClient,
+	"SaveAuthorize":   proxySaveAuthorize,
+	"LoadAuthorize":   proxyLoadAuthorize,
+	"RemoveAuthorize": proxyRemoveAuthorize,
+	"SaveAccess":      proxySaveAccess,
+	"LoadAccess":      proxyLoadAccess,
+	"RemoveAccess":    proxyRemoveAccess,
+	"LoadRefresh":     proxyLoadRefresh,
+	"RemoveRefresh":   proxyRemoveRefresh,
+}
+
+// proxyHandler is the http.Handler returned by NewProxyHandler. The calls are made concurrently, as the repository
+// can be used by multiple goroutines, like by the ones of an HTTP server.
+type proxyHandler struct {
+	r     *repo
+	token []byte
+}
+
+// NewProxyHandler returns an http.Handler serving the repository to the ProxyClients of other processes.
+// As the handler gives access to the metadata of the actors, including their private keys, the clients need to
+// send the token as their bearer token, and it fails when the token is empty.
+func NewProxyHandler(r *repo, token string) (http.Handler, error) {
+	if token == "" {
+		return nil, errors.NotValidf("the storage proxy needs a token to authenticate its clients")
+	}
+	return &proxyHandler{r: r, token: []byte(token)}, nil
+}
+
+// authorized returns whether the request has the token of the handler as its bearer token.
+func (p *proxyHandler) authorized(req *http.Request) bool {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), p.token) == 1
+}
+
+func (p *proxyHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !p.authorized(req) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeProxyResponse(w, http.StatusUnauthorized, proxyResponse{Error: http.StatusText(http.StatusUnauthorized)})
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeProxyResponse(w, http.StatusMethodNotAllowed, proxyResponse{Error: http.StatusText(http.StatusMethodNotAllowed)})
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, "/")
+	fn, ok := proxyMethods[name]
+	if !ok {
+		writeProxyResponse(w, http.StatusNotImplemented, proxyResponse{Error: fmt.Sprintf("unknown method %q", name)})
+		return
+	}
+	pr := proxyRequest{}
+	if err := json.NewDecoder(io.LimitReader(req.Body, proxyMaxRequestSize)).Decode(&pr); err != nil {
+		writeProxyResponse(w, http.StatusBadRequest, proxyResponse{Error: fmt.Sprintf("invalid request: %s", err)})
+		return
+	}
+
+	res, err := fn(p.r, pr)
+
+	status := http.StatusOK
+	if t, ok := err.(Truncated); ok {
+		res.Truncated = t.Limit
+	} else if err != nil {
+		status = proxyStatus(err)
+		res.Error = err.Error()
+	}
+	writeProxyResponse(w, status, res)
+}
+
+func writeProxyResponse(w http.ResponseWriter, status int, res proxyResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// proxyStatus returns the status of the response of a call failing with err, from which the ProxyClient
+// recreates an error of the same kind.
+func proxyStatus(err error) int {
+	switch {
+	case errors.IsNotFound(err):
+		return http.StatusNotFound
+	case errors.IsNotValid(err):
+		return http.StatusBadRequest
+	case errors.IsUnauthorized(err):
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+func proxyError(status int, msg string) error {
+	switch status {
+	case http.StatusNotFound:
+		return errors.NewNotFound(ErrNotFound, "%s", msg)
+	case http.StatusBadRequest:
+		return errors.NotValidf("%s", msg)
+	case http.StatusUnauthorized:
+		return errors.NewUnauthorized(nil, "%s", msg)
+	}
+	return errors.Newf("%s", msg)
+}
+
+func proxyItemResponse(it vocab.Item, err error) (proxyResponse, error) {
+	res := proxyResponse{}
+	if vocab.IsNil(it) {
+		return res, err
+	}
+	raw, encErr := encodeItemFn(it)
+	if encErr != nil {
+		return res, encErr
+	}
+	res.Item = raw
+	return res, err
+}
+
+func proxyRequestItem(req proxyRequest) (vocab.Item, error) {
+	if len(req.Item) == 0 {
+		return nil, errors.NotValidf("missing item")
+	}
+	return decodeItemFn(req.Item)
+}
+
+func proxyLoad(r *repo, req proxyRequest) (proxyResponse, error) {
+	checks := make(filters.Checks, 0, len(req.Authorized)+1)
+	for _, a := range req.Authorized {
+		checks = append(checks, filters.Authorized(a))
+	}
+	if req.Bypass {
+		checks = append(checks, BypassCache())
+	}
+	return proxyItemResponse(r.Load(req.IRI, checks...))
+}
+
+func proxyCreate(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	col, ok := it.(vocab.CollectionInterface)
+	if !ok {
+		return proxyResponse{}, errors.NotValidf("invalid collection type %s", it.GetType())
+	}
+	return proxyItemResponse(r.Create(col))
+}
+
+func proxySave(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyItemResponse(r.Save(it))
+}
+
+func proxyDelete(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{}, r.Delete(it)
+}
+
+func proxyAddTo(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{}, r.AddTo(req.Col, it)
+}
+
+func proxyRemoveFrom(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{}, r.RemoveFrom(req.Col, it)
+}
+
+func proxyPasswordSet(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{}, r.PasswordSet(it, req.Password)
+}
+
+func proxyPasswordCheck(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{}, r.PasswordCheck(it, req.Password)
+}
+
+func proxyLoadMetadata(r *repo, req proxyRequest) (proxyResponse, error) {
+	m, err := r.LoadMetadata(req.IRI)
+	return proxyResponse{Metadata: m}, err
+}
+
+func proxySaveMetadata(r *repo, req proxyRequest) (proxyResponse, error) {
+	if req.Metadata == nil {
+		return proxyResponse{}, errors.NotValidf("missing metadata")
+	}
+	return proxyResponse{}, r.SaveMetadata(*req.Metadata, req.IRI)
+}
+
+func proxySaveKey(r *repo, req proxyRequest) (proxyResponse, error) {
+	key, err := x509.ParsePKCS8PrivateKey(req.Key)
+	if err != nil {
+		return proxyResponse{}, errors.NewNotValid(err, "invalid private key")
+	}
+	return proxyItemResponse(r.SaveKey(req.IRI, key))
+}
+
+func proxyCreateService(r *repo, req proxyRequest) (proxyResponse, error) {
+	it, err := proxyRequestItem(req)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	service, err := vocab.ToActor(it)
+	if err != nil {
+		return proxyResponse{}, errors.NewNotValid(err, "invalid service")
+	}
+	return proxyResponse{}, r.CreateService(service)
+}
+
+func proxyGetClient(r *repo, req proxyRequest) (proxyResponse, error) {
+	c, err := r.GetClient(req.ID)
+	return proxyResponse{Client: proxyCl(c)}, err
+}
+
+func proxyListClients(r *repo, _ proxyRequest) (proxyResponse, error) {
+	clients, err := r.ListClients()
+	res := proxyResponse{Clients: make([]cl, 0, len(clients))}
+	for _, c := range clients {
+		if pc := proxyCl(c); pc != nil {
+			res.Clients = append(res.Clients, *pc)
+		}
+	}
+	return res, err
+}
+
+func proxyUpdateClient(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyResponse{}, r.UpdateClient(osinClient(req.Client, ""))
+}
+
+func proxyRemoveClient(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyResponse{}, r.RemoveClient(req.ID)
+}
+
+func proxySaveAuthorize(r *repo, req proxyRequest) (proxyResponse, error) {
+	if req.Authorize == nil {
+		return proxyResponse{}, errors.NotValidf("missing authorization")
+	}
+	return proxyResponse{}, r.SaveAuthorize(osinAuthorize(req.Authorize, req.Client))
+}
+
+func proxyLoadAuthorize(r *repo, req proxyRequest) (proxyResponse, error) {
+	a, err := r.LoadAuthorize(req.ID)
+	if a == nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{Authorize: proxyAuth(a), Client: proxyCl(a.Client)}, err
+}
+
+func proxyRemoveAuthorize(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyResponse{}, r.RemoveAuthorize(req.ID)
+}
+
+func proxySaveAccess(r *repo, req proxyRequest) (proxyResponse, error) {
+	if req.Access == nil {
+		return proxyResponse{}, errors.NotValidf("missing access")
+	}
+	return proxyResponse{}, r.SaveAccess(osinAccess(req.Access, req.Client, nil, nil))
+}
+
+func proxyLoadAccess(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyAccessResponse(r.LoadAccess(req.ID))
+}
+
+func proxyLoadRefresh(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyAccessResponse(r.LoadRefresh(req.ID))
+}
+
+func proxyAccessResponse(a *osin.AccessData, err error) (proxyResponse, error) {
+	if a == nil {
+		return proxyResponse{}, err
+	}
+	return proxyResponse{Access: proxyAcc(a), Client: proxyCl(a.Client), Authorize: proxyAuth(a.AuthorizeData), Previous: proxyAcc(a.AccessData)}, err
+}
+
+func proxyRemoveAccess(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyResponse{}, r.RemoveAccess(req.ID)
+}
+
+func proxyRemoveRefresh(r *repo, req proxyRequest) (proxyResponse, error) {
+	return proxyResponse{}, r.RemoveRefresh(req.ID)
+}
+
+func proxyCl(c osin.Client) *cl {
+	if c == nil || interfaceIsNil(c) {
+		return nil
+	}
+	return &cl{Id: c.GetId(), Secret: c.GetSecret(), RedirectUri: c.GetRedirectUri(), Extra: c.GetUserData()}
+}
+
+// osinClient returns the client c, or the one having only the id, when c is missing.
+func osinClient(c *cl, id string) osin.Client {
+	if c != nil {
+		return &osin.DefaultClient{Id: c.Id, Secret: c.Secret, RedirectUri: c.RedirectUri, UserData: c.Extra}
+	}
+	if id != "" {
+		return &osin.DefaultClient{Id: id}
+	}
+	return nil
+}
+
+func proxyAuth(a *osin.AuthorizeData) *auth {
+	if a == nil {
+		return nil
+	}
+	res := auth{
+		Code:        a.Code,
+		ExpiresIn:   time.Duration(a.ExpiresIn),
+		Scope:       a.Scope,
+		RedirectURI: a.RedirectUri,
+		State:       a.State,
+		CreatedAt:   a.CreatedAt.UTC(),
+		Extra:       a.UserData,
+	}
+	if c := proxyCl(a.Client); c != nil {
+		res.Client = c.Id
+	}
+	return &res
+}
+
+func osinAuthorize(a *auth, c *cl) *osin.AuthorizeData {
+	if a == nil {
+		return nil
+	}
+	return &osin.AuthorizeData{
+		Client:      osinClient(c, a.Client),
+		Code:        a.Code,
+		ExpiresIn:   int32(a.ExpiresIn),
+		Scope:       a.Scope,
+		RedirectUri: a.RedirectURI,
+		State:       a.State,
+		CreatedAt:   a.CreatedAt,
+		UserData:    a.Extra,
+	}
+}
+
+func proxyAcc(a *osin.AccessData) *acc {
+	if a == nil {
+		return nil
+	}
+	res := acc{
+		AccessToken:  a.AccessToken,
+		RefreshToken: a.RefreshToken,
+		ExpiresIn:    time.Duration(a.ExpiresIn),
+		Scope:        a.Scope,
+		RedirectURI:  a.RedirectUri,
+		CreatedAt:    a.CreatedAt.UTC(),
+		Extra:        a.UserData,
+	}
+	if c := proxyCl(a.Client); c != nil {
+		res.Client = c.Id
+	}
+	if a.AuthorizeData != nil {
+		res.Authorize = a.AuthorizeData.Code
+	}
+	if a.AccessData != nil {
+		res.Previous = a.AccessData.AccessToken
+	}
+	return &res
+}
+
+// osinAccess returns the access a, with its client, and with its authorization and previous access, or with only
+// their codes, when they are missing.
+func osinAccess(a *acc, c *cl, authorize *auth, prev *acc) *osin.AccessData {
+	if a == nil {
+		return nil
+	}
+	d := osin.AccessData{
+		Client:       osinClient(c, a.Client),
+		AccessToken:  a.AccessToken,
+		RefreshToken: a.RefreshToken,
+		ExpiresIn:    int32(a.ExpiresIn),
+		Scope:        a.Scope,
+		RedirectUri:  a.RedirectURI,
+		CreatedAt:    a.CreatedAt,
+		UserData:     a.Extra,
+	}
+	if authorize != nil {
+		d.AuthorizeData = osinAuthorize(authorize, c)
+	} else if a.Authorize != "" {
+		d.AuthorizeData = &osin.AuthorizeData{Code: a.Authorize}
+	}
+	if prev != nil {
+		d.AccessData = osinAccess(prev, c, nil, nil)
+	} else if a.Previous != "" {
+		d.AccessData = &osin.AccessData{AccessToken: a.Previous}
+	}
+	return &d
+}
+
+// ProxyClient is the storage of a process using the repository served by NewProxyHandler in another process.
+// It has the methods of the repository used by the applications, for the objects, the metadata and the keys of the
+// actors, and the OAuth data, with the same semantics, and it can be used as their osin.Storage.
+//
+// The checks given to Load can only be the filters.Authorized checks and the BypassCache option, the other
+// filters need to be in the query of the IRI.
+type ProxyClient struct {
+	url   string
+	token string
+	c     *http.Client
+}
+
+// NewProxyClient returns the ProxyClient of the NewProxyHandler served at url, authenticating with the token
+// of the handler. When c is nil, http.DefaultClient is used. For a handler listening on a unix socket, c can have
+// a Transport dialing it.
+func NewProxyClient(url, token string, c *http.Client) *ProxyClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &ProxyClient{url: strings.TrimSuffix(url, "/"), token: token, c: c}
+}
+
+func (p *ProxyClient) call(method string, req proxyRequest) (proxyResponse, error) {
+	res := proxyResponse{}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return res, err
+	}
+	hr, err := http.NewRequest(http.MethodPost, p.url+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	hr.Header.Set("Authorization", "Bearer "+p.token)
+	resp, err := p.c.Do(hr)
+	if err != nil {
+		return res, errors.Annotatef(err, "unable to reach the storage proxy")
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return res, errors.Annotatef(err, "invalid response of the storage proxy, with status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return res, proxyError(resp.StatusCode, res.Error)
+	}
+	if res.Truncated > 0 {
+		return res, Truncated{Limit: res.Truncated}
+	}
+	return res, nil
+}
+
+func (p *ProxyClient) callItem(method string, it vocab.Item, req proxyRequest) (proxyResponse, error) {
+	raw, err := encodeItemFn(it)
+	if err != nil {
+		return proxyResponse{}, err
+	}
+	req.Item = raw
+	return p.call(method, req)
+}
+
+func responseItem(res proxyResponse, err error) (vocab.Item, error) {
+	if len(res.Item) == 0 {
+		return nil, err
+	}
+	it, decErr := decodeItemFn(res.Item)
+	if decErr != nil {
+		return nil, decErr
+	}
+	return it, err
+}
+
+// proxyRequester returns the IRI of the requester of a filters.Authorized check, when it can be sent to the proxy.
+//
+// NOTE(marius): until the filters package exposes the requester of its checks, we read it from the underlying
+// string of the check, and verify it by building the check again. When that fails, like after a change of the
+// filters package, the check is refused instead of being sent without its requester.
+func proxyRequester(c filters.Check) (vocab.IRI, bool) {
+	if len(filters.AuthorizedChecks(c)) == 0 {
+		return "", false
+	}
+	if rc, ok := c.(requesterCheck); ok {
+		return rc.Requester(), true
+	}
+	v := reflect.ValueOf(c)
+	if !v.IsValid() || v.Kind() != reflect.String {
+		return "", false
+	}
+	iri := vocab.IRI(v.String())
+	return iri, filters.Authorized(iri) == c
+}
+
+// Open does nothing, as the repository is opened by the process serving it.
+func (p *ProxyClient) Open() error {
+	return nil
+}
+
+// Close does nothing, as the repository is closed by the process serving it.
+func (p *ProxyClient) Close() {}
+
+// Clone returns p, which can be used concurrently.
+func (p *ProxyClient) Clone() osin.Storage {
+	return p
+}
+
+func (p *ProxyClient) Load(i vocab.IRI, checks ...filters.Check) (vocab.Item, error) {
+	req := proxyRequest{IRI: i}
+	for _, c := range checks {
+		if _, ok := c.(CacheBypass); ok {
+			req.Bypass = true
+			continue
+		}
+		requester, ok := proxyRequester(c)
+		if !ok {
+			return nil, errors.NotValidf("the %T check can't be sent to the storage proxy, it needs to be in the query of the IRI", c)
+		}
+		req.Authorized = append(req.Authorized, requester)
+	}
+	return responseItem(p.call("Load", req))
+}
+
+func (p *ProxyClient) Create(col vocab.CollectionInterface) (vocab.CollectionInterface, error) {
+	it, err := responseItem(p.callItem("Create", col, proxyRequest{}))
+	if err != nil {
+		return col, err
+	}
+	if c, ok := it.(vocab.CollectionInterface); ok {
+		return c, nil
+	}
+	return col, nil
+}
+
+func (p *ProxyClient) Save(it vocab.Item) (vocab.Item, error) {
+	saved, err := responseItem(p.callItem("Save", it, proxyRequest{}))
+	if err != nil || vocab.IsNil(saved) {
+		return it, err
+	}
+	return saved, nil
+}
+
+func (p *ProxyClient) Delete(it vocab.Item) error {
+	_, err := p.callItem("Delete", it, proxyRequest{})
+	return err
+}
+
+func (p *ProxyClient) AddTo(col vocab.IRI, it vocab.Item) error {
+	_, err := p.callItem("AddTo", it, proxyRequest{Col: col})
+	return err
+}
+
+func (p *ProxyClient) RemoveFrom(col vocab.IRI, it vocab.Item) error {
+	_, err := p.callItem("RemoveFrom", it, proxyRequest{Col: col})
+	return err
+}
+
+func (p *ProxyClient) PasswordSet(it vocab.Item, pw []byte) error {
+	_, err := p.callItem("PasswordSet", it, proxyRequest{Password: pw})
+	return err
+}
+
+func (p *ProxyClient) PasswordCheck(it vocab.Item, pw []byte) error {
+	_, err := p.callItem("PasswordCheck", it, proxyRequest{Password: pw})
+	return err
+}
+
+func (p *ProxyClient) LoadMetadata(iri vocab.IRI) (*processing.Metadata, error) {
+	res, err := p.call("LoadMetadata", proxyRequest{IRI: iri})
+	if res.Metadata == nil {
+		res.Metadata = &processing.Metadata{}
+	}
+	return res.Metadata, err
+}
+
+func (p *ProxyClient) SaveMetadata(m processing.Metadata, iri vocab.IRI) error {
+	_, err := p.call("SaveMetadata", proxyRequest{IRI: iri, Metadata: &m})
+	return err
+}
+
+func (p *ProxyClient) LoadKey(iri vocab.IRI) (crypto.PrivateKey, error) {
+	m, err := p.LoadMetadata(iri)
+	if err != nil {
+		return nil, err
+	}
+	return decodePrivateKey(m.PrivateKey)
+}
+
+func (p *ProxyClient) SaveKey(iri vocab.IRI, key crypto.PrivateKey) (vocab.Item, error) {
+	raw, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return responseItem(p.call("SaveKey", proxyRequest{IRI: iri, Key: raw}))
+}
+
+func (p *ProxyClient) CreateService(service *vocab.Service) error {
+	_, err := p.callItem("CreateService", service, proxyRequest{})
+	return err
+}
+
+func (p *ProxyClient) GetClient(id string) (osin.Client, error) {
+	res, err := p.call("GetClient", proxyRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	return osinClient(res.Client, ""), nil
+}
+
+func (p *ProxyClient) ListClients() ([]osin.Client, error) {
+	res, err := p.call("ListClients", proxyRequest{})
+	clients := make([]osin.Client, 0, len(res.Clients))
+	for i := range res.Clients {
+		clients = append(clients, osinClient(&res.Clients[i], ""))
+	}
+	return clients, err
+}
+
+func (p *ProxyClient) UpdateClient(c osin.Client) error {
+	if interfaceIsNil(c) {
+		return nil
+	}
+	_, err := p.call("UpdateClient", proxyRequest{Client: proxyCl(c)})
+	return err
+}
+
+func (p *ProxyClient) CreateClient(c osin.Client) error {
+	return p.UpdateClient(c)
+}
+
+func (p *ProxyClient) RemoveClient(id string) error {
+	_, err := p.call("RemoveClient", proxyRequest{ID: id})
+	return err
+}
+
+func (p *ProxyClient) SaveAuthorize(data *osin.AuthorizeData) error {
+	if data == nil {
+		return errors.NotValidf("nil authorization")
+	}
+	_, err := p.call("SaveAuthorize", proxyRequest{Authorize: proxyAuth(data), Client: proxyCl(data.Client)})
+	return err
+}
+
+func (p *ProxyClient) LoadAuthorize(code string) (*osin.AuthorizeData, error) {
+	res, err := p.call("LoadAuthorize", proxyRequest{ID: code})
+	if err != nil {
+		return nil, err
+	}
+	return osinAuthorize(res.Authorize, res.Client), nil
+}
+
+func (p *ProxyClient) RemoveAuthorize(code string) error {
+	_, err := p.call("RemoveAuthorize", proxyRequest{ID: code})
+	return err
+}
+
+func (p *ProxyClient) SaveAccess(data *osin.AccessData) error {
+	if data == nil {
+		return errors.NotValidf("nil access")
+	}
+	_, err := p.call("SaveAccess", proxyRequest{Access: proxyAcc(data), Client: proxyCl(data.Client)})
+	return err
+}
+
+func (p *ProxyClient) LoadAccess(token string) (*osin.AccessData, error) {
+	res, err := p.call("LoadAccess", proxyRequest{ID: token})
+	return osinAccess(res.Access, res.Client, res.Authorize, res.Previous), err
+}
+
+func (p *ProxyClient) RemoveAccess(token string) error {
+	_, err := p.call("RemoveAccess", proxyRequest{ID: token})
+	return err
+}
+
+func (p *ProxyClient) LoadRefresh(token string) (*osin.AccessData, error) {
+	res, err := p.call("LoadRefresh", proxyRequest{ID: token})
+	return osinAccess(res.Access, res.Client, res.Authorize, res.Previous), err
+}
+
+func (p *ProxyClient) RemoveRefresh(token string) error {
+	_, err := p.call("RemoveRefresh", proxyRequest{ID: token})
+	return err
+}
@@ -0,0 +1,188 @@
+package badger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+func initProxyForTesting(t *testing.T) *ProxyClient {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	h, err := NewProxyHandler(r, "secret")
+	if err != nil {
+		t.Fatalf("NewProxyHandler() error = %s", err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return NewProxyClient(srv.URL, "secret", srv.Client())
+}
+
+func Test_ProxyClient_objects(t *testing.T) {
+	p := initProxyForTesting(t)
+
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err := p.Save(it); err != nil {
+			t.Fatalf("Save(%s) error = %s", it.GetLink(), err)
+		}
+	}
+	if err := p.AddTo(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("AddTo() error = %s", err)
+	}
+
+	it, err := p.Load(jdoe.ID, filters.Authorized(vocab.PublicNS), BypassCache())
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	if it.GetLink() != jdoe.ID || it.GetType() != vocab.PersonType {
+		t.Errorf("Load() = %s %s, want %s %s", it.GetType(), it.GetLink(), vocab.PersonType, jdoe.ID)
+	}
+	col, err := p.Load(jdoe.Outbox.GetLink())
+	if err != nil {
+		t.Fatalf("Load() of the outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(note.ID) {
+		t.Errorf("Load() of the outbox = %v, doesn't contain %s", col, note.ID)
+	}
+	if err = p.RemoveFrom(jdoe.Outbox.GetLink(), note); err != nil {
+		t.Fatalf("RemoveFrom() error = %s", err)
+	}
+	if err = p.Delete(note); err != nil {
+		t.Fatalf("Delete() error = %s", err)
+	}
+	if _, err = p.Load("https://example.com/objects/2"); !errors.IsNotFound(err) {
+		t.Errorf("Load() of a missing object error = %v, want NotFound", err)
+	}
+	if _, err = p.Load(jdoe.ID, filters.HasType(vocab.PersonType)); !errors.IsNotValid(err) {
+		t.Errorf("Load() with a filter check error = %v, want NotValid", err)
+	}
+
+	if err = p.SaveMetadata(processing.Metadata{Pw: []byte("hash")}, jdoe.ID); err != nil {
+		t.Fatalf("SaveMetadata() error = %s", err)
+	}
+	m, err := p.LoadMetadata(jdoe.ID)
+	if err != nil {
+		t.Fatalf("LoadMetadata() error = %s", err)
+	}
+	if string(m.Pw) != "hash" {
+		t.Errorf("LoadMetadata() password = %s, want %s", m.Pw, "hash")
+	}
+	if err = p.PasswordSet(jdoe, []byte("s3cr3t")); err != nil {
+		t.Fatalf("PasswordSet() error = %s", err)
+	}
+	if err = p.PasswordCheck(jdoe, []byte("s3cr3t")); err != nil {
+		t.Errorf("PasswordCheck() error = %s", err)
+	}
+	if err = p.PasswordCheck(jdoe, []byte("wrong")); err == nil {
+		t.Errorf("PasswordCheck() of a wrong password succeeded")
+	}
+}
+
+func Test_ProxyClient_osin(t *testing.T) {
+	p := initProxyForTesting(t)
+
+	var s osin.Storage = p
+	client := &osin.DefaultClient{Id: "app", Secret: "s3cr3t", RedirectUri: "https://example.com/callback"}
+	if err := p.CreateClient(client); err != nil {
+		t.Fatalf("CreateClient() error = %s", err)
+	}
+	c, err := s.GetClient("app")
+	if err != nil {
+		t.Fatalf("GetClient() error = %s", err)
+	}
+	if c.GetSecret() != client.Secret || c.GetRedirectUri() != client.RedirectUri {
+		t.Errorf("GetClient() = %+v, want %+v", c, client)
+	}
+	clients, err := p.ListClients()
+	if err != nil || len(clients) != 1 {
+		t.Errorf("ListClients() = %v, %v, want the app client", clients, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	authorize := &osin.AuthorizeData{Client: client, Code: "code", ExpiresIn: 3600, State: "state", CreatedAt: now}
+	if err = s.SaveAuthorize(authorize); err != nil {
+		t.Fatalf("SaveAuthorize() error = %s", err)
+	}
+	a, err := s.LoadAuthorize("code")
+	if err != nil {
+		t.Fatalf("LoadAuthorize() error = %s", err)
+	}
+	if a.Code != "code" || a.State != "state" || !a.CreatedAt.Equal(now) {
+		t.Errorf("LoadAuthorize() = %+v, want %+v", a, authorize)
+	}
+
+	access := &osin.AccessData{Client: client, AuthorizeData: authorize, AccessToken: "token", ExpiresIn: 3600, CreatedAt: now}
+	if err = s.SaveAccess(access); err != nil {
+		t.Fatalf("SaveAccess() error = %s", err)
+	}
+	acc, err := s.LoadAccess("token")
+	if err != nil {
+		t.Fatalf("LoadAccess() error = %s", err)
+	}
+	if acc.AccessToken != "token" || acc.AuthorizeData == nil || acc.AuthorizeData.Code != "code" {
+		t.Errorf("LoadAccess() = %+v, want %+v", acc, access)
+	}
+	if err = s.RemoveAccess("token"); err != nil {
+		t.Fatalf("RemoveAccess() error = %s", err)
+	}
+	if _, err = s.LoadAccess("token"); err == nil {
+		t.Errorf("LoadAccess() of a removed token succeeded")
+	}
+	if err = s.RemoveAuthorize("code"); err != nil {
+		t.Fatalf("RemoveAuthorize() error = %s", err)
+	}
+	if err = p.RemoveClient("app"); err != nil {
+		t.Fatalf("RemoveClient() error = %s", err)
+	}
+	if _, err = s.GetClient("app"); !errors.IsNotFound(err) {
+		t.Errorf("GetClient() of a removed client error = %v, want NotFound", err)
+	}
+}
+
+func Test_proxyHandler_methods(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = NewProxyHandler(r, ""); !errors.IsNotValid(err) {
+		t.Errorf("NewProxyHandler() without a token error = %v, want NotValid", err)
+	}
+	h, err := NewProxyHandler(r, "secret")
+	if err != nil {
+		t.Fatalf("NewProxyHandler() error = %s", err)
+	}
+	for _, tt := range []struct {
+		method, path string
+		token        string
+		status       int
+	}{
+		{method: http.MethodPost, path: "/Load", status: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/Load", token: "invalid", status: http.StatusUnauthorized},
+		{method: http.MethodGet, path: "/Load", token: "secret", status: http.StatusMethodNotAllowed},
+		{method: http.MethodPost, path: "/Reindex", token: "secret", status: http.StatusNotImplemented},
+		{method: http.MethodPost, path: "/Load", token: "secret", status: http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// quarantineKey is the prefix of the keys under which the values failing to decode are moved.
+// The keys are followed by the path of the object, without its __raw suffix, so the full scans of the objects
+// don't find them.
+const quarantineKey = "__quarantine"
+
+// QuarantinedValue is the value of an object which failed to decode while loading it, and which was moved out
+// of the way of the loads.
+type QuarantinedValue struct {
+	// Key is the original key of the value.
+	Key string
+	// Error is the decoding error.
+	Error string
+	Time  time.Time
+	Raw   []byte
+	// MemberOf are the collections which contained the object, whose membership keys are restored with it.
+	MemberOf []string `json:",omitempty"`
+}
+
+func getQuarantineKey(p []byte) []byte {
+	return append(append([]byte(quarantineKey), sep...), p...)
+}
+
+// quarantine moves the value of the object key k, which failed to decode with the cause, under the quarantine
+// prefix, so the next loads of its collections don't fail on it, and logs it. The value is kept when it decodes
+// successfully by now, as it could have been replaced in the meantime.
+func (r *repo) quarantine(k []byte, cause error) {
+	if !isObjectKey(k) || cause == nil {
+		return
+	}
+	p := quarantinedPath(string(k))
+	moved := false
+	err := r.update(func(tx dbTxn) error {
+		i, err := tx.Get(k)
+		if err != nil {
+			// NOTE(marius): a concurrent load quarantined it already.
+			return nil
+		}
+		raw, err := i.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if _, err = loadItem(raw); err == nil {
+			return nil
+		}
+		memberOf, err := unlinkQuarantined(tx, p)
+		if err != nil {
+			return err
+		}
+		entry, err := json.Marshal(QuarantinedValue{Key: string(k), Error: cause.Error(), Time: time.Now().UTC(), Raw: raw, MemberOf: memberOf})
+		if err != nil {
+			return err
+		}
+		if err = tx.Set(getQuarantineKey(p), entry); err != nil {
+			return err
+		}
+		moved = true
+		return tx.Delete(k)
+	})
+	if err != nil {
+		r.errFn("unable to quarantine %s, which can't be decoded: %+s", k, err)
+		return
+	}
+	if moved {
+		r.errFn("quarantined %s, which can't be decoded: %+s", k, cause)
+	}
+}
+
+// ListQuarantined returns the values which were moved out of the way of the loads, because they failed to decode.
+func (r *repo) ListQuarantined() ([]QuarantinedValue, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	values := make([]QuarantinedValue, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		values = quarantinedValues(tx)
+		return nil
+	})
+	return values, err
+}
+
+// ReprocessQuarantined decodes again the quarantined values, after passing them through fix when it is not nil,
+// like after fixing a decoding bug, or to repair them. The values which decode are restored under their
+// original keys, unless a new value was stored there in the meantime, in which case they are dropped.
+// It returns the original keys of the values which were restored.
+func (r *repo) ReprocessQuarantined(fix func(v QuarantinedValue) ([]byte, error)) ([]string, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	restored := make([]string, 0)
+	err = r.update(func(tx dbTxn) error {
+		restored = restored[:0]
+		for _, v := range quarantinedValues(tx) {
+			raw := v.Raw
+			if fix != nil {
+				if raw, err = fix(v); err != nil {
+					r.errFn("unable to fix the quarantined %s: %+s", v.Key, err)
+					continue
+				}
+			}
+			if _, err := loadItem(raw); err != nil {
+				continue
+			}
+			v.Raw = raw
+			ok, err := r.restoreQuarantined(tx, v)
+			if err != nil {
+				return err
+			}
+			if ok {
+				restored = append(restored, v.Key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to reprocess the quarantined values")
+	}
+	if len(restored) > 0 {
+		r.invalidateResults()
+	}
+	return restored, nil
+}
+
+// RetryQuarantined decodes again the quarantined value of the original key, like after a vocabulary upgrade,
+// and restores it when it decodes, returning if it was restored, or dropped because a new value was stored at
+// the key in the meantime. When it still fails to decode, the returned error is NotValid, and it stays quarantined.
+func (r *repo) RetryQuarantined(key string) (bool, error) {
+	err := r.Open()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	restored := false
+	err = r.update(func(tx dbTxn) error {
+		v, err := loadQuarantined(tx, key)
+		if err != nil {
+			return err
+		}
+		if _, err = loadItem(v.Raw); err != nil {
+			return errors.NewNotValid(err, "the quarantined %s still fails to decode", key)
+		}
+		restored, err = r.restoreQuarantined(tx, v)
+		return err
+	})
+	if restored {
+		r.invalidateResults()
+	}
+	return restored, err
+}
+
+// PurgeQuarantined removes the values quarantined before the time, and returns their number.
+func (r *repo) PurgeQuarantined(before time.Time) (int, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	purged := 0
+	err = r.update(func(tx dbTxn) error {
+		purged = 0
+		for _, v := range quarantinedValues(tx) {
+			if !v.Time.Before(before) {
+				continue
+			}
+			if err := tx.Delete(getQuarantineKey(quarantinedPath(v.Key))); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Annotatef(err, "unable to purge the quarantined values")
+	}
+	if purged > 0 {
+		r.logFn("Purged %d quarantined values", purged)
+	}
+	return purged, nil
+}
+
+// quarantinedPath returns the path of the object whose value was quarantined from the key.
+func quarantinedPath(key string) []byte {
+	return bytes.TrimSuffix([]byte(key), append(append([]byte{}, sep...), objectKey...))
+}
+
+// loadQuarantined returns the quarantined value of the original key.
+func loadQuarantined(tx dbTxn, key string) (QuarantinedValue, error) {
+	v := QuarantinedValue{}
+	k := getQuarantineKey(quarantinedPath(key))
+	i, err := tx.Get(k)
+	if err != nil {
+		return v, errors.NewNotFound(wrapErr(ErrNotFound, err), "%s is not quarantined", key)
+	}
+	err = i.Value(func(raw []byte) error {
+		return json.Unmarshal(raw, &v)
+	})
+	return v, err
+}
+
+// restoreQuarantined stores the raw value of v at the original key, together with the type, index and membership
+// keys of the object, unless a new value was stored there in the meantime, and removes it from the quarantine.
+func (r *repo) restoreQuarantined(tx dbTxn, v QuarantinedValue) (bool, error) {
+	restored := false
+	k := []byte(v.Key)
+	p := quarantinedPath(v.Key)
+	if _, err := tx.Get(k); err == badger.ErrKeyNotFound {
+		if err = tx.Set(k, v.Raw); err != nil {
+			return false, err
+		}
+		if err = r.relinkQuarantined(tx, p, v); err != nil {
+			return false, err
+		}
+		restored = true
+	}
+	return restored, tx.Delete(getQuarantineKey(p))
+}
+
+// unlinkQuarantined removes the keys referring to the object at path p, whose value is quarantined: its type keys,
+// its index keys and its membership keys. As the value can't be decoded, the index keys are found by scanning
+// the indexes. It returns the collections the object was a member of.
+func unlinkQuarantined(tx dbTxn, p []byte) ([]string, error) {
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+
+	keys := make([][]byte, 0)
+	memberOf := make([]string, 0)
+	scan := func(prefix []byte, fn func(i *badger.Item) error) error {
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := fn(it.Item()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := scan(append(append([]byte{}, p...), sep...), func(i *badger.Item) error {
+		if tp, _ := splitTypeKey(i.Key()); bytes.Equal(tp, p) {
+			keys = append(keys, i.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scan(append([]byte(indexKey), sep...), func(i *badger.Item) error {
+		if bytes.Equal(indexKeyPath(i.Key()), p) {
+			keys = append(keys, i.KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = scan(getMemberOfPrefix(p), func(i *badger.Item) error {
+		keys = append(keys, i.KeyCopy(nil))
+		return i.Value(func(val []byte) error {
+			memberOf = append(memberOf, string(val))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		if err = tx.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	return memberOf, nil
+}
+
+// relinkQuarantined stores the type, index and membership keys of the restored object at path p,
+// which were removed by unlinkQuarantined.
+func (r *repo) relinkQuarantined(tx dbTxn, p []byte, v QuarantinedValue) error {
+	it, err := loadItem(v.Raw)
+	if err != nil || vocab.IsNil(it) {
+		return err
+	}
+	if it.IsObject() {
+		if err = setTypeKey(tx, p, nil, it); err != nil {
+			return err
+		}
+		if err = updateIndexes(tx, r.indexes, p, nil, it); err != nil {
+			return err
+		}
+	}
+	for _, col := range v.MemberOf {
+		if err = tx.Set(getMemberOfKey(p, itemPath(vocab.IRI(col))), []byte(col)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quarantinedCount returns the number of the quarantined values.
+func quarantinedCount(tx dbTxn) int {
+	prefix := append([]byte(quarantineKey), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	count := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		count++
+	}
+	return count
+}
+
+func quarantinedValues(tx dbTxn) []QuarantinedValue {
+	prefix := append([]byte(quarantineKey), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	values := make([]QuarantinedValue, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v := QuarantinedValue{}
+		err := it.Item().Value(func(raw []byte) error {
+			return json.Unmarshal(raw, &v)
+		})
+		if err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
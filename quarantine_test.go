@@ -0,0 +1,187 @@
+package badger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_quarantine(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+	good, bad := vocab.ObjectNew(vocab.NoteType), vocab.ObjectNew(vocab.NoteType)
+	good.ID, bad.ID = "https://example.com/objects/1", "https://example.com/objects/2"
+	for _, ob := range []vocab.Item{good, bad} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.GetLink(), err)
+		}
+	}
+	badKey := getObjectKey(itemPath(bad.ID))
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(badKey, []byte(`{"id": "https://example.com/objects/2", `))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to corrupt %s: %s", badKey, err)
+	}
+
+	r.errFn = t.Logf
+	it, err := r.Load("https://example.com/objects")
+	if err != nil {
+		t.Fatalf("Load() of a collection with a corrupt value error = %s", err)
+	}
+	var items vocab.ItemCollection
+	_ = vocab.OnCollectionIntf(it, func(col vocab.CollectionInterface) error {
+		items = col.Collection()
+		return nil
+	})
+	if len(items) != 1 || !items.Contains(good.ID) {
+		t.Errorf("Load() of a collection with a corrupt value = %v, want only %s", items, good.ID)
+	}
+
+	values, err := r.ListQuarantined()
+	if err != nil {
+		t.Fatalf("ListQuarantined() error = %s", err)
+	}
+	if len(values) != 1 || values[0].Key != string(badKey) || values[0].Error == "" {
+		t.Fatalf("ListQuarantined() = %+v, want the value of %s", values, badKey)
+	}
+	typeKey := string(getTypeKey(itemPath(bad.ID), bad.Type))
+	if keys, _ := r.Keys(typeKey, 0); len(keys) != 0 {
+		t.Errorf("quarantine() left the type key %v of %s", keys, bad.ID)
+	}
+	keys, _ := r.Keys(indexKey, 0)
+	for _, k := range keys {
+		if string(indexKeyPath([]byte(k))) == string(itemPath(bad.ID)) {
+			t.Errorf("quarantine() left the index key %q of %s", k, bad.ID)
+		}
+	}
+
+	restored, err := r.ReprocessQuarantined(func(v QuarantinedValue) ([]byte, error) {
+		return encodeItemFn(bad)
+	})
+	if err != nil {
+		t.Fatalf("ReprocessQuarantined() error = %s", err)
+	}
+	if len(restored) != 1 || restored[0] != string(badKey) {
+		t.Errorf("ReprocessQuarantined() = %v, want %s", restored, badKey)
+	}
+	if _, err = r.Load(bad.ID); err != nil {
+		t.Errorf("Load() of the restored object error = %s", err)
+	}
+	if keys, _ := r.Keys(typeKey, 0); len(keys) != 1 {
+		t.Errorf("ReprocessQuarantined() restored the type keys %v of %s, want one", keys, bad.ID)
+	}
+	if values, _ = r.ListQuarantined(); len(values) != 0 {
+		t.Errorf("ListQuarantined() after the reprocessing = %+v, want none", values)
+	}
+}
+
+func Test_repo_RetryQuarantined(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.errFn = t.Logf
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+	k := getObjectKey(itemPath(ob.ID))
+	raw, _ := encodeItemFn(ob)
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(k, []byte(`{"id": `))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to corrupt %s: %s", k, err)
+	}
+	_, _ = r.Load("https://example.com/objects")
+
+	if s, err := r.Stats(); err != nil || s.Quarantined != 1 {
+		t.Errorf("Stats().Quarantined = %d, %v, want 1", s.Quarantined, err)
+	}
+	if restored, err := r.RetryQuarantined(string(k)); err == nil || restored {
+		t.Fatalf("RetryQuarantined() of an undecodable value = %t, %v, want an error", restored, err)
+	}
+	if _, err = r.RetryQuarantined("https://example.com/objects/2/__raw"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RetryQuarantined() of a missing value error = %v, want %s", err, ErrNotFound)
+	}
+
+	// NOTE(marius): fix the quarantined value, like a vocabulary upgrade would make it decode.
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		v, err := loadQuarantined(tx, string(k))
+		if err != nil {
+			return err
+		}
+		v.Raw = raw
+		entry, _ := json.Marshal(v)
+		return tx.Set(getQuarantineKey(quarantinedPath(string(k))), entry)
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to fix the quarantined %s: %s", k, err)
+	}
+	restored, err := r.RetryQuarantined(string(k))
+	if err != nil || !restored {
+		t.Fatalf("RetryQuarantined() = %t, %v, want true", restored, err)
+	}
+	if _, err = r.Load(ob.ID); err != nil {
+		t.Errorf("Load() of the restored object error = %s", err)
+	}
+	if s, _ := r.Stats(); s.Quarantined != 0 {
+		t.Errorf("Stats().Quarantined after the retry = %d, want 0", s.Quarantined)
+	}
+}
+
+func Test_repo_PurgeQuarantined(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	now := time.Now().UTC()
+	err = r.d.Update(func(tx *badger.Txn) error {
+		for i, age := range []time.Duration{time.Hour, 48 * time.Hour} {
+			v := QuarantinedValue{Key: fmt.Sprintf("example.com/objects/%d/__raw", i), Time: now.Add(-age)}
+			entry, _ := json.Marshal(v)
+			if err := tx.Set(getQuarantineKey(quarantinedPath(v.Key)), entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to quarantine the values: %s", err)
+	}
+
+	purged, err := r.PurgeQuarantined(now.Add(-24 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeQuarantined() = %d, %v, want 1", purged, err)
+	}
+	values, _ := r.ListQuarantined()
+	if len(values) != 1 || values[0].Key != "example.com/objects/0/__raw" {
+		t.Errorf("ListQuarantined() after the purge = %+v, want the recent value", values)
+	}
+}
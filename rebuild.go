@@ -0,0 +1,183 @@
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// RebuildSource is where RebuildCollection finds the members of a collection.
+type RebuildSource string
+
+const (
+	// RebuildFromMembership finds the members in the __member_of keys of the items, which record the collections
+	// containing them.
+	RebuildFromMembership RebuildSource = "membership"
+	// RebuildFromPrefix finds the members in the objects stored under the path of the collection, like the ones
+	// of the objects and the activities collections.
+	RebuildFromPrefix RebuildSource = "prefix"
+)
+
+// RebuildOptions configures RebuildCollection.
+type RebuildOptions struct {
+	Source RebuildSource
+	// DryRun only reports the members which would be recovered.
+	DryRun bool
+}
+
+// RebuildReport is the result of RebuildCollection.
+type RebuildReport struct {
+	Source RebuildSource
+	DryRun bool
+	// Members is the number of members the collection had before the rebuild.
+	Members int
+	// Recovered are the IRIs of the members found in the source, and missing from the collection.
+	Recovered vocab.IRIs
+}
+
+// RebuildCollection adds to the collection the members found in the source, which are missing from it, keeping
+// the existing members, and their order. The recovered members are added after them, in the order of their paths.
+func (r *repo) RebuildCollection(col vocab.IRI, opt RebuildOptions) (RebuildReport, error) {
+	report := RebuildReport{Source: opt.Source, DryRun: opt.DryRun, Recovered: make(vocab.IRIs, 0)}
+	colPath := itemPath(col)
+	if len(colPath) == 0 {
+		return report, errors.NotValidf("invalid collection IRI %s", col)
+	}
+	var find func(tx dbTxn) vocab.IRIs
+	switch opt.Source {
+	case RebuildFromMembership:
+		find = func(tx dbTxn) vocab.IRIs {
+			return membersFromMembership(tx, col, colPath)
+		}
+	case RebuildFromPrefix:
+		find = func(tx dbTxn) vocab.IRIs {
+			return membersFromPrefix(tx, colPath)
+		}
+	default:
+		return report, errors.NotValidf("unknown rebuild source %q", opt.Source)
+	}
+
+	err := r.Open()
+	if err != nil {
+		return report, err
+	}
+	defer r.Close()
+
+	err = r.d.View(func(tx *badger.Txn) error {
+		ob, err := loadRawItem(tx, colPath)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		members, ok := collectionIRIs(ob)
+		if !ok && !vocab.IsNil(ob) {
+			return errors.NotValidf("%s is not a collection", col)
+		}
+		report.Members = len(members)
+		for _, iri := range find(tx) {
+			if !members.Contains(iri) && !report.Recovered.Contains(iri) {
+				report.Recovered = append(report.Recovered, iri)
+			}
+		}
+		return nil
+	})
+	if err != nil || opt.DryRun || len(report.Recovered) == 0 {
+		return report, err
+	}
+	added, err := r.addToCollection(col, report.Recovered)
+	if added > 0 {
+		r.invalidateResults(col)
+		r.logFn("Recovered %d members of %s from %s", added, col, opt.Source)
+	}
+	return report, err
+}
+
+// addToCollection appends the missing iris to the col collection, in chunks of collectionChunkSize, each in its
+// own transaction, and returns the number of IRIs added, which are kept when the next chunks fail.
+func (r *repo) addToCollection(col vocab.IRI, iris vocab.IRIs) (int, error) {
+	added := 0
+	for len(iris) > 0 {
+		chunk := iris[:min(collectionChunkSize, len(iris))]
+		iris = iris[len(chunk):]
+		count := 0
+		err := r.update(func(tx dbTxn) error {
+			count = 0
+			return onCollectionIRIs(tx, col, r.sizeLimitedFn(col, func(members vocab.IRIs) (vocab.IRIs, error) {
+				known := make(map[vocab.IRI]struct{}, len(members))
+				for _, iri := range members {
+					known[iri] = struct{}{}
+				}
+				for _, iri := range chunk {
+					if _, ok := known[iri]; !ok {
+						known[iri] = struct{}{}
+						members = append(members, iri)
+						count++
+					}
+				}
+				return members, nil
+			}))
+		})
+		if err != nil {
+			return added, errors.Annotatef(err, "unable to add %d members to %s", len(chunk), col)
+		}
+		added += count
+	}
+	return added, nil
+}
+
+// membersFromMembership returns the IRIs of the items whose membership keys record them as members of col.
+func membersFromMembership(tx dbTxn, col vocab.IRI, colPath []byte) vocab.IRIs {
+	prefix := append([]byte(memberOfKey), sep...)
+	suffix := append([]byte{indexValueSep}, colPath...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	scheme := "https"
+	if u, err := col.URL(); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	iris := make(vocab.IRIs, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		if !bytes.HasSuffix(k, suffix) {
+			continue
+		}
+		p := bytes.TrimSuffix(bytes.TrimPrefix(k, prefix), suffix)
+		// NOTE(marius): the IRI of the member is the one of its stored object, when it exists.
+		iri := vocab.IRI(scheme + "://" + string(p))
+		if ob, err := loadRawItem(tx, p); err == nil && !vocab.IsNil(ob) {
+			iri = ob.GetLink()
+		}
+		iris = append(iris, iri)
+	}
+	return iris
+}
+
+// membersFromPrefix returns the IRIs of the objects stored under the path of the collection.
+func membersFromPrefix(tx dbTxn, colPath []byte) vocab.IRIs {
+	prefix := append(append([]byte{}, colPath...), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	iris := make(vocab.IRIs, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		i := it.Item()
+		if !isObjectKey(i.Key()) {
+			continue
+		}
+		_ = i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err == nil && !vocab.IsNil(ob) && ob.IsObject() {
+				iris = append(iris, ob.GetLink())
+			}
+			return nil
+		})
+	}
+	return iris
+}
@@ -0,0 +1,69 @@
+package badger
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_RebuildCollection(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	col := vocab.IRI("https://example.com/objects")
+	notes := make(vocab.IRIs, 0)
+	for _, id := range []vocab.IRI{"https://example.com/objects/1", "https://example.com/objects/2"} {
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = id
+		if _, err = r.Save(note); err != nil {
+			t.Fatalf("unable to save %s: %s", id, err)
+		}
+		if err = r.AddTo(col, id); err != nil {
+			t.Fatalf("unable to add %s to %s: %s", id, col, err)
+		}
+		notes = append(notes, id)
+	}
+
+	// NOTE(marius): the collection loses its members, and keeps the membership keys of its former members.
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getObjectKey(itemPath(col)), []byte("[]"))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to empty %s: %s", col, err)
+	}
+
+	for _, source := range []RebuildSource{RebuildFromPrefix, RebuildFromMembership} {
+		report, err := r.RebuildCollection(col, RebuildOptions{Source: source, DryRun: true})
+		if err != nil {
+			t.Fatalf("RebuildCollection() from %s error = %s", source, err)
+		}
+		if report.Members != 0 || len(report.Recovered) != len(notes) {
+			t.Errorf("RebuildCollection() from %s = %+v, want %v recovered", source, report, notes)
+		}
+	}
+	if items := loadIRIs(t, r, col); len(items) != 0 {
+		t.Errorf("RebuildCollection() dry run changed %s to %v", col, items)
+	}
+
+	report, err := r.RebuildCollection(col, RebuildOptions{Source: RebuildFromMembership})
+	if err != nil {
+		t.Fatalf("RebuildCollection() error = %s", err)
+	}
+	if items := loadIRIs(t, r, col); len(items) != len(notes) || !items.Contains(notes[0]) || !items.Contains(notes[1]) {
+		t.Errorf("RebuildCollection() = %+v, and %s contains %v, want %v", report, col, items, notes)
+	}
+	if report, _ = r.RebuildCollection(col, RebuildOptions{Source: RebuildFromPrefix}); len(report.Recovered) != 0 {
+		t.Errorf("RebuildCollection() of a complete collection recovered %v", report.Recovered)
+	}
+
+	if _, err = r.RebuildCollection(col, RebuildOptions{Source: "guess"}); !errors.IsNotValid(err) {
+		t.Errorf("RebuildCollection() with an unknown source error = %v, want NotValid", err)
+	}
+}
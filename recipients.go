@@ -0,0 +1,215 @@
+package badger
+
+import (
+	"bytes"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// noRecipients is the index value for objects which are not addressed to anyone.
+// The filters consider such objects as visible to everyone, so the lookups need to return them too.
+const noRecipients = ""
+
+// RecipientIndex indexes objects by their to, cc, bto, bcc and audience recipients.
+// It allows loading everything addressed to an actor, or to the public collection, with range scans.
+type RecipientIndex struct{}
+
+func (RecipientIndex) Name() string {
+	return "recipient"
+}
+
+func (RecipientIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0)
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		// NOTE(marius): we don't use Object.Recipients() as it deduplicates the recipients in place,
+		// which would modify the object we're saving.
+		for _, recipients := range []vocab.ItemCollection{o.To, o.CC, o.Bto, o.BCC, o.Audience} {
+			for _, rec := range recipients {
+				if vocab.IsNil(rec) {
+					continue
+				}
+				if v := recipientValue(rec.GetLink()); !stringsContain(values, v) {
+					values = append(values, v)
+				}
+			}
+		}
+		return nil
+	})
+	if len(values) == 0 {
+		values = append(values, noRecipients)
+	}
+	return values
+}
+
+// Lookup mirrors the audience filter, which accepts public objects and the objects without recipients
+// besides the ones addressed to the requested recipients, or to the authenticated actor.
+func (RecipientIndex) Lookup(f *filters.Filters) ([]ValueRange, bool) {
+	values, ok := equalityValues(f.Aud)
+	if !ok {
+		return nil, false
+	}
+	for i, v := range values {
+		v = recipientValue(vocab.IRI(v))
+		if !isAbsoluteIRI(v) {
+			return nil, false
+		}
+		values[i] = v
+	}
+	if f.Authenticated != nil {
+		values = append(values, f.Authenticated.GetLink().String())
+	}
+	values = append(values, vocab.PublicNS.String(), noRecipients)
+	return exactValues(values...), true
+}
+
+// recipientValue normalizes the compacted forms of the public namespace to its full IRI.
+func recipientValue(iri vocab.IRI) string {
+	switch iri {
+	case "as:Public", "Public":
+		return vocab.PublicNS.String()
+	}
+	return iri.String()
+}
+
+// LoadAddressedTo loads the objects found in the storage collection col (eg: /activities, /objects)
+// which are explicitly addressed to any of the recipients.
+// When the recipient index is maintained this is a range scan over it, otherwise all the objects
+// in the collection are checked.
+func (r *repo) LoadAddressedTo(col vocab.IRI, recipients ...vocab.IRI) (vocab.ItemCollection, error) {
+	if len(recipients) == 0 {
+		return nil, errors.NotValidf("no recipients to load items for")
+	}
+	base := itemPath(col)
+	if !isStorageCollectionKey(base) {
+		return nil, errors.NotValidf("%s is not a storage collection", col)
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	values := make([]string, 0, len(recipients))
+	for _, rec := range recipients {
+		values = append(values, recipientValue(rec))
+	}
+
+	result := make(vocab.ItemCollection, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if !hasIndex(r.indexes, RecipientIndex{}.Name()) {
+			return r.scanAddressedTo(tx, &result, base, values)
+		}
+		for _, p := range sortedPaths(scanIndex(tx, RecipientIndex{}.Name(), base, exactValues(values...))) {
+			it, err := loadRawItem(tx, p)
+			if err != nil || vocab.IsNil(it) {
+				r.errFn("unable to load indexed item %s: %+s", p, err)
+				continue
+			}
+			result = append(result, it)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// scanAddressedTo iterates over the objects in the storage collection found at base, and appends
+// to result the ones addressed to any of the recipient values.
+func (r *repo) scanAddressedTo(tx dbTxn, result *vocab.ItemCollection, base []byte, values []string) error {
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = base
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Seek(base); it.ValidForPrefix(base); it.Next() {
+		i := it.Item()
+		k := i.Key()
+		if !isObjectKey(k) || !isPathOrChildKey(base, k) || bytes.Equal(k, getObjectKey(base)) || iterKeyIsTooDeep(base, k, 1) {
+			continue
+		}
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil || vocab.IsNil(ob) {
+				return err
+			}
+			for _, v := range (RecipientIndex{}).Values(ob) {
+				if v != noRecipients && stringsContain(values, v) {
+					*result = append(*result, ob)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			r.errFn("unable to load item %s: %+s", k, err)
+		}
+	}
+	return nil
+}
+
+// The values of the public index: objects addressed to the public collection, and objects without recipients,
+// which the filters also consider visible to everyone.
+const (
+	publicAddressed   = "addressed"
+	publicUnaddressed = "unaddressed"
+)
+
+// PublicIndex flags the objects which are visible to anonymous requesters, so the public timelines,
+// and the loads done on behalf of anonymous requesters, don't need to decode the privately addressed ones.
+type PublicIndex struct{}
+
+func (PublicIndex) Name() string {
+	return "public"
+}
+
+func (PublicIndex) Values(it vocab.Item) []string {
+	values := make([]string, 0, 1)
+	if vocab.IsNil(it) || !it.IsObject() {
+		return values
+	}
+	for _, v := range (RecipientIndex{}).Values(it) {
+		switch v {
+		case vocab.PublicNS.String():
+			return append(values, publicAddressed)
+		case noRecipients:
+			return append(values, publicUnaddressed)
+		}
+	}
+	return values
+}
+
+func (PublicIndex) Lookup(_ *filters.Filters) ([]ValueRange, bool) {
+	return nil, false
+}
+
+// LoadPublic loads the objects found in the storage collection col which are addressed to the public collection.
+// When the public index is maintained, the privately addressed objects are not loaded at all.
+func (r *repo) LoadPublic(col vocab.IRI) (vocab.ItemCollection, error) {
+	if !hasIndex(r.indexes, PublicIndex{}.Name()) {
+		return r.LoadAddressedTo(col, vocab.PublicNS)
+	}
+	base := itemPath(col)
+	if !isStorageCollectionKey(base) {
+		return nil, errors.NotValidf("%s is not a storage collection", col)
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := make(vocab.ItemCollection, 0)
+	err = r.d.View(func(tx *badger.Txn) error {
+		for _, p := range sortedPaths(scanIndex(tx, PublicIndex{}.Name(), base, exactValues(publicAddressed))) {
+			it, err := loadRawItem(tx, p)
+			if err != nil || vocab.IsNil(it) {
+				r.errFn("unable to load indexed item %s: %+s", p, err)
+				continue
+			}
+			result = append(result, it)
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,120 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+func Test_repo_LoadAddressedTo(t *testing.T) {
+	jdoe := vocab.IRI("http://example.com/actors/jdoe")
+
+	public := vocab.ObjectNew(vocab.NoteType)
+	public.ID = "http://example.com/objects/1"
+	public.To = vocab.ItemCollection{vocab.PublicNS}
+	public.CC = vocab.ItemCollection{jdoe}
+
+	private := vocab.ObjectNew(vocab.NoteType)
+	private.ID = "http://example.com/objects/2"
+	private.Bto = vocab.ItemCollection{jdoe}
+
+	other := vocab.ObjectNew(vocab.NoteType)
+	other.ID = "http://example.com/objects/3"
+	other.To = vocab.ItemCollection{vocab.IRI("http://example.com/actors/alice")}
+
+	for _, indexes := range [][]Indexer{DefaultIndexes, nil} {
+		r, err := initBadgerForTesting(t)
+		if err != nil {
+			t.Fatalf("Unable to initialize badger: %s", err)
+		}
+		r.indexes = indexes
+
+		for _, ob := range []*vocab.Object{public, private, other} {
+			if _, err = r.Save(ob); err != nil {
+				t.Fatalf("unable to save %s: %s", ob.ID, err)
+			}
+		}
+
+		col, err := r.LoadAddressedTo("http://example.com/objects", jdoe)
+		if err != nil {
+			t.Fatalf("LoadAddressedTo() error = %s", err)
+		}
+		if len(col) != 2 || !col.Contains(public.ID) || !col.Contains(private.ID) {
+			t.Errorf("LoadAddressedTo() = %v, want %s and %s", col.IRIs(), public.ID, private.ID)
+		}
+
+		col, err = r.LoadPublic("http://example.com/objects")
+		if err != nil {
+			t.Fatalf("LoadPublic() error = %s", err)
+		}
+		if len(col) != 1 || !col.Contains(public.ID) {
+			t.Errorf("LoadPublic() = %v, want only %s", col.IRIs(), public.ID)
+		}
+	}
+
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if _, err = r.LoadAddressedTo("http://example.com/jdoe/inbox", jdoe); err == nil {
+		t.Errorf("LoadAddressedTo() expected error for a non storage collection")
+	}
+}
+
+func Test_repo_Load_PublicIndex(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+
+	jdoe := vocab.IRI("http://example.com/actors/jdoe")
+	public := vocab.ObjectNew(vocab.NoteType)
+	public.ID = "http://example.com/objects/public"
+	public.To = vocab.ItemCollection{vocab.PublicNS}
+	unaddressed := vocab.ObjectNew(vocab.NoteType)
+	unaddressed.ID = "http://example.com/objects/unaddressed"
+	private := vocab.ObjectNew(vocab.NoteType)
+	private.ID = "http://example.com/objects/private"
+	private.To = vocab.ItemCollection{jdoe}
+	for _, ob := range []*vocab.Object{public, unaddressed, private} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+
+	// NOTE(marius): we count the decoded private objects, which need to be skipped based on the index.
+	decoded := 0
+	oldDecode := decodeItemFn
+	decodeItemFn = func(raw []byte) (vocab.Item, error) {
+		it, err := oldDecode(raw)
+		if err == nil && !vocab.IsNil(it) && it.GetLink().Equals(private.ID, false) {
+			decoded++
+		}
+		return it, err
+	}
+	defer func() {
+		decodeItemFn = oldDecode
+	}()
+
+	res, err := r.Load("http://example.com/objects", filters.Authorized(vocab.PublicNS))
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	got := collectionItemIRIs(res)
+	if len(got) != 2 || !got.Contains(public.ID) || !got.Contains(unaddressed.ID) {
+		t.Errorf("Load() = %v, want %s and %s", got, public.ID, unaddressed.ID)
+	}
+
+	items, err := r.LoadPublic("http://example.com/objects")
+	if err != nil {
+		t.Fatalf("LoadPublic() error = %s", err)
+	}
+	if len(items) != 1 || !items[0].GetLink().Equals(public.ID, false) {
+		t.Errorf("LoadPublic() = %v, want only %s", items, public.ID)
+	}
+	if decoded > 0 {
+		t.Errorf("the private object was decoded %d times", decoded)
+	}
+}
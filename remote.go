@@ -0,0 +1,82 @@
+package badger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// The copies of the objects fetched from other servers are stored in __remote/<item path> keys, separately from
+// the local objects, so they are never returned by Load, and they expire after the RemoteTTL of the Config.
+const remoteKey = "__remote"
+
+// DefaultRemoteTTL is the time for which the copies of remote objects are kept when the Config doesn't set one.
+const DefaultRemoteTTL = 24 * time.Hour
+
+func getRemoteKey(iri vocab.IRI) []byte {
+	return bytes.Join([][]byte{[]byte(remoteKey), itemPath(iri)}, sep)
+}
+
+// SaveRemote stores a copy of the object it, fetched from another server, replacing the previous one.
+// The copy expires after the RemoteTTL of the Config.
+func (r *repo) SaveRemote(it vocab.Item) error {
+	if vocab.IsNil(it) || len(it.GetLink()) == 0 {
+		return errors.Newf("Unable to save a remote object without an IRI")
+	}
+	raw, err := encodeItemFn(it)
+	if err != nil {
+		return errors.Annotatef(err, "unable to encode remote object %s", it.GetLink())
+	}
+	if err = r.Open(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ttl := r.remoteTTL
+	if ttl <= 0 {
+		ttl = DefaultRemoteTTL
+	}
+	return r.update(func(tx dbTxn) error {
+		return tx.SetEntry(badger.NewEntry(getRemoteKey(it.GetLink()), raw).WithTTL(ttl))
+	})
+}
+
+// LoadRemote returns the copy of the remote object stored at iri by SaveRemote, or a NotFound error
+// when there isn't one, or it has expired.
+func (r *repo) LoadRemote(iri vocab.IRI) (vocab.Item, error) {
+	if err := r.Open(); err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var it vocab.Item
+	err := r.d.View(func(tx *badger.Txn) error {
+		i, err := tx.Get(getRemoteKey(iri))
+		if err != nil {
+			return err
+		}
+		return i.Value(func(raw []byte) error {
+			it, err = r.decode(raw)
+			return err
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, errors.NewNotFound(ErrNotFound, "no copy of %s is stored", iri)
+	}
+	return it, err
+}
+
+// DeleteRemote removes the copy of the remote object stored at iri, when it was deleted on its server.
+func (r *repo) DeleteRemote(iri vocab.IRI) error {
+	if err := r.Open(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.update(func(tx dbTxn) error {
+		return tx.Delete(getRemoteKey(iri))
+	})
+}
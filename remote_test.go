@@ -0,0 +1,64 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_SaveRemote(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://remote.example.com/objects/1"
+	if err = r.SaveRemote(ob); err != nil {
+		t.Fatalf("SaveRemote() error = %s", err)
+	}
+	it, err := r.LoadRemote(ob.ID)
+	if err != nil {
+		t.Fatalf("LoadRemote() error = %s", err)
+	}
+	if !it.GetLink().Equals(ob.ID, false) || it.GetType() != vocab.NoteType {
+		t.Errorf("LoadRemote() = %s %s, want %s %s", it.GetType(), it.GetLink(), ob.Type, ob.ID)
+	}
+	if _, err = r.Load(ob.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() of a remote copy error = %v, want NotFound", err)
+	}
+
+	if err = r.DeleteRemote(ob.ID); err != nil {
+		t.Fatalf("DeleteRemote() error = %s", err)
+	}
+	if _, err = r.LoadRemote(ob.ID); !errors.IsNotFound(err) {
+		t.Errorf("LoadRemote() after DeleteRemote() error = %v, want NotFound", err)
+	}
+	if err = r.SaveRemote(nil); err == nil {
+		t.Errorf("SaveRemote(nil) didn't return an error")
+	}
+}
+
+func Test_repo_SaveRemote_Expires(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.remoteTTL = time.Second
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://remote.example.com/objects/1"
+	if err = r.SaveRemote(ob); err != nil {
+		t.Fatalf("SaveRemote() error = %s", err)
+	}
+	if _, err = r.LoadRemote(ob.ID); err != nil {
+		t.Fatalf("LoadRemote() error = %s", err)
+	}
+	// NOTE(marius): badger stores the expiration times with a precision of one second.
+	time.Sleep(2 * time.Second)
+	if _, err = r.LoadRemote(ob.ID); !errors.IsNotFound(err) {
+		t.Errorf("LoadRemote() after the ttl error = %v, want NotFound", err)
+	}
+}
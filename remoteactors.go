@@ -0,0 +1,178 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// The snapshots of the actors fetched from other servers are stored in __remote_actor/<item path> keys, which,
+// unlike the copies stored by SaveRemote, don't expire. Their fetch times are stored in
+// __remote_actor_fetched/<unix nano time>\x00<item path> keys, having the IRI of the actor as value, so the
+// stale snapshots can be found in order without decoding the actor documents.
+const (
+	remoteActorKey        = "__remote_actor"
+	remoteActorFetchedKey = "__remote_actor_fetched"
+)
+
+func getRemoteActorKey(iri vocab.IRI) []byte {
+	return bytes.Join([][]byte{[]byte(remoteActorKey), itemPath(iri)}, sep)
+}
+
+func getRemoteActorFetchedKey(iri vocab.IRI, fetched time.Time) []byte {
+	k := append([]byte(remoteActorFetchedKey), sep...)
+	k = binary.BigEndian.AppendUint64(k, uint64(fetched.UnixNano()))
+	return append(append(k, indexValueSep), itemPath(iri)...)
+}
+
+// RemoteActor is a snapshot of an actor document fetched from another server.
+type RemoteActor struct {
+	Actor vocab.Item
+	// ETag is the entity tag returned by the server with the document, for revalidating the snapshot.
+	ETag string
+	// Fetched is the time when the document was fetched. When zero, the time of the save is used.
+	Fetched time.Time
+}
+
+type remoteActorRecord struct {
+	ETag    string          `json:"etag,omitempty"`
+	Fetched time.Time       `json:"fetched"`
+	Actor   json.RawMessage `json:"actor"`
+}
+
+// SaveRemoteActor stores the snapshot of a remote actor, replacing the previous one.
+func (r *repo) SaveRemoteActor(a RemoteActor) error {
+	if vocab.IsNil(a.Actor) || len(a.Actor.GetLink()) == 0 {
+		return errors.Newf("Unable to save a remote actor without an IRI")
+	}
+	if !vocab.ActorTypes.Contains(a.Actor.GetType()) {
+		return errors.NotValidf("%s is not an actor", a.Actor.GetLink())
+	}
+	if a.Fetched.IsZero() {
+		a.Fetched = time.Now()
+	}
+	raw, err := encodeItemFn(a.Actor)
+	if err != nil {
+		return errors.Annotatef(err, "unable to encode remote actor %s", a.Actor.GetLink())
+	}
+	rec, err := json.Marshal(remoteActorRecord{ETag: a.ETag, Fetched: a.Fetched.UTC(), Actor: raw})
+	if err != nil {
+		return err
+	}
+	if err = r.Open(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	iri := a.Actor.GetLink()
+	return r.update(func(tx dbTxn) error {
+		if old, err := loadRemoteActorRecord(tx, iri); err == nil {
+			if err = tx.Delete(getRemoteActorFetchedKey(iri, old.Fetched)); err != nil {
+				return err
+			}
+		}
+		if err := tx.Set(getRemoteActorKey(iri), rec); err != nil {
+			return err
+		}
+		return tx.Set(getRemoteActorFetchedKey(iri, a.Fetched), []byte(iri))
+	})
+}
+
+func loadRemoteActorRecord(tx dbTxn, iri vocab.IRI) (remoteActorRecord, error) {
+	rec := remoteActorRecord{}
+	i, err := tx.Get(getRemoteActorKey(iri))
+	if err != nil {
+		return rec, err
+	}
+	err = i.Value(func(raw []byte) error {
+		return json.Unmarshal(raw, &rec)
+	})
+	return rec, err
+}
+
+// LoadRemoteActor returns the snapshot of the remote actor at iri, or a NotFound error when there isn't one.
+func (r *repo) LoadRemoteActor(iri vocab.IRI) (RemoteActor, error) {
+	if err := r.Open(); err != nil {
+		return RemoteActor{}, err
+	}
+	defer r.Close()
+
+	var rec remoteActorRecord
+	err := r.d.View(func(tx *badger.Txn) error {
+		var err error
+		rec, err = loadRemoteActorRecord(tx, iri)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return RemoteActor{}, errors.NewNotFound(ErrNotFound, "no snapshot of %s is stored", iri)
+	}
+	if err != nil {
+		return RemoteActor{}, err
+	}
+	it, err := r.decode(rec.Actor)
+	if err != nil {
+		return RemoteActor{}, errors.Annotatef(err, "unable to decode remote actor %s", iri)
+	}
+	return RemoteActor{Actor: it, ETag: rec.ETag, Fetched: rec.Fetched}, nil
+}
+
+// DeleteRemoteActor removes the snapshot of the remote actor at iri, when it was deleted on its server.
+func (r *repo) DeleteRemoteActor(iri vocab.IRI) error {
+	if err := r.Open(); err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.update(func(tx dbTxn) error {
+		old, err := loadRemoteActorRecord(tx, iri)
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = tx.Delete(getRemoteActorFetchedKey(iri, old.Fetched)); err != nil {
+			return err
+		}
+		return tx.Delete(getRemoteActorKey(iri))
+	})
+}
+
+// StaleActors returns the IRIs of the remote actors whose snapshots were fetched more than olderThan ago,
+// the least recently fetched first, for refreshing them in the background.
+func (r *repo) StaleActors(olderThan time.Duration) (vocab.IRIs, error) {
+	if err := r.Open(); err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	prefix := append([]byte(remoteActorFetchedKey), sep...)
+	cutoff := binary.BigEndian.AppendUint64(append([]byte{}, prefix...), uint64(time.Now().Add(-olderThan).UnixNano()))
+	stale := make(vocab.IRIs, 0)
+	err := r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = prefix
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			i := it.Item()
+			if bytes.Compare(i.Key(), cutoff) >= 0 {
+				break
+			}
+			err := i.Value(func(val []byte) error {
+				stale = append(stale, vocab.IRI(val))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return stale, err
+}
@@ -0,0 +1,65 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_SaveRemoteActor(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	now := time.Now().UTC()
+	fresh := vocab.PersonNew("https://remote.example.com/actors/fresh")
+	stale := vocab.PersonNew("https://remote.example.com/actors/stale")
+	older := vocab.PersonNew("https://remote.example.com/actors/older")
+	snapshots := []RemoteActor{
+		{Actor: fresh, ETag: `"1"`, Fetched: now.Add(-time.Minute)},
+		{Actor: stale, ETag: `"2"`, Fetched: now.Add(-2 * time.Hour)},
+		{Actor: older, Fetched: now.Add(-3 * time.Hour)},
+	}
+	for _, a := range snapshots {
+		if err = r.SaveRemoteActor(a); err != nil {
+			t.Fatalf("SaveRemoteActor() error = %s", err)
+		}
+	}
+	a, err := r.LoadRemoteActor(stale.ID)
+	if err != nil {
+		t.Fatalf("LoadRemoteActor() error = %s", err)
+	}
+	if !a.Actor.GetLink().Equals(stale.ID, false) || a.ETag != `"2"` || !a.Fetched.Equal(snapshots[1].Fetched) {
+		t.Errorf("LoadRemoteActor() = %+v, want %+v", a, snapshots[1])
+	}
+	if _, err = r.Load(stale.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() of a remote actor snapshot error = %v, want NotFound", err)
+	}
+
+	got, err := r.StaleActors(time.Hour)
+	if err != nil {
+		t.Fatalf("StaleActors() error = %s", err)
+	}
+	if len(got) != 2 || !got[0].Equals(older.ID, false) || !got[1].Equals(stale.ID, false) {
+		t.Errorf("StaleActors() = %v, want [%s %s]", got, older.ID, stale.ID)
+	}
+
+	// NOTE(marius): refreshing the snapshot removes its previous fetch time.
+	if err = r.SaveRemoteActor(RemoteActor{Actor: stale, ETag: `"3"`}); err != nil {
+		t.Fatalf("SaveRemoteActor() error = %s", err)
+	}
+	if err = r.DeleteRemoteActor(older.ID); err != nil {
+		t.Fatalf("DeleteRemoteActor() error = %s", err)
+	}
+	if got, _ = r.StaleActors(time.Hour); len(got) != 0 {
+		t.Errorf("StaleActors() after refreshing = %v, want none", got)
+	}
+	if _, err = r.LoadRemoteActor(older.ID); !errors.IsNotFound(err) {
+		t.Errorf("LoadRemoteActor() after DeleteRemoteActor() error = %v, want NotFound", err)
+	}
+	if err = r.SaveRemoteActor(RemoteActor{Actor: vocab.ObjectNew(vocab.NoteType)}); err == nil {
+		t.Errorf("SaveRemoteActor() of an object without an IRI didn't return an error")
+	}
+}
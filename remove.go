@@ -0,0 +1,152 @@
+package badger
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// RemoveOptions configures Remove.
+type RemoveOptions struct {
+	// Cascade removes the item from the collections containing it, and removes the objects stored under its path,
+	// like the collections of an actor, or the replies of an object.
+	Cascade bool
+	// Tombstone replaces the item with a Tombstone having its former type, instead of removing it.
+	Tombstone bool
+	// DryRun only reports what would be removed.
+	DryRun bool
+}
+
+// RemoveReport is the result of Remove.
+type RemoveReport struct {
+	DryRun bool
+	// Removed are the IRIs of the objects removed, the item itself included, unless it was replaced by a Tombstone.
+	Removed vocab.IRIs
+	// Tombstone is the IRI of the item, when it was replaced by a Tombstone.
+	Tombstone vocab.IRI
+	// Collections are the IRIs of the collections the item was removed from.
+	Collections vocab.IRIs
+}
+
+// Remove removes the item stored at iri, and with the Cascade option, the references to it and the objects
+// depending on it.
+func (r *repo) Remove(iri vocab.IRI, opt RemoveOptions) (RemoveReport, error) {
+	report := RemoveReport{DryRun: opt.DryRun, Removed: make(vocab.IRIs, 0), Collections: make(vocab.IRIs, 0)}
+	if len(itemPath(iri)) == 0 {
+		return report, errors.NotValidf("invalid IRI %s", iri)
+	}
+
+	it, err := r.removeDependents(iri, opt, &report)
+	if err != nil {
+		return report, err
+	}
+	for _, col := range report.Collections {
+		if opt.DryRun {
+			break
+		}
+		if err = r.RemoveFrom(col, iri); err != nil {
+			return report, errors.Annotatef(err, "unable to remove %s from %s", iri, col)
+		}
+	}
+
+	if opt.Tombstone {
+		report.Tombstone = iri
+		if opt.DryRun {
+			return report, nil
+		}
+		tomb := vocab.Tombstone{
+			ID:         iri,
+			Type:       vocab.TombstoneType,
+			FormerType: it.GetType(),
+			Deleted:    time.Now().UTC(),
+		}
+		if _, err = r.Save(&tomb); err != nil {
+			return report, errors.Annotatef(err, "unable to save tombstone for %s", iri)
+		}
+		return report, nil
+	}
+	report.Removed = append(report.Removed, iri)
+	if opt.DryRun {
+		return report, nil
+	}
+	if !it.IsObject() {
+		// NOTE(marius): deleting a collection item would delete its members too, so we delete only its IRI.
+		it = iri
+	}
+	return report, r.Delete(it)
+}
+
+// removeDependents loads the item stored at iri, and with the Cascade option, removes the objects stored under
+// its path, and finds the collections containing it.
+func (r *repo) removeDependents(iri vocab.IRI, opt RemoveOptions, report *RemoveReport) (vocab.Item, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	base := itemPath(iri)
+	var it vocab.Item
+	dependents := make(map[string]vocab.Item)
+	err = r.d.View(func(tx *badger.Txn) error {
+		if it, err = loadRawItem(tx, base); err != nil || vocab.IsNil(it) {
+			return errors.NewNotFound(ErrNotFound, "%s not found", iri)
+		}
+		if !opt.Cascade {
+			return nil
+		}
+		report.Collections = append(report.Collections, collectionsContaining(tx, iri)...)
+
+		iopt := badger.DefaultIteratorOptions
+		iopt.Prefix = append(append([]byte{}, base...), sep...)
+		i := tx.NewIterator(iopt)
+		defer i.Close()
+		for i.Seek(iopt.Prefix); i.ValidForPrefix(iopt.Prefix); i.Next() {
+			k := i.Item().Key()
+			if !isObjectKey(k) {
+				continue
+			}
+			p := bytes.TrimSuffix(k, append(sep, objectKey...))
+			dep := vocab.IRI(strings.TrimRight(iri.String(), "/") + string(bytes.TrimPrefix(p, base)))
+			ob, err := loadRawItem(tx, p)
+			if err != nil {
+				return errors.Annotatef(err, "unable to load %s", dep)
+			}
+			if vocab.IsNil(ob) || !ob.IsObject() {
+				ob = dep
+			}
+			dependents[dep.String()] = ob
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, dep := range sortedKeys(dependents) {
+		report.Removed = append(report.Removed, vocab.IRI(dep))
+	}
+	if opt.DryRun || len(dependents) == 0 {
+		return it, nil
+	}
+
+	b := r.newWriteBatch()
+	for _, dep := range dependents {
+		if err = deleteFromPath(r, b, dep); err != nil {
+			b.Cancel()
+			return nil, errors.Annotatef(err, "unable to remove %s", dep.GetLink())
+		}
+	}
+	if err = b.Flush(); err != nil {
+		return nil, err
+	}
+	for _, dep := range report.Removed {
+		r.invalidateItem(dep)
+		r.notify(MirrorDelete, "", dep)
+	}
+	r.logFn("Removed %d objects depending on %s", len(dependents), iri)
+	return it, nil
+}
@@ -0,0 +1,65 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_Remove(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Inbox = vocab.Inbox.IRI(jdoe)
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo(jdoe.Outbox.GetLink(), note.ID); err != nil {
+		t.Fatalf("unable to add %s to the outbox: %s", note.ID, err)
+	}
+
+	dry, err := r.Remove(note.ID, RemoveOptions{Cascade: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("Remove() dry run error = %s", err)
+	}
+	if !dry.Collections.Contains(jdoe.Outbox.GetLink()) || !dry.Removed.Contains(note.ID) {
+		t.Errorf("Remove() dry run = %+v, want the note removed from the outbox", dry)
+	}
+	if items := loadIRIs(t, r, jdoe.Outbox.GetLink()); !items.Contains(note.ID) {
+		t.Errorf("Remove() dry run changed the outbox to %v", items)
+	}
+
+	if _, err = r.Remove(note.ID, RemoveOptions{Cascade: true}); err != nil {
+		t.Fatalf("Remove() error = %s", err)
+	}
+	if items := loadIRIs(t, r, jdoe.Outbox.GetLink()); items.Contains(note.ID) {
+		t.Errorf("Remove() left %s in the outbox", note.ID)
+	}
+
+	report, err := r.Remove(jdoe.ID, RemoveOptions{Cascade: true, Tombstone: true})
+	if err != nil {
+		t.Fatalf("Remove() with a tombstone error = %s", err)
+	}
+	if report.Tombstone != jdoe.ID || !report.Removed.Contains(jdoe.Inbox.GetLink()) || report.Removed.Contains(jdoe.ID) {
+		t.Errorf("Remove() with a tombstone = %+v, want the collections removed and the actor tombstoned", report)
+	}
+	it, err := r.Load(jdoe.ID)
+	if err != nil {
+		t.Fatalf("Load() of the tombstone error = %s", err)
+	}
+	if it.GetType() != vocab.TombstoneType {
+		t.Errorf("Load() = %s, want a Tombstone", it.GetType())
+	}
+
+	if _, err = r.Remove("https://example.com/objects/2", RemoveOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("Remove() of a missing object error = %v, want NotFound", err)
+	}
+}
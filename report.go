@@ -0,0 +1,131 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// The sections of the rows written by ReportCSV.
+const (
+	ReportActor = "actor"
+	ReportType  = "type"
+	ReportDay   = "day"
+)
+
+// ReportOptions selects the objects counted by ReportCSV.
+type ReportOptions struct {
+	// Since and Until limit the report to the objects published in [Since, Until). When zero, they don't
+	// limit it.
+	Since time.Time
+	Until time.Time
+}
+
+// ReportCSV writes to w a usage report of the objects published in the interval of the options, as CSV with
+// the "report", "key" and "count" columns. Its rows are, in this order:
+//   - ReportActor rows, with the number of activities of each actor
+//   - ReportType rows, with the number of objects of each type, including the activities and the actors
+//   - ReportDay rows, with the number of articles, notes, pages and questions published each day, in UTC
+//
+// The report is computed from the type, the published and the actor indexes, without loading the objects,
+// so the objects without a published date are not counted.
+func (r *repo) ReportCSV(w io.Writer, opts ReportOptions) error {
+	for _, name := range []string{TypeIndex{}.Name(), PublishedIndex{}.Name(), ActorIndex{}.Name()} {
+		if !hasIndex(r.indexes, name) {
+			return errors.NotValidf("the %s index is not maintained", name)
+		}
+	}
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	actors := make(map[string]int)
+	types := make(map[string]int)
+	days := make(map[string]int)
+	err = r.d.View(func(tx *badger.Txn) error {
+		published := reportPublished(tx, opts)
+		eachIndexKey(tx, TypeIndex{}.Name(), func(value, p []byte) {
+			day, ok := published[string(p)]
+			if !ok {
+				return
+			}
+			types[string(value)]++
+			if nodeInfoPostTypes.Contains(vocab.ActivityVocabularyType(value)) {
+				days[day]++
+			}
+		})
+		eachIndexKey(tx, ActorIndex{}.Name(), func(value, p []byte) {
+			if _, ok := published[string(p)]; ok {
+				actors[string(value)]++
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"report", "key", "count"})
+	for _, section := range []struct {
+		name   string
+		counts map[string]int
+	}{{ReportActor, actors}, {ReportType, types}, {ReportDay, days}} {
+		keys := make([]string, 0, len(section.counts))
+		for k := range section.counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			_ = cw.Write([]string{section.name, k, strconv.Itoa(section.counts[k])})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// reportPublished returns the days on which the objects published in the interval of the options were published,
+// by their paths.
+func reportPublished(tx dbTxn, opts ReportOptions) map[string]string {
+	start, end := "", "\xff"
+	if !opts.Since.IsZero() {
+		start = opts.Since.UTC().Format(publishedIndexFormat)
+	}
+	if !opts.Until.IsZero() {
+		end = opts.Until.UTC().Format(publishedIndexFormat)
+	}
+	published := make(map[string]string)
+	eachIndexKey(tx, PublishedIndex{}.Name(), func(value, p []byte) {
+		if v := string(value); v >= start && v < end && len(v) >= len(time.DateOnly) {
+			published[string(p)] = v[:len(time.DateOnly)]
+		}
+	})
+	return published
+}
+
+// eachIndexKey calls fn with the value and the path of the keys of the index. They are valid only during the call.
+func eachIndexKey(tx dbTxn, name string, fn func(value, p []byte)) {
+	prefix := getIndexPrefix(name)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		i := bytes.IndexByte(k, indexValueSep)
+		if i < 0 {
+			continue
+		}
+		fn(k[len(prefix):i], k[i+1:])
+	}
+}
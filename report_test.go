@@ -0,0 +1,57 @@
+package badger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_ReportCSV(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	if err = r.ReportCSV(&bytes.Buffer{}, ReportOptions{}); !errors.IsNotValid(err) {
+		t.Errorf("ReportCSV() without indexes error = %v, want NotValid", err)
+	}
+	r.indexes = DefaultIndexes
+
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	items := []vocab.Item{jdoe}
+	for i, published := range []time.Time{day, day.Add(time.Hour), day.Add(24 * time.Hour), day.Add(-48 * time.Hour)} {
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = vocab.IRI("https://example.com/objects/" + string(rune('a'+i)))
+		note.Published = published
+		create := vocab.CreateNew(vocab.IRI("https://example.com/activities/"+string(rune('a'+i))), note.ID)
+		create.Actor = jdoe.ID
+		create.Published = published
+		items = append(items, note, create)
+	}
+	for _, it := range items {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if err = r.ReportCSV(&buf, ReportOptions{Since: day.Add(-time.Hour), Until: day.Add(48 * time.Hour)}); err != nil {
+		t.Fatalf("ReportCSV() error = %s", err)
+	}
+	want := strings.Join([]string{
+		"report,key,count",
+		"actor,https://example.com/actors/jdoe,3",
+		"type,Create,3",
+		"type,Note,3",
+		"day,2026-10-01,2",
+		"day,2026-10-02,1",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("ReportCSV() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -8,10 +8,15 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	vocab "github.com/go-ap/activitypub"
@@ -20,14 +25,43 @@ import (
 	"github.com/go-ap/processing"
 	"github.com/go-ap/storage-badger/internal/cache"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/singleflight"
 )
 
 type repo struct {
-	d     *badger.DB
-	path  string
-	cache cache.CanStore
-	logFn loggerFn
-	errFn loggerFn
+	d             *badger.DB
+	h             *handle
+	opened        int
+	path          string
+	cache         cache.CanStore
+	notFound      cache.CanStoreMissing
+	decoded       cache.CanStoreDecoded
+	serialized    cache.CanStoreSerialized
+	loads         *singleflight.Group
+	remoteTTL     time.Duration
+	embedded      *cache.Embedded
+	cacheCounters *cacheCounters
+	indexes       []Indexer
+	scanWorkers   int
+	maxLoadItems  int
+	deref         DerefOptions
+	mirror        *mirror
+	canary        *canary
+	watchers      *watchers
+	hooks         *hooks
+	events        *bus
+	dryRun        *DryRunLog
+	log           *slog.Logger
+	badger        BadgerOptions
+	encryptionKey []byte
+	retryAttempts int
+	retryBackoff  time.Duration
+	throttle      *throttle
+	maxItemSize   int64
+	validator     Validator
+	rewrites      []rewrite
+	logFn         loggerFn
+	errFn         loggerFn
 }
 
 var encodeItemFn = vocab.MarshalJSON
@@ -39,8 +73,105 @@ type loggerFn func(string, ...interface{})
 type Config struct {
 	Path        string
 	CacheEnable bool
-	LogFn       loggerFn
-	ErrFn       loggerFn
+	// Cache is the store used for the results of Load, instead of a built-in one. When set, CacheEnable,
+	// CacheBackend and the cache limits are ignored.
+	Cache Cache
+	// CacheBackend chooses the built-in store used for the results of Load when CacheEnable is set.
+	// When empty, CacheMap is used, or CacheLRU if any of the cache limits are set.
+	CacheBackend CacheBackend
+	// CacheMaxEntries is the maximum number of results kept by the CacheLRU and CacheRistretto stores.
+	CacheMaxEntries int
+	// CacheMaxBytes is the approximate maximum memory used by the results kept by the CacheLRU and CacheRistretto
+	// stores. The CacheRistretto store enforces only this limit when both are set.
+	CacheMaxBytes int64
+	// CacheCollectionTTL is the time for which the collections loaded are kept in the cache, so the ones
+	// containing items from remote servers get reloaded without needing to be invalidated explicitly.
+	// When zero, they are kept until invalidated or evicted.
+	CacheCollectionTTL time.Duration
+	// CacheObjectTTL is the time for which the objects loaded are kept in the cache. As activities don't change,
+	// this can be much longer than CacheCollectionTTL. When zero, they are kept until invalidated or evicted.
+	CacheObjectTTL time.Duration
+	// Indexes is the list of filter indexes to maintain on Save and Delete. When nil, DefaultIndexes are used,
+	// and when empty, like for `indexes = []` in the configuration file, none of them are maintained.
+	Indexes []Indexer
+	// SkipIndexing disables the maintenance of the filter indexes, trading read speed for write speed.
+	SkipIndexing bool
+	// ScanWorkers is the number of goroutines used for decoding the objects of large storage collections.
+	// When zero, GOMAXPROCS is used.
+	ScanWorkers int
+	// MaxLoadItems caps the number of items returned by a Load which doesn't contain a MaxCount check.
+	// When the cap is reached, the partial collection is returned together with a Truncated error.
+	// When zero, the results are not capped.
+	MaxLoadItems int
+	// Deref configures the dereferencing of the properties of the loaded items, like the actors and objects
+	// of activities. The zero value keeps the default behaviour described for DerefDefault.
+	Deref DerefOptions
+	// NotFoundTTL is the time for which the IRIs of the objects that were not found get remembered, so the
+	// repeated lookups of missing objects, like the ones of reply chains, don't reach the database.
+	// Saving an object at the IRI forgets it earlier. When zero, the missing objects are not remembered.
+	NotFoundTTL time.Duration
+	// DecodedCacheSize is the number of decoded items kept in memory, for the values which get loaded repeatedly,
+	// like the instance actor and the popular authors, as decoding large actors is expensive. The cached items
+	// are copied when loaded, so they can be modified. When zero, the decoded items are not cached.
+	DecodedCacheSize int
+	// SerializedCacheSize is the number of JSON encoded collection pages kept in memory by LoadJSON, for the
+	// anonymous requests which get repeated, like the ones for the public timelines. The pages are removed when
+	// items are added to, or removed from, their collections. When zero, the pages are not cached.
+	SerializedCacheSize int
+	// RemoteTTL is the time for which the copies of the objects fetched from other servers, stored with SaveRemote,
+	// are kept. When zero, DefaultRemoteTTL is used.
+	RemoteTTL time.Duration
+	// Mirror receives the changes made by Save, Delete, AddTo and RemoveFrom, asynchronously, in the order
+	// they were made, while the repository is open. The changes still queued are sent by the Close matching
+	// the first Open. The failed changes are logged, and can be listed with MirrorFailures.
+	Mirror Mirror
+	// MirrorQueueSize is the number of changes waiting to be sent to the Mirror, after which the changes are
+	// dropped, and recorded as failures. When zero, DefaultMirrorQueueSize is used.
+	MirrorQueueSize int
+	// Canary receives the changes made to the repository, like the Mirror, which can't be set together with it.
+	// The results of CanaryReadPercent of the calls to Load are compared to the ones of the Canary, and the
+	// mismatches are logged, and counted in the CanaryStats.
+	Canary Canary
+	// CanaryReadPercent is the percentage, between 0 and 100, of the calls to Load compared with the Canary.
+	CanaryReadPercent float64
+	// Badger tunes the badger database. The zero value keeps the badger defaults.
+	Badger BadgerOptions
+	// EncryptionKey encrypts the stored data with AES. It must be 16, 24 or 32 bytes long, and the same key
+	// is needed for opening the database afterwards. When empty, the data is not encrypted.
+	EncryptionKey []byte
+	// RetryAttempts is the number of times the writes failing with transient badger errors, like the conflicts
+	// with concurrent writes, are attempted before returning the error. When zero, DefaultRetryAttempts is used,
+	// while 1 or a negative value disables the retries.
+	RetryAttempts int
+	// RetryBackoff is the time waited before the first retry, which is doubled for each of the next ones.
+	// When zero, DefaultRetryBackoff is used.
+	RetryBackoff time.Duration
+	// WriteRate is the number of writes per second, like the ones of Save, AddTo and Update, after which the
+	// next ones wait. The bulk imports are not limited. When zero, the rate of writes is not limited.
+	WriteRate float64
+	// WriteBurst is the number of writes which can be made at once above the WriteRate. When zero, it is 1.
+	WriteBurst int
+	// MaxPendingWrites is the number of writes which can wait for the WriteRate, or be made, at the same time,
+	// after which the next ones fail with ErrOverloaded. When zero, the pending writes are not limited.
+	MaxPendingWrites int
+	// MaxItemSize is the size, in bytes, of the largest encoded value of an object, or of the list of items of
+	// a collection, which can be written. The writes of larger ones fail with a TooLarge error, before reaching
+	// badger, whose limits fail them mid transaction. When zero, the sizes are only limited by badger.
+	MaxItemSize int64
+	// Validator checks the items before Save stores them, and the ones it returns an error for are rejected
+	// with a NotValid error wrapping it. ValidateItem does the checks of the vocabulary types and of the
+	// required properties. When nil, the items are not validated.
+	Validator Validator
+	// Rewrites maps the old hosts of the instance to their new ones, for the instances which changed their domain.
+	// The IRIs of the old hosts are replaced with the ones of the new hosts in the loaded items, and the items of
+	// the new hosts are loaded from under the old ones, when they weren't re-keyed yet. The new items are
+	// stored under the new hosts.
+	Rewrites map[string]string
+	// Logger receives the log messages at their levels, and the operations made on the repository, with the
+	// operation, iri, duration and error fields. When set, LogFn and ErrFn are ignored.
+	Logger *slog.Logger
+	LogFn  loggerFn
+	ErrFn  loggerFn
 }
 
 var emptyLogFn = func(string, ...interface{}) {}
@@ -54,10 +185,37 @@ func New(c Config) (*repo, error) {
 	if err != nil {
 		return nil, err
 	}
+	rc, err := newResultCache(c)
+	if err != nil {
+		return nil, err
+	}
 	b := repo{
-		path:  c.Path,
-		logFn: emptyLogFn,
-		errFn: emptyLogFn,
+		path:          c.Path,
+		h:             new(handle),
+		cache:         rc,
+		embedded:      cache.NewEmbedded(),
+		cacheCounters: new(cacheCounters),
+		notFound:      cache.NewNotFound(c.NotFoundTTL),
+		decoded:       cache.NewDecoded(c.DecodedCacheSize),
+		serialized:    cache.NewSerialized(c.SerializedCacheSize),
+		loads:         new(singleflight.Group),
+		remoteTTL:     c.RemoteTTL,
+		scanWorkers:   c.ScanWorkers,
+		maxLoadItems:  c.MaxLoadItems,
+		deref:         c.Deref,
+		badger:        c.Badger,
+		encryptionKey: c.EncryptionKey,
+		retryAttempts: c.RetryAttempts,
+		retryBackoff:  c.RetryBackoff,
+		throttle:      newThrottle(c.WriteRate, c.WriteBurst, c.MaxPendingWrites),
+		maxItemSize:   c.MaxItemSize,
+		validator:     c.Validator,
+		rewrites:      newRewrites(c.Rewrites),
+		watchers:      new(watchers),
+		hooks:         new(hooks),
+		events:        new(bus),
+		logFn:         emptyLogFn,
+		errFn:         emptyLogFn,
 	}
 	if c.LogFn != nil {
 		b.logFn = c.LogFn
@@ -65,53 +223,185 @@ func New(c Config) (*repo, error) {
 	if c.ErrFn != nil {
 		b.errFn = c.ErrFn
 	}
+	if c.Logger != nil {
+		b.log = c.Logger
+		b.logFn = slogFn(c.Logger, slog.LevelInfo)
+		b.errFn = slogFn(c.Logger, slog.LevelError)
+	}
+	if err = checkEncryptionKey(c.EncryptionKey); err != nil {
+		return nil, err
+	}
+	if c.Mirror != nil && c.Canary != nil {
+		return nil, errors.NotValidf("only one of Mirror and Canary can be set")
+	}
+	if c.Mirror != nil {
+		b.mirror = newMirror(c.Mirror, c.MirrorQueueSize, b.errFn)
+	}
+	if c.Canary != nil {
+		b.mirror = newMirror(c.Canary, c.MirrorQueueSize, b.errFn)
+		b.canary = &canary{c: c.Canary, percent: c.CanaryReadPercent}
+	}
+	if !c.SkipIndexing {
+		b.indexes = DefaultIndexes
+		if c.Indexes != nil {
+			b.indexes = c.Indexes
+		}
+	}
 	return &b, nil
 }
 
-// Open opens the badger database if possible.
-func (r *repo) Open() error {
+// options returns the badger options of the repository.
+func (r *repo) options() badger.Options {
 	c := badger.DefaultOptions(r.path)
-	logger := logger{logFn: r.logFn, errFn: r.errFn}
+	logger := logger{logFn: r.logFn, errFn: r.errFn, log: r.log}
 	c = c.WithLogger(logger)
 	if r.path == "" {
 		c.InMemory = true
 	}
 	c.MetricsEnabled = false
+	c = r.badger.apply(c)
+	if len(r.encryptionKey) > 0 {
+		c = c.WithEncryptionKey(r.encryptionKey)
+	}
+	return c
+}
 
-	var err error
-	r.d, err = badger.Open(c)
-	if err != nil {
-		err = errors.Annotatef(err, "unable to open storage")
+// handle is the badger database shared by a repository and its copies, which is opened by the first of their
+// Open calls, and closed by the Close call matching the last of them.
+type handle struct {
+	mu   sync.Mutex
+	db   *badger.DB
+	refs int
+}
+
+// Open opens the badger database if possible. When it is already open, by r or by one of its copies, its handle
+// is reused, so the calls to Open can be nested, and made concurrently. Each call needs a matching Close.
+func (r *repo) Open() error {
+	r.h.mu.Lock()
+	defer r.h.mu.Unlock()
+
+	if r.h.db == nil {
+		db, err := badger.Open(r.options())
+		if err != nil {
+			return errors.Annotatef(storageErr(err), "unable to open storage")
+		}
+		r.h.db = db
 	}
-	return err
+	// NOTE(marius): r.d changes only when the database gets reopened, which happens when none of the
+	// operations of r are using it, so the operations can read it without holding the lock.
+	if r.d != r.h.db {
+		r.d = r.h.db
+	}
+	r.h.refs++
+	r.opened++
+	r.mirror.start()
+	return nil
 }
 
 // Close closes the badger database if possible.
 func (r *repo) close() error {
-	if r.d == nil {
+	r.h.mu.Lock()
+	defer r.h.mu.Unlock()
+
+	// NOTE(marius): the calls to Close without a matching Open, like the one osin makes for the clones
+	// at the end of the requests, are ignored.
+	if r.opened == 0 {
+		return nil
+	}
+	r.opened--
+	r.h.refs--
+	if r.h.refs > 0 {
 		return nil
 	}
-	return r.d.Close()
+	// NOTE(marius): the changes queued for the Mirror are sent before the last Close returns.
+	r.mirror.stop()
+	if r.h.db == nil {
+		return nil
+	}
+	db := r.h.db
+	r.h.db = nil
+	return db.Close()
 }
 
-// Load
-func (r *repo) Load(i vocab.IRI, _ ...filters.Check) (vocab.Item, error) {
-	var err error
-	if r.Open(); err != nil {
-		return nil, err
+// copy returns a copy of r sharing its database handle, which has no Open calls of its own.
+func (r *repo) copy() *repo {
+	r.h.mu.Lock()
+	defer r.h.mu.Unlock()
+
+	c := *r
+	c.d = nil
+	c.opened = 0
+	return &c
+}
+
+// Load loads the item, or the collection of items, found at the IRI.
+//
+// When the checks contain a filters.Authorized check, the loaded items are scoped to its requester:
+// items that are not addressed to them are omitted, and the blind recipients of the rest are stripped.
+//
+// When the number of items found exceeds the MaxLoadItems of the Config, and the checks don't contain
+// a MaxCount, the partial collection is returned together with a Truncated error.
+//
+// When the checks contain the BypassCache option, the cached results are not used.
+func (r *repo) Load(i vocab.IRI, checks ...filters.Check) (vocab.Item, error) {
+	start := time.Now()
+	it, err := r.loadResult(i, checks...)
+	r.compareWithCanary(i, checks, it, err)
+	r.logOp("Load", i, start, err)
+	return it, err
+}
+
+// loadResult returns the cached result of loading i, or loads it from the database.
+func (r *repo) loadResult(i vocab.IRI, checks ...filters.Check) (vocab.Item, error) {
+	bypass, keyChecks := bypassCache(checks)
+	key, cacheable := resultCacheKey(i, keyChecks)
+	bypass = bypass || !cacheable
+	if !bypass {
+		if it := r.cachedResult(key); it != nil {
+			return it, nil
+		}
 	}
-	defer r.Close()
 
 	f, err := filters.FiltersFromIRI(i)
 	if err != nil {
 		return nil, err
 	}
+	if f.IsItemIRI() && !bypass && r.isNotFound(i) {
+		return nil, errors.NewNotFound(ErrNotFound, "%s does not exist", i)
+	}
+	if bypass {
+		return r.load(i, f, key, checks...)
+	}
+	return r.sharedLoad(key, func() (vocab.Item, error) {
+		return r.load(i, f, key, checks...)
+	})
+}
 
-	ret, err := r.loadFromPath(f, f.IsItemIRI())
+// load reads the item, or the collection of items, found at i from the database, and caches it under key.
+func (r *repo) load(i vocab.IRI, f *filters.Filters, key vocab.IRI, checks ...filters.Check) (vocab.Item, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	maxItems, capped := r.loadLimit(f, checks)
+	ret, err := r.loadFromPath(f, maxItems, checks...)
+	if err != nil {
+		if f.IsItemIRI() && errors.IsNotFound(err) {
+			r.setNotFound(i)
+		}
+		return ret, err
+	}
 	if len(ret) == 1 && f.IsItemIRI() {
-		return ret.First(), err
+		r.cacheResult(key, ret.First())
+		return ret.First(), nil
+	}
+	if capped && len(ret) > r.maxLoadItems {
+		return ret[:r.maxLoadItems], Truncated{Limit: r.maxLoadItems}
 	}
-	return ret, err
+	r.cacheResult(key, ret)
+	return ret, nil
 }
 
 func (r *repo) Create(col vocab.CollectionInterface) (vocab.CollectionInterface, error) {
@@ -122,13 +412,31 @@ func (r *repo) Create(col vocab.CollectionInterface) (vocab.CollectionInterface,
 	}
 	defer r.Close()
 
-	_, err = createCollectionInPath(r.d.NewWriteBatch(), col.GetLink())
-	return col, err
+	err = r.update(func(tx dbTxn) error {
+		_, err := createCollectionInPath(r, tx, col.GetLink())
+		return err
+	})
+	if err != nil {
+		return col, err
+	}
+	r.clearNotFound(col.GetLink())
+	r.invalidateResults(col.GetLink())
+	// NOTE(marius): the Mirror interface has no Create, so the new collection is sent to it as a Save,
+	// for the Loads compared with the Canary to find it.
+	r.mirror.enqueue(MirrorSave, "", col)
+	return col, nil
 }
 
 // Save
 func (r *repo) Save(it vocab.Item) (vocab.Item, error) {
 	var err error
+	defer r.logItemOp(MirrorSave, "", it, time.Now(), &err)
+	if err = r.validate(it); err != nil {
+		return it, err
+	}
+	if err = r.hooks.runBeforeSave(it); err != nil {
+		return it, err
+	}
 	err = r.Open()
 	if err != nil {
 		return it, err
@@ -142,12 +450,28 @@ func (r *repo) Save(it vocab.Item) (vocab.Item, error) {
 			op = "Added new"
 		}
 		r.logFn("%s %s: %s", op, it.GetType(), it.GetLink())
+		r.notify(MirrorSave, "", it)
 	}
 
 	return it, err
 }
 
-func onCollection(r *repo, col vocab.IRI, it vocab.Item, fn func(iris vocab.IRIs) (vocab.IRIs, error)) error {
+// validate runs the Validator of the Config on the item, when one is set.
+func (r *repo) validate(it vocab.Item) error {
+	if r.validator == nil {
+		return nil
+	}
+	if err := r.validator(it); err != nil {
+		var iri vocab.IRI
+		if !vocab.IsNil(it) {
+			iri = it.GetLink()
+		}
+		return errors.NewNotValid(err, "unable to save %s", iri)
+	}
+	return nil
+}
+
+func onCollection(tx dbTxn, col vocab.IRI, it vocab.Item, fn func(iris vocab.IRIs) (vocab.IRIs, error)) error {
 	if vocab.IsNil(it) {
 		return errors.Newf("Unable to operate on nil element")
 	}
@@ -157,54 +481,65 @@ func onCollection(r *repo, col vocab.IRI, it vocab.Item, fn func(iris vocab.IRIs
 	if len(it.GetLink()) == 0 {
 		return errors.Newf("Invalid collection, it does not have a valid IRI")
 	}
-	p := itemPath(col)
-
-	err := r.Open()
-	if err != nil {
-		return err
-	}
-	defer r.Close()
-	return r.d.Update(func(tx *badger.Txn) error {
-		var iris vocab.IRIs
+	return onCollectionIRIs(tx, col, fn)
+}
 
-		rawKey := getObjectKey(p)
-		if i, err := tx.Get(rawKey); err == nil {
-			err = i.Value(func(raw []byte) error {
-				it, err := decodeItemFn(raw)
-				if err != nil {
-					return errors.Annotatef(err, "Unable to unmarshal collection %s", p)
-				}
-				err = vocab.OnIRIs(it, func(col *vocab.IRIs) error {
-					iris = *col
-					return nil
-				})
-				if err != nil {
-					return errors.Annotatef(err, "Unable to unmarshal to IRI collection %s", p)
-				}
-				return nil
-			})
-		}
-		var err error
-		iris, err = fn(iris)
+// onCollectionIRIs operates on the list of IRIs stored for the col collection,
+// keeping the sequence numbers of its members, and the collections of its members, in sync.
+func onCollectionIRIs(tx dbTxn, col vocab.IRI, fn func(iris vocab.IRIs) (vocab.IRIs, error)) error {
+	colPath := itemPath(col)
+	return onIRIsKey(tx, getObjectKey(colPath), func(iris vocab.IRIs) (vocab.IRIs, error) {
+		old := append(vocab.IRIs{}, iris...)
+		iris, err := fn(iris)
 		if err != nil {
-			return errors.Annotatef(err, "Unable operate on collection %s", p)
+			return iris, err
 		}
-		var raw []byte
-		raw, err = encodeItemFn(iris)
-		if err != nil {
-			return errors.Newf("Unable to marshal entries in collection %s", p)
+		if err = updateMemberOfKeys(tx, col, old, iris); err != nil {
+			return iris, err
 		}
-		err = tx.Set(rawKey, raw)
-		if err != nil {
-			return errors.Annotatef(err, "Unable to save entries to collection %s", p)
-		}
-		return err
+		return iris, updateCursorKeys(tx, colPath, old, iris)
 	})
 }
 
-// RemoveFrom
-func (r *repo) RemoveFrom(col vocab.IRI, it vocab.Item) error {
-	return onCollection(r, col, it, func(iris vocab.IRIs) (vocab.IRIs, error) {
+// onIRIsKey loads the list of IRIs stored at the key, and saves it back after applying fn on it.
+func onIRIsKey(tx dbTxn, rawKey []byte, fn func(iris vocab.IRIs) (vocab.IRIs, error)) error {
+	var iris vocab.IRIs
+
+	if i, err := tx.Get(rawKey); err == nil {
+		err = i.Value(func(raw []byte) error {
+			it, err := decodeItemFn(raw)
+			if err != nil {
+				return errors.Annotatef(err, "Unable to unmarshal collection %s", rawKey)
+			}
+			err = vocab.OnIRIs(it, func(col *vocab.IRIs) error {
+				iris = *col
+				return nil
+			})
+			if err != nil {
+				return errors.Annotatef(err, "Unable to unmarshal to IRI collection %s", rawKey)
+			}
+			return nil
+		})
+	}
+	var err error
+	iris, err = fn(iris)
+	if err != nil {
+		return errors.Annotatef(err, "Unable operate on collection %s", rawKey)
+	}
+	var raw []byte
+	raw, err = encodeItemFn(iris)
+	if err != nil {
+		return errors.Newf("Unable to marshal entries in collection %s", rawKey)
+	}
+	err = tx.Set(rawKey, raw)
+	if err != nil {
+		return errors.Annotatef(err, "Unable to save entries to collection %s", rawKey)
+	}
+	return err
+}
+
+func removeIRIFn(it vocab.Item) func(iris vocab.IRIs) (vocab.IRIs, error) {
+	return func(iris vocab.IRIs) (vocab.IRIs, error) {
 		for k, iri := range iris {
 			if iri.GetLink().Equals(it.GetLink(), false) {
 				iris = append(iris[:k], iris[k+1:]...)
@@ -212,11 +547,55 @@ func (r *repo) RemoveFrom(col vocab.IRI, it vocab.Item) error {
 			}
 		}
 		return iris, nil
+	}
+}
+
+func addIRIFn(it vocab.Item) func(iris vocab.IRIs) (vocab.IRIs, error) {
+	return func(iris vocab.IRIs) (vocab.IRIs, error) {
+		if iris.Contains(it.GetLink()) {
+			return iris, nil
+		}
+		return append(iris, it.GetLink()), nil
+	}
+}
+
+// RemoveFrom
+func (r *repo) RemoveFrom(col vocab.IRI, it vocab.Item) error {
+	var err error
+	defer r.logItemOp(MirrorRemoveFrom, col, it, time.Now(), &err)
+	err = r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	changed := vocab.IRIs{col}
+	err = r.update(func(tx dbTxn) error {
+		if vocab.IsNil(it) {
+			return errors.Newf("Unable to operate on nil element")
+		}
+		toRemove, refs := appreciationReferences(tx, col, it, true)
+		if err := onCollection(tx, r.storedIRI(tx, col), toRemove, removeIRIFn(toRemove)); err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if err := onCollection(tx, r.storedIRI(tx, ref.col), ref.it, removeIRIFn(ref.it)); err != nil {
+				r.errFn("unable to remove %s from %s: %+s", ref.it, ref.col, err)
+				continue
+			}
+			changed = append(changed, ref.col)
+		}
+		return nil
 	})
+	if err == nil {
+		r.invalidateResults(changed...)
+		r.notify(MirrorRemoveFrom, col, it)
+	}
+	return err
 }
 
+var allStorageCollections = append(vocab.ActivityPubCollections, filters.FedBOXCollections...)
+
 func addCollectionOnObject(r *repo, col vocab.IRI) error {
-	allStorageCollections := append(vocab.ActivityPubCollections, filters.FedBOXCollections...)
 	if ob, t := allStorageCollections.Split(col); vocab.ValidCollection(t) {
 		// Create the collection on the object, if it doesn't exist
 		if i, _ := r.loadOneFromPath(ob); i != nil {
@@ -231,24 +610,52 @@ func addCollectionOnObject(r *repo, col vocab.IRI) error {
 
 // AddTo
 func (r *repo) AddTo(col vocab.IRI, it vocab.Item) error {
+	var err error
+	defer r.logItemOp(MirrorAddTo, col, it, time.Now(), &err)
+	err = r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
 	addCollectionOnObject(r, col)
-	return onCollection(r, col, it, func(iris vocab.IRIs) (vocab.IRIs, error) {
-		if iris.Contains(it.GetLink()) {
-			return iris, nil
+	changed := vocab.IRIs{col}
+	err = r.update(func(tx dbTxn) error {
+		if vocab.IsNil(it) {
+			return errors.Newf("Unable to operate on nil element")
 		}
-		return append(iris, it.GetLink()), nil
+		toAdd, refs := appreciationReferences(tx, col, it, false)
+		if err := onCollection(tx, r.storedIRI(tx, col), toAdd, r.sizeLimitedFn(col, addIRIFn(toAdd))); err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			if err := onCollection(tx, r.storedIRI(tx, ref.col), ref.it, r.sizeLimitedFn(ref.col, addIRIFn(ref.it))); err != nil {
+				r.errFn("unable to add %s to %s: %+s", ref.it, ref.col, err)
+				continue
+			}
+			changed = append(changed, ref.col)
+		}
+		return nil
 	})
+	if err == nil {
+		r.invalidateResults(changed...)
+		r.notify(MirrorAddTo, col, it)
+	}
+	return err
 }
 
 // Delete
 func (r *repo) Delete(it vocab.Item) error {
 	var err error
+	defer r.logItemOp(MirrorDelete, "", it, time.Now(), &err)
 	err = r.Open()
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	return delete(r, it)
+	if err = delete(r, it); err == nil {
+		r.notify(MirrorDelete, "", it)
+	}
+	return err
 }
 
 func getMetadataKey(p []byte) []byte {
@@ -264,7 +671,7 @@ func (r *repo) PasswordSet(it vocab.Item, pw []byte) error {
 	}
 	defer r.Close()
 
-	err = r.d.Update(func(tx *badger.Txn) error {
+	err = r.update(func(tx dbTxn) error {
 		pw, err = bcrypt.GenerateFromPassword(pw, -1)
 		if err != nil {
 			return errors.Annotatef(err, "Could not encrypt the pw")
@@ -282,7 +689,9 @@ func (r *repo) PasswordSet(it vocab.Item, pw []byte) error {
 		}
 		return nil
 	})
-
+	if err == nil {
+		r.metadataChanged(it.GetLink())
+	}
 	return err
 }
 
@@ -297,7 +706,7 @@ func (r *repo) PasswordCheck(it vocab.Item, pw []byte) error {
 
 	m := processing.Metadata{}
 	err = r.d.View(func(tx *badger.Txn) error {
-		i, err := tx.Get(getMetadataKey(path))
+		i, err := tx.Get(r.storedKey(tx, path, getMetadataKey))
 		if err != nil {
 			return errors.Annotatef(err, "Could not find metadata in path %s", path)
 		}
@@ -327,9 +736,9 @@ func (r *repo) LoadMetadata(iri vocab.IRI) (*processing.Metadata, error) {
 
 	m := processing.Metadata{}
 	err = r.d.View(func(tx *badger.Txn) error {
-		i, err := tx.Get(getMetadataKey(path))
+		i, err := tx.Get(r.storedKey(tx, path, getMetadataKey))
 		if err != nil {
-			return errors.Annotatef(err, "Could not find metadata in path %s", path)
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "Could not find metadata in path %s", path)
 		}
 		return i.Value(func(raw []byte) error {
 			return decodeFn(raw, &m)
@@ -347,7 +756,7 @@ func (r *repo) SaveMetadata(m processing.Metadata, iri vocab.IRI) error {
 	defer r.Close()
 
 	path := itemPath(iri)
-	err = r.d.Update(func(tx *badger.Txn) error {
+	err = r.update(func(tx dbTxn) error {
 		entryBytes, err := encodeFn(m)
 		if err != nil {
 			return errors.Annotatef(err, "Could not marshal metadata")
@@ -358,7 +767,9 @@ func (r *repo) SaveMetadata(m processing.Metadata, iri vocab.IRI) error {
 		}
 		return nil
 	})
-
+	if err == nil {
+		r.metadataChanged(iri)
+	}
 	return err
 }
 
@@ -368,7 +779,12 @@ func (r *repo) LoadKey(iri vocab.IRI) (crypto.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	b, _ := pem.Decode(m.PrivateKey)
+	return decodePrivateKey(m.PrivateKey)
+}
+
+// decodePrivateKey decodes the PEM encoded private key of the metadata of an actor.
+func decodePrivateKey(raw []byte) (crypto.PrivateKey, error) {
+	b, _ := pem.Decode(raw)
 	if b == nil {
 		return nil, errors.Errorf("failed decoding pem")
 	}
@@ -403,44 +819,59 @@ func delete(r *repo, it vocab.Item) error {
 		return err
 	}
 
-	db := r.d.NewWriteBatch()
-	return deleteFromPath(r, db, old)
+	err = r.update(func(tx dbTxn) error {
+		return updatePageLinks(tx, old, nil)
+	})
+	if err != nil {
+		return errors.Annotatef(err, "could not update collection page links")
+	}
+
+	db := r.newWriteBatch()
+	if err = deleteFromPath(r, db, old); err != nil {
+		db.Cancel()
+		return err
+	}
+	if err = db.Flush(); err != nil {
+		return err
+	}
+	r.invalidateItem(old.GetLink())
+	return nil
 }
 
 // createCollections
-func createCollections(tx *badger.WriteBatch, it vocab.Item) error {
+func createCollections(r *repo, tx dbTxn, it vocab.Item) error {
 	if vocab.IsNil(it) || !it.IsObject() {
 		return nil
 	}
 	if vocab.ActorTypes.Contains(it.GetType()) {
 		vocab.OnActor(it, func(p *vocab.Actor) error {
 			if p.Inbox != nil {
-				p.Inbox, _ = createCollectionInPath(tx, p.Inbox)
+				p.Inbox, _ = createCollectionInPath(r, tx, p.Inbox)
 			}
 			if p.Outbox != nil {
-				p.Outbox, _ = createCollectionInPath(tx, p.Outbox)
+				p.Outbox, _ = createCollectionInPath(r, tx, p.Outbox)
 			}
 			if p.Followers != nil {
-				p.Followers, _ = createCollectionInPath(tx, p.Followers)
+				p.Followers, _ = createCollectionInPath(r, tx, p.Followers)
 			}
 			if p.Following != nil {
-				p.Following, _ = createCollectionInPath(tx, p.Following)
+				p.Following, _ = createCollectionInPath(r, tx, p.Following)
 			}
 			if p.Liked != nil {
-				p.Liked, _ = createCollectionInPath(tx, p.Liked)
+				p.Liked, _ = createCollectionInPath(r, tx, p.Liked)
 			}
 			return nil
 		})
 	}
 	return vocab.OnObject(it, func(o *vocab.Object) error {
 		if o.Replies != nil {
-			o.Replies, _ = createCollectionInPath(tx, o.Replies)
+			o.Replies, _ = createCollectionInPath(r, tx, o.Replies)
 		}
 		if o.Likes != nil {
-			o.Likes, _ = createCollectionInPath(tx, o.Likes)
+			o.Likes, _ = createCollectionInPath(r, tx, o.Likes)
 		}
 		if o.Shares != nil {
-			o.Shares, _ = createCollectionInPath(tx, o.Shares)
+			o.Shares, _ = createCollectionInPath(r, tx, o.Shares)
 		}
 		return nil
 	})
@@ -448,7 +879,7 @@ func createCollections(tx *badger.WriteBatch, it vocab.Item) error {
 
 // deleteCollections
 func deleteCollections(r *repo, it vocab.Item) error {
-	tx := r.d.NewWriteBatch()
+	tx := r.newWriteBatch()
 	if vocab.ActorTypes.Contains(it.GetType()) {
 		return vocab.OnActor(it, func(p *vocab.Actor) error {
 			var err error
@@ -473,40 +904,82 @@ func deleteCollections(r *repo, it vocab.Item) error {
 }
 
 func save(r *repo, it vocab.Item) (vocab.Item, error) {
+	var old vocab.Item
+	err := r.update(func(tx dbTxn) error {
+		var err error
+		old, err = saveItem(r, tx, it)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.clearNotFound(it.GetLink())
+	if vocab.IsNil(old) {
+		r.invalidateResults(it.GetLink())
+	} else {
+		r.invalidateItem(it.GetLink())
+	}
+
+	return it, nil
+}
+
+// saveItem writes the it object in the tx transaction, together with its missing collections, page links,
+// type key and indexes, and returns the previously stored version of it, if any.
+// It is the single write path shared by save and the transactions of WithTx.
+func saveItem(r *repo, tx dbTxn, it vocab.Item) (vocab.Item, error) {
 	itPath := itemPath(it.GetLink())
-	db := r.d.NewWriteBatch()
 
-	if err := createCollections(db, it); err != nil {
+	if err := createCollections(r, tx, it); err != nil {
 		return nil, errors.Annotatef(err, "could not create object's collections")
 	}
+	// NOTE(marius): the object is encoded after its collections are replaced by their IRIs, and before writing
+	// anything, so the objects which are too large don't leave their page links behind.
 	entryBytes, err := encodeItemFn(it)
 	if err != nil {
 		return nil, errors.Annotatef(err, "could not marshal object")
 	}
-	k := getObjectKey(itPath)
-	err = db.Set(k, entryBytes)
-	if err != nil {
+	if err = r.checkItemSize(it.GetLink(), entryBytes); err != nil {
+		return nil, err
+	}
+	old, _ := loadRawItem(tx, itPath)
+	if err = updatePageLinks(tx, old, it); err != nil {
+		return nil, errors.Annotatef(err, "could not update collection page links")
+	}
+	if err = setTypeKey(tx, itPath, old, it); err != nil {
+		return nil, errors.Annotatef(err, "could not store object's type")
+	}
+	if err = updateIndexes(tx, r.indexes, itPath, old, it); err != nil {
+		return nil, errors.Annotatef(err, "could not update object's indexes")
+	}
+	if err = tx.Set(getObjectKey(itPath), entryBytes); err != nil {
 		return nil, errors.Annotatef(err, "could not store encoded object")
 	}
-
-	return it, err
+	return old, nil
 }
 
 var emptyCollection, _ = encodeItemFn(vocab.IRIs{})
 
-func createCollectionInPath(b *badger.WriteBatch, it vocab.Item) (vocab.Item, error) {
+// createCollectionInPath stores an empty collection at the IRI of it, if nothing is stored there already,
+// including under the old host of the IRI, when the Config.Rewrites contain it.
+// NOTE(marius): the existing collections are left untouched, so saving an object again doesn't lose their items.
+func createCollectionInPath(r *repo, tx dbTxn, it vocab.Item) (vocab.Item, error) {
 	if vocab.IsNil(it) {
 		return nil, nil
 	}
-	p := getObjectKey(itemPath(it.GetLink()))
+	p := r.storedKey(tx, itemPath(it.GetLink()), getObjectKey)
 
-	if err := b.Set(p, emptyCollection); err != nil {
+	if _, err := tx.Get(p); err == nil {
+		return it.GetLink(), nil
+	} else if err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if err := tx.Set(p, emptyCollection); err != nil {
 		return nil, err
 	}
 	return it.GetLink(), nil
 }
 
-func deleteFromPath(r *repo, b *badger.WriteBatch, it vocab.Item) error {
+func deleteFromPath(r *repo, b keySetter, it vocab.Item) error {
 	if vocab.IsNil(it) {
 		return nil
 	}
@@ -514,53 +987,64 @@ func deleteFromPath(r *repo, b *badger.WriteBatch, it vocab.Item) error {
 	if err := b.Delete(p); err != nil {
 		return err
 	}
+	if !it.IsObject() {
+		return nil
+	}
+	if err := updateIndexes(b, r.indexes, itemPath(it.GetLink()), it, nil); err != nil {
+		return err
+	}
+	if len(it.GetType()) > 0 {
+		return b.Delete(getTypeKey(itemPath(it.GetLink()), it.GetType()))
+	}
 	return nil
 }
 
-func (r *repo) loadFromIterator(col *vocab.ItemCollection, f Filterable) func(val []byte) error {
+// loadFromIterator returns the function loading the value of the key k into col: it decodes the value, and appends
+// to col the items resulted from it which match the filters and the checks. The nested properties of the items
+// get dereferenced through refs. When maxItems is larger than zero, the members of a collection are loaded
+// only until col contains that many items. The values which fail to decode are quarantined.
+func (r *repo) loadFromIterator(refs *derefs, k []byte, col *vocab.ItemCollection, maxItems int, f Filterable, checks ...filters.Check) func(val []byte) error {
+	auth := filters.AuthorizedChecks(checks...)
+	deref := derefOptions(r.deref, checks)
+	tagFiltered := len(filters.TagChecks(checks...)) > 0
 	isColFn := func(ff Filterable) bool {
 		_, ok := ff.(vocab.IRI)
 		return ok
 	}
 	return func(val []byte) error {
-		it, err := loadItem(val)
+		it, err := r.decode(val)
+		if err != nil {
+			r.quarantine(k, err)
+		}
 		if err != nil || vocab.IsNil(it) {
-			return errors.NewNotFound(err, "not found")
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "not found")
 		}
 		if !it.IsObject() && it.IsLink() {
-			c, err := r.loadItemsElements(f, it.GetLink())
+			c, err := r.loadItemsElements(f, remainingItems(maxItems, *col), checks, it.GetLink())
 			if err != nil {
 				return err
 			}
-			for _, it := range c {
-				if col.Contains(it.GetLink()) {
-					continue
-				}
-				*col = append(*col, it)
-			}
+			appendMissing(col, auth, c)
 		} else if it.IsCollection() {
 			return vocab.OnCollectionIntf(it, func(ci vocab.CollectionInterface) error {
+				members := r.collectionMembers(refs.tx, ci)
 				if isColFn(f) {
-					f = ci.Collection()
+					f = members
 				}
-				c, err := r.loadItemsElements(f, ci.Collection()...)
+				c, err := r.loadItemsElements(f, remainingItems(maxItems, *col), checks, members...)
 				if err != nil {
 					return err
 				}
-				for _, it := range c {
-					if col.Contains(it.GetLink()) {
-						continue
-					}
-					*col = append(*col, it)
-				}
+				appendMissing(col, auth, c)
 				return nil
 			})
 		} else {
-			if it.GetType() == vocab.CreateType {
+			objectMode := deref.mode(DerefObject)
+			if (it.GetType() == vocab.CreateType && objectMode == DerefDefault) || (objectMode == DerefAlways && vocab.ActivityTypes.Contains(it.GetType())) {
 				// TODO(marius): this seems terribly not nice
 				vocab.OnActivity(it, func(a *vocab.Activity) error {
-					if !a.Object.IsObject() {
-						ob, _ := r.loadOneFromPath(a.Object.GetLink())
+					if !vocab.IsNil(a.Object) && !a.Object.IsObject() {
+						ob, _ := refs.load(a.Object.GetLink())
 						a.Object = ob
 					}
 					return nil
@@ -573,19 +1057,19 @@ func (r *repo) loadFromIterator(col *vocab.ItemCollection, f Filterable) func(va
 			}
 			if it != nil {
 				if vocab.ActorTypes.Contains(it.GetType()) {
-					vocab.OnActor(it, loadFilteredPropsForActor(r, f))
+					vocab.OnActor(it, loadFilteredPropsForActor(refs, deref, tagFiltered))
 				}
 				if vocab.ObjectTypes.Contains(it.GetType()) {
-					vocab.OnObject(it, loadFilteredPropsForObject(r, f))
+					vocab.OnObject(it, loadFilteredPropsForObject(refs, deref, tagFiltered))
 				}
 				if vocab.IntransitiveActivityTypes.Contains(it.GetType()) {
-					vocab.OnIntransitiveActivity(it, loadFilteredPropsForIntransitiveActivity(r, f))
+					vocab.OnIntransitiveActivity(it, loadFilteredPropsForIntransitiveActivity(refs, f, deref))
 				}
 				if vocab.ActivityTypes.Contains(it.GetType()) {
-					vocab.OnActivity(it, loadFilteredPropsForActivity(r, f))
+					vocab.OnActivity(it, loadFilteredPropsForActivity(refs, f, deref))
 				}
-				if !col.Contains(it.GetLink()) {
-					*col = append(*col, it)
+				if !col.Contains(it.GetLink()) && checksMatch(checks, it) {
+					*col = append(*col, scopeToRequester(auth, it))
 				}
 			}
 		}
@@ -593,15 +1077,30 @@ func (r *repo) loadFromIterator(col *vocab.ItemCollection, f Filterable) func(va
 	}
 }
 
-func loadFilteredPropsForActor(r *repo, f Filterable) func(a *vocab.Actor) error {
+// appendMissing appends to col the items which it doesn't contain already, scoped to the requester.
+func appendMissing(col *vocab.ItemCollection, auth filters.Checks, items vocab.ItemCollection) {
+	present := make(map[vocab.IRI]struct{}, len(*col))
+	for _, it := range *col {
+		present[it.GetLink()] = struct{}{}
+	}
+	for _, it := range items {
+		if _, ok := present[it.GetLink()]; ok {
+			continue
+		}
+		present[it.GetLink()] = struct{}{}
+		*col = append(*col, scopeToRequester(auth, it))
+	}
+}
+
+func loadFilteredPropsForActor(refs *derefs, deref DerefOptions, tagFiltered bool) func(a *vocab.Actor) error {
 	return func(a *vocab.Actor) error {
-		return vocab.OnObject(a, loadFilteredPropsForObject(r, f))
+		return vocab.OnObject(a, loadFilteredPropsForObject(refs, deref, tagFiltered))
 	}
 }
 
-func loadFilteredPropsForObject(r *repo, f Filterable) func(o *vocab.Object) error {
+func loadFilteredPropsForObject(refs *derefs, deref DerefOptions, tagFiltered bool) func(o *vocab.Object) error {
 	return func(o *vocab.Object) error {
-		if len(o.Tag) == 0 {
+		if len(o.Tag) == 0 || !deref.deref(DerefTag, tagFiltered, true) {
 			return nil
 		}
 		return vocab.OnItemCollection(o.Tag, func(col *vocab.ItemCollection) error {
@@ -609,7 +1108,7 @@ func loadFilteredPropsForObject(r *repo, f Filterable) func(o *vocab.Object) err
 				if vocab.IsNil(t) || !vocab.IsIRI(t) {
 					return nil
 				}
-				if ob, err := r.loadOneFromPath(t.GetLink()); err == nil {
+				if ob, err := refs.load(t.GetLink()); err == nil {
 					(*col)[i] = ob
 				}
 			}
@@ -617,31 +1116,40 @@ func loadFilteredPropsForObject(r *repo, f Filterable) func(o *vocab.Object) err
 		})
 	}
 }
-func loadFilteredPropsForActivity(r *repo, f Filterable) func(a *vocab.Activity) error {
+func loadFilteredPropsForActivity(refs *derefs, f Filterable, deref DerefOptions) func(a *vocab.Activity) error {
 	return func(a *vocab.Activity) error {
-		if ok, fo := filters.FiltersOnActivityObject(f); ok && !vocab.IsNil(a.Object) && vocab.IsIRI(a.Object) {
-			if ob, err := r.loadOneFromPath(a.Object.GetLink()); err == nil {
-				if ob, _ = filters.FilterIt(ob, fo); ob != nil {
+		if ok, fo := filters.FiltersOnActivityObject(f); deref.deref(DerefObject, ok, ok) && !vocab.IsNil(a.Object) && vocab.IsIRI(a.Object) {
+			if ob, err := refs.load(a.Object.GetLink()); err == nil {
+				if ok {
+					ob, _ = filters.FilterIt(ob, fo)
+				}
+				if ob != nil {
 					a.Object = ob
 				}
 			}
 		}
-		return vocab.OnIntransitiveActivity(a, loadFilteredPropsForIntransitiveActivity(r, f))
+		return vocab.OnIntransitiveActivity(a, loadFilteredPropsForIntransitiveActivity(refs, f, deref))
 	}
 }
 
-func loadFilteredPropsForIntransitiveActivity(r *repo, f Filterable) func(a *vocab.IntransitiveActivity) error {
+func loadFilteredPropsForIntransitiveActivity(refs *derefs, f Filterable, deref DerefOptions) func(a *vocab.IntransitiveActivity) error {
 	return func(a *vocab.IntransitiveActivity) error {
-		if ok, fa := filters.FiltersOnActivityActor(f); ok && !vocab.IsNil(a.Actor) && vocab.IsIRI(a.Actor) {
-			if act, err := r.loadOneFromPath(a.Actor.GetLink()); err == nil {
-				if act, _ = filters.FilterIt(act, fa); act != nil {
+		if ok, fa := filters.FiltersOnActivityActor(f); deref.deref(DerefActor, ok, ok) && !vocab.IsNil(a.Actor) && vocab.IsIRI(a.Actor) {
+			if act, err := refs.load(a.Actor.GetLink()); err == nil {
+				if ok {
+					act, _ = filters.FilterIt(act, fa)
+				}
+				if act != nil {
 					a.Actor = act
 				}
 			}
 		}
-		if ok, ft := filters.FiltersOnActivityTarget(f); ok && !vocab.IsNil(a.Target) && vocab.IsIRI(a.Target) {
-			if t, err := r.loadOneFromPath(a.Target.GetLink()); err == nil {
-				if t, _ = filters.FilterIt(t, ft); t != nil {
+		if ok, ft := filters.FiltersOnActivityTarget(f); deref.deref(DerefTarget, ok, ok) && !vocab.IsNil(a.Target) && vocab.IsIRI(a.Target) {
+			if t, err := refs.load(a.Target.GetLink()); err == nil {
+				if ok {
+					t, _ = filters.FilterIt(t, ft)
+				}
+				if t != nil {
 					a.Target = t
 				}
 			}
@@ -668,12 +1176,15 @@ func iterKeyIsTooDeep(base, k []byte, depth int) bool {
 	return cnt > depth
 }
 
-func (r *repo) loadFromPath(f Filterable, loadMaxOne bool) (vocab.ItemCollection, error) {
+// loadFromPath loads the items found at the path of the filters.
+// When maxItems is larger than zero, the loading stops once that many items have been collected.
+func (r *repo) loadFromPath(f Filterable, maxItems int, checks ...filters.Check) (vocab.ItemCollection, error) {
 	col := make(vocab.ItemCollection, 0)
 
 	err := r.d.View(func(tx *badger.Txn) error {
 		iri := f.GetLink()
-		fullPath := itemPath(iri)
+		fullPath := r.storedPath(tx, itemPath(iri))
+		refs := newDerefs(r, tx)
 
 		depth := 0
 		if isStorageCollectionKey(fullPath) {
@@ -682,8 +1193,44 @@ func (r *repo) loadFromPath(f Filterable, loadMaxOne bool) (vocab.ItemCollection
 		if vocab.ValidCollectionIRI(vocab.IRI(fullPath)) {
 			depth = 2
 		}
+		// NOTE(marius): when filtering by type, the objects that don't match can be skipped
+		// based on their type key, without loading their values.
+		types := typesFilter(f)
+		var typedPath []byte
+		var typ vocab.ActivityVocabularyType
+
+		order, sorted := sortOrder(checks)
+		if sorted {
+			// NOTE(marius): the items are sorted after loading, so we can stop early only when
+			// they are loaded in the sort order, from its date index.
+			if depth != 1 || !hasIndex(r.indexes, order.index()) {
+				maxItems = 0
+			}
+			defer func() {
+				order.sort(col)
+			}()
+		}
+		if depth == 2 && !sorted && r.loadFromCursor(tx, &col, fullPath, f, checks) {
+			return nil
+		}
+		if depth == 1 {
+			paths, planned := candidatePaths(tx, fullPath, planQuery(r.indexes, f, checks))
+			if sorted && hasIndex(r.indexes, order.index()) {
+				paths, planned = orderedPaths(tx, fullPath, order, paths, planned), true
+			}
+			if planned {
+				return r.loadFromIndexedPaths(refs, &col, paths, f, maxItems, checks...)
+			}
+		}
+
+		// NOTE(marius): the storage collections can be very large, so we collect only the keys of their
+		// objects while iterating, and we decode the values afterwards, possibly in parallel.
+		scanKeys := depth == 1 && maxItems != 1
+		objectKeys := make([][]byte, 0)
+
 		opt := badger.DefaultIteratorOptions
 		opt.Prefix = fullPath
+		opt.PrefetchValues = len(types) == 0 && !scanKeys
 		it := tx.NewIterator(opt)
 		defer it.Close()
 		pathExists := false
@@ -691,21 +1238,35 @@ func (r *repo) loadFromPath(f Filterable, loadMaxOne bool) (vocab.ItemCollection
 			i := it.Item()
 			k := i.Key()
 			pathExists = true
+			if len(types) > 0 && isTypeKey(k) {
+				typedPath, typ = splitTypeKey(i.KeyCopy(nil))
+				continue
+			}
 			if iterKeyIsTooDeep(fullPath, k, depth) {
 				continue
 			}
 			if isObjectKey(k) {
-				if err := i.Value(r.loadFromIterator(&col, f)); err != nil {
+				if len(types) > 0 && bytes.Equal(typedPath, bytes.TrimSuffix(k, append(sep, objectKey...))) && !typeMatches(types, typ) {
+					continue
+				}
+				if scanKeys {
+					objectKeys = append(objectKeys, i.KeyCopy(nil))
+					continue
+				}
+				if err := i.Value(r.loadFromIterator(refs, k, &col, maxItems, f, checks...)); err != nil {
 					r.errFn("unable to load item %s: %+s", k, err)
 					continue
 				}
-				if len(col) == 1 && loadMaxOne {
+				if maxItems > 0 && len(col) >= maxItems {
 					break
 				}
 			}
 		}
+		if len(objectKeys) > 0 {
+			col = append(col, r.loadKeys(refs, objectKeys, maxItems, f, checks...)...)
+		}
 		if !pathExists && len(col) == 0 {
-			return errors.NotFoundf("%s does not exist", fullPath)
+			return errors.NewNotFound(ErrNotFound, "%s does not exist", fullPath)
 		}
 		return nil
 	})
@@ -713,22 +1274,55 @@ func (r *repo) loadFromPath(f Filterable, loadMaxOne bool) (vocab.ItemCollection
 	return col, err
 }
 
+// loadFromIndexedPaths loads the objects found at the paths resulted from an index lookup.
+// The objects are still checked against the filters, as the indexes can return false positives.
+func (r *repo) loadFromIndexedPaths(refs *derefs, col *vocab.ItemCollection, paths [][]byte, f Filterable, maxItems int, checks ...filters.Check) error {
+	for _, p := range paths {
+		k := getObjectKey(p)
+		i, err := refs.tx.Get(k)
+		if err != nil {
+			continue
+		}
+		if err = i.Value(r.loadFromIterator(refs, k, col, maxItems, f, checks...)); err != nil {
+			r.errFn("unable to load item %s: %+s", p, err)
+			continue
+		}
+		if maxItems > 0 && len(*col) >= maxItems {
+			break
+		}
+	}
+	return nil
+}
+
 func (r *repo) LoadOne(f Filterable) (vocab.Item, error) {
+	// NOTE(marius): only the loads of plain IRIs can share the cached results of Load.
+	iri, cacheable := f.(vocab.IRI)
+	if cacheable {
+		if it := r.cachedResult(iri); it != nil && !it.IsCollection() {
+			return it, nil
+		}
+	}
 	err := r.Open()
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
-	return r.loadOneFromPath(f)
+	it, err := r.loadOneFromPath(f)
+	if err == nil && cacheable {
+		if ff, ferr := filters.FiltersFromIRI(iri); ferr == nil && ff.IsItemIRI() {
+			r.cacheResult(iri, it)
+		}
+	}
+	return it, err
 }
 
 func (r *repo) loadOneFromPath(f Filterable) (vocab.Item, error) {
-	col, err := r.loadFromPath(f, true)
+	col, err := r.loadFromPath(f, 1)
 	if err != nil {
 		return nil, err
 	}
 	if len(col) == 0 {
-		return nil, errors.NotFoundf("nothing found")
+		return nil, errors.NewNotFound(ErrNotFound, "nothing found")
 	}
 	return col.First(), nil
 }
@@ -737,25 +1331,81 @@ func getObjectKey(p []byte) []byte {
 	return bytes.Join([][]byte{p, []byte(objectKey)}, sep)
 }
 
-func (r *repo) loadItemsElements(f Filterable, iris ...vocab.Item) (vocab.ItemCollection, error) {
+// loadItemsElements loads the items found at the iris which match the filters and the checks.
+// When maxItems is larger than zero, the loading stops once that many items have matched.
+//
+// The items are looked up in batches of loadBatchSize, each of them through a single pass of an iterator
+// over their sorted keys, instead of one Get for each of them.
+func (r *repo) loadItemsElements(f Filterable, maxItems int, checks filters.Checks, iris ...vocab.Item) (vocab.ItemCollection, error) {
 	col := make(vocab.ItemCollection, 0)
+	seen := make(map[vocab.IRI]struct{})
 	err := r.d.View(func(tx *badger.Txn) error {
-		for _, iri := range iris {
-			it, err := r.loadItem(tx, itemPath(iri.GetLink()), f)
-			if err != nil || vocab.IsNil(it) || col.Contains(it.GetLink()) {
-				continue
+		for start := 0; start < len(iris); start += loadBatchSize {
+			batch := iris[start:min(start+loadBatchSize, len(iris))]
+			for j, raw := range r.loadRawBatch(tx, batch) {
+				if raw == nil {
+					continue
+				}
+				it, err := r.itemFromRaw(raw, f)
+				if err != nil {
+					if _, err = r.decode(raw); err != nil {
+						r.quarantine(getObjectKey(itemPath(batch[j].GetLink())), err)
+					}
+					continue
+				}
+				if vocab.IsNil(it) {
+					continue
+				}
+				if _, ok := seen[it.GetLink()]; ok || !checksMatch(checks, it) {
+					continue
+				}
+				seen[it.GetLink()] = struct{}{}
+				col = append(col, it)
+				if maxItems > 0 && len(col) >= maxItems {
+					return nil
+				}
 			}
-			col = append(col, it)
 		}
 		return nil
 	})
 	return col, err
 }
 
-func (r *repo) loadItem(b *badger.Txn, path []byte, f Filterable) (vocab.Item, error) {
+// loadBatchSize is the number of items looked up together by loadItemsElements.
+const loadBatchSize = 256
+
+// loadRawBatch returns the stored values of the objects at the iris, in their order, having nil for the ones
+// which are missing.
+func loadRawBatch(tx dbTxn, iris []vocab.Item) [][]byte {
+	keys := make([][]byte, len(iris))
+	order := make([]int, len(iris))
+	for i, iri := range iris {
+		keys[i] = getObjectKey(itemPath(iri.GetLink()))
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return bytes.Compare(keys[order[i]], keys[order[j]]) < 0
+	})
+
+	opt := badger.DefaultIteratorOptions
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	raws := make([][]byte, len(iris))
+	for _, i := range order {
+		if it.Seek(keys[i]); !it.Valid() || !bytes.Equal(it.Item().Key(), keys[i]) {
+			continue
+		}
+		raws[i], _ = it.Item().ValueCopy(nil)
+	}
+	return raws
+}
+
+func (r *repo) loadItem(b dbTxn, path []byte, f Filterable) (vocab.Item, error) {
 	i, err := b.Get(getObjectKey(path))
 	if err != nil {
-		return nil, errors.NewNotFound(err, "Unable to load path %s", path)
+		return nil, errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load path %s", path)
 	}
 	var raw []byte
 	i.Value(func(val []byte) error {
@@ -765,13 +1415,18 @@ func (r *repo) loadItem(b *badger.Txn, path []byte, f Filterable) (vocab.Item, e
 	if raw == nil {
 		return nil, nil
 	}
-	var it vocab.Item
-	it, err = loadItem(raw)
+	return r.itemFromRaw(raw, f)
+}
+
+// itemFromRaw decodes the item stored in raw, loading it when raw contains only its IRI, and filters it.
+// The stored collections are returned as they are, for their members to be loaded separately.
+func (r *repo) itemFromRaw(raw []byte, f Filterable) (vocab.Item, error) {
+	it, err := r.decode(raw)
 	if err != nil {
 		return nil, err
 	}
 	if vocab.IsNil(it) {
-		return nil, errors.NotFoundf("not found")
+		return nil, errors.NewNotFound(ErrNotFound, "not found")
 	}
 	if it.IsCollection() {
 		// we need to dereference them, so no further filtering/processing is needed here
@@ -791,9 +1446,49 @@ func loadItem(raw []byte) (vocab.Item, error) {
 		// TODO(marius): log this instead of stopping the iteration and returning an error
 		return nil, errors.Errorf("empty raw item")
 	}
+	if raw[0] == '[' {
+		if col, ok := decodeIRIList(raw); ok {
+			return col, nil
+		}
+	}
 	return decodeItemFn(raw)
 }
 
+// decodeIRIList decodes the lists of IRIs, in which the collections are stored, without the quadratic
+// deduplication done by vocab.ItemCollection.Append, which shows up when loading large collections.
+func decodeIRIList(raw []byte) (vocab.ItemCollection, bool) {
+	var iris []string
+	if err := json.Unmarshal(raw, &iris); err != nil {
+		return nil, false
+	}
+	col := make(vocab.ItemCollection, 0, len(iris))
+	seen := make(map[string]struct{}, len(iris))
+	for _, iri := range iris {
+		if _, ok := seen[iri]; ok {
+			continue
+		}
+		seen[iri] = struct{}{}
+		col = append(col, vocab.IRI(iri))
+	}
+	return col, true
+}
+
+// decode returns the item encoded in raw, taking it from the decoded items cache when possible.
+// The IRIs of the old hosts of the Rewrites of the Config are replaced by the ones of the new hosts.
+func (r *repo) decode(raw []byte) (vocab.Item, error) {
+	if r.decoded == nil {
+		return loadItem(r.rewriteRaw(raw))
+	}
+	if it := r.decoded.Get(raw); it != nil {
+		return it, nil
+	}
+	it, err := loadItem(r.rewriteRaw(raw))
+	if err == nil {
+		r.decoded.Set(raw, it)
+	}
+	return it, err
+}
+
 func itemPath(iri vocab.IRI) []byte {
 	url, err := iri.URL()
 	if err != nil {
@@ -804,10 +1499,10 @@ func itemPath(iri vocab.IRI) []byte {
 
 func (r *repo) CreateService(service *vocab.Service) error {
 	err := r.Open()
-	defer r.Close()
 	if err != nil {
 		return err
 	}
+	defer r.Close()
 	if it, err := save(r, service); err == nil {
 		op := "Updated"
 		id := it.GetID()
@@ -815,6 +1510,7 @@ func (r *repo) CreateService(service *vocab.Service) error {
 			op = "Added new"
 		}
 		r.logFn("%s %s: %s", op, it.GetType(), it.GetLink())
+		r.notify(MirrorSave, "", it)
 	}
 	return err
 }
@@ -827,11 +1523,10 @@ func Path(c Config) (string, error) {
 }
 
 func mkDirIfNotExists(p string) error {
-	const defaultPerm = os.ModeDir | os.ModePerm | 0700
 	p, _ = filepath.Abs(p)
 	if fi, err := os.Stat(p); err != nil {
 		if os.IsNotExist(err) {
-			if err = os.MkdirAll(p, defaultPerm); err != nil {
+			if err = os.MkdirAll(p, storageDirPerm); err != nil {
 				return err
 			}
 		}
@@ -843,7 +1538,12 @@ func mkDirIfNotExists(p string) error {
 
 // SaveKey saves a private key for an actor found by its IRI
 func (r *repo) SaveKey(iri vocab.IRI, key crypto.PrivateKey) (vocab.Item, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
 	ob, err := r.loadOneFromPath(iri)
+	r.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -889,6 +1589,8 @@ func (r *repo) SaveKey(iri vocab.IRI, key crypto.PrivateKey) (vocab.Item, error)
 		pub = &prv.PublicKey
 	case *ed25519.PrivateKey:
 		pub = prv.Public()
+	case ed25519.PrivateKey:
+		pub = prv.Public()
 	default:
 		r.errFn("received key %T does not match any of the known private key types", key)
 		return ob, nil
@@ -2,14 +2,16 @@ package badger
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
 	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
 )
 
-func initBadgerForTesting(t *testing.T) (*repo, error) {
+func initBadgerForTesting(t testing.TB) (*repo, error) {
 	tempDir, err := Path(Config{Path: t.TempDir()})
 	if err != nil {
 		return nil, fmt.Errorf("invalid path for initializing boltdb %s: %s", tempDir, err)
@@ -17,6 +19,7 @@ func initBadgerForTesting(t *testing.T) (*repo, error) {
 
 	c := badger.DefaultOptions(tempDir)
 	r := &repo{
+		h:     new(handle),
 		path:  tempDir,
 		logFn: t.Logf,
 		errFn: t.Errorf,
@@ -114,3 +117,149 @@ func Test_repo_AddTo(t *testing.T) {
 		})
 	}
 }
+
+func Test_repo_Load_Authorized(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	requester := vocab.IRI("http://example.com/jdoe")
+	outbox := vocab.IRI("http://example.com/alice/outbox")
+
+	public := vocab.ObjectNew(vocab.NoteType)
+	public.ID = "http://example.com/objects/public"
+	public.To = vocab.ItemCollection{vocab.PublicNS}
+	public.BCC = vocab.ItemCollection{vocab.IRI("http://example.com/bob")}
+	addressed := vocab.ObjectNew(vocab.NoteType)
+	addressed.ID = "http://example.com/objects/addressed"
+	addressed.To = vocab.ItemCollection{requester}
+	private := vocab.ObjectNew(vocab.NoteType)
+	private.ID = "http://example.com/objects/private"
+	private.To = vocab.ItemCollection{vocab.IRI("http://example.com/bob")}
+
+	if _, err = r.Create(orderedCollection(outbox)); err != nil {
+		t.Fatalf("unable to create collection %s: %s", outbox, err)
+	}
+	for _, ob := range []*vocab.Object{public, addressed, private} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+		if err = r.AddTo(outbox, ob.GetLink()); err != nil {
+			t.Fatalf("unable to add %s to %s: %s", ob.ID, outbox, err)
+		}
+	}
+
+	res, err := r.Load(outbox, filters.Authorized(requester))
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	err = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		if col.Count() != 2 {
+			t.Errorf("Load() returned %d items, want 2", col.Count())
+		}
+		if col.Contains(private.GetLink()) {
+			t.Errorf("Load() returned %s which is not addressed to %s", private.ID, requester)
+		}
+		for _, it := range col.Collection() {
+			_ = vocab.OnObject(it, func(o *vocab.Object) error {
+				if len(o.BCC) > 0 {
+					t.Errorf("Load() returned %s with bcc recipients %v", o.ID, o.BCC)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Load() returned invalid collection: %s", err)
+	}
+}
+
+func Test_repo_Save_KeepsCollections(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	act := vocab.PersonNew("http://example.com/jdoe")
+	act.Inbox = vocab.Inbox.IRI(act)
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save %s: %s", act.ID, err)
+	}
+	ob := vocab.IRI("http://example.com/objects/1")
+	if err = r.AddTo(act.Inbox.GetLink(), ob); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", ob, act.Inbox.GetLink(), err)
+	}
+	if _, err = r.Save(act); err != nil {
+		t.Fatalf("unable to save %s again: %s", act.ID, err)
+	}
+
+	res, err := r.Load(act.Inbox.GetLink(), BypassCache())
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	err = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		if !col.Contains(ob) {
+			t.Errorf("Load() %s doesn't contain %s after saving the actor again", act.Inbox.GetLink(), ob)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Load() returned invalid collection: %s", err)
+	}
+}
+
+func Test_repo_Open_Concurrent(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	clone := r.Clone().(*repo)
+	// NOTE(marius): osin closes the clones at the end of the requests, without opening them.
+	clone.Close()
+
+	iris := make(vocab.IRIs, 8)
+	for i := range iris {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%d", i))
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+		iris[i] = ob.ID
+	}
+	wg := sync.WaitGroup{}
+	for i, iri := range iris {
+		s := r
+		if i%2 == 1 {
+			s = clone
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Load(iri, BypassCache()); err != nil {
+				t.Errorf("concurrent Load() of %s error = %s", iri, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err = r.Open(); err != nil {
+		t.Fatalf("Open() error = %s", err)
+	}
+	if err = r.Open(); err != nil {
+		t.Fatalf("nested Open() error = %s", err)
+	}
+	if _, err = clone.Load("http://example.com/objects/1"); err != nil {
+		t.Errorf("Load() from the clone of an open repository error = %s", err)
+	}
+	r.Close()
+	if r.h.db == nil {
+		t.Errorf("Close() closed the database before the matching Close of the nested Open")
+	}
+	r.Close()
+	r.Close()
+	if r.h.db != nil || r.h.refs != 0 {
+		t.Errorf("Close() kept the database open, with %d references", r.h.refs)
+	}
+}
@@ -0,0 +1,32 @@
+package badger
+
+import (
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// requesterCanSee checks if the item matches the Authorized checks of the requester.
+// Items that don't have any recipients, like actors, are visible to everyone.
+func requesterCanSee(auth filters.Checks, it vocab.Item) bool {
+	if len(auth) == 0 || vocab.IsNil(it) || !hasRecipients(it) {
+		return true
+	}
+	return filters.All(auth...).Match(it)
+}
+
+// scopeToRequester removes the blind recipients of the item when loading it on behalf of a requester.
+func scopeToRequester(auth filters.Checks, it vocab.Item) vocab.Item {
+	if len(auth) == 0 {
+		return it
+	}
+	return vocab.CleanRecipients(it)
+}
+
+func hasRecipients(it vocab.Item) bool {
+	has := false
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		has = len(o.To)+len(o.CC)+len(o.Bto)+len(o.BCC)+len(o.Audience) > 0
+		return nil
+	})
+	return has
+}
@@ -0,0 +1,310 @@
+package badger
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// Cache is the interface of the stores for the results of Load, which can be set in the Config.
+// Remove is called without any IRIs when all the results need to be removed.
+type Cache = cache.CanStore
+
+// CacheBackend is the built-in store used for the results of Load.
+type CacheBackend string
+
+const (
+	// CacheMap keeps all the results, without any limits. It is the default when no limits are configured.
+	CacheMap CacheBackend = "map"
+	// CacheLRU evicts the least recently used results which exceed the limits.
+	// It is the default when limits are configured.
+	CacheLRU CacheBackend = "lru"
+	// CacheRistretto uses a ristretto cache, which evicts the results less likely to be requested again.
+	CacheRistretto CacheBackend = "ristretto"
+)
+
+// newResultCache returns the store for the results of Load described by the Config, which expires its results
+// when any of the cache TTLs are set.
+func newResultCache(c Config) (Cache, error) {
+	rc, err := newResultStore(c)
+	if err != nil || rc == nil {
+		return rc, err
+	}
+	if c.CacheCollectionTTL <= 0 && c.CacheObjectTTL <= 0 {
+		return rc, nil
+	}
+	return cache.NewExpiring(rc, resultTTL(c.CacheCollectionTTL, c.CacheObjectTTL)), nil
+}
+
+// resultTTL returns the time for which a result of Load is kept in the cache, depending on it being a collection.
+func resultTTL(collectionTTL, objectTTL time.Duration) cache.TTLFn {
+	return func(_ vocab.IRI, it vocab.Item) time.Duration {
+		if it.IsCollection() {
+			return collectionTTL
+		}
+		return objectTTL
+	}
+}
+
+func newResultStore(c Config) (Cache, error) {
+	if c.Cache != nil {
+		return c.Cache, nil
+	}
+	if !c.CacheEnable {
+		return nil, nil
+	}
+	backend := c.CacheBackend
+	if backend == "" {
+		backend = CacheMap
+		if c.CacheMaxEntries > 0 || c.CacheMaxBytes > 0 {
+			backend = CacheLRU
+		}
+	}
+	switch backend {
+	case CacheMap:
+		return cache.New(true), nil
+	case CacheLRU:
+		return cache.NewLRU(c.CacheMaxEntries, c.CacheMaxBytes), nil
+	case CacheRistretto:
+		return cache.NewRistretto(c.CacheMaxEntries, c.CacheMaxBytes)
+	}
+	return nil, errors.NotValidf("unknown cache backend %q", backend)
+}
+
+// CacheBypass is the Load option which skips the cached results, and the remembered missing objects, for a single
+// load, so it observes the latest stored state. The loaded result still replaces the cached one.
+// It is passed to Load together with the other checks, and it matches all the items.
+type CacheBypass struct{}
+
+// BypassCache returns the Load option which skips the caches.
+func BypassCache() filters.Check {
+	return CacheBypass{}
+}
+
+func (CacheBypass) Match(_ vocab.Item) bool {
+	return true
+}
+
+// bypassCache returns if the checks contain the CacheBypass option, and the rest of the checks, which
+// are the ones identifying the cached result.
+func bypassCache(checks filters.Checks) (bool, filters.Checks) {
+	bypass := false
+	rest := make(filters.Checks, 0, len(checks))
+	for _, c := range checks {
+		if _, ok := c.(CacheBypass); ok {
+			bypass = true
+			continue
+		}
+		rest = append(rest, c)
+	}
+	return bypass, rest
+}
+
+// resultCacheKey returns the key under which the result of a Load gets cached.
+// The hash of the checks is appended as a fragment to the IRI, so the invalidation of the cache,
+// which matches on the IRI path, removes the results for all the checks.
+// It returns false when the checks can't be hashed, and the result can't be cached.
+func resultCacheKey(i vocab.IRI, checks filters.Checks) (vocab.IRI, bool) {
+	if len(checks) == 0 {
+		return i, true
+	}
+	h, ok := checksHash(checks)
+	if !ok {
+		return "", false
+	}
+	sep := "#"
+	if strings.Contains(i.String(), "#") {
+		sep = ";"
+	}
+	return vocab.IRI(fmt.Sprintf("%s%schecks=%x", i, sep, h)), true
+}
+
+// checksHash returns a hash of the checks which doesn't depend on their order,
+// as the top level checks of a Load are all required to match.
+// It returns false when any of the checks doesn't print the same for the same values, like the ones
+// containing pointers or functions, whose printed addresses differ between equivalent checks.
+func checksHash(checks filters.Checks) (uint64, bool) {
+	normalized := make([]string, 0, len(checks))
+	for _, c := range checks {
+		if !printsDeterministically(reflect.ValueOf(c)) {
+			return 0, false
+		}
+		normalized = append(normalized, fmt.Sprintf("%T:%v", c, c))
+	}
+	sort.Strings(normalized)
+	h := fnv.New64a()
+	for _, s := range normalized {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+	return h.Sum64(), true
+}
+
+// printsDeterministically returns whether the printed value of v depends only on the values it contains,
+// and not on the addresses of its pointers, functions or channels.
+func printsDeterministically(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Uintptr:
+		return false
+	case reflect.Interface:
+		return v.IsNil() || printsDeterministically(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !printsDeterministically(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			if !printsDeterministically(it.Key()) || !printsDeterministically(it.Value()) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !printsDeterministically(v.Field(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sharedLoad runs fn only once for the concurrent loads of the same key, which can't be served from the cache,
+// so a burst of identical requests results in a single database read. The callers which joined the load get
+// copies of its result, so each of them can modify what it gets.
+func (r *repo) sharedLoad(key vocab.IRI, fn func() (vocab.Item, error)) (vocab.Item, error) {
+	if r.loads == nil {
+		return fn()
+	}
+	v, err, shared := r.loads.Do(key.String(), func() (interface{}, error) {
+		return fn()
+	})
+	it, _ := v.(vocab.Item)
+	if shared {
+		it = cache.Copy(it)
+	}
+	return it, err
+}
+
+func (r *repo) cachedResult(key vocab.IRI) vocab.Item {
+	if r.cache == nil {
+		return nil
+	}
+	it := r.cache.Get(key)
+	r.countCacheLookup(!vocab.IsNil(it))
+	return it
+}
+
+func (r *repo) cacheResult(key vocab.IRI, it vocab.Item) {
+	if r.cache == nil || len(key) == 0 || vocab.IsNil(it) {
+		return
+	}
+	r.cache.Set(key, it)
+	r.embedded.Add(key, embeddedIRIs(it)...)
+}
+
+// invalidateResults removes from the cache the results loaded from the iris, or from their parents.
+// When called without any IRIs, it removes all the cached results.
+func (r *repo) invalidateResults(iris ...vocab.IRI) {
+	if r.serialized != nil {
+		r.serialized.Remove(iris...)
+	}
+	if r.cache == nil {
+		return
+	}
+	if len(iris) == 0 {
+		r.embedded.Clear()
+	}
+	r.cache.Remove(iris...)
+}
+
+// clearCaches forgets all the cached results, the failed lookups and the decoded items, for when the keys get
+// replaced outside the regular write paths, like when restoring a backup, or importing data.
+func (r *repo) clearCaches() {
+	r.invalidateResults()
+	r.clearNotFound()
+	if r.decoded != nil {
+		r.decoded.Clear()
+	}
+}
+
+// invalidateItem removes from the cache the results which can contain the updated or deleted item at iri:
+// its own, the ones of its parent collection and of the collections containing it, and the ones
+// in which it was embedded as the dereferenced property of another item.
+func (r *repo) invalidateItem(iri vocab.IRI) {
+	if r.cache == nil && r.serialized == nil {
+		return
+	}
+	iris := vocab.IRIs{iri}
+	_ = r.d.View(func(tx *badger.Txn) error {
+		iris = append(iris, collectionsContaining(tx, iri)...)
+		return nil
+	})
+	iris = append(iris, r.embedded.Take(iri)...)
+	if r.serialized != nil {
+		r.serialized.Remove(iris...)
+	}
+	if r.cache != nil {
+		r.cache.Remove(iris...)
+	}
+}
+
+// embeddedIRIs returns the IRIs of the items embedded in it, or in the items of the collection it,
+// as the actors, objects, targets and tags of other items.
+func embeddedIRIs(it vocab.Item) vocab.IRIs {
+	iris := make(vocab.IRIs, 0)
+	var appendEmbedded func(it vocab.Item, top bool)
+	appendEmbedded = func(it vocab.Item, top bool) {
+		if vocab.IsNil(it) {
+			return
+		}
+		if it.IsCollection() {
+			_ = vocab.OnCollectionIntf(it, func(col vocab.CollectionInterface) error {
+				for _, ob := range col.Collection() {
+					appendEmbedded(ob, top)
+				}
+				return nil
+			})
+			return
+		}
+		if !it.IsObject() {
+			return
+		}
+		if !top && !iris.Contains(it.GetLink()) {
+			iris = append(iris, it.GetLink())
+		}
+		_ = vocab.OnObject(it, func(o *vocab.Object) error {
+			for _, t := range o.Tag {
+				appendEmbedded(t, false)
+			}
+			return nil
+		})
+		if vocab.IntransitiveActivityTypes.Contains(it.GetType()) || vocab.ActivityTypes.Contains(it.GetType()) {
+			_ = vocab.OnIntransitiveActivity(it, func(a *vocab.IntransitiveActivity) error {
+				appendEmbedded(a.Actor, false)
+				appendEmbedded(a.Target, false)
+				return nil
+			})
+		}
+		if vocab.ActivityTypes.Contains(it.GetType()) {
+			_ = vocab.OnActivity(it, func(a *vocab.Activity) error {
+				appendEmbedded(a.Object, false)
+				return nil
+			})
+		}
+	}
+	appendEmbedded(it, true)
+	return iris
+}
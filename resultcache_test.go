@@ -0,0 +1,405 @@
+package badger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/storage-badger/internal/cache"
+	"golang.org/x/sync/singleflight"
+)
+
+func Test_resultCacheKey(t *testing.T) {
+	iri := vocab.IRI("http://example.com/objects")
+	if got, ok := resultCacheKey(iri, nil); got != iri || !ok {
+		t.Errorf("resultCacheKey() without checks = %s, %t, want %s", got, ok, iri)
+	}
+	note := filters.HasType(vocab.NoteType)
+	auth := filters.Authorized("http://example.com/actors/jdoe")
+	k1, _ := resultCacheKey(iri, filters.Checks{note, auth})
+	k2, _ := resultCacheKey(iri, filters.Checks{auth, note})
+	if k1 != k2 {
+		t.Errorf("resultCacheKey() depends on the order of checks: %s != %s", k1, k2)
+	}
+	if k3, _ := resultCacheKey(iri, filters.Checks{note}); k3 == k1 {
+		t.Errorf("resultCacheKey() is the same for different checks: %s", k3)
+	}
+	if !k1.Contains(iri, false) {
+		t.Errorf("resultCacheKey() %s is not matched by its IRI %s", k1, iri)
+	}
+	if k, ok := resultCacheKey(iri, filters.Checks{pointerCheck{}}); ok {
+		t.Errorf("resultCacheKey() = %s for a check containing a pointer, want it not cacheable", k)
+	}
+}
+
+// pointerCheck is a check whose printed value contains the address of its pointer.
+type pointerCheck struct {
+	iri *vocab.IRI
+}
+
+func (pointerCheck) Match(vocab.Item) bool {
+	return true
+}
+
+func Test_repo_Load_ResultCache(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(true)
+
+	first := vocab.ObjectNew(vocab.NoteType)
+	first.ID = "http://example.com/objects/1"
+	if _, err = r.Save(first); err != nil {
+		t.Fatalf("unable to save %s: %s", first.ID, err)
+	}
+
+	countItems := func(iri vocab.IRI, checks ...filters.Check) int {
+		res, err := r.Load(iri, checks...)
+		if err != nil {
+			t.Fatalf("Load() error = %s", err)
+		}
+		cnt := 0
+		_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+			cnt = int(col.Count())
+			return nil
+		})
+		return cnt
+	}
+
+	objects := vocab.IRI("http://example.com/objects")
+	if cnt := countItems(objects); cnt != 1 {
+		t.Fatalf("Load() = %d items, want 1", cnt)
+	}
+	if key, _ := resultCacheKey(objects, nil); r.cache.Get(key) == nil {
+		t.Errorf("Load() result was not cached")
+	}
+	if cnt := countItems(objects, filters.HasType(vocab.NoteType)); cnt != 1 {
+		t.Fatalf("Load() with checks = %d items, want 1", cnt)
+	}
+
+	second := vocab.ObjectNew(vocab.NoteType)
+	second.ID = "http://example.com/objects/2"
+	if _, err = r.Save(second); err != nil {
+		t.Fatalf("unable to save %s: %s", second.ID, err)
+	}
+	if cnt := countItems(objects); cnt != 2 {
+		t.Errorf("Load() after Save = %d items, want 2", cnt)
+	}
+	if cnt := countItems(objects, filters.HasType(vocab.NoteType)); cnt != 2 {
+		t.Errorf("Load() with checks after Save = %d items, want 2", cnt)
+	}
+
+	outbox := vocab.IRI("http://example.com/actors/jdoe/outbox")
+	if _, err = r.Create(&vocab.OrderedCollection{ID: outbox, Type: vocab.OrderedCollectionType}); err != nil {
+		t.Fatalf("unable to create %s: %s", outbox, err)
+	}
+	if cnt := countItems(outbox); cnt != 0 {
+		t.Fatalf("Load() outbox = %d items, want 0", cnt)
+	}
+	if err = r.AddTo(outbox, first); err != nil {
+		t.Fatalf("unable to add to %s: %s", outbox, err)
+	}
+	if cnt := countItems(outbox); cnt != 1 {
+		t.Errorf("Load() outbox after AddTo = %d items, want 1", cnt)
+	}
+
+	first.Type = vocab.ArticleType
+	if _, err = r.Save(first); err != nil {
+		t.Fatalf("unable to save %s: %s", first.ID, err)
+	}
+	res, err := r.Load(outbox)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		if col.Count() != 1 || col.Collection().First().GetType() != vocab.ArticleType {
+			t.Errorf("Load() outbox after update = %v, want the updated %s", col.Collection(), first.ID)
+		}
+		return nil
+	})
+}
+
+func Test_repo_ResultCache_Invalidation(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(true)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	ob.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("note"))
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+	outbox := vocab.IRI("http://example.com/actors/jdoe/outbox")
+	if _, err = r.Create(&vocab.OrderedCollection{ID: outbox, Type: vocab.OrderedCollectionType}); err != nil {
+		t.Fatalf("unable to create %s: %s", outbox, err)
+	}
+	if err = r.AddTo(outbox, ob); err != nil {
+		t.Fatalf("unable to add to %s: %s", outbox, err)
+	}
+
+	it, err := r.LoadOne(ob.ID)
+	if err != nil {
+		t.Fatalf("LoadOne() error = %s", err)
+	}
+	if r.cache.Get(ob.ID) == nil {
+		t.Errorf("LoadOne() result was not cached")
+	}
+	// NOTE(marius): modifying a loaded item must not modify the cached one.
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		o.Name = vocab.NaturalLanguageValuesNew(vocab.DefaultLangRef("modified"))
+		return nil
+	})
+	it, err = r.Load(ob.ID)
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		if o.Name.First().String() != "note" {
+			t.Errorf("Load() returned the name %q, want %q", o.Name.First(), "note")
+		}
+		return nil
+	})
+
+	if got := collectionItemIRIs(mustLoad(t, r, outbox)); len(got) != 1 {
+		t.Fatalf("Load() outbox = %v, want %s", got, ob.ID)
+	}
+	if err = r.RemoveFrom(outbox, ob); err != nil {
+		t.Fatalf("unable to remove from %s: %s", outbox, err)
+	}
+	if got := collectionItemIRIs(mustLoad(t, r, outbox)); len(got) != 0 {
+		t.Errorf("Load() outbox after RemoveFrom = %v, want empty", got)
+	}
+
+	other := vocab.ObjectNew(vocab.NoteType)
+	other.ID = "http://example.com/objects/2"
+	if _, err = r.Save(other); err != nil {
+		t.Fatalf("unable to save %s: %s", other.ID, err)
+	}
+	objects := vocab.IRI("http://example.com/objects")
+	if got := collectionItemIRIs(mustLoad(t, r, objects)); len(got) != 2 {
+		t.Fatalf("Load() objects = %v, want %s and %s", got, ob.ID, other.ID)
+	}
+	if err = r.Delete(ob); err != nil {
+		t.Fatalf("unable to delete %s: %s", ob.ID, err)
+	}
+	if r.cache.Get(ob.ID) != nil {
+		t.Errorf("the cached %s was not removed on Delete", ob.ID)
+	}
+	if got := collectionItemIRIs(mustLoad(t, r, objects)); len(got) != 1 || got.Contains(ob.ID) {
+		t.Errorf("Load() objects after Delete = %v, want only %s", got, other.ID)
+	}
+}
+
+func Test_repo_ResultCache_Disabled(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(false)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+	_ = mustLoad(t, r, ob.ID)
+	if r.cache.Get(ob.ID) != nil {
+		t.Errorf("Load() result was cached with the cache disabled")
+	}
+}
+
+func mustLoad(t *testing.T, r *repo, iri vocab.IRI) vocab.Item {
+	it, err := r.Load(iri)
+	if err != nil {
+		t.Fatalf("Load(%s) error = %s", iri, err)
+	}
+	return it
+}
+
+func Test_newResultCache(t *testing.T) {
+	custom := cache.NewLRU(1, 0)
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		wantErr bool
+	}{
+		{name: "disabled", config: Config{}, want: "<nil>"},
+		{name: "custom", config: Config{Cache: custom}, want: fmt.Sprintf("%T", custom)},
+		{name: "map", config: Config{CacheEnable: true}, want: fmt.Sprintf("%T", cache.New(true))},
+		{name: "lru with limits", config: Config{CacheEnable: true, CacheMaxEntries: 10}, want: fmt.Sprintf("%T", custom)},
+		{name: "lru", config: Config{CacheEnable: true, CacheBackend: CacheLRU}, want: fmt.Sprintf("%T", custom)},
+		{name: "ristretto", config: Config{CacheEnable: true, CacheBackend: CacheRistretto, CacheMaxBytes: 1 << 20}, want: "*cache.ristrettoStore"},
+		{name: "ristretto without limits", config: Config{CacheEnable: true, CacheBackend: CacheRistretto}, wantErr: true},
+		{name: "unknown", config: Config{CacheEnable: true, CacheBackend: "memcached"}, wantErr: true},
+		{name: "expiring", config: Config{CacheEnable: true, CacheObjectTTL: time.Hour}, want: "*cache.expiring"},
+		{name: "disabled with ttl", config: Config{CacheCollectionTTL: time.Minute}, want: "<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newResultCache(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newResultCache() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if typ := fmt.Sprintf("%T", got); typ != tt.want {
+				t.Errorf("newResultCache() = %s, want %s", typ, tt.want)
+			}
+		})
+	}
+}
+
+func Test_repo_Clone_SharesCache(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(true)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+
+	clone, ok := r.Clone().(*repo)
+	if !ok || clone == r {
+		t.Fatalf("Clone() = %T %p, want a different *repo than %p", clone, clone, r)
+	}
+	if _, err = clone.Load(ob.ID); err != nil {
+		t.Fatalf("Load() from the clone error = %s", err)
+	}
+	if r.cache.Get(ob.ID) == nil {
+		t.Errorf("the result loaded from the clone is not in the cache of the repository")
+	}
+	if clone.h != r.h {
+		t.Errorf("the clone doesn't share the database handle of the repository")
+	}
+}
+
+func Test_resultTTL(t *testing.T) {
+	ttl := resultTTL(time.Minute, time.Hour)
+	if got := ttl("http://example.com/objects", vocab.ItemCollection{}); got != time.Minute {
+		t.Errorf("resultTTL() for a collection = %s, want %s", got, time.Minute)
+	}
+	ob := vocab.ObjectNew(vocab.NoteType)
+	if got := ttl("http://example.com/objects/1", ob); got != time.Hour {
+		t.Errorf("resultTTL() for an object = %s, want %s", got, time.Hour)
+	}
+}
+
+func Test_repo_Load_BypassCache(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.cache = cache.New(true)
+	r.notFound = cache.NewNotFound(time.Minute)
+
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "http://example.com/objects/1"
+	ob.Name = vocab.DefaultNaturalLanguageValue("stored")
+	if _, err = r.Save(ob); err != nil {
+		t.Fatalf("unable to save %s: %s", ob.ID, err)
+	}
+
+	stale := vocab.ObjectNew(vocab.NoteType)
+	stale.ID = ob.ID
+	stale.Name = vocab.DefaultNaturalLanguageValue("stale")
+	r.cache.Set(ob.ID, stale)
+
+	nameOf := func(it vocab.Item) string {
+		name := ""
+		_ = vocab.OnObject(it, func(o *vocab.Object) error {
+			name = o.Name.String()
+			return nil
+		})
+		return name
+	}
+	if name := nameOf(mustLoad(t, r, ob.ID)); name != "stale" {
+		t.Errorf("Load() = %q, want the cached %q", name, "stale")
+	}
+	it, err := r.Load(ob.ID, BypassCache())
+	if err != nil {
+		t.Fatalf("Load() with BypassCache error = %s", err)
+	}
+	if name := nameOf(it); name != "stored" {
+		t.Errorf("Load() with BypassCache = %q, want %q", name, "stored")
+	}
+	if name := nameOf(mustLoad(t, r, ob.ID)); name != "stored" {
+		t.Errorf("Load() after BypassCache = %q, want the refreshed %q", name, "stored")
+	}
+
+	r.setNotFound(ob.ID)
+	if _, err = r.Load(ob.ID, BypassCache()); err != nil {
+		t.Errorf("Load() with BypassCache of an IRI remembered as missing error = %s", err)
+	}
+}
+
+func Test_repo_sharedLoad(t *testing.T) {
+	r := repo{loads: new(singleflight.Group)}
+	key := vocab.IRI("http://example.com/objects/1")
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	load := func() (vocab.Item, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = key
+		return ob, nil
+	}
+
+	const loaders = 10
+	results := make([]vocab.Item, loaders)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = r.sharedLoad(key, load)
+	}()
+	<-started
+	for i := 1; i < loaders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = r.sharedLoad(key, load)
+		}(i)
+	}
+	// NOTE(marius): give the other loads the time to join the one in progress.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("sharedLoad() ran the load %d times, want 1", n)
+	}
+	_ = vocab.OnObject(results[0], func(o *vocab.Object) error {
+		o.Name = vocab.DefaultNaturalLanguageValue("modified")
+		return nil
+	})
+	for i, it := range results[1:] {
+		if vocab.IsNil(it) || !it.GetLink().Equals(key, false) {
+			t.Errorf("sharedLoad() result %d = %v, want %s", i+1, it, key)
+			continue
+		}
+		_ = vocab.OnObject(it, func(o *vocab.Object) error {
+			if len(o.Name) > 0 {
+				t.Errorf("sharedLoad() result %d shares its value with the others", i+1)
+			}
+			return nil
+		})
+	}
+}
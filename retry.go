@@ -0,0 +1,47 @@
+package badger
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The defaults for the retries of the transactions failing with transient errors.
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryBackoff  = 10 * time.Millisecond
+)
+
+// isTransientErr returns whether err is a badger error which can go away when the transaction is retried:
+// a conflict with a concurrent transaction, or the writes being blocked while badger drops keys.
+func isTransientErr(err error) bool {
+	return err == badger.ErrConflict || err == badger.ErrBlockedWrites
+}
+
+// retry calls fn until it succeeds, or it fails with an error which is not transient, or until all the attempts
+// of the Config are made, waiting between them for a backoff doubled after each of them.
+// A negative number of attempts disables the retries, calling fn only once.
+func (r *repo) retry(fn func() error) error {
+	attempts, backoff := r.retryAttempts, r.retryBackoff
+	switch {
+	case attempts == 0:
+		attempts = DefaultRetryAttempts
+	case attempts < 0:
+		attempts = 1
+	}
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); !isTransientErr(err) {
+			return err
+		}
+		if i < attempts-1 {
+			r.logFn("retrying the transaction after %s: %s", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
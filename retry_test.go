@@ -0,0 +1,53 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_retry(t *testing.T) {
+	r := repo{retryAttempts: 3, retryBackoff: time.Millisecond, logFn: t.Logf}
+
+	calls := 0
+	err := r.retry(func() error {
+		if calls++; calls < 3 {
+			return badger.ErrConflict
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("retry() = %v after %d calls, want success after 3", err, calls)
+	}
+
+	calls = 0
+	err = r.retry(func() error {
+		calls++
+		return badger.ErrBlockedWrites
+	})
+	if err != badger.ErrBlockedWrites || calls != 3 {
+		t.Errorf("retry() = %v after %d calls, want %v after 3", err, calls, badger.ErrBlockedWrites)
+	}
+
+	calls = 0
+	persistent := errors.Newf("persistent")
+	if err = r.retry(func() error { calls++; return persistent }); err != persistent || calls != 1 {
+		t.Errorf("retry() = %v after %d calls, want %v after 1", err, calls, persistent)
+	}
+
+	for _, attempts := range []int{1, -1} {
+		r.retryAttempts = attempts
+		calls = 0
+		if err = r.retry(func() error { calls++; return badger.ErrConflict }); err != badger.ErrConflict || calls != 1 {
+			t.Errorf("retry() with %d attempts = %v after %d calls, want %v after 1", attempts, err, calls, badger.ErrConflict)
+		}
+	}
+
+	r.retryAttempts = 0
+	calls = 0
+	if err = r.retry(func() error { calls++; return badger.ErrConflict }); calls != DefaultRetryAttempts {
+		t.Errorf("retry() with 0 attempts = %v after %d calls, want %d calls", err, calls, DefaultRetryAttempts)
+	}
+}
@@ -0,0 +1,212 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+// rewrite is a change of the host of the instance, from old to new.
+type rewrite struct {
+	old []byte
+	new []byte
+}
+
+// newRewrites returns the rewrites of the hosts of the map, from the old hosts to the new ones.
+func newRewrites(hosts map[string]string) []rewrite {
+	rewrites := make([]rewrite, 0, len(hosts))
+	for old, new := range hosts {
+		old, new = strings.ToLower(old), strings.ToLower(new)
+		if old == "" || new == "" || old == new {
+			continue
+		}
+		rewrites = append(rewrites, rewrite{old: []byte(old), new: []byte(new)})
+	}
+	return rewrites
+}
+
+// naturalLanguageProps are the properties of the encoded items whose values are text, in which the mentions of the
+// old hosts are kept as they are.
+var naturalLanguageProps = []string{
+	"content", "contentMap", "summary", "summaryMap", "name", "nameMap",
+	"preferredUsername", "preferredUsernameMap", "source",
+}
+
+// rewriteRaw returns the encoded item with the IRIs of the old hosts replaced by the ones of the new hosts.
+// Only the values which are IRIs get replaced, outside the natural language properties, so the text mentioning
+// the old hosts is kept. The raw value is returned as it is when it doesn't contain any of them, or when it
+// can't be decoded.
+func (r *repo) rewriteRaw(raw []byte) []byte {
+	found := false
+	for _, rw := range r.rewrites {
+		if found = bytes.Contains(bytes.ToLower(raw), append([]byte("://"), rw.old...)); found {
+			break
+		}
+	}
+	if !found {
+		return raw
+	}
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return raw
+	}
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.rewriteValue(v)); err != nil {
+		return raw
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+}
+
+// rewriteValue replaces the IRIs of the old hosts found in the decoded JSON value v.
+func (r *repo) rewriteValue(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, el := range vv {
+			if stringsContain(naturalLanguageProps, k) {
+				continue
+			}
+			vv[k] = r.rewriteValue(el)
+		}
+	case []any:
+		for i, el := range vv {
+			vv[i] = r.rewriteValue(el)
+		}
+	case string:
+		return r.rewriteIRI(vv)
+	}
+	return v
+}
+
+// rewriteIRI returns the IRI s with its old host replaced by the new one. The values which are not IRIs, or
+// which have other hosts, are returned as they are.
+func (r *repo) rewriteIRI(s string) string {
+	i := strings.Index(s, "://")
+	if i <= 0 || strings.ContainsAny(s, " \t\n") {
+		return s
+	}
+	host := s[i+3:]
+	for _, rw := range r.rewrites {
+		old := string(rw.old)
+		if len(host) < len(old) || !strings.EqualFold(host[:len(old)], old) {
+			continue
+		}
+		if rest := host[len(old):]; len(rest) == 0 || strings.IndexByte("/:?#", rest[0]) >= 0 {
+			return s[:i+3] + string(rw.new) + rest
+		}
+	}
+	return s
+}
+
+// rewrittenFn returns fn called with the raw values having the IRIs of the old hosts replaced, like rewriteRaw.
+// It's used for the values which aren't decoded through decode, like the OAuth data.
+func (r *repo) rewrittenFn(fn func(raw []byte) error) func(raw []byte) error {
+	if len(r.rewrites) == 0 {
+		return fn
+	}
+	return func(raw []byte) error {
+		return fn(r.rewriteRaw(raw))
+	}
+}
+
+// rewriteOf returns the rewrite of the new host which the path belongs to.
+func (r *repo) rewriteOf(p []byte) (rewrite, bool) {
+	for _, rw := range r.rewrites {
+		if !bytes.HasPrefix(p, rw.new) {
+			continue
+		}
+		if rest := p[len(rw.new):]; len(rest) == 0 || rest[0] == '/' {
+			return rw, true
+		}
+	}
+	return rewrite{}, false
+}
+
+// legacyPath returns the path under the old host of a path of the new host, and false for the other paths.
+func (r *repo) legacyPath(p []byte) ([]byte, bool) {
+	rw, ok := r.rewriteOf(p)
+	if !ok {
+		return p, false
+	}
+	return append(append([]byte{}, rw.old...), p[len(rw.new):]...), true
+}
+
+// storedPath returns the path of the new host, or the one under the old host, when the data is still stored
+// under it, as it wasn't re-keyed yet.
+func (r *repo) storedPath(tx dbTxn, p []byte) []byte {
+	old, ok := r.legacyPath(p)
+	if !ok || pathExists(tx, p) || !pathExists(tx, old) {
+		return p
+	}
+	return old
+}
+
+// storedKey returns the key built by keyFn for the path of the new host, or for the one under the old host,
+// when only that one is stored, as it wasn't re-keyed yet.
+func (r *repo) storedKey(tx dbTxn, p []byte, keyFn func([]byte) []byte) []byte {
+	k := keyFn(p)
+	old, ok := r.legacyPath(p)
+	if !ok {
+		return k
+	}
+	if keyExists(tx, k) {
+		return k
+	}
+	if oldKey := keyFn(old); keyExists(tx, oldKey) {
+		return oldKey
+	}
+	return k
+}
+
+// storedIRI returns the IRI of the new host, or the one under the old host, when the data is still stored
+// under it, so the writes to the collections which weren't re-keyed yet keep their existing members.
+func (r *repo) storedIRI(tx dbTxn, iri vocab.IRI) vocab.IRI {
+	p := itemPath(iri)
+	if stored := r.storedPath(tx, p); bytes.Equal(stored, p) {
+		return iri
+	}
+	rw, _ := r.rewriteOf(p)
+	return vocab.IRI(strings.Replace(iri.String(), "://"+string(rw.new), "://"+string(rw.old), 1))
+}
+
+// pathExists returns whether any key belongs to the path, without matching the sibling paths sharing
+// its prefix (eg: "example.com/jdoe" vs "example.com/jdoe2").
+func pathExists(tx dbTxn, p []byte) bool {
+	prefix := append(append([]byte{}, p...), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	it.Seek(prefix)
+	return it.ValidForPrefix(prefix)
+}
+
+// loadRawBatch returns the stored values of the objects at the iris, like loadRawBatch, looking up the ones
+// which are missing under their old hosts.
+func (r *repo) loadRawBatch(tx dbTxn, iris []vocab.Item) [][]byte {
+	raws := loadRawBatch(tx, iris)
+	if len(r.rewrites) == 0 {
+		return raws
+	}
+	for i, raw := range raws {
+		if raw != nil {
+			continue
+		}
+		old, ok := r.legacyPath(itemPath(iris[i].GetLink()))
+		if !ok {
+			continue
+		}
+		if item, err := tx.Get(getObjectKey(old)); err == nil {
+			raws[i], _ = item.ValueCopy(nil)
+		}
+	}
+	return raws
+}
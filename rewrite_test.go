@@ -0,0 +1,188 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Load_Rewrites(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://old.example.com/objects/1"
+	note.AttributedTo = vocab.IRI("https://old.example.com/actors/jdoe")
+	// NOTE(marius): the text mentioning the old host, and the IRIs of the hosts sharing its prefix, are kept.
+	note.Content = vocab.DefaultNaturalLanguageValue(`moved from <a href="https://old.example.com/">https://old.example.com</a>`)
+	note.Summary = vocab.DefaultNaturalLanguageValue("https://old.example.com/objects/1")
+	note.URL = vocab.IRI("https://old.example.com.other.org/objects/1")
+	if _, err = r.Save(note); err != nil {
+		t.Fatalf("unable to save %s: %s", note.ID, err)
+	}
+	outbox := vocab.IRI("https://old.example.com/actors/jdoe/outbox")
+	if err = r.AddTo(outbox, note.ID); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", note.ID, outbox, err)
+	}
+
+	r.rewrites = newRewrites(map[string]string{"old.example.com": "new.example.com"})
+	it, err := r.Load("https://new.example.com/objects/1")
+	if err != nil {
+		t.Fatalf("Load() of an object stored under the old host error = %s", err)
+	}
+	if it.GetLink() != "https://new.example.com/objects/1" {
+		t.Errorf("Load() = %s, want the IRI of the new host", it.GetLink())
+	}
+	_ = vocab.OnObject(it, func(ob *vocab.Object) error {
+		if ob.AttributedTo.GetLink() != "https://new.example.com/actors/jdoe" {
+			t.Errorf("Load() attributedTo = %s, want the IRI of the new host", ob.AttributedTo.GetLink())
+		}
+		if ob.Content.String() != note.Content.String() || ob.Summary.String() != note.Summary.String() {
+			t.Errorf("Load() rewrote the text %q, %q, want it unchanged", ob.Content, ob.Summary)
+		}
+		if ob.URL.GetLink() != note.URL.GetLink() {
+			t.Errorf("Load() url = %s, want %s", ob.URL.GetLink(), note.URL.GetLink())
+		}
+		return nil
+	})
+
+	col, err := r.Load("https://new.example.com/actors/jdoe/outbox")
+	if err != nil {
+		t.Fatalf("Load() of a collection stored under the old host error = %s", err)
+	}
+	var items vocab.ItemCollection
+	_ = vocab.OnCollectionIntf(col, func(c vocab.CollectionInterface) error {
+		items = c.Collection()
+		return nil
+	})
+	if len(items) != 1 || items[0].GetLink() != "https://new.example.com/objects/1" || !items[0].IsObject() {
+		t.Errorf("Load() of the collection = %v, want the object with the IRI of the new host", items)
+	}
+}
+
+func Test_repo_AddTo_Rewrites(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://old.example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	if err = r.PasswordSet(jdoe, []byte("secret")); err != nil {
+		t.Fatalf("unable to set the password of %s: %s", jdoe.ID, err)
+	}
+	first := vocab.IRI("https://old.example.com/objects/1")
+	if err = r.AddTo(jdoe.Outbox.GetLink(), first); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", first, jdoe.Outbox.GetLink(), err)
+	}
+	// NOTE(marius): the sibling actor stored under the new host doesn't make the old data unreachable.
+	if _, err = r.Save(vocab.PersonNew("https://new.example.com/actors/jdoe2")); err != nil {
+		t.Fatalf("unable to save the sibling actor: %s", err)
+	}
+
+	r.rewrites = newRewrites(map[string]string{"old.example.com": "new.example.com"})
+	newJdoe := vocab.IRI("https://new.example.com/actors/jdoe")
+	if err = r.PasswordCheck(newJdoe, []byte("secret")); err != nil {
+		t.Errorf("PasswordCheck() of an actor stored under the old host error = %s", err)
+	}
+	if _, err = r.LoadMetadata(newJdoe); err != nil {
+		t.Errorf("LoadMetadata() of an actor stored under the old host error = %s", err)
+	}
+
+	outbox := vocab.IRI("https://new.example.com/actors/jdoe/outbox")
+	second := vocab.IRI("https://new.example.com/objects/2")
+	if err = r.AddTo(outbox, second); err != nil {
+		t.Fatalf("AddTo() of a collection stored under the old host error = %s", err)
+	}
+	col, err := r.Load(outbox, BypassCache())
+	if err != nil {
+		t.Fatalf("Load() of %s error = %s", outbox, err)
+	}
+	_ = vocab.OnCollectionIntf(col, func(c vocab.CollectionInterface) error {
+		if !c.Contains(second) || !c.Contains("https://new.example.com/objects/1") {
+			t.Errorf("Load() of %s = %v, want both the old and the added members", outbox, c.Collection())
+		}
+		return nil
+	})
+}
+
+func Test_repo_WithTx_Rewrites(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://old.example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	first, second := vocab.IRI("https://old.example.com/objects/1"), vocab.IRI("https://old.example.com/objects/2")
+	for _, iri := range []vocab.IRI{first, second} {
+		if err = r.AddTo(jdoe.Outbox.GetLink(), iri); err != nil {
+			t.Fatalf("unable to add %s to %s: %s", iri, jdoe.Outbox.GetLink(), err)
+		}
+	}
+
+	r.rewrites = newRewrites(map[string]string{"old.example.com": "new.example.com"})
+	newJdoe := vocab.IRI("https://new.example.com/actors/jdoe")
+	outbox := vocab.IRI("https://new.example.com/actors/jdoe/outbox")
+	third := vocab.IRI("https://new.example.com/objects/3")
+	err = r.WithTx(func(s Store) error {
+		it, err := s.Load(newJdoe)
+		if err != nil {
+			return err
+		}
+		if it.GetLink() != newJdoe {
+			t.Errorf("Load() = %s, want the IRI of the new host", it.GetLink())
+		}
+		return s.AddTo(outbox, third)
+	})
+	if err != nil {
+		t.Fatalf("WithTx() on the items stored under the old host error = %s", err)
+	}
+	members := func() vocab.ItemCollection {
+		col, err := r.Load(outbox, BypassCache())
+		if err != nil {
+			t.Fatalf("Load() of %s error = %s", outbox, err)
+		}
+		var items vocab.ItemCollection
+		_ = vocab.OnCollectionIntf(col, func(c vocab.CollectionInterface) error {
+			items = c.Collection()
+			return nil
+		})
+		return items
+	}
+	if items := members(); !items.Contains("https://new.example.com/objects/1") || !items.Contains(third) {
+		t.Errorf("Load() of %s = %v, want both the old and the added members", outbox, items)
+	}
+
+	if err = r.WithTx(func(s Store) error { return s.RemoveFrom(outbox, third) }); err != nil {
+		t.Fatalf("WithTx() on the items stored under the old host error = %s", err)
+	}
+	if items := members(); items.Contains(third) || !items.Contains("https://new.example.com/objects/2") {
+		t.Errorf("Load() of %s = %v, want the old members, without the removed one", outbox, items)
+	}
+}
+
+func Test_repo_legacyPath(t *testing.T) {
+	r := repo{rewrites: newRewrites(map[string]string{"old.example.com": "new.example.com"})}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{path: "new.example.com/objects/1", want: "old.example.com/objects/1", ok: true},
+		{path: "new.example.com", want: "old.example.com", ok: true},
+		{path: "new.example.community/objects/1", want: "new.example.community/objects/1"},
+		{path: "other.example.com/objects/1", want: "other.example.com/objects/1"},
+	}
+	for _, tt := range tests {
+		got, ok := r.legacyPath([]byte(tt.path))
+		if string(got) != tt.want || ok != tt.ok {
+			t.Errorf("legacyPath(%s) = %s, %t, want %s, %t", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package badger
+
+import (
+	"crypto"
+	"net/url"
+	"strings"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/processing"
+	"github.com/openshift/osin"
+)
+
+// Router is a storage which sends the operations to the repository of the host of their IRIs, so a single
+// process can serve multiple domains, each of them with its own database directory.
+//
+// The OAuth data isn't identified by IRIs, so it is kept in the default repository.
+type Router struct {
+	hosts map[string]*repo
+	def   *repo
+}
+
+// NewRouter returns the Router sending the operations on the IRIs of the hosts to their repositories, and the
+// ones of the other hosts to the default repository. When def is nil, the operations of the other hosts fail
+// with a NotFound error, and so do the ones on the OAuth data.
+func NewRouter(hosts map[string]*repo, def *repo) (*Router, error) {
+	r := Router{hosts: make(map[string]*repo, len(hosts)), def: def}
+	for host, repo := range hosts {
+		if host == "" || repo == nil {
+			return nil, errors.NotValidf("invalid repository for the host %q", host)
+		}
+		r.hosts[strings.ToLower(host)] = repo
+	}
+	return &r, nil
+}
+
+// route returns the repository of the host of the iri.
+func (r *Router) route(iri vocab.IRI) (*repo, error) {
+	host := ""
+	if u, err := url.Parse(string(iri)); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+	if repo, ok := r.hosts[host]; ok {
+		return repo, nil
+	}
+	if r.def == nil {
+		return nil, errors.NewNotFound(ErrNotFound, "no repository is configured for the host %q of %s", host, iri)
+	}
+	return r.def, nil
+}
+
+// repos returns the repositories of the hosts, and the default one.
+func (r *Router) repos() []*repo {
+	repos := make([]*repo, 0, len(r.hosts)+1)
+	for _, repo := range r.hosts {
+		repos = append(repos, repo)
+	}
+	if r.def != nil {
+		repos = append(repos, r.def)
+	}
+	return repos
+}
+
+// Open opens the repositories of all the hosts, closing them again when one fails.
+func (r *Router) Open() error {
+	opened := make([]*repo, 0, len(r.hosts)+1)
+	for _, repo := range r.repos() {
+		if err := repo.Open(); err != nil {
+			for _, o := range opened {
+				o.Close()
+			}
+			return err
+		}
+		opened = append(opened, repo)
+	}
+	return nil
+}
+
+// Close closes the repositories of all the hosts.
+func (r *Router) Close() {
+	for _, repo := range r.repos() {
+		repo.Close()
+	}
+}
+
+func (r *Router) Load(iri vocab.IRI, checks ...filters.Check) (vocab.Item, error) {
+	repo, err := r.route(iri)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Load(iri, checks...)
+}
+
+func (r *Router) LoadOne(f Filterable) (vocab.Item, error) {
+	repo, err := r.route(f.GetLink())
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadOne(f)
+}
+
+func (r *Router) Create(col vocab.CollectionInterface) (vocab.CollectionInterface, error) {
+	repo, err := r.route(col.GetLink())
+	if err != nil {
+		return col, err
+	}
+	return repo.Create(col)
+}
+
+func (r *Router) Save(it vocab.Item) (vocab.Item, error) {
+	if vocab.IsNil(it) {
+		return it, errors.NotValidf("Unable to save a nil element")
+	}
+	repo, err := r.route(it.GetLink())
+	if err != nil {
+		return it, err
+	}
+	return repo.Save(it)
+}
+
+func (r *Router) Delete(it vocab.Item) error {
+	if vocab.IsNil(it) {
+		return errors.NotValidf("Unable to delete a nil element")
+	}
+	repo, err := r.route(it.GetLink())
+	if err != nil {
+		return err
+	}
+	return repo.Delete(it)
+}
+
+// AddTo adds the item to the collection, in the repository of the host of the collection.
+func (r *Router) AddTo(col vocab.IRI, it vocab.Item) error {
+	repo, err := r.route(col)
+	if err != nil {
+		return err
+	}
+	return repo.AddTo(col, it)
+}
+
+// RemoveFrom removes the item from the collection, in the repository of the host of the collection.
+func (r *Router) RemoveFrom(col vocab.IRI, it vocab.Item) error {
+	repo, err := r.route(col)
+	if err != nil {
+		return err
+	}
+	return repo.RemoveFrom(col, it)
+}
+
+func (r *Router) PasswordSet(it vocab.Item, pw []byte) error {
+	repo, err := r.route(it.GetLink())
+	if err != nil {
+		return err
+	}
+	return repo.PasswordSet(it, pw)
+}
+
+func (r *Router) PasswordCheck(it vocab.Item, pw []byte) error {
+	repo, err := r.route(it.GetLink())
+	if err != nil {
+		return err
+	}
+	return repo.PasswordCheck(it, pw)
+}
+
+func (r *Router) LoadMetadata(iri vocab.IRI) (*processing.Metadata, error) {
+	repo, err := r.route(iri)
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadMetadata(iri)
+}
+
+func (r *Router) SaveMetadata(m processing.Metadata, iri vocab.IRI) error {
+	repo, err := r.route(iri)
+	if err != nil {
+		return err
+	}
+	return repo.SaveMetadata(m, iri)
+}
+
+func (r *Router) LoadKey(iri vocab.IRI) (crypto.PrivateKey, error) {
+	repo, err := r.route(iri)
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadKey(iri)
+}
+
+func (r *Router) SaveKey(iri vocab.IRI, key crypto.PrivateKey) (vocab.Item, error) {
+	repo, err := r.route(iri)
+	if err != nil {
+		return nil, err
+	}
+	return repo.SaveKey(iri, key)
+}
+
+func (r *Router) CreateService(service *vocab.Service) error {
+	repo, err := r.route(service.GetLink())
+	if err != nil {
+		return err
+	}
+	return repo.CreateService(service)
+}
+
+// oauth returns the default repository, which keeps the OAuth data.
+func (r *Router) oauth() (*repo, error) {
+	if r.def == nil {
+		return nil, errors.NewNotFound(ErrNotFound, "no default repository is configured for the OAuth data")
+	}
+	return r.def, nil
+}
+
+// Clone returns a Router with clones of the repositories.
+func (r *Router) Clone() osin.Storage {
+	c := Router{hosts: make(map[string]*repo, len(r.hosts))}
+	for host, repo := range r.hosts {
+		c.hosts[host] = repo.copy()
+	}
+	if r.def != nil {
+		c.def = r.def.copy()
+	}
+	return &c
+}
+
+func (r *Router) GetClient(id string) (osin.Client, error) {
+	repo, err := r.oauth()
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetClient(id)
+}
+
+func (r *Router) ListClients() ([]osin.Client, error) {
+	repo, err := r.oauth()
+	if err != nil {
+		return nil, err
+	}
+	return repo.ListClients()
+}
+
+func (r *Router) UpdateClient(c osin.Client) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.UpdateClient(c)
+}
+
+func (r *Router) CreateClient(c osin.Client) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.CreateClient(c)
+}
+
+func (r *Router) RemoveClient(id string) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.RemoveClient(id)
+}
+
+func (r *Router) SaveAuthorize(data *osin.AuthorizeData) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.SaveAuthorize(data)
+}
+
+func (r *Router) LoadAuthorize(code string) (*osin.AuthorizeData, error) {
+	repo, err := r.oauth()
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadAuthorize(code)
+}
+
+func (r *Router) RemoveAuthorize(code string) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.RemoveAuthorize(code)
+}
+
+func (r *Router) SaveAccess(data *osin.AccessData) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.SaveAccess(data)
+}
+
+func (r *Router) LoadAccess(code string) (*osin.AccessData, error) {
+	repo, err := r.oauth()
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadAccess(code)
+}
+
+func (r *Router) RemoveAccess(token string) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.RemoveAccess(token)
+}
+
+func (r *Router) LoadRefresh(token string) (*osin.AccessData, error) {
+	repo, err := r.oauth()
+	if err != nil {
+		return nil, err
+	}
+	return repo.LoadRefresh(token)
+}
+
+func (r *Router) RemoveRefresh(token string) error {
+	repo, err := r.oauth()
+	if err != nil {
+		return err
+	}
+	return repo.RemoveRefresh(token)
+}
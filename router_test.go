@@ -0,0 +1,49 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func TestRouter(t *testing.T) {
+	first, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	second, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r, err := NewRouter(map[string]*repo{"first.example.com": first, "Second.example.com": second}, nil)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %s", err)
+	}
+
+	firstNote, secondNote := vocab.ObjectNew(vocab.NoteType), vocab.ObjectNew(vocab.NoteType)
+	firstNote.ID, secondNote.ID = "https://first.example.com/objects/1", "https://second.example.com/objects/1"
+	for _, ob := range []vocab.Item{firstNote, secondNote} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("Save() of %s error = %s", ob.GetLink(), err)
+		}
+	}
+	if _, err = first.Load(firstNote.ID); err != nil {
+		t.Errorf("Load() from the repository of its host error = %s", err)
+	}
+	if _, err = first.Load(secondNote.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() from the repository of another host error = %v, want NotFound", err)
+	}
+	if it, err := r.Load(secondNote.ID); err != nil || !it.GetLink().Equals(secondNote.ID, false) {
+		t.Errorf("Load() through the router = %v, %v, want %s", it, err, secondNote.ID)
+	}
+
+	unknown := vocab.ObjectNew(vocab.NoteType)
+	unknown.ID = "https://third.example.com/objects/1"
+	if _, err = r.Save(unknown); !errors.IsNotFound(err) {
+		t.Errorf("Save() for an unknown host error = %v, want NotFound", err)
+	}
+	if _, err = r.GetClient("client"); !errors.IsNotFound(err) {
+		t.Errorf("GetClient() without a default repository error = %v, want NotFound", err)
+	}
+}
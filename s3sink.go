@@ -0,0 +1,139 @@
+package badger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-ap/errors"
+)
+
+// S3Sink is a BackupSink storing the backups in a bucket of an S3 compatible object storage, like AWS S3, MinIO,
+// or the ones of the other cloud providers, using path style requests signed with AWS Signature Version 4.
+//
+// The backups are spooled to a temporary file before uploading, for their size and checksum to be signed,
+// so they are limited to the 5GiB of a single PUT request.
+type S3Sink struct {
+	// Endpoint is the URL of the object storage, eg: "https://s3.eu-central-1.amazonaws.com".
+	Endpoint string
+	// Region is the region of the bucket, eg: "eu-central-1". When empty, "us-east-1" is used.
+	Region string
+	// Bucket is the name of the bucket the backups are stored in.
+	Bucket string
+	// Prefix is prepended to the names of the backups, eg: "backups/".
+	Prefix string
+	// AccessKey and SecretKey are the credentials of the requests.
+	AccessKey string
+	SecretKey string
+	// Client is the HTTP client of the requests. When nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// s3TimeFormat is the format of the times of the signed requests.
+const s3TimeFormat = "20060102T150405Z"
+
+// Upload stores the content of r as the object Prefix+name of the Bucket.
+func (s S3Sink) Upload(name string, r io.Reader) error {
+	f, err := os.CreateTemp("", ".s3-upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/") + "/" + s3Escape(s.Bucket+"/"+s.Prefix+name))
+	if err != nil {
+		return errors.NewNotValid(err, "invalid S3 endpoint %s", s.Endpoint)
+	}
+	req, err := http.NewRequest(http.MethodPut, u.String(), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	s.sign(req, hex.EncodeToString(h.Sum(nil)), time.Now().UTC())
+
+	c := s.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return errors.Newf("unable to upload %s to bucket %s: %s %s", name, s.Bucket, res.Status, body)
+	}
+	return nil
+}
+
+// sign adds to req the headers of AWS Signature Version 4, for the payload with the hex encoded SHA-256 checksum.
+func (s S3Sink) sign(req *http.Request, payload string, now time.Time) {
+	region := s.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	date := now.Format(s3TimeFormat)
+	scope := date[:8] + "/" + region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	req.Header.Set("X-Amz-Date", date)
+
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payload,
+		"x-amz-date:" + date,
+		"",
+		signed,
+		payload,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, v := range []string{date[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, v string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(v))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes the path of an object the way the canonical requests of AWS Signature Version 4 expect it,
+// leaving only the unreserved characters, and the separators, unescaped.
+func s3Escape(p string) string {
+	b := strings.Builder{}
+	for _, c := range []byte(p) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', strings.IndexByte("-._~/", c) >= 0:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
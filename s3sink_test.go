@@ -0,0 +1,53 @@
+package badger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestS3Sink_Upload(t *testing.T) {
+	var (
+		gotPath string
+		gotAuth string
+		gotBody []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.EscapedPath(), r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		sum := sha256.Sum256(gotBody)
+		if r.Method != http.MethodPut || r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	sink := S3Sink{Endpoint: srv.URL, Region: "eu-central-1", Bucket: "backups", Prefix: "badger/", AccessKey: "key", SecretKey: "secret"}
+	if err := sink.Upload("badger-1.bak", strings.NewReader("backup")); err != nil {
+		t.Fatalf("Upload() error = %s", err)
+	}
+	if gotPath != "/backups/badger/badger-1.bak" || string(gotBody) != "backup" {
+		t.Errorf("Upload() sent %q to %s, want %q to %s", gotBody, gotPath, "backup", "/backups/badger/badger-1.bak")
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=key/") || !strings.Contains(gotAuth, "/eu-central-1/s3/aws4_request, ") {
+		t.Errorf("Upload() Authorization = %s, want a signature of the key for the region", gotAuth)
+	}
+
+	sink.Bucket = "missing"
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+	})
+	if err := sink.Upload("badger-1.bak", strings.NewReader("backup")); err == nil {
+		t.Errorf("Upload() to a missing bucket didn't fail")
+	}
+}
+
+func Test_s3Escape(t *testing.T) {
+	if got := s3Escape("bucket/a b+c~d.bak"); got != "bucket/a%20b%2Bc~d.bak" {
+		t.Errorf("s3Escape() = %s, want %s", got, "bucket/a%20b%2Bc~d.bak")
+	}
+}
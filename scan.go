@@ -0,0 +1,97 @@
+package badger
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// parallelScanMinKeys is the number of objects from which the decoding of a storage collection scan
+// gets split between multiple goroutines. Under it, the overhead isn't worth it.
+const parallelScanMinKeys = 512
+
+// loadKeys decodes and filters the objects stored at keys, and returns them in the order of the keys.
+// When maxItems is larger than zero, the decoding stops once that many objects have matched, which
+// can't be known in advance across workers, so the keys are loaded sequentially.
+func (r *repo) loadKeys(refs *derefs, keys [][]byte, maxItems int, f Filterable, checks ...filters.Check) vocab.ItemCollection {
+	workers := r.scanWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || maxItems > 0 || len(keys) < parallelScanMinKeys {
+		col := make(vocab.ItemCollection, 0)
+		r.loadKeysInTxn(refs, &col, keys, maxItems, f, checks...)
+		return col
+	}
+
+	// NOTE(marius): the keys are split in contiguous ranges, one for each worker, which loads them
+	// in its own read transaction, as badger's transactions are not safe for concurrent use.
+	// The partial results are merged back in the order of their ranges, and each worker dereferences
+	// the nested properties of its items through its own transaction.
+	size := (len(keys) + workers - 1) / workers
+	results := make([]vocab.ItemCollection, 0, workers)
+	wg := sync.WaitGroup{}
+	for start := 0; start < len(keys); start += size {
+		end := start + size
+		if end > len(keys) {
+			end = len(keys)
+		}
+		results = append(results, make(vocab.ItemCollection, 0, end-start))
+		part := &results[len(results)-1]
+
+		wg.Add(1)
+		go func(part *vocab.ItemCollection, keys [][]byte, f Filterable) {
+			defer wg.Done()
+			err := r.d.View(func(tx *badger.Txn) error {
+				r.loadKeysInTxn(newDerefs(r, tx), part, keys, 0, f, checks...)
+				return nil
+			})
+			if err != nil {
+				r.errFn("unable to load items: %+s", err)
+			}
+		}(part, keys[start:end], copyFilterable(f))
+	}
+	wg.Wait()
+
+	col := make(vocab.ItemCollection, 0, len(keys))
+	for _, part := range results {
+		for _, it := range part {
+			if !col.Contains(it.GetLink()) {
+				col = append(col, it)
+			}
+		}
+	}
+	return col
+}
+
+func (r *repo) loadKeysInTxn(refs *derefs, col *vocab.ItemCollection, keys [][]byte, maxItems int, f Filterable, checks ...filters.Check) {
+	for _, k := range keys {
+		i, err := refs.tx.Get(k)
+		if err != nil {
+			r.errFn("unable to load item %s: %+s", k, err)
+			continue
+		}
+		if err = i.Value(r.loadFromIterator(refs, k, col, maxItems, f, checks...)); err != nil {
+			r.errFn("unable to load item %s: %+s", k, err)
+			continue
+		}
+		if maxItems > 0 && len(*col) >= maxItems {
+			return
+		}
+	}
+}
+
+// copyFilterable returns a copy of the filters which can be used from a different goroutine,
+// as some of their accessors modify them in place.
+func copyFilterable(f Filterable) Filterable {
+	ff, ok := f.(*filters.Filters)
+	if !ok || ff == nil {
+		return f
+	}
+	c := *ff
+	c.InReplTo = append(filters.CompStrs(nil), ff.InReplTo...)
+	return &c
+}
@@ -0,0 +1,53 @@
+package badger
+
+import (
+	"fmt"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Load_ParallelScan(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	count := parallelScanMinKeys + 100
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open badger: %s", err)
+	}
+	for i := 0; i < count; i++ {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = vocab.IRI(fmt.Sprintf("http://example.com/objects/%04d", i))
+		if _, err = save(r, ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+	r.Close()
+
+	load := func(workers int) vocab.ItemCollection {
+		r.scanWorkers = workers
+		res, err := r.Load("http://example.com/objects")
+		if err != nil {
+			t.Fatalf("Load() error = %s", err)
+		}
+		var items vocab.ItemCollection
+		_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+			items = col.Collection()
+			return nil
+		})
+		return items
+	}
+
+	sequential := load(1)
+	parallel := load(4)
+	if len(sequential) != count || len(parallel) != count {
+		t.Fatalf("Load() = %d sequential, %d parallel items, want %d", len(sequential), len(parallel), count)
+	}
+	for i := range sequential {
+		if sequential[i].GetLink() != parallel[i].GetLink() {
+			t.Fatalf("Load() parallel item %d = %s, want %s", i, parallel[i].GetLink(), sequential[i].GetLink())
+		}
+	}
+}
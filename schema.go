@@ -0,0 +1,251 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// schemaVersionKey stores the number of the schema migrations applied to the database.
+// The databases which don't have it predate the migrations, and are at version 0.
+const schemaVersionKey = "__schema_version"
+
+// keyChange is a key written, or removed, by a migration of the key layout.
+type keyChange struct {
+	k, v []byte
+	del  bool
+}
+
+func (c keyChange) apply(b keySetter) error {
+	if c.del {
+		return b.Delete(c.k)
+	}
+	return b.Set(c.k, c.v)
+}
+
+// schemaMigration is a change of the key layout, which creates the keys that the databases written by the previous
+// versions are missing. The changes are computed from the stored objects, so running a migration again is harmless.
+type schemaMigration struct {
+	description string
+	changes     func(r *repo, tx dbTxn) ([]keyChange, error)
+}
+
+// schemaMigrations are the migrations of the key layout, in order. The schema version is the number of them applied.
+var schemaMigrations = []schemaMigration{
+	{description: "create the type keys of the objects", changes: typeKeyChanges},
+	{description: "create the cursor and the membership keys of the collection members", changes: collectionKeyChanges},
+	{description: "create the missing filter index keys", changes: indexKeyChanges},
+}
+
+// SchemaOptions configures MigrateSchema.
+type SchemaOptions struct {
+	// DryRun only counts the keys which the pending migrations would change.
+	DryRun bool
+	// ProgressFn is called every thousand keys changed, and after each migration, with the report so far.
+	ProgressFn func(SchemaReport)
+}
+
+// SchemaReport is the result of MigrateSchema.
+type SchemaReport struct {
+	// Version is the schema version of the database before the migration, and Target the latest one.
+	Version int
+	Target  int
+	// Applied are the descriptions of the migrations applied, or which would be applied in a dry run.
+	Applied []string
+	// Keys is the number of keys changed, or which would be changed in a dry run.
+	Keys int
+}
+
+// SchemaVersion returns the schema version of the database, and the latest one.
+func (r *repo) SchemaVersion() (int, int, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer r.Close()
+
+	version := 0
+	err = r.d.View(func(tx *badger.Txn) error {
+		version, err = loadSchemaVersion(tx)
+		return err
+	})
+	return version, len(schemaMigrations), err
+}
+
+// MigrateSchema applies the pending migrations of the key layout. The schema version is stored after each of them,
+// as a checkpoint, so an interrupted run continues with the migration which didn't complete.
+func (r *repo) MigrateSchema(opt SchemaOptions) (SchemaReport, error) {
+	report := SchemaReport{Target: len(schemaMigrations), Applied: make([]string, 0)}
+	err := r.Open()
+	if err != nil {
+		return report, err
+	}
+	defer r.Close()
+
+	progress := func() {
+		if opt.ProgressFn != nil {
+			opt.ProgressFn(report)
+		}
+	}
+	err = r.d.View(func(tx *badger.Txn) error {
+		report.Version, err = loadSchemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for v := report.Version; v < len(schemaMigrations); v++ {
+		m := schemaMigrations[v]
+		var changes []keyChange
+		err = r.d.View(func(tx *badger.Txn) error {
+			changes, err = m.changes(r, tx)
+			return err
+		})
+		if err != nil {
+			return report, errors.Annotatef(err, "unable to %s", m.description)
+		}
+		report.Applied = append(report.Applied, m.description)
+		if opt.DryRun {
+			report.Keys += len(changes)
+			progress()
+			continue
+		}
+
+		b := r.newWriteBatch()
+		for _, c := range changes {
+			if err = c.apply(b); err != nil {
+				b.Cancel()
+				return report, errors.Annotatef(err, "unable to %s", m.description)
+			}
+			if report.Keys++; report.Keys%importProgressInterval == 0 {
+				progress()
+			}
+		}
+		if err = b.Flush(); err != nil {
+			return report, err
+		}
+		err = r.update(func(tx dbTxn) error {
+			return tx.Set([]byte(schemaVersionKey), []byte(strconv.Itoa(v+1)))
+		})
+		if err != nil {
+			return report, errors.Annotatef(err, "unable to store the schema version")
+		}
+		r.logFn("Schema migration %d: %s, %d keys", v+1, m.description, len(changes))
+		progress()
+	}
+	if len(report.Applied) > 0 && !opt.DryRun {
+		r.invalidateResults()
+	}
+	return report, nil
+}
+
+func loadSchemaVersion(tx dbTxn) (int, error) {
+	i, err := tx.Get([]byte(schemaVersionKey))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	err = i.Value(func(raw []byte) error {
+		version, err = strconv.Atoi(string(raw))
+		return err
+	})
+	if err != nil {
+		return 0, errors.NewNotValid(err, "invalid schema version")
+	}
+	return version, nil
+}
+
+// collectionKeyChanges returns the cursor keys of the members of the collections which don't have any, and the
+// membership keys missing for the members of all the collections.
+func collectionKeyChanges(r *repo, tx dbTxn) ([]keyChange, error) {
+	changes := make([]keyChange, 0)
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		if !isObjectKey(i.Key()) {
+			continue
+		}
+		p := bytes.TrimSuffix(i.KeyCopy(nil), append(sep, objectKey...))
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil {
+				return err
+			}
+			iris, ok := collectionIRIs(ob)
+			if !ok || len(iris) == 0 {
+				return nil
+			}
+			col, colErr := collectionIRI(tx, p)
+			cursored := lastCursorSeq(tx, p) > 0
+			for n, iri := range iris {
+				if !cursored {
+					seq := uint64(n + 1)
+					changes = append(changes,
+						keyChange{k: getCursorKey(p, seq), v: []byte(iri)},
+						keyChange{k: getCursorPosKey(p, iri), v: binary.BigEndian.AppendUint64(nil, seq)},
+					)
+				}
+				if mk := getMemberOfKey(itemPath(iri), p); colErr == nil && !keyExists(tx, mk) {
+					changes = append(changes, keyChange{k: mk, v: []byte(col)})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			r.errFn("unable to load %s: %+s", i.Key(), err)
+		}
+	}
+	return changes, nil
+}
+
+// indexKeyChanges returns the filter index keys missing for the stored objects.
+func indexKeyChanges(r *repo, tx dbTxn) ([]keyChange, error) {
+	changes := make([]keyChange, 0)
+	if len(r.indexes) == 0 {
+		return changes, nil
+	}
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		if !isObjectKey(i.Key()) {
+			continue
+		}
+		p := bytes.TrimSuffix(i.KeyCopy(nil), append(sep, objectKey...))
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil || vocab.IsNil(ob) || !ob.IsObject() {
+				return err
+			}
+			if _, ok := collectionIRIs(ob); ok {
+				return nil
+			}
+			for _, idx := range r.indexes {
+				for _, v := range idx.Values(ob) {
+					if k := getIndexKey(idx.Name(), v, p); !keyExists(tx, k) {
+						changes = append(changes, keyChange{k: k})
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			r.errFn("unable to load %s: %+s", i.Key(), err)
+		}
+	}
+	return changes, nil
+}
+
+func keyExists(tx dbTxn, k []byte) bool {
+	_, err := tx.Get(k)
+	return err == nil
+}
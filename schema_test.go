@@ -0,0 +1,64 @@
+package badger
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_MigrateSchema(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	// NOTE(marius): an object stored without its type key, like the versions predating them did.
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	raw, _ := encodeItemFn(note)
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getObjectKey(itemPath(note.ID)), raw)
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store untagged object: %s", err)
+	}
+
+	if v, target, err := r.SchemaVersion(); err != nil || v != 0 || target != len(schemaMigrations) {
+		t.Errorf("SchemaVersion() = %d, %d, %v, want 0, %d", v, target, err, len(schemaMigrations))
+	}
+
+	dry, err := r.MigrateSchema(SchemaOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("MigrateSchema() dry run error = %s", err)
+	}
+	if len(dry.Applied) != len(schemaMigrations) || dry.Keys != 1 {
+		t.Errorf("MigrateSchema() dry run = %+v, want all migrations pending and 1 key", dry)
+	}
+	if v, _, _ := r.SchemaVersion(); v != 0 {
+		t.Errorf("SchemaVersion() after a dry run = %d, want 0", v)
+	}
+
+	progress := 0
+	report, err := r.MigrateSchema(SchemaOptions{ProgressFn: func(SchemaReport) { progress++ }})
+	if err != nil {
+		t.Fatalf("MigrateSchema() error = %s", err)
+	}
+	if report.Keys != dry.Keys || progress != len(schemaMigrations) {
+		t.Errorf("MigrateSchema() = %+v with %d progress calls, want %d keys", report, progress, dry.Keys)
+	}
+	if v, _, _ := r.SchemaVersion(); v != len(schemaMigrations) {
+		t.Errorf("SchemaVersion() after migrating = %d, want %d", v, len(schemaMigrations))
+	}
+	if _, err = r.Load("http://example.com/objects?type=Note"); err != nil {
+		t.Errorf("Load() by type after migrating error = %s", err)
+	}
+
+	if report, _ = r.MigrateSchema(SchemaOptions{}); len(report.Applied) != 0 || report.Keys != 0 {
+		t.Errorf("MigrateSchema() second run = %+v, want nothing pending", report)
+	}
+}
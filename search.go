@@ -0,0 +1,151 @@
+package badger
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+// searchSnippetContext is the number of characters kept around the match in the snippets of Search.
+const searchSnippetContext = 30
+
+// SearchResult is an object found by Search.
+type SearchResult struct {
+	IRI vocab.IRI
+	// Field is the property that matched: id, preferredUsername, name, summary or content.
+	Field string
+	// Snippet is the part of the property around the match.
+	Snippet string
+}
+
+// Search returns the objects whose IRI, preferred username, name, summary or content contains q, ignoring case,
+// at most limit of them, a limit lower than 1 meaning all of them.
+//
+// When the actor name index is maintained, the actors having a name starting with q are found through it first.
+// The other objects are found by decoding all of them.
+func (r *repo) Search(q string, limit int) ([]SearchResult, error) {
+	q = strings.ToLower(strings.TrimSpace(q))
+	results := make([]SearchResult, 0)
+	if len(q) == 0 {
+		return results, nil
+	}
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	full := func() bool {
+		return limit > 0 && len(results) >= limit
+	}
+	found := make(map[vocab.IRI]struct{})
+	err = r.d.View(func(tx *badger.Txn) error {
+		if hasIndex(r.indexes, ActorNameIndex{}.Name()) {
+			// NOTE(marius): the stale index keys are skipped here, and only removed by FindActors.
+			actors, _ := r.findActorsInIndex(tx, q, limit)
+			for _, act := range actors {
+				if res, ok := searchItem(act, q); ok {
+					results = append(results, res)
+					found[res.IRI] = struct{}{}
+				}
+			}
+		}
+
+		it := tx.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid() && !full(); it.Next() {
+			i := it.Item()
+			if !isObjectKey(i.Key()) {
+				continue
+			}
+			err := i.Value(func(raw []byte) error {
+				ob, err := loadItem(raw)
+				if err != nil || vocab.IsNil(ob) || !ob.IsObject() {
+					return err
+				}
+				if _, ok := found[ob.GetLink()]; ok {
+					return nil
+				}
+				if res, ok := searchItem(ob, q); ok {
+					results = append(results, res)
+				}
+				return nil
+			})
+			if err != nil {
+				r.errFn("unable to load %s: %+s", i.Key(), err)
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+// searchItem returns the first property of it which contains q.
+func searchItem(it vocab.Item, q string) (SearchResult, bool) {
+	res := SearchResult{IRI: it.GetLink()}
+	match := func(field string, values ...string) bool {
+		for _, v := range values {
+			if snippet, ok := searchSnippet(v, q); ok {
+				res.Field, res.Snippet = field, snippet
+				return true
+			}
+		}
+		return false
+	}
+	nlValues := func(nl vocab.NaturalLanguageValues) []string {
+		values := make([]string, 0, len(nl))
+		for _, v := range nl {
+			values = append(values, v.String())
+		}
+		return values
+	}
+
+	if match("id", res.IRI.String()) {
+		return res, true
+	}
+	if vocab.ActorTypes.Contains(it.GetType()) {
+		var names []string
+		_ = vocab.OnActor(it, func(a *vocab.Actor) error {
+			names = nlValues(a.PreferredUsername)
+			return nil
+		})
+		if match("preferredUsername", names...) {
+			return res, true
+		}
+	}
+	ok := false
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		ok = match("name", nlValues(o.Name)...) ||
+			match("summary", nlValues(o.Summary)...) ||
+			match("content", nlValues(o.Content)...)
+		return nil
+	})
+	return res, ok
+}
+
+// searchSnippet returns the part of s around the first occurrence of the lower cased q, ignoring case.
+func searchSnippet(s, q string) (string, bool) {
+	rs := []rune(s)
+	lower := make([]rune, len(rs))
+	for i, c := range rs {
+		lower[i] = unicode.ToLower(c)
+	}
+	pos := strings.Index(string(lower), q)
+	if pos < 0 {
+		return "", false
+	}
+	start := len([]rune(string(lower)[:pos]))
+	end := start + len([]rune(q))
+
+	from, to := start-searchSnippetContext, end+searchSnippetContext
+	prefix, suffix := "…", "…"
+	if from <= 0 {
+		from, prefix = 0, ""
+	}
+	if to >= len(rs) {
+		to, suffix = len(rs), ""
+	}
+	return prefix + strings.Join(strings.Fields(string(rs[from:to])), " ") + suffix, true
+}
@@ -0,0 +1,48 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Search(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.indexes = DefaultIndexes
+
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.PreferredUsername = vocab.DefaultNaturalLanguageValue("jdoe")
+	jdoe.Name = vocab.DefaultNaturalLanguageValue("Marigold Doe")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	note.Content = vocab.DefaultNaturalLanguageValue("The marigolds in the garden have bloomed early this year, which is unusual")
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	results, err := r.Search("Marigold", 0)
+	if err != nil {
+		t.Fatalf("Search() error = %s", err)
+	}
+	if len(results) != 2 || results[0].IRI != jdoe.ID || results[0].Field != "name" {
+		t.Fatalf("Search() = %+v, want the actor first, then the note", results)
+	}
+	if results[1].IRI != note.ID || results[1].Field != "content" || results[1].Snippet != "The marigolds in the garden have bloomed e…" {
+		t.Errorf("Search() = %+v, want a snippet of the content of the note", results[1])
+	}
+
+	if results, _ = r.Search("objects/1", 0); len(results) != 1 || results[0].Field != "id" {
+		t.Errorf("Search() by IRI = %+v, want the note", results)
+	}
+	if results, _ = r.Search("garden", 1); len(results) != 1 {
+		t.Errorf("Search() with limit = %+v, want 1 result", results)
+	}
+	if results, _ = r.Search("nothing like it", 0); len(results) != 0 {
+		t.Errorf("Search() = %+v, want no results", results)
+	}
+}
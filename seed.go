@@ -0,0 +1,143 @@
+package badger
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// seedPersons are the preferred usernames of the persons created by Seed.
+var seedPersons = []string{"alice", "bob"}
+
+// BootstrapWithSeed bootstraps the storage like Bootstrap, and seeds it with the demo data of Seed, under base.
+func BootstrapWithSeed(conf Config, base vocab.IRI) error {
+	if err := Bootstrap(conf); err != nil {
+		return err
+	}
+	r, err := New(conf)
+	if err != nil {
+		return err
+	}
+	_, err = r.Seed(base)
+	return err
+}
+
+// Seed creates demo data in an empty storage, for the development and the integration-test environments:
+// a service actor at base, the alice and bob persons under base/actors, a public note created by each of them,
+// and each of them following the other one. It returns the saved items.
+//
+// It fails when the storage already contains objects, so it can't replace the data of a real instance.
+func (r *repo) Seed(base vocab.IRI) (vocab.ItemCollection, error) {
+	if len(itemPath(base)) == 0 {
+		return nil, errors.NotValidf("invalid base IRI %s", base)
+	}
+	if err := r.checkEmpty(); err != nil {
+		return nil, err
+	}
+
+	seeded := make(vocab.ItemCollection, 0)
+	saved := func(it vocab.Item, err error) error {
+		if err != nil {
+			return errors.Annotatef(err, "unable to seed the demo data")
+		}
+		seeded = append(seeded, it)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	service := vocab.ServiceNew(base)
+	service.Name = vocab.DefaultNaturalLanguageValue("Demo service")
+	service.Inbox = vocab.Inbox.IRI(service)
+	service.Outbox = vocab.Outbox.IRI(service)
+	service.Published = now
+	if err := saved(service, r.CreateService(service)); err != nil {
+		return seeded, err
+	}
+
+	persons := make([]*vocab.Person, 0, len(seedPersons))
+	for _, name := range seedPersons {
+		p := vocab.PersonNew(base.AddPath("actors", name))
+		p.PreferredUsername = vocab.DefaultNaturalLanguageValue(name)
+		p.Name = vocab.DefaultNaturalLanguageValue(name)
+		p.AttributedTo = service.GetLink()
+		p.Inbox = vocab.Inbox.IRI(p)
+		p.Outbox = vocab.Outbox.IRI(p)
+		p.Followers = vocab.Followers.IRI(p)
+		p.Following = vocab.Following.IRI(p)
+		p.Liked = vocab.Liked.IRI(p)
+		p.Published = now
+		if err := saved(r.Save(p)); err != nil {
+			return seeded, err
+		}
+		persons = append(persons, p)
+	}
+
+	activities := 0
+	activity := func(typ vocab.ActivityVocabularyType, actor *vocab.Person, ob vocab.Item, to ...vocab.Item) error {
+		activities++
+		act := vocab.ActivityNew(base.AddPath("activities", fmt.Sprintf("%d", activities)), typ, ob)
+		act.Actor = actor.GetLink()
+		act.To = to
+		act.Published = now
+		if err := saved(r.Save(act)); err != nil {
+			return err
+		}
+		return r.AddTo(actor.Outbox.GetLink(), act.GetLink())
+	}
+
+	for i, p := range persons {
+		note := vocab.ObjectNew(vocab.NoteType)
+		note.ID = base.AddPath("objects", fmt.Sprintf("%d", i+1))
+		note.AttributedTo = p.GetLink()
+		note.Content = vocab.DefaultNaturalLanguageValue(fmt.Sprintf("Hello, I'm %s!", seedPersons[i]))
+		note.To = vocab.ItemCollection{vocab.PublicNS}
+		note.CC = vocab.ItemCollection{p.Followers.GetLink()}
+		note.Published = now
+		if err := saved(r.Save(note)); err != nil {
+			return seeded, err
+		}
+		if err := activity(vocab.CreateType, p, note.GetLink(), vocab.PublicNS, p.Followers.GetLink()); err != nil {
+			return seeded, err
+		}
+	}
+
+	for i, p := range persons {
+		followed := persons[(i+1)%len(persons)]
+		if err := activity(vocab.FollowType, p, followed.GetLink(), followed.GetLink()); err != nil {
+			return seeded, err
+		}
+		if err := r.AddTo(p.Following.GetLink(), followed.GetLink()); err != nil {
+			return seeded, err
+		}
+		if err := r.AddTo(followed.Followers.GetLink(), p.GetLink()); err != nil {
+			return seeded, err
+		}
+	}
+	r.logFn("Seeded %d items under %s", len(seeded), base)
+	return seeded, nil
+}
+
+// checkEmpty returns an error when the storage contains any object.
+func (r *repo) checkEmpty() error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.d.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if isObjectKey(it.Item().Key()) {
+				return errors.NotValidf("the storage is not empty, it contains %s", it.Item().Key())
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_Seed(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	base := vocab.IRI("https://example.com")
+	seeded, err := r.Seed(base)
+	if err != nil {
+		t.Fatalf("Seed() error = %s", err)
+	}
+	// NOTE(marius): the service, two persons, their notes, and a Create and a Follow activity for each of them.
+	if len(seeded) != 9 {
+		t.Errorf("Seed() saved %d items, want 9", len(seeded))
+	}
+	if _, err = r.Load(base); err != nil {
+		t.Errorf("Load() of the service error = %s", err)
+	}
+	if items := loadIRIs(t, r, "https://example.com/actors/alice/outbox"); len(items) != 2 {
+		t.Errorf("Load() of the outbox of alice = %v, want a Create and a Follow", items)
+	}
+	if items := loadIRIs(t, r, "https://example.com/actors/bob/followers"); !items.Contains("https://example.com/actors/alice") {
+		t.Errorf("Load() of the followers of bob = %v, want alice", items)
+	}
+
+	if _, err = r.Seed(base); !errors.IsNotValid(err) {
+		t.Errorf("Seed() of a storage which is not empty error = %v, want NotValid", err)
+	}
+}
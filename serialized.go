@@ -0,0 +1,50 @@
+package badger
+
+import (
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// LoadJSON returns the JSON encoding of the item, or the collection of items, found at the IRI, with the same
+// semantics as Load.
+//
+// The collection pages loaded without a requester, or on behalf of the public, are kept encoded when requested
+// repeatedly, so they can be returned again without reading the database or encoding them.
+func (r *repo) LoadJSON(i vocab.IRI, checks ...filters.Check) ([]byte, error) {
+	bypass, keyChecks := bypassCache(checks)
+	key, cacheable := resultCacheKey(i, keyChecks)
+	cacheable = cacheable && r.serialized != nil && isAnonymous(checks) && !isItemIRI(i)
+	if cacheable && !bypass {
+		if raw := r.serialized.Get(key); raw != nil {
+			return raw, nil
+		}
+	}
+	it, err := r.Load(i, checks...)
+	if err != nil && !IsTruncated(err) {
+		return nil, err
+	}
+	raw, encErr := encodeItemFn(it)
+	if encErr != nil {
+		return nil, encErr
+	}
+	if cacheable && err == nil {
+		r.serialized.Set(key, raw)
+		r.embedded.Add(key, embeddedIRIs(it)...)
+	}
+	return raw, err
+}
+
+// isAnonymous returns if the checks don't scope the loaded items to a requester other than the public.
+func isAnonymous(checks filters.Checks) bool {
+	for _, c := range filters.AuthorizedChecks(checks...) {
+		if c != filters.Authorized(vocab.PublicNS) && c != filters.Authorized("") {
+			return false
+		}
+	}
+	return true
+}
+
+func isItemIRI(i vocab.IRI) bool {
+	f, err := filters.FiltersFromIRI(i)
+	return err == nil && f.IsItemIRI()
+}
@@ -0,0 +1,77 @@
+package badger
+
+import (
+	"bytes"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+func Test_repo_LoadJSON(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.serialized = cache.NewSerialized(10)
+
+	outbox := vocab.IRI("http://example.com/actors/jdoe/outbox")
+	if _, err = r.Create(&vocab.OrderedCollection{ID: outbox, Type: vocab.OrderedCollectionType}); err != nil {
+		t.Fatalf("unable to create %s: %s", outbox, err)
+	}
+	first := vocab.ObjectNew(vocab.NoteType)
+	first.ID = "http://example.com/objects/1"
+	first.To = vocab.ItemCollection{vocab.PublicNS}
+	if _, err = r.Save(first); err != nil {
+		t.Fatalf("unable to save %s: %s", first.ID, err)
+	}
+	if err = r.AddTo(outbox, first); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", first.ID, outbox, err)
+	}
+
+	public := filters.Authorized(vocab.PublicNS)
+	key, _ := resultCacheKey(outbox, filters.Checks{public})
+	loadJSON := func(checks ...filters.Check) []byte {
+		raw, err := r.LoadJSON(outbox, checks...)
+		if err != nil {
+			t.Fatalf("LoadJSON() error = %s", err)
+		}
+		return raw
+	}
+
+	raw := loadJSON(public)
+	if !bytes.Contains(raw, []byte(first.ID)) {
+		t.Errorf("LoadJSON() = %s, doesn't contain %s", raw, first.ID)
+	}
+	if r.serialized.Get(key) != nil {
+		t.Errorf("LoadJSON() cached the page after the first request")
+	}
+	_ = loadJSON(public)
+	if cached := r.serialized.Get(key); !bytes.Equal(cached, raw) {
+		t.Errorf("LoadJSON() cached %s after the second request, want %s", cached, raw)
+	}
+
+	second := vocab.ObjectNew(vocab.NoteType)
+	second.ID = "http://example.com/objects/2"
+	second.To = vocab.ItemCollection{vocab.PublicNS}
+	if _, err = r.Save(second); err != nil {
+		t.Fatalf("unable to save %s: %s", second.ID, err)
+	}
+	if err = r.AddTo(outbox, second); err != nil {
+		t.Fatalf("unable to add %s to %s: %s", second.ID, outbox, err)
+	}
+	if r.serialized.Get(key) != nil {
+		t.Errorf("LoadJSON() page is still cached after AddTo")
+	}
+	if raw = loadJSON(public); !bytes.Contains(raw, []byte(second.ID)) {
+		t.Errorf("LoadJSON() after AddTo = %s, doesn't contain %s", raw, second.ID)
+	}
+
+	jdoe := filters.Authorized("http://example.com/actors/jdoe")
+	_ = loadJSON(jdoe)
+	_ = loadJSON(jdoe)
+	if key, _ := resultCacheKey(outbox, filters.Checks{jdoe}); r.serialized.Get(key) != nil {
+		t.Errorf("LoadJSON() cached the page loaded on behalf of a requester")
+	}
+}
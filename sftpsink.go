@@ -0,0 +1,237 @@
+package badger
+
+import (
+	"encoding/binary"
+	"io"
+	"path"
+
+	"github.com/go-ap/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTPSink is a BackupSink storing the backups in a directory of an SFTP server.
+// The backups are written to a temporary file first, and renamed once complete, so a failed upload
+// doesn't leave a partial backup behind.
+type SFTPSink struct {
+	// Addr is the address of the SSH server, eg: "backups.example.com:22".
+	Addr string
+	// Config is the configuration of the SSH client, with the user, the authentication methods,
+	// and the HostKeyCallback checking the key of the server.
+	Config *ssh.ClientConfig
+	// Path is the directory of the server where the backups are stored, which needs to exist.
+	Path string
+}
+
+// Upload writes the content of r to the file name in the directory of the server.
+func (s SFTPSink) Upload(name string, r io.Reader) error {
+	if s.Config == nil {
+		return errors.NotValidf("nil SSH client config for %s", s.Addr)
+	}
+	client, err := ssh.Dial("tcp", s.Addr, s.Config)
+	if err != nil {
+		return errors.Annotatef(err, "unable to connect to %s", s.Addr)
+	}
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	w, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	rd, err := sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = sess.RequestSubsystem("sftp"); err != nil {
+		return errors.Annotatef(err, "unable to start the sftp subsystem of %s", s.Addr)
+	}
+	c := sftpConn{w: w, r: rd}
+	if err = c.init(); err != nil {
+		return err
+	}
+
+	dst := path.Join(s.Path, name)
+	tmp := path.Join(s.Path, ".upload-"+name)
+	if err = c.put(tmp, r); err != nil {
+		_ = c.remove(tmp)
+		return errors.Annotatef(err, "unable to upload %s to %s", name, s.Addr)
+	}
+	if err = c.rename(tmp, dst); err != nil {
+		_ = c.remove(tmp)
+		return errors.Annotatef(err, "unable to rename %s on %s", name, s.Addr)
+	}
+	return nil
+}
+
+// The packet types, and the flags, of the version 3 of the SFTP protocol used by the SFTPSink.
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpWrite   = 6
+	sftpRemove  = 13
+	sftpRename  = 18
+	sftpStatus  = 101
+	sftpHandle  = 102
+
+	sftpFlagWrite    = 0x02
+	sftpFlagCreate   = 0x08
+	sftpFlagTruncate = 0x10
+
+	sftpStatusOK = 0
+
+	// sftpChunkSize is the size of the data written by each request, which the servers are required to accept.
+	sftpChunkSize = 32 * 1024
+	// sftpMaxPacket is the size of the largest response accepted from the server.
+	sftpMaxPacket = 256 * 1024
+)
+
+// sftpConn is a minimal client of the SFTP protocol, which sends its requests one at a time.
+type sftpConn struct {
+	w  io.Writer
+	r  io.Reader
+	id uint32
+}
+
+func (c *sftpConn) init() error {
+	if err := c.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return err
+	}
+	typ, _, err := c.recv()
+	if err != nil {
+		return err
+	}
+	if typ != sftpVersion {
+		return errors.Newf("unexpected sftp packet %d, instead of the version", typ)
+	}
+	return nil
+}
+
+// put writes the content of r to the file p, replacing it if it exists.
+func (c *sftpConn) put(p string, r io.Reader) error {
+	req := sftpString(nil, p)
+	req = binary.BigEndian.AppendUint32(req, sftpFlagWrite|sftpFlagCreate|sftpFlagTruncate)
+	// NOTE(marius): the attributes of the new file, without any of their flags set.
+	req = binary.BigEndian.AppendUint32(req, 0)
+	typ, res, err := c.request(sftpOpen, req)
+	if err != nil {
+		return err
+	}
+	if typ != sftpHandle {
+		return sftpErr(typ, res)
+	}
+	handle, _ := sftpReadString(res)
+
+	buf := make([]byte, sftpChunkSize)
+	var off uint64
+	for {
+		n, rErr := io.ReadFull(r, buf)
+		if n > 0 {
+			req = sftpString(nil, string(handle))
+			req = binary.BigEndian.AppendUint64(req, off)
+			req = sftpString(req, string(buf[:n]))
+			if err = c.status(sftpWrite, req); err != nil {
+				return err
+			}
+			off += uint64(n)
+		}
+		if rErr == io.EOF || rErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rErr != nil {
+			return rErr
+		}
+	}
+	return c.status(sftpClose, sftpString(nil, string(handle)))
+}
+
+func (c *sftpConn) rename(from, to string) error {
+	return c.status(sftpRename, sftpString(sftpString(nil, from), to))
+}
+
+func (c *sftpConn) remove(p string) error {
+	return c.status(sftpRemove, sftpString(nil, p))
+}
+
+// status sends the request, and returns the error of the status received for it.
+func (c *sftpConn) status(typ byte, payload []byte) error {
+	typ, res, err := c.request(typ, payload)
+	if err != nil {
+		return err
+	}
+	return sftpErr(typ, res)
+}
+
+// request sends the request with a new id, and returns the type and the payload of its response, without the id.
+func (c *sftpConn) request(typ byte, payload []byte) (byte, []byte, error) {
+	c.id++
+	if err := c.send(typ, append(binary.BigEndian.AppendUint32(nil, c.id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	resTyp, res, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(res) < 4 || binary.BigEndian.Uint32(res) != c.id {
+		return 0, nil, errors.Newf("unexpected sftp response for request %d", c.id)
+	}
+	return resTyp, res[4:], nil
+}
+
+func (c *sftpConn) send(typ byte, payload []byte) error {
+	pkt := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(payload)), uint32(len(payload)+1))
+	pkt = append(append(pkt, typ), payload...)
+	_, err := c.w.Write(pkt)
+	return err
+}
+
+func (c *sftpConn) recv() (byte, []byte, error) {
+	var l [4]byte
+	if _, err := io.ReadFull(c.r, l[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(l[:])
+	if n == 0 || n > sftpMaxPacket {
+		return 0, nil, errors.Newf("invalid sftp packet length %d", n)
+	}
+	pkt := make([]byte, n)
+	if _, err := io.ReadFull(c.r, pkt); err != nil {
+		return 0, nil, err
+	}
+	return pkt[0], pkt[1:], nil
+}
+
+// sftpErr returns the error of a status response, or of an unexpected response type.
+func sftpErr(typ byte, res []byte) error {
+	if typ != sftpStatus {
+		return errors.Newf("unexpected sftp packet %d", typ)
+	}
+	if len(res) < 4 {
+		return errors.Newf("invalid sftp status")
+	}
+	if code := binary.BigEndian.Uint32(res); code != sftpStatusOK {
+		msg, _ := sftpReadString(res[4:])
+		return errors.Newf("sftp error %d: %s", code, msg)
+	}
+	return nil
+}
+
+func sftpString(b []byte, s string) []byte {
+	return append(binary.BigEndian.AppendUint32(b, uint32(len(s))), s...)
+}
+
+func sftpReadString(b []byte) ([]byte, []byte) {
+	if len(b) < 4 {
+		return nil, nil
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil
+	}
+	return b[4 : 4+n], b[4+n:]
+}
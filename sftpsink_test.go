@@ -0,0 +1,152 @@
+package badger
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"maps"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpServer is an SSH server accepting any password, whose sftp subsystem stores the written files in memory.
+type sftpServer struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	ln    net.Listener
+}
+
+func newSFTPServer(t *testing.T) *sftpServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate the host key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("unable to create the host key signer: %s", err)
+	}
+	conf := ssh.ServerConfig{PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) { return nil, nil }}
+	conf.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %s", err)
+	}
+	s := sftpServer{files: make(map[string][]byte), ln: ln}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc, &conf)
+		}
+	}()
+	t.Cleanup(func() { _ = ln.Close() })
+	return &s
+}
+
+func (s *sftpServer) serve(nc net.Conn, conf *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, conf)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		ch, reqs, err := nch.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range reqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+				if req.Type == "subsystem" {
+					go s.sftp(ch)
+				}
+			}
+		}()
+	}
+}
+
+// sftp handles the requests sent by the sftpConn of the SFTPSink.
+func (s *sftpServer) sftp(ch ssh.Channel) {
+	defer ch.Close()
+	c := sftpConn{w: ch, r: ch}
+	handles := make(map[string]string)
+	for {
+		typ, pkt, err := c.recv()
+		if err != nil {
+			return
+		}
+		if typ == sftpInit {
+			_ = c.send(sftpVersion, binary.BigEndian.AppendUint32(nil, 3))
+			continue
+		}
+		id, pkt := pkt[:4], pkt[4:]
+		status := func(code uint32) {
+			_ = c.send(sftpStatus, sftpString(binary.BigEndian.AppendUint32(append([]byte{}, id...), code), "status"))
+		}
+		name, rest := sftpReadString(pkt)
+		s.mu.Lock()
+		switch typ {
+		case sftpOpen:
+			h := string(name) + ".handle"
+			handles[h] = string(name)
+			s.files[string(name)] = nil
+			_ = c.send(sftpHandle, sftpString(append([]byte{}, id...), h))
+		case sftpWrite:
+			data, _ := sftpReadString(rest[8:])
+			f := handles[string(name)]
+			s.files[f] = append(s.files[f], data...)
+			status(sftpStatusOK)
+		case sftpRename:
+			to, _ := sftpReadString(rest)
+			s.files[string(to)] = s.files[string(name)]
+			maps.DeleteFunc(s.files, func(k string, _ []byte) bool { return k == string(name) })
+			status(sftpStatusOK)
+		case sftpClose, sftpRemove:
+			status(sftpStatusOK)
+		default:
+			status(8)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestSFTPSink_Upload(t *testing.T) {
+	srv := newSFTPServer(t)
+	sink := SFTPSink{
+		Addr: srv.ln.Addr().String(),
+		Config: &ssh.ClientConfig{
+			User:            "backup",
+			Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		},
+		Path: "/backups",
+	}
+	// NOTE(marius): the backup is larger than the data of a single write request.
+	backup := strings.Repeat("backup", sftpChunkSize/3)
+	if err := sink.Upload("badger-1.bak", strings.NewReader(backup)); err != nil {
+		t.Fatalf("Upload() error = %s", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if got := srv.files["/backups/badger-1.bak"]; string(got) != backup {
+		t.Errorf("Upload() stored %d bytes, want %d", len(got), len(backup))
+	}
+	if len(srv.files) != 1 {
+		t.Errorf("Upload() left %d files, want only the backup", len(srv.files))
+	}
+}
+
+func TestSFTPSink_Upload_Failed(t *testing.T) {
+	sink := SFTPSink{Addr: "127.0.0.1:0", Config: &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()}}
+	if err := sink.Upload("badger-1.bak", io.LimitReader(rand.Reader, 10)); err == nil {
+		t.Errorf("Upload() to an unreachable server didn't fail")
+	}
+}
@@ -0,0 +1,295 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// shellKeysLimit is the maximum number of keys listed by the keys command of the Shell.
+const shellKeysLimit = 100
+
+// Shell runs the commands of the interactive storage shell of the control CLI, which reads the lines and
+// completes them with a line editor, for exploratory debugging against a copy of the storage.
+type Shell struct {
+	r *repo
+	w io.Writer
+}
+
+type shellCommand struct {
+	usage string
+	help  string
+	// args is the number of arguments, the last one receiving the rest of the line.
+	args int
+	// optional is set when the last argument can be missing.
+	optional bool
+	// keys is set for the commands whose arguments are keys, instead of IRIs.
+	keys bool
+	run  func(s *Shell, args []string) error
+}
+
+var shellCommands = map[string]shellCommand{
+	"load": {
+		usage: "load <iri>", help: "loads the object or the collection at the IRI, which can have filters as query",
+		args: 1, run: (*Shell).load,
+	},
+	"filter": {
+		usage: "filter <iri> <query>", help: "loads the collection at the IRI, filtered by the query, like type=Note",
+		args: 2, run: (*Shell).filter,
+	},
+	"save": {
+		usage: "save <json>", help: "saves the object in the JSON document",
+		args: 1, run: (*Shell).save,
+	},
+	"addto": {
+		usage: "addto <collection> <iri>", help: "adds the IRI to the collection",
+		args: 2, run: (*Shell).addTo,
+	},
+	"keys": {
+		usage: "keys [prefix]", help: "lists the keys starting with the prefix",
+		args: 1, optional: true, keys: true, run: (*Shell).keys,
+	},
+	"get": {
+		usage: "get <key>", help: "shows the raw value of the key",
+		args: 1, keys: true, run: (*Shell).get,
+	},
+}
+
+// shellHelp is the usage of the help command, which is not in shellCommands, as it lists them.
+const shellHelp = "help"
+
+// NewShell returns a Shell writing the results of the commands to w.
+func NewShell(r *repo, w io.Writer) *Shell {
+	return &Shell{r: r, w: w}
+}
+
+// Exec runs the command of the line. The empty lines are ignored.
+func (s *Shell) Exec(line string) error {
+	name, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if name == "" {
+		return nil
+	}
+	if name == shellHelp {
+		return s.help()
+	}
+	cmd, ok := shellCommands[name]
+	if !ok {
+		return errors.NotValidf("unknown command %q, see help", name)
+	}
+	args := make([]string, 0, cmd.args)
+	for rest = strings.TrimSpace(rest); rest != "" && len(args) < cmd.args; rest = strings.TrimSpace(rest) {
+		if len(args) == cmd.args-1 {
+			args, rest = append(args, rest), ""
+			break
+		}
+		var arg string
+		arg, rest, _ = strings.Cut(rest, " ")
+		args = append(args, arg)
+	}
+	if len(args) < cmd.args && !(cmd.optional && len(args) == cmd.args-1) {
+		return errors.NotValidf("usage: %s", cmd.usage)
+	}
+	return cmd.run(s, args)
+}
+
+// Complete returns the completions of the line: the names of the commands for its first word, and the keys,
+// or the IRIs, starting with its last word otherwise.
+func (s *Shell) Complete(line string) ([]string, error) {
+	name, rest, hasArgs := strings.Cut(strings.TrimLeft(line, " "), " ")
+	if !hasArgs {
+		completions := make([]string, 0)
+		if strings.HasPrefix(shellHelp, name) {
+			completions = append(completions, shellHelp+" ")
+		}
+		for n := range shellCommands {
+			if strings.HasPrefix(n, name) {
+				completions = append(completions, n+" ")
+			}
+		}
+		sort.Strings(completions)
+		return completions, nil
+	}
+	cmd, ok := shellCommands[name]
+	if !ok {
+		return nil, nil
+	}
+	start := line[:len(line)-len(rest)]
+	word := rest
+	if i := strings.LastIndexByte(rest, ' '); i >= 0 {
+		start, word = line[:len(line)-len(rest)+i+1], rest[i+1:]
+	}
+
+	scheme := ""
+	prefix := word
+	if !cmd.keys {
+		if i := strings.Index(word, "://"); i > 0 {
+			scheme, prefix = word[:i+3], word[i+3:]
+		}
+	}
+	keys, err := s.r.keyCompletions(prefix)
+	if err != nil {
+		return nil, err
+	}
+	completions := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if !cmd.keys {
+			// NOTE(marius): the IRIs are completed with the paths of the objects, skipping the internal keys.
+			if !isPathKey(k) {
+				continue
+			}
+			k = strings.TrimSuffix(k, "/")
+		}
+		completions = append(completions, start+scheme+k)
+	}
+	return completions, nil
+}
+
+// keyCompletions returns the keys starting with prefix, with the keys having another segment after it grouped
+// by their next segment, like in the listings of the browse handler.
+func (r *repo) keyCompletions(prefix string) ([]string, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	page := browsePage{}
+	err = r.d.View(func(tx *badger.Txn) error {
+		browseList(tx, prefix, "", &page)
+		return nil
+	})
+	keys := make([]string, 0, len(page.Entries))
+	for _, e := range page.Entries {
+		if e.Key != "" {
+			keys = append(keys, e.Key)
+		} else {
+			keys = append(keys, e.Prefix)
+		}
+	}
+	return keys, err
+}
+
+// isPathKey returns whether the key, or the prefix of keys, is the path of an object, and not an internal key,
+// or the key of the OAuth data.
+func isPathKey(k string) bool {
+	if strings.HasPrefix(k, folder+string(sep)) || strings.ContainsRune(k, indexValueSep) {
+		return false
+	}
+	for _, segment := range strings.Split(k, string(sep)) {
+		if strings.HasPrefix(segment, "__") {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Shell) load(args []string) error {
+	it, err := s.r.Load(vocab.IRI(args[0]))
+	if err != nil {
+		return err
+	}
+	return s.printItem(it)
+}
+
+func (s *Shell) filter(args []string) error {
+	return s.load([]string{args[0] + "?" + strings.TrimPrefix(args[1], "?")})
+}
+
+func (s *Shell) save(args []string) error {
+	it, err := decodeItemFn([]byte(args[0]))
+	if err != nil {
+		return errors.NewNotValid(err, "invalid JSON document")
+	}
+	if vocab.IsNil(it) || !it.GetLink().IsValid() {
+		return errors.NotValidf("the object has no valid id")
+	}
+	if it, err = s.r.Save(it); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "saved %s\n", it.GetLink())
+	return err
+}
+
+func (s *Shell) addTo(args []string) error {
+	if err := s.r.AddTo(vocab.IRI(args[0]), vocab.IRI(args[1])); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(s.w, "added %s to %s\n", args[1], args[0])
+	return err
+}
+
+func (s *Shell) keys(args []string) error {
+	prefix := ""
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	keys, err := s.r.Keys(prefix, shellKeysLimit+1)
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if i == shellKeysLimit {
+			_, err = fmt.Fprintln(s.w, "...")
+			break
+		}
+		if _, err = fmt.Fprintln(s.w, browseName(k)); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Shell) get(args []string) error {
+	err := s.r.Open()
+	if err != nil {
+		return err
+	}
+	defer s.r.Close()
+
+	return s.r.d.View(func(tx *badger.Txn) error {
+		i, err := tx.Get([]byte(args[0]))
+		if err != nil {
+			return errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load key %s", args[0])
+		}
+		return i.Value(func(raw []byte) error {
+			out, _ := browseRaw(raw)
+			_, err := fmt.Fprintln(s.w, strings.TrimSuffix(out, "\n"))
+			return err
+		})
+	})
+}
+
+func (s *Shell) help() error {
+	names := make([]string, 0, len(shellCommands))
+	for n := range shellCommands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if _, err := fmt.Fprintf(s.w, "%-26s %s\n", shellCommands[n].usage, shellCommands[n].help); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(s.w, "%-26s %s\n", shellHelp, "lists the commands")
+	return err
+}
+
+func (s *Shell) printItem(it vocab.Item) error {
+	raw, err := encodeItemFn(it)
+	if err != nil {
+		return err
+	}
+	buf := bytes.Buffer{}
+	if err = json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(s.w, buf.String())
+	return err
+}
@@ -0,0 +1,72 @@
+package badger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func TestShell_Exec(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	out := bytes.Buffer{}
+	s := NewShell(r, &out)
+
+	if err = s.Exec(`save {"id":"https://example.com/objects/1","type":"Note","content":"hello"}`); err != nil {
+		t.Fatalf("Exec(save) error = %s", err)
+	}
+	if err = s.Exec("addto https://example.com/objects https://example.com/objects/1"); err != nil {
+		t.Fatalf("Exec(addto) error = %s", err)
+	}
+	out.Reset()
+	if err = s.Exec("filter https://example.com/objects type=Note"); err != nil {
+		t.Fatalf("Exec(filter) error = %s", err)
+	}
+	if !strings.Contains(out.String(), `"content": "hello"`) {
+		t.Errorf("Exec(filter) printed %s, want the note", out.String())
+	}
+	out.Reset()
+	if err = s.Exec("get example.com/objects/1/__raw"); err != nil || !strings.Contains(out.String(), "hello") {
+		t.Errorf("Exec(get) = %v, printed %s, want the raw note", err, out.String())
+	}
+
+	if err = s.Exec("load"); !errors.IsNotValid(err) {
+		t.Errorf("Exec(load) without arguments error = %v, want NotValid", err)
+	}
+	if err = s.Exec("drop everything"); !errors.IsNotValid(err) {
+		t.Errorf("Exec() of an unknown command error = %v, want NotValid", err)
+	}
+}
+
+func TestShell_Complete(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Inbox = vocab.Inbox.IRI(jdoe)
+	if _, err = r.Save(jdoe); err != nil {
+		t.Fatalf("unable to save %s: %s", jdoe.ID, err)
+	}
+	s := NewShell(r, &bytes.Buffer{})
+
+	if got, _ := s.Complete("lo"); len(got) != 1 || got[0] != "load " {
+		t.Errorf("Complete(lo) = %v, want the load command", got)
+	}
+	got, err := s.Complete("load https://example.com/actors/jdoe/")
+	if err != nil {
+		t.Fatalf("Complete() error = %s", err)
+	}
+	if len(got) != 1 || got[0] != "load https://example.com/actors/jdoe/inbox" {
+		t.Errorf("Complete() = %v, want the inbox of jdoe", got)
+	}
+	got, _ = s.Complete("get example.com/actors/jdoe/")
+	if !stringsContain(got, "get example.com/actors/jdoe/__raw") {
+		t.Errorf("Complete() of a key = %v, want the object key of jdoe", got)
+	}
+}
@@ -0,0 +1,243 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// SnapshotSource opens the badger database compared by CompareSnapshots.
+type SnapshotSource func() (*badger.DB, error)
+
+// SnapshotDir returns the SnapshotSource of the badger directory at path, which is opened read only.
+func SnapshotDir(path string) SnapshotSource {
+	return func() (*badger.DB, error) {
+		db, err := badger.Open(badger.DefaultOptions(path).WithReadOnly(true).WithLogger(nil))
+		if err != nil {
+			return nil, errors.Annotatef(err, "unable to open %s", path)
+		}
+		return db, nil
+	}
+}
+
+// SnapshotBackup returns the SnapshotSource of a backup written by Backup, which is loaded in memory.
+func SnapshotBackup(rd io.Reader) SnapshotSource {
+	return func() (*badger.DB, error) {
+		db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+		if err != nil {
+			return nil, err
+		}
+		if err = db.Load(rd, 256); err != nil {
+			_ = db.Close()
+			return nil, errors.NewNotValid(err, "unable to load the backup")
+		}
+		return db, nil
+	}
+}
+
+// SnapshotChange is the kind of the difference of a key between two snapshots.
+type SnapshotChange string
+
+const (
+	SnapshotAdded   SnapshotChange = "added"
+	SnapshotRemoved SnapshotChange = "removed"
+	SnapshotChanged SnapshotChange = "changed"
+)
+
+// SnapshotDiff is a key which differs between two snapshots.
+type SnapshotDiff struct {
+	Key    string
+	Change SnapshotChange
+	// Old and New summarize the values of the key in the from and the to snapshots, like "Note https://example.com/1"
+	// for an object, or "collection of 3 items" for a collection. They are empty when the key is missing.
+	Old string
+	New string
+	// Fields are the top level properties of the JSON values which are changed.
+	Fields []string
+}
+
+// SnapshotReport counts the keys compared by CompareSnapshots.
+type SnapshotReport struct {
+	Compared int
+	Added    int
+	Removed  int
+	Changed  int
+}
+
+// Equal returns if the snapshots have the same keys, with the same values.
+func (s SnapshotReport) Equal() bool {
+	return s.Added+s.Removed+s.Changed == 0
+}
+
+// CompareOptions configures CompareSnapshots.
+type CompareOptions struct {
+	// Internal compares the keys which badger derives from the objects too, like the type keys, the indexes and
+	// the cursors. Without it, only the objects, the metadata and the OAuth data are compared, like they are
+	// written by ExportToFS, as the imports rebuild the rest.
+	Internal bool
+	// DiffFn is called for each key which differs between the snapshots, in the order of the keys.
+	DiffFn func(SnapshotDiff)
+}
+
+// CompareSnapshots compares two badger databases key by key, for verifying that the migrations and the restores
+// are lossless, and reports the number of keys added in the snapshot to, removed from the snapshot from, and changed.
+// The JSON values are compared ignoring the surrounding whitespace.
+func CompareSnapshots(from, to SnapshotSource, opt CompareOptions) (SnapshotReport, error) {
+	a, err := from()
+	if err != nil {
+		return SnapshotReport{}, err
+	}
+	defer a.Close()
+	b, err := to()
+	if err != nil {
+		return SnapshotReport{}, err
+	}
+	defer b.Close()
+
+	ta, tb := a.NewTransaction(false), b.NewTransaction(false)
+	defer ta.Discard()
+	defer tb.Discard()
+	ia, ib := ta.NewIterator(badger.DefaultIteratorOptions), tb.NewIterator(badger.DefaultIteratorOptions)
+	defer ia.Close()
+	defer ib.Close()
+
+	compared := func(k []byte) bool {
+		return opt.Internal || isExportedKey(k)
+	}
+	next := func(it *badger.Iterator) {
+		for it.Next(); it.Valid() && !compared(it.Item().Key()); it.Next() {
+		}
+	}
+	for _, it := range []*badger.Iterator{ia, ib} {
+		for it.Rewind(); it.Valid() && !compared(it.Item().Key()); it.Next() {
+		}
+	}
+
+	report := SnapshotReport{}
+	diff := func(d SnapshotDiff) {
+		if opt.DiffFn != nil {
+			opt.DiffFn(d)
+		}
+	}
+	for ia.Valid() || ib.Valid() {
+		c := 0
+		switch {
+		case !ia.Valid():
+			c = 1
+		case !ib.Valid():
+			c = -1
+		default:
+			c = bytes.Compare(ia.Item().Key(), ib.Item().Key())
+		}
+		report.Compared++
+		switch c {
+		case -1:
+			k, va, err := snapshotValue(ia)
+			if err != nil {
+				return report, err
+			}
+			report.Removed++
+			diff(SnapshotDiff{Key: string(k), Change: SnapshotRemoved, Old: snapshotSummary(k, va)})
+			next(ia)
+		case 1:
+			k, vb, err := snapshotValue(ib)
+			if err != nil {
+				return report, err
+			}
+			report.Added++
+			diff(SnapshotDiff{Key: string(k), Change: SnapshotAdded, New: snapshotSummary(k, vb)})
+			next(ib)
+		default:
+			k, va, err := snapshotValue(ia)
+			if err != nil {
+				return report, err
+			}
+			_, vb, err := snapshotValue(ib)
+			if err != nil {
+				return report, err
+			}
+			if !bytes.Equal(bytes.TrimSpace(va), bytes.TrimSpace(vb)) {
+				report.Changed++
+				diff(SnapshotDiff{
+					Key:    string(k),
+					Change: SnapshotChanged,
+					Old:    snapshotSummary(k, va),
+					New:    snapshotSummary(k, vb),
+					Fields: changedFields(va, vb),
+				})
+			}
+			next(ia)
+			next(ib)
+		}
+	}
+	return report, nil
+}
+
+func snapshotValue(it *badger.Iterator) ([]byte, []byte, error) {
+	i := it.Item()
+	k := i.KeyCopy(nil)
+	v, err := i.ValueCopy(nil)
+	if err != nil {
+		return k, nil, errors.Annotatef(err, "unable to read %s", k)
+	}
+	return k, v, nil
+}
+
+// snapshotSummary describes the value of a key, without showing it, as it can be a secret.
+func snapshotSummary(k, raw []byte) string {
+	switch dumpRecordType(k, raw) {
+	case DumpCollection:
+		iris := make([]json.RawMessage, 0)
+		if err := json.Unmarshal(raw, &iris); err != nil {
+			return fmt.Sprintf("invalid collection of %d bytes", len(raw))
+		}
+		return fmt.Sprintf("collection of %d items", len(iris))
+	case DumpObject:
+		if isExportedKey(k) {
+			it, err := decodeItemFn(raw)
+			if err != nil || vocab.IsNil(it) {
+				return fmt.Sprintf("invalid object of %d bytes", len(raw))
+			}
+			return fmt.Sprintf("%s %s", it.GetType(), it.GetLink())
+		}
+	case DumpMetadata:
+		return fmt.Sprintf("metadata of %d bytes", len(raw))
+	}
+	return fmt.Sprintf("%d bytes", len(raw))
+}
+
+// changedFields returns the top level properties which differ between two JSON objects, and nil when any of
+// the values isn't one.
+func changedFields(a, b []byte) []string {
+	ma, mb := make(map[string]json.RawMessage), make(map[string]json.RawMessage)
+	if json.Unmarshal(a, &ma) != nil || json.Unmarshal(b, &mb) != nil {
+		return nil
+	}
+	fields := make([]string, 0)
+	for k, va := range ma {
+		if vb, ok := mb[k]; !ok || !jsonEqual(va, vb) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range mb {
+		if _, ok := ma[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	ca, cb := bytes.Buffer{}, bytes.Buffer{}
+	if json.Compact(&ca, a) != nil || json.Compact(&cb, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(ca.Bytes(), cb.Bytes())
+}
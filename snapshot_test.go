@@ -0,0 +1,75 @@
+package badger
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_CompareSnapshots(t *testing.T) {
+	from, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	to, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	changed := vocab.ObjectNew(vocab.NoteType)
+	changed.ID = "https://example.com/objects/1"
+	changed.Content = vocab.DefaultNaturalLanguageValue("updated")
+	added := vocab.ObjectNew(vocab.NoteType)
+	added.ID = "https://example.com/objects/2"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = from.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	for _, it := range []vocab.Item{changed, added} {
+		if _, err = to.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	diffs := make([]SnapshotDiff, 0)
+	report, err := CompareSnapshots(SnapshotDir(from.path), SnapshotDir(to.path), CompareOptions{
+		DiffFn: func(d SnapshotDiff) { diffs = append(diffs, d) },
+	})
+	if err != nil {
+		t.Fatalf("CompareSnapshots() error = %s", err)
+	}
+	if want := (SnapshotReport{Compared: 3, Added: 1, Removed: 1, Changed: 1}); report != want {
+		t.Errorf("CompareSnapshots() = %+v, want %+v", report, want)
+	}
+	want := []SnapshotDiff{
+		{Key: "example.com/actors/jdoe/__raw", Change: SnapshotRemoved, Old: "Person https://example.com/actors/jdoe"},
+		{
+			Key:    "example.com/objects/1/__raw",
+			Change: SnapshotChanged,
+			Old:    "Note https://example.com/objects/1",
+			New:    "Note https://example.com/objects/1",
+			Fields: []string{"content"},
+		},
+		{Key: "example.com/objects/2/__raw", Change: SnapshotAdded, New: "Note https://example.com/objects/2"},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("CompareSnapshots() diffs = %+v, want %+v", diffs, want)
+	}
+
+	buf := bytes.Buffer{}
+	if _, err = from.Backup(&buf); err != nil {
+		t.Fatalf("Backup() error = %s", err)
+	}
+	report, err = CompareSnapshots(SnapshotDir(from.path), SnapshotBackup(&buf), CompareOptions{Internal: true})
+	if err != nil {
+		t.Fatalf("CompareSnapshots() of the backup error = %s", err)
+	}
+	if !report.Equal() || report.Compared == 0 {
+		t.Errorf("CompareSnapshots() of the backup = %+v, want all the keys equal", report)
+	}
+}
@@ -0,0 +1,172 @@
+package badger
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+// SortField is the date property by which the items of a collection can be ordered.
+type SortField string
+
+const (
+	SortByPublished SortField = "published"
+	SortByUpdated   SortField = "updated"
+)
+
+// SortOrder is the Load option which orders the items of a collection by one of their dates.
+// It is passed to Load together with the other checks, and it matches all the items.
+// Items without a value for the date are sorted as the oldest ones.
+type SortOrder struct {
+	By        SortField
+	Ascending bool
+}
+
+// SortBy returns the Load option which orders the items of a collection by the field.
+func SortBy(by SortField, ascending bool) filters.Check {
+	return SortOrder{By: by, Ascending: ascending}
+}
+
+func (SortOrder) Match(_ vocab.Item) bool {
+	return true
+}
+
+// index returns the name of the index which stores the date of the sort order.
+func (o SortOrder) index() string {
+	if o.By == SortByUpdated {
+		return UpdatedIndex{}.Name()
+	}
+	return PublishedIndex{}.Name()
+}
+
+func (o SortOrder) time(it vocab.Item) time.Time {
+	var t time.Time
+	_ = vocab.OnObject(it, func(ob *vocab.Object) error {
+		t = ob.Published
+		if o.By == SortByUpdated && !ob.Updated.IsZero() {
+			t = ob.Updated
+		}
+		return nil
+	})
+	return t
+}
+
+func (o SortOrder) sort(col vocab.ItemCollection) {
+	sort.SliceStable(col, func(i, j int) bool {
+		ti, tj := o.time(col[i]), o.time(col[j])
+		if o.Ascending {
+			return ti.Before(tj)
+		}
+		return ti.After(tj)
+	})
+}
+
+// sortOrder returns the SortOrder found in the checks.
+func sortOrder(checks filters.Checks) (SortOrder, bool) {
+	for _, c := range checks {
+		if o, ok := c.(SortOrder); ok {
+			return o, true
+		}
+	}
+	return SortOrder{}, false
+}
+
+// orderedPaths returns the paths of the objects directly under base in the order of the date index of the sort order.
+// The objects which are not part of the index don't have the date, and are placed as the oldest ones.
+// When restricted is true, only the paths in candidates are returned, otherwise all the objects under base are.
+func orderedPaths(tx dbTxn, base []byte, order SortOrder, candidates [][]byte, restricted bool) [][]byte {
+	var allowed map[string]struct{}
+	if restricted {
+		allowed = make(map[string]struct{}, len(candidates))
+		for _, p := range candidates {
+			allowed[string(p)] = struct{}{}
+		}
+	}
+
+	prefix := getIndexPrefix(order.index())
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	opt.Reverse = !order.Ascending
+	it := tx.NewIterator(opt)
+	defer it.Close()
+
+	dated := make([][]byte, 0)
+	seen := make(map[string]struct{})
+	start := prefix
+	if opt.Reverse {
+		start = append(append([]byte{}, prefix...), 0xff)
+	}
+	basePrefix := append(append([]byte{}, base...), sep...)
+	for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+		p := indexKeyPath(it.Item().KeyCopy(nil))
+		if !bytes.HasPrefix(p, basePrefix) || iterKeyIsTooDeep(base, p, 0) {
+			continue
+		}
+		if _, ok := seen[string(p)]; ok {
+			continue
+		}
+		if _, ok := allowed[string(p)]; restricted && !ok {
+			continue
+		}
+		seen[string(p)] = struct{}{}
+		dated = append(dated, p)
+	}
+
+	if !restricted {
+		candidates = objectPaths(tx, base)
+	}
+	undated := make([][]byte, 0)
+	for _, p := range candidates {
+		if _, ok := seen[string(p)]; !ok {
+			undated = append(undated, p)
+		}
+	}
+	if order.Ascending {
+		return append(undated, dated...)
+	}
+	return append(dated, undated...)
+}
+
+// objectPaths returns the paths of the objects stored directly under base, without loading their values.
+func objectPaths(tx dbTxn, base []byte) [][]byte {
+	paths := make([][]byte, 0)
+	prefix := append(append([]byte{}, base...), sep...)
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = prefix
+	opt.PrefetchValues = false
+	it := tx.NewIterator(opt)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		k := it.Item().Key()
+		if !isObjectKey(k) || iterKeyIsTooDeep(base, k, 1) {
+			continue
+		}
+		paths = append(paths, bytes.TrimSuffix(it.Item().KeyCopy(nil), append(sep, objectKey...)))
+	}
+	return paths
+}
+
+// UpdatedIndex indexes objects by their updated time, or by their published time when they were never updated.
+// It backs the loads sorted by SortByUpdated.
+type UpdatedIndex struct{}
+
+func (UpdatedIndex) Name() string {
+	return "updated"
+}
+
+func (UpdatedIndex) Values(it vocab.Item) []string {
+	t := SortOrder{By: SortByUpdated}.time(it)
+	if t.IsZero() {
+		return nil
+	}
+	return []string{t.UTC().Format(publishedIndexFormat)}
+}
+
+func (UpdatedIndex) Lookup(_ *filters.Filters) ([]ValueRange, bool) {
+	return nil, false
+}
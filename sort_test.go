@@ -0,0 +1,100 @@
+package badger
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/filters"
+)
+
+func Test_repo_Load_SortOrder(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	// NOTE(marius): the objects are saved out of chronological order, and the last one doesn't have any dates.
+	minutes := []int{3, 1, 4, 0, 2}
+	iri := func(i int) vocab.IRI {
+		return vocab.IRI(fmt.Sprintf("http://example.com/objects/%d", i))
+	}
+
+	tests := []struct {
+		name    string
+		indexes []Indexer
+		checks  filters.Checks
+		want    vocab.IRIs
+	}{
+		{
+			name:    "published descending",
+			indexes: DefaultIndexes,
+			checks:  filters.Checks{SortBy(SortByPublished, false)},
+			want:    vocab.IRIs{iri(2), iri(0), iri(4), iri(1), iri(3), iri(5)},
+		},
+		{
+			name:    "published ascending",
+			indexes: DefaultIndexes,
+			checks:  filters.Checks{SortBy(SortByPublished, true)},
+			want:    vocab.IRIs{iri(5), iri(3), iri(1), iri(4), iri(0), iri(2)},
+		},
+		{
+			name:    "published descending, with max count",
+			indexes: DefaultIndexes,
+			checks:  filters.Checks{SortBy(SortByPublished, false), filters.WithMaxCount(1)},
+			want:    vocab.IRIs{iri(2), iri(0)},
+		},
+		{
+			name:    "updated descending, with max count",
+			indexes: DefaultIndexes,
+			checks:  filters.Checks{SortBy(SortByUpdated, false), filters.WithMaxCount(1)},
+			want:    vocab.IRIs{iri(3), iri(2)},
+		},
+		{
+			name:   "published descending, without indexes",
+			checks: filters.Checks{SortBy(SortByPublished, false), filters.WithMaxCount(1)},
+			want:   vocab.IRIs{iri(2), iri(0), iri(4), iri(1), iri(3), iri(5)},
+		},
+		{
+			name:    "type filter and published ascending",
+			indexes: DefaultIndexes,
+			checks:  filters.Checks{filters.HasType(vocab.ArticleType), SortBy(SortByPublished, true)},
+			want:    vocab.IRIs{iri(3), iri(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := initBadgerForTesting(t)
+			if err != nil {
+				t.Fatalf("Unable to initialize badger: %s", err)
+			}
+			r.indexes = tt.indexes
+			for i, m := range minutes {
+				typ := vocab.NoteType
+				if i%2 == 1 {
+					typ = vocab.ArticleType
+				}
+				ob := vocab.ObjectNew(typ)
+				ob.ID = iri(i)
+				ob.Published = now.Add(time.Duration(m) * time.Minute)
+				if i == 3 {
+					ob.Updated = now.Add(time.Hour)
+				}
+				if _, err = r.Save(ob); err != nil {
+					t.Fatalf("unable to save %s: %s", ob.ID, err)
+				}
+			}
+			undated := vocab.ObjectNew(vocab.NoteType)
+			undated.ID = iri(len(minutes))
+			if _, err = r.Save(undated); err != nil {
+				t.Fatalf("unable to save %s: %s", undated.ID, err)
+			}
+
+			res, err := r.Load("http://example.com/objects", tt.checks...)
+			if err != nil {
+				t.Fatalf("Load() error = %s", err)
+			}
+			if got := collectionItemIRIs(res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package badger
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// sqliteImportBatch is the number of rows imported between two checkpoints.
+const sqliteImportBatch = 1000
+
+type importedKey struct {
+	k, v []byte
+}
+
+// sqliteTable describes how the rows of a table of storage-sqlite are read, and the keys they become.
+// The query receives the key of the last row imported, and returns the rows after it, ordered by their key,
+// which is their first column.
+type sqliteTable struct {
+	name  string
+	query string
+	scan  func(rows *sql.Rows) (string, []importedKey, error)
+}
+
+var sqliteTables = []sqliteTable{
+	{name: "actors", query: `SELECT iri, raw FROM actors WHERE iri > ? ORDER BY iri`, scan: scanSqliteObject},
+	{name: "activities", query: `SELECT iri, raw FROM activities WHERE iri > ? ORDER BY iri`, scan: scanSqliteObject},
+	{name: "objects", query: `SELECT iri, raw FROM objects WHERE iri > ? ORDER BY iri`, scan: scanSqliteObject},
+	{name: "collections", query: `SELECT iri, items FROM collections WHERE iri > ? ORDER BY iri`, scan: scanSqliteObject},
+	{name: "meta", query: `SELECT iri, raw FROM meta WHERE iri > ? ORDER BY iri`, scan: scanSqliteMetadata},
+	{
+		name:  "clients",
+		query: `SELECT code, secret, redirect_uri, extra FROM clients WHERE code > ? ORDER BY code`,
+		scan:  scanSqliteClient,
+	},
+	{
+		name: "authorize",
+		query: `SELECT code, client, expires_in, scope, redirect_uri, state, created_at, extra FROM authorize
+WHERE code > ? ORDER BY code`,
+		scan: scanSqliteAuthorize,
+	},
+	{
+		name: "access",
+		query: `SELECT token, client, authorize, previous, refresh_token, expires_in, scope, redirect_uri, created_at, extra
+FROM access WHERE token > ? ORDER BY token`,
+		scan: scanSqliteAccess,
+	},
+	{name: "refresh", query: `SELECT token, access_token FROM refresh WHERE token > ? ORDER BY token`, scan: scanSqliteRefresh},
+}
+
+// ImportFromSQLite copies the data of a storage-sqlite database, which the caller opens with the sqlite driver
+// of its choice: the actors, activities, objects, collections and their metadata, and the OAuth clients,
+// authorizations, access and refresh tokens.
+//
+// The rows are read table by table, in the order of their keys, and a checkpoint is stored after every thousand
+// of them, so an interrupted import continues from where it stopped when run again. The type keys get created
+// while copying, and the filter indexes, the collection cursors and the membership keys are rebuilt after,
+// by ReindexAll.
+func (r *repo) ImportFromSQLite(db *sql.DB, opt MigrationOptions) (MigrationReport, error) {
+	if db == nil {
+		return MigrationReport{}, errors.NotValidf("nil sqlite database")
+	}
+	imp, err := r.newImporter("sqlite", "", opt)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	table, last := splitSqliteCheckpoint(imp.resumePosition())
+	resuming := table != ""
+	for _, t := range sqliteTables {
+		if resuming && t.name != table {
+			continue
+		}
+		from := ""
+		if resuming {
+			from, resuming = last, false
+		}
+		if err = importSqliteTable(db, imp, t, from); err != nil {
+			break
+		}
+	}
+	return imp.finish(err)
+}
+
+func importSqliteTable(db *sql.DB, imp *importer, t sqliteTable, from string) error {
+	rows, err := db.Query(t.query, from)
+	if err != nil {
+		return errors.Annotatef(err, "unable to read the %s table", t.name)
+	}
+	defer rows.Close()
+
+	count, last := 0, from
+	for rows.Next() {
+		key, keys, err := t.scan(rows)
+		if err != nil {
+			return errors.Annotatef(err, "unable to read row %s of the %s table", key, t.name)
+		}
+		for _, kv := range keys {
+			if err = imp.set(kv.k, kv.v); err != nil {
+				return err
+			}
+		}
+		count, last = count+1, key
+		if count%sqliteImportBatch == 0 {
+			if err = imp.checkpoint(sqliteCheckpoint(t.name, last)); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Annotatef(err, "unable to read the %s table", t.name)
+	}
+	// NOTE(marius): the position of the next table, so the rows of this one don't get imported again.
+	if next := nextSqliteTable(t.name); next != "" {
+		return imp.checkpoint(sqliteCheckpoint(next, ""))
+	}
+	return nil
+}
+
+func sqliteCheckpoint(table, last string) []byte {
+	return append(append([]byte(table), indexValueSep), last...)
+}
+
+func splitSqliteCheckpoint(pos []byte) (string, string) {
+	table, last, ok := bytes.Cut(pos, []byte{indexValueSep})
+	if !ok {
+		return "", ""
+	}
+	return string(table), string(last)
+}
+
+func nextSqliteTable(name string) string {
+	for i, t := range sqliteTables {
+		if t.name == name && i+1 < len(sqliteTables) {
+			return sqliteTables[i+1].name
+		}
+	}
+	return ""
+}
+
+func scanSqliteObject(rows *sql.Rows) (string, []importedKey, error) {
+	var iri string
+	var raw []byte
+	if err := rows.Scan(&iri, &raw); err != nil {
+		return iri, nil, err
+	}
+	return iri, []importedKey{{k: getObjectKey(itemPath(vocab.IRI(iri))), v: raw}}, nil
+}
+
+func scanSqliteMetadata(rows *sql.Rows) (string, []importedKey, error) {
+	var iri string
+	var raw []byte
+	if err := rows.Scan(&iri, &raw); err != nil {
+		return iri, nil, err
+	}
+	return iri, []importedKey{{k: getMetadataKey(itemPath(vocab.IRI(iri))), v: raw}}, nil
+}
+
+func scanSqliteClient(rows *sql.Rows) (string, []importedKey, error) {
+	c := cl{}
+	var extra []byte
+	if err := rows.Scan(&c.Id, &c.Secret, &c.RedirectUri, &extra); err != nil {
+		return c.Id, nil, err
+	}
+	c.Extra = sqliteExtra(extra)
+	raw, err := encodeFn(c)
+	return c.Id, []importedKey{{k: badgerItemPath(clientsBucket, c.Id), v: raw}}, err
+}
+
+func scanSqliteAuthorize(rows *sql.Rows) (string, []importedKey, error) {
+	a := auth{}
+	var expiresIn int64
+	var createdAt any
+	var extra []byte
+	if err := rows.Scan(&a.Code, &a.Client, &expiresIn, &a.Scope, &a.RedirectURI, &a.State, &createdAt, &extra); err != nil {
+		return a.Code, nil, err
+	}
+	a.ExpiresIn = time.Duration(expiresIn)
+	a.CreatedAt = sqliteTime(createdAt)
+	a.Extra = sqliteExtra(extra)
+	raw, err := encodeFn(a)
+	return a.Code, []importedKey{{k: badgerItemPath(authorizeBucket, a.Code), v: raw}}, err
+}
+
+func scanSqliteAccess(rows *sql.Rows) (string, []importedKey, error) {
+	a := acc{}
+	var expiresIn int64
+	var createdAt any
+	var extra []byte
+	err := rows.Scan(&a.AccessToken, &a.Client, &a.Authorize, &a.Previous, &a.RefreshToken, &expiresIn, &a.Scope,
+		&a.RedirectURI, &createdAt, &extra)
+	if err != nil {
+		return a.AccessToken, nil, err
+	}
+	a.ExpiresIn = time.Duration(expiresIn)
+	a.CreatedAt = sqliteTime(createdAt)
+	a.Extra = sqliteExtra(extra)
+	raw, err := encodeFn(a)
+	return a.AccessToken, []importedKey{{k: badgerItemPath(accessBucket, a.AccessToken), v: raw}}, err
+}
+
+func scanSqliteRefresh(rows *sql.Rows) (string, []importedKey, error) {
+	var token string
+	rf := ref{}
+	if err := rows.Scan(&token, &rf.Access); err != nil {
+		return token, nil, err
+	}
+	raw, err := encodeFn(rf)
+	return token, []importedKey{{k: badgerItemPath(refreshBucket, token), v: raw}}, err
+}
+
+// sqliteExtra returns the extra data of the OAuth rows, which storage-sqlite stores as text.
+func sqliteExtra(extra []byte) interface{} {
+	if len(extra) == 0 {
+		return nil
+	}
+	return string(extra)
+}
+
+// sqliteTime returns the time stored in a column, depending on the driver, as a time, a text or a unix timestamp.
+func sqliteTime(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case int64:
+		return time.Unix(t, 0).UTC()
+	case string, []byte:
+		s := fmt.Sprintf("%s", t)
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05"} {
+			if tt, err := time.Parse(layout, s); err == nil {
+				return tt.UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
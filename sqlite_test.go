@@ -0,0 +1,193 @@
+package badger
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteTestSchema = `
+CREATE TABLE actors (iri TEXT PRIMARY KEY, raw BLOB);
+CREATE TABLE activities (iri TEXT PRIMARY KEY, raw BLOB);
+CREATE TABLE objects (iri TEXT PRIMARY KEY, raw BLOB);
+CREATE TABLE collections (iri TEXT PRIMARY KEY, items BLOB);
+CREATE TABLE meta (iri TEXT PRIMARY KEY, raw BLOB);
+CREATE TABLE clients (code TEXT PRIMARY KEY, secret TEXT, redirect_uri TEXT, extra BLOB);
+CREATE TABLE authorize (code TEXT PRIMARY KEY, client TEXT, expires_in INTEGER, scope TEXT, redirect_uri TEXT,
+	state TEXT, created_at DATETIME, extra BLOB);
+CREATE TABLE access (token TEXT PRIMARY KEY, client TEXT, authorize TEXT, previous TEXT, refresh_token TEXT,
+	expires_in INTEGER, scope TEXT, redirect_uri TEXT, created_at DATETIME, extra BLOB);
+CREATE TABLE refresh (token TEXT PRIMARY KEY, access_token TEXT);
+`
+
+func initSqliteForTesting(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "storage.sqlite"))
+	if err != nil {
+		t.Skipf("sqlite is not available: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err = db.Exec(sqliteTestSchema); err != nil {
+		t.Skipf("sqlite is not available: %s", err)
+	}
+
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	rawActor, _ := encodeItemFn(jdoe)
+	rawNote, _ := encodeItemFn(note)
+	rawOutbox, _ := encodeItemFn(vocab.IRIs{note.ID})
+	now := time.Now().UTC().Truncate(time.Second)
+	inserts := []struct {
+		q    string
+		args []any
+	}{
+		{q: `INSERT INTO actors VALUES (?, ?)`, args: []any{jdoe.ID, rawActor}},
+		{q: `INSERT INTO objects VALUES (?, ?)`, args: []any{note.ID, rawNote}},
+		{q: `INSERT INTO collections VALUES (?, ?)`, args: []any{jdoe.Outbox.GetLink(), rawOutbox}},
+		{q: `INSERT INTO meta VALUES (?, ?)`, args: []any{jdoe.ID, []byte(`{}`)}},
+		{q: `INSERT INTO clients VALUES (?, ?, ?, ?)`, args: []any{"app", "s3cr3t", "https://example.com/callback", nil}},
+		{q: `INSERT INTO authorize VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, args: []any{"code", "app", 3600, "", "", "", now, nil}},
+		{q: `INSERT INTO access VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args: []any{"token", "app", "code", "", "refresh", 3600, "", "", now, nil}},
+		{q: `INSERT INTO refresh VALUES (?, ?)`, args: []any{"refresh", "token"}},
+	}
+	for _, ins := range inserts {
+		if _, err = db.Exec(ins.q, ins.args...); err != nil {
+			t.Fatalf("unable to populate sqlite database: %s", err)
+		}
+	}
+	return db
+}
+
+func Test_repo_ImportFromSQLite(t *testing.T) {
+	db := initSqliteForTesting(t)
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	report, err := r.ImportFromSQLite(db, MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("ImportFromSQLite() dry run error = %s", err)
+	}
+	if report.Keys != 8 || report.Objects != 3 {
+		t.Errorf("ImportFromSQLite() dry run = %+v, want 8 keys and 3 objects", report)
+	}
+
+	if report, err = r.ImportFromSQLite(db, MigrationOptions{}); err != nil {
+		t.Fatalf("ImportFromSQLite() error = %s", err)
+	}
+	if report.Keys != 8 || report.Objects != 3 {
+		t.Errorf("ImportFromSQLite() = %+v, want 8 keys and 3 objects", report)
+	}
+	col, err := r.Load("https://example.com/actors/jdoe/outbox")
+	if err != nil {
+		t.Fatalf("Load() of the imported outbox error = %s", err)
+	}
+	if !col.(vocab.ItemCollection).Contains(vocab.IRI("https://example.com/objects/1")) {
+		t.Errorf("Load() of the imported outbox = %v, doesn't contain the imported note", col)
+	}
+	if _, err = r.LoadMetadata("https://example.com/actors/jdoe"); err != nil {
+		t.Errorf("LoadMetadata() of the imported actor error = %s", err)
+	}
+	c, err := r.GetClient("app")
+	if err != nil {
+		t.Fatalf("GetClient() of the imported client error = %s", err)
+	}
+	if c.GetSecret() != "s3cr3t" {
+		t.Errorf("GetClient() secret = %q, want %q", c.GetSecret(), "s3cr3t")
+	}
+	if _, err = r.LoadAccess("token"); err != nil {
+		t.Errorf("LoadAccess() of the imported token error = %s", err)
+	}
+	_ = r.Open()
+	err = r.d.View(func(tx *badger.Txn) error {
+		_, err := tx.Get(getImportCheckpointKey("sqlite", ""))
+		return err
+	})
+	r.Close()
+	if err != badger.ErrKeyNotFound {
+		t.Errorf("the checkpoint of the completed import was not removed: %v", err)
+	}
+}
+
+func Test_repo_ImportFromSQLite_Resume(t *testing.T) {
+	db := initSqliteForTesting(t)
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	// NOTE(marius): a previous import stopped after the actors were copied.
+	_ = r.Open()
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getImportCheckpointKey("sqlite", ""), sqliteCheckpoint("activities", ""))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store the checkpoint: %s", err)
+	}
+
+	report, err := r.ImportFromSQLite(db, MigrationOptions{})
+	if err != nil {
+		t.Fatalf("ImportFromSQLite() error = %s", err)
+	}
+	if report.Keys != 7 {
+		t.Errorf("ImportFromSQLite() resumed = %+v, want 7 keys", report)
+	}
+	_ = r.Open()
+	err = r.d.View(func(tx *badger.Txn) error {
+		_, err := tx.Get(getObjectKey(itemPath("https://example.com/actors/jdoe")))
+		return err
+	})
+	r.Close()
+	if err != badger.ErrKeyNotFound {
+		t.Errorf("the actor before the checkpoint was imported again: %v", err)
+	}
+	if _, err = r.Load("https://example.com/objects/1"); err != nil {
+		t.Errorf("Load() of an object after the checkpoint error = %s", err)
+	}
+}
+
+func Test_repo_ImportFromSQLite_OtherCheckpoint(t *testing.T) {
+	db := initSqliteForTesting(t)
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.notFound = cache.NewNotFound(time.Minute)
+	if _, err = r.Load("https://example.com/objects/1"); !errors.IsNotFound(err) {
+		t.Fatalf("Load() before the import error = %v, want NotFound", err)
+	}
+
+	// NOTE(marius): the checkpoints of the imports of other sources don't apply.
+	_ = r.Open()
+	err = r.d.Update(func(tx *badger.Txn) error {
+		if err := tx.Set(getImportCheckpointKey("sqlite", "other.db"), sqliteCheckpoint("activities", "")); err != nil {
+			return err
+		}
+		return tx.Set(getImportCheckpointKey("dump", ""), []byte("2"))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store the checkpoints: %s", err)
+	}
+
+	report, err := r.ImportFromSQLite(db, MigrationOptions{})
+	if err != nil {
+		t.Fatalf("ImportFromSQLite() error = %s", err)
+	}
+	if report.Keys != 8 {
+		t.Errorf("ImportFromSQLite() = %+v, want 8 keys", report)
+	}
+	if _, err = r.Load("https://example.com/objects/1"); err != nil {
+		t.Errorf("Load() of an object missing before the import error = %s", err)
+	}
+}
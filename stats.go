@@ -0,0 +1,39 @@
+package badger
+
+import "github.com/dgraph-io/badger/v4"
+
+// Stats is the report of the sizes of the database and of the cache, for health checks.
+type Stats struct {
+	// LSMSize is the size in bytes of the LSM tree, which contains the keys and the small values.
+	// Badger updates the sizes periodically, so they can lag behind the latest writes.
+	LSMSize int64
+	// VLogSize is the size in bytes of the value log, which contains the large values.
+	VLogSize int64
+	// Cache contains the counters and the occupancy of the cache for the results of Load.
+	Cache CacheStats
+	// Mirror contains the state of the queue of changes sent to the Mirror of the Config.
+	Mirror MirrorStats
+	// Canary contains the counters of the comparisons with the Canary of the Config.
+	Canary CanaryStats
+	// Writes contains the counters of the limiter of the writes.
+	Writes WriteStats
+	// Quarantined is the number of the object values which failed to decode, and were quarantined.
+	Quarantined int
+}
+
+// Stats returns the current sizes of the database and the state of the cache.
+func (r *repo) Stats() (Stats, error) {
+	err := r.Open()
+	if err != nil {
+		return Stats{}, err
+	}
+	defer r.Close()
+
+	s := Stats{Cache: r.CacheStats(), Mirror: r.MirrorStats(), Canary: r.CanaryStats(), Writes: r.WriteStats()}
+	s.LSMSize, s.VLogSize = r.d.Size()
+	err = r.d.View(func(tx *badger.Txn) error {
+		s.Quarantined = quarantinedCount(tx)
+		return nil
+	})
+	return s, err
+}
@@ -0,0 +1,85 @@
+package badger
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// StreamOptions configures the iteration done by Stream.
+type StreamOptions struct {
+	// KeysOnly skips loading the stored values, the callback receives only the IRIs of the objects.
+	// As value prefetching dominates the cost of iteration, this is the option to use for counting
+	// or checking the existence of objects.
+	KeysOnly bool
+}
+
+// StreamFn is called by Stream for every object found. When iterating with KeysOnly, the item is nil.
+// Returning an error stops the iteration.
+type StreamFn func(iri vocab.IRI, it vocab.Item) error
+
+// Stream iterates over the objects stored under the iri, without materializing them all in memory.
+func (r *repo) Stream(iri vocab.IRI, opt StreamOptions, fn StreamFn) error {
+	if fn == nil {
+		return errors.NotValidf("nil stream function")
+	}
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	base := itemPath(iri)
+	scheme := "https"
+	if u, err := iri.URL(); err == nil && u.Scheme != "" {
+		scheme = u.Scheme
+	}
+	return r.d.View(func(tx *badger.Txn) error {
+		return streamInTxn(tx, base, scheme, opt, fn)
+	})
+}
+
+func streamInTxn(tx dbTxn, base []byte, scheme string, opt StreamOptions, fn StreamFn) error {
+	iopt := badger.DefaultIteratorOptions
+	iopt.Prefix = base
+	iopt.PrefetchValues = !opt.KeysOnly
+	it := tx.NewIterator(iopt)
+	defer it.Close()
+	for it.Seek(base); it.ValidForPrefix(base); it.Next() {
+		i := it.Item()
+		k := i.Key()
+		if !isObjectKey(k) || !isPathOrChildKey(base, k) {
+			continue
+		}
+		p := bytes.TrimSuffix(k, append(sep, objectKey...))
+		iri := vocab.IRI((&url.URL{Scheme: scheme, Host: string(p)}).String())
+		if idx := bytes.IndexByte(p, sep[0]); idx > 0 {
+			iri = vocab.IRI((&url.URL{Scheme: scheme, Host: string(p[:idx]), Path: string(p[idx:])}).String())
+		}
+		if opt.KeysOnly {
+			if err := fn(iri, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		var ob vocab.Item
+		err := i.Value(func(raw []byte) error {
+			var err error
+			ob, err = loadItem(raw)
+			return err
+		})
+		if err != nil {
+			return errors.Annotatef(err, "unable to load %s", iri)
+		}
+		if vocab.IsNil(ob) {
+			continue
+		}
+		if err = fn(iri, ob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_Stream(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	saved := vocab.IRIs{"http://example.com/objects/1", "http://example.com/objects/2"}
+	for _, iri := range saved {
+		ob := vocab.ObjectNew(vocab.NoteType)
+		ob.ID = iri
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", iri, err)
+		}
+	}
+
+	keys := make(vocab.IRIs, 0)
+	err = r.Stream("http://example.com/objects", StreamOptions{KeysOnly: true}, func(iri vocab.IRI, it vocab.Item) error {
+		if it != nil {
+			t.Errorf("Stream() with KeysOnly received item %v", it)
+		}
+		keys = append(keys, iri)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %s", err)
+	}
+	if len(keys) != len(saved) || !keys.Contains(saved[0]) || !keys.Contains(saved[1]) {
+		t.Errorf("Stream() with KeysOnly = %v, want %v", keys, saved)
+	}
+
+	items := make(vocab.ItemCollection, 0)
+	stop := errors.Newf("stop")
+	err = r.Stream("http://example.com/objects", StreamOptions{}, func(iri vocab.IRI, it vocab.Item) error {
+		items = append(items, it)
+		return stop
+	})
+	if err != stop {
+		t.Errorf("Stream() error = %v, want %v", err, stop)
+	}
+	if len(items) != 1 || items[0].GetType() != vocab.NoteType {
+		t.Errorf("Stream() = %v, want a single Note before stopping", items)
+	}
+}
@@ -0,0 +1,125 @@
+package badger
+
+import (
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// LoadThread loads the object found at the root IRI, together with its reply tree, in a single transaction.
+// The replies of every object in the tree are set as an ordered collection on its Replies property,
+// sorted by their published time.
+// The depth limits the number of reply levels that are loaded, a value lower than 1 loads the whole thread.
+func (r *repo) LoadThread(root vocab.IRI, depth int) (vocab.Item, error) {
+	err := r.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var thread vocab.Item
+	err = r.d.View(func(tx *badger.Txn) error {
+		thread, err = loadRawItem(tx, itemPath(root))
+		if err != nil {
+			return err
+		}
+		if vocab.IsNil(thread) || !thread.IsObject() {
+			return errors.NewNotFound(ErrNotFound, "%s does not exist", root)
+		}
+		childrenFn := r.threadChildrenFn(tx)
+		seen := map[vocab.IRI]struct{}{thread.GetLink(): {}}
+		return loadReplies(childrenFn, thread, depth, seen)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+type childrenFn func(parent vocab.IRI) vocab.ItemCollection
+
+// threadChildrenFn returns the function that loads the direct replies of an object.
+// When the inReplyTo index is maintained the replies are looked up in it, otherwise
+// all the objects in storage are loaded once, and grouped by the objects they reply to.
+func (r *repo) threadChildrenFn(tx dbTxn) childrenFn {
+	name := InReplyToIndex{}.Name()
+	if hasIndex(r.indexes, name) {
+		return func(parent vocab.IRI) vocab.ItemCollection {
+			children := make(vocab.ItemCollection, 0)
+			for _, p := range sortedPaths(scanIndex(tx, name, nil, exactValues(parent))) {
+				it, err := loadRawItem(tx, p)
+				if err != nil || vocab.IsNil(it) {
+					r.errFn("unable to load reply %s: %+s", p, err)
+					continue
+				}
+				children = append(children, it)
+			}
+			return children
+		}
+	}
+
+	byParent := make(map[string]vocab.ItemCollection)
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		if !isObjectKey(i.Key()) {
+			continue
+		}
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil || vocab.IsNil(ob) || !ob.IsObject() {
+				return err
+			}
+			for _, parent := range (InReplyToIndex{}).Values(ob) {
+				byParent[parent] = append(byParent[parent], ob)
+			}
+			return nil
+		})
+		if err != nil {
+			r.errFn("unable to load item %s: %+s", i.Key(), err)
+		}
+	}
+	return func(parent vocab.IRI) vocab.ItemCollection {
+		return byParent[parent.String()]
+	}
+}
+
+// loadReplies sets recursively the replies of the object, up to depth levels.
+// The seen map guards against reply cycles.
+func loadReplies(childrenFn childrenFn, parent vocab.Item, depth int, seen map[vocab.IRI]struct{}) error {
+	replies := make(vocab.ItemCollection, 0)
+	for _, child := range childrenFn(parent.GetLink()) {
+		if _, ok := seen[child.GetLink()]; ok {
+			continue
+		}
+		seen[child.GetLink()] = struct{}{}
+		if depth != 1 {
+			if err := loadReplies(childrenFn, child, depth-1, seen); err != nil {
+				return err
+			}
+		}
+		replies = append(replies, child)
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	sort.SliceStable(replies, func(i, j int) bool {
+		return publishedTime(replies[i]).Before(publishedTime(replies[j]))
+	})
+	return vocab.OnObject(parent, func(o *vocab.Object) error {
+		var id vocab.ID
+		if !vocab.IsNil(o.Replies) {
+			id = o.Replies.GetLink()
+		}
+		o.Replies = &vocab.OrderedCollection{
+			ID:           id,
+			Type:         vocab.OrderedCollectionType,
+			OrderedItems: replies,
+			TotalItems:   uint(len(replies)),
+		}
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package badger
+
+import (
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func repliesOf(it vocab.Item) vocab.ItemCollection {
+	var replies vocab.ItemCollection
+	_ = vocab.OnObject(it, func(o *vocab.Object) error {
+		if vocab.IsNil(o.Replies) || !o.Replies.IsCollection() {
+			return nil
+		}
+		return vocab.OnCollectionIntf(o.Replies, func(col vocab.CollectionInterface) error {
+			replies = col.Collection()
+			return nil
+		})
+	})
+	return replies
+}
+
+func Test_repo_LoadThread(t *testing.T) {
+	now := time.Now().UTC()
+
+	root := vocab.ObjectNew(vocab.NoteType)
+	root.ID = "http://example.com/objects/1"
+	root.Replies = vocab.Replies.IRI(root.ID)
+
+	first := vocab.ObjectNew(vocab.NoteType)
+	first.ID = "http://example.com/objects/3"
+	first.InReplyTo = root.ID
+	first.Published = now.Add(-2 * time.Minute)
+
+	second := vocab.ObjectNew(vocab.NoteType)
+	second.ID = "http://example.com/objects/2"
+	second.InReplyTo = root.ID
+	second.Published = now.Add(-time.Minute)
+
+	nested := vocab.ObjectNew(vocab.NoteType)
+	nested.ID = "http://example.com/objects/4"
+	nested.InReplyTo = first.ID
+	nested.Published = now
+
+	for _, indexes := range [][]Indexer{DefaultIndexes, nil} {
+		r, err := initBadgerForTesting(t)
+		if err != nil {
+			t.Fatalf("Unable to initialize badger: %s", err)
+		}
+		r.indexes = indexes
+
+		for _, ob := range []*vocab.Object{root, first, second, nested} {
+			if _, err = r.Save(ob); err != nil {
+				t.Fatalf("unable to save %s: %s", ob.ID, err)
+			}
+		}
+
+		thread, err := r.LoadThread(root.ID, 0)
+		if err != nil {
+			t.Fatalf("LoadThread() error = %s", err)
+		}
+		replies := repliesOf(thread)
+		if len(replies) != 2 || replies[0].GetLink() != first.ID || replies[1].GetLink() != second.ID {
+			t.Fatalf("LoadThread() replies = %v, want [%s %s]", replies, first.ID, second.ID)
+		}
+		if nestedReplies := repliesOf(replies[0]); len(nestedReplies) != 1 || nestedReplies[0].GetLink() != nested.ID {
+			t.Errorf("LoadThread() nested replies = %v, want [%s]", nestedReplies, nested.ID)
+		}
+
+		thread, err = r.LoadThread(root.ID, 1)
+		if err != nil {
+			t.Fatalf("LoadThread() error = %s", err)
+		}
+		replies = repliesOf(thread)
+		if len(replies) != 2 {
+			t.Fatalf("LoadThread() with depth 1 replies = %v, want 2 items", replies)
+		}
+		if nestedReplies := repliesOf(replies[0]); len(nestedReplies) != 0 {
+			t.Errorf("LoadThread() with depth 1 loaded nested replies %v", nestedReplies)
+		}
+
+		if _, err = r.LoadThread("http://example.com/objects/404", 0); err == nil {
+			t.Errorf("LoadThread() expected error for missing root")
+		}
+	}
+}
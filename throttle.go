@@ -0,0 +1,105 @@
+package badger
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WriteStats contains the counters of the limiter of the writes.
+type WriteStats struct {
+	// Pending is the number of writes waiting for the limiter, or being made.
+	Pending int
+	// Admitted is the number of writes let through since the repository was created.
+	Admitted int64
+	// Rejected is the number of writes failed with ErrOverloaded, because MaxPendingWrites were already pending.
+	Rejected int64
+	// Waited is the total time the admitted writes waited for the limiter.
+	Waited time.Duration
+}
+
+// throttle limits the rate of the writes with a token bucket, and the number of the pending ones, so the bursts
+// of writes, like the ones of the federation deliveries, wait, or fail early, instead of piling up.
+type throttle struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// pending has a slot for each of the writes allowed to wait or to run, and is nil when they're not bounded.
+	pending  chan struct{}
+	admitted atomic.Int64
+	rejected atomic.Int64
+	waited   atomic.Int64
+}
+
+// newThrottle returns the limiter for the rate of writes per second, with its burst, and the maximum number
+// of pending writes. It returns nil when neither the rate nor the pending writes are limited.
+func newThrottle(rate float64, burst, maxPending int) *throttle {
+	if rate <= 0 && maxPending <= 0 {
+		return nil
+	}
+	t := throttle{rate: rate, burst: float64(max(burst, 1))}
+	t.tokens = t.burst
+	if maxPending > 0 {
+		t.pending = make(chan struct{}, maxPending)
+	}
+	return &t
+}
+
+// acquire waits for the write to be allowed, and returns the function to call after it is made.
+// It fails with ErrOverloaded when the pending writes are at their maximum.
+func (t *throttle) acquire() (func(), error) {
+	if t == nil {
+		return func() {}, nil
+	}
+	release := func() {}
+	if t.pending != nil {
+		select {
+		case t.pending <- struct{}{}:
+			release = func() { <-t.pending }
+		default:
+			t.rejected.Add(1)
+			return nil, ErrOverloaded
+		}
+	}
+	if wait := t.reserve(time.Now()); wait > 0 {
+		time.Sleep(wait)
+		t.waited.Add(int64(wait))
+	}
+	t.admitted.Add(1)
+	return release, nil
+}
+
+// reserve takes a token from the bucket, and returns the time to wait until it would have been available.
+func (t *throttle) reserve(now time.Time) time.Duration {
+	if t.rate <= 0 {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.last.IsZero() {
+		t.tokens = min(t.burst, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	}
+	t.last = now
+	// NOTE(marius): the tokens can go negative, for the writes already waiting, so the next ones wait after them.
+	t.tokens--
+	if t.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-t.tokens * float64(time.Second) / t.rate)
+}
+
+// WriteStats returns the counters of the limiter of the writes, which are zero when the writes aren't limited.
+func (r *repo) WriteStats() WriteStats {
+	if r.throttle == nil {
+		return WriteStats{}
+	}
+	return WriteStats{
+		Pending:  len(r.throttle.pending),
+		Admitted: r.throttle.admitted.Load(),
+		Rejected: r.throttle.rejected.Load(),
+		Waited:   time.Duration(r.throttle.waited.Load()),
+	}
+}
@@ -0,0 +1,76 @@
+package badger
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_newThrottle(t *testing.T) {
+	if th := newThrottle(0, 0, 0); th != nil {
+		t.Errorf("newThrottle() without limits = %v, want nil", th)
+	}
+	release, err := (*throttle)(nil).acquire()
+	if err != nil {
+		t.Fatalf("acquire() of a nil throttle error = %s", err)
+	}
+	release()
+}
+
+func Test_throttle_reserve(t *testing.T) {
+	th := newThrottle(10, 2, 0)
+	now := time.Now()
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := th.reserve(now); got != w {
+			t.Errorf("reserve() #%d = %s, want %s", i, got, w)
+		}
+	}
+	// NOTE(marius): after a second, the bucket refills the tokens owed by the waiting writes, and the burst.
+	if got := th.reserve(now.Add(time.Second)); got != 0 {
+		t.Errorf("reserve() after the refill = %s, want 0", got)
+	}
+}
+
+func Test_throttle_acquire_Overloaded(t *testing.T) {
+	th := newThrottle(0, 0, 2)
+	releases := make([]func(), 0)
+	for i := 0; i < 2; i++ {
+		release, err := th.acquire()
+		if err != nil {
+			t.Fatalf("acquire() #%d error = %s", i, err)
+		}
+		releases = append(releases, release)
+	}
+	if _, err := th.acquire(); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("acquire() above MaxPendingWrites error = %v, want %s", err, ErrOverloaded)
+	}
+	releases[0]()
+	if _, err := th.acquire(); err != nil {
+		t.Errorf("acquire() after a release error = %s", err)
+	}
+	if th.admitted.Load() != 3 || th.rejected.Load() != 1 {
+		t.Errorf("admitted, rejected = %d, %d, want 3, 1", th.admitted.Load(), th.rejected.Load())
+	}
+}
+
+func Test_repo_WriteStats(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.throttle = newThrottle(1000, 1, 1)
+	ob := vocab.ObjectNew(vocab.NoteType)
+	ob.ID = "https://example.com/objects/1"
+	for i := 0; i < 3; i++ {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("Save() error = %s", err)
+		}
+	}
+	s := r.WriteStats()
+	if s.Admitted < 3 || s.Rejected != 0 || s.Pending != 0 {
+		t.Errorf("WriteStats() = %+v, want at least 3 admitted writes, and none rejected or pending", s)
+	}
+}
@@ -0,0 +1,142 @@
+package badger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+
+	"github.com/go-ap/errors"
+)
+
+// TransferFormat is the format of the data written by ExportTo and read by ImportFrom.
+type TransferFormat string
+
+const (
+	// TransferJSONL is the JSON Lines stream of Dump and LoadDump.
+	TransferJSONL TransferFormat = "jsonl"
+	// TransferArchive is the encrypted archive of ExportArchive and ImportArchive.
+	TransferArchive TransferFormat = "archive"
+	// TransferFS is the storage-fs directory layout of ExportToFS and ImportFromFS.
+	TransferFS TransferFormat = "fs"
+)
+
+// TransferFormats are the formats supported by ExportTo and ImportFrom.
+var TransferFormats = []TransferFormat{TransferJSONL, TransferArchive, TransferFS}
+
+// TransferOptions configures ExportTo and ImportFrom.
+type TransferOptions struct {
+	MigrationOptions
+	Format TransferFormat
+	// Passphrase is the one used for encrypting, and decrypting, the archives.
+	Passphrase []byte
+}
+
+// ExportTo writes the data of the repository at path, in the format of the options: a file for the JSON Lines
+// stream and the archive, and a directory for the storage-fs layout.
+// With the DryRun option, the keys which would be exported are only counted.
+func (r *repo) ExportTo(path string, opt TransferOptions) (MigrationReport, error) {
+	switch opt.Format {
+	case TransferFS:
+		return r.ExportToFS(path, opt.MigrationOptions)
+	case TransferJSONL, TransferArchive:
+	default:
+		return MigrationReport{}, errors.NotValidf("unknown export format %q", opt.Format)
+	}
+	if opt.Format == TransferArchive && len(opt.Passphrase) == 0 {
+		return MigrationReport{}, errors.NotValidf("empty archive passphrase")
+	}
+	if opt.DryRun {
+		return r.countExported(opt.MigrationOptions)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return MigrationReport{}, errors.Annotatef(err, "unable to create %s", path)
+	}
+	report := MigrationReport{}
+	if opt.Format == TransferArchive {
+		m, err := r.ExportArchive(f, opt.Passphrase)
+		if err == nil {
+			report.Objects = m.Records[DumpObject] + m.Records[DumpCollection]
+			for _, n := range m.Records {
+				report.Keys += n
+			}
+		}
+		return report, closeExport(f, err, opt.MigrationOptions, report)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	err = r.dumpRecords(func(rec DumpRecord) error {
+		countRecord(&report, rec, opt.MigrationOptions)
+		return enc.Encode(rec)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	return report, closeExport(f, err, opt.MigrationOptions, report)
+}
+
+// ImportFrom copies the data found at path, in the format of the options, like LoadDump, ImportArchive
+// and ImportFromFS do. Setting the CheckpointFile option allows resuming the imports which got interrupted.
+func (r *repo) ImportFrom(path string, opt TransferOptions) (MigrationReport, error) {
+	switch opt.Format {
+	case TransferFS:
+		return r.ImportFromFS(path, opt.MigrationOptions)
+	case TransferJSONL, TransferArchive:
+	default:
+		return MigrationReport{}, errors.NotValidf("unknown import format %q", opt.Format)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return MigrationReport{}, errors.NewNotFound(wrapErr(ErrNotFound, err), "unable to open %s", path)
+	}
+	defer f.Close()
+	if opt.Format == TransferArchive {
+		return r.ImportArchive(f, opt.Passphrase, opt.MigrationOptions)
+	}
+	return r.LoadDump(bufio.NewReader(f), opt.MigrationOptions)
+}
+
+func (r *repo) countExported(opt MigrationOptions) (MigrationReport, error) {
+	report := MigrationReport{}
+	err := r.dumpRecords(func(rec DumpRecord) error {
+		countRecord(&report, rec, opt)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	if opt.ProgressFn != nil {
+		opt.ProgressFn(report)
+	}
+	return report, nil
+}
+
+func countRecord(report *MigrationReport, rec DumpRecord, opt MigrationOptions) {
+	report.Keys++
+	if rec.Type == DumpObject || rec.Type == DumpCollection {
+		report.Objects++
+	}
+	if report.Keys%importProgressInterval == 0 && opt.ProgressFn != nil {
+		opt.ProgressFn(*report)
+	}
+}
+
+// closeExport closes the exported file, which gets removed when the export failed, so it's not mistaken
+// for a complete one.
+func closeExport(f *os.File, err error, opt MigrationOptions, report MigrationReport) error {
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if opt.ProgressFn != nil {
+		opt.ProgressFn(report)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package badger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func Test_repo_ExportTo_ImportFrom(t *testing.T) {
+	src, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	jdoe := vocab.PersonNew("https://example.com/actors/jdoe")
+	jdoe.Outbox = vocab.Outbox.IRI(jdoe)
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{jdoe, note} {
+		if _, err = src.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+
+	for _, format := range TransferFormats {
+		t.Run(string(format), func(t *testing.T) {
+			opt := TransferOptions{Format: format, Passphrase: []byte("secret")}
+			path := filepath.Join(t.TempDir(), "export")
+			exported, err := src.ExportTo(path, opt)
+			if err != nil {
+				t.Fatalf("ExportTo() error = %s", err)
+			}
+			if exported.Keys != 3 || exported.Objects != 3 {
+				t.Errorf("ExportTo() = %+v, want 3 objects", exported)
+			}
+
+			dst, err := initBadgerForTesting(t)
+			if err != nil {
+				t.Fatalf("Unable to initialize badger: %s", err)
+			}
+			imported, err := dst.ImportFrom(path, opt)
+			if err != nil {
+				t.Fatalf("ImportFrom() error = %s", err)
+			}
+			if imported.Keys != exported.Keys {
+				t.Errorf("ImportFrom() = %+v, want %d keys", imported, exported.Keys)
+			}
+			if _, err = dst.Load(note.ID); err != nil {
+				t.Errorf("Load() of the imported %s error = %s", note.ID, err)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	if _, err = src.ExportTo(path, TransferOptions{Format: TransferJSONL}); err != nil {
+		t.Fatalf("ExportTo() error = %s", err)
+	}
+	dst, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+	if err = os.WriteFile(checkpoint, []byte("2"), 0o600); err != nil {
+		t.Fatalf("unable to write the checkpoint: %s", err)
+	}
+	opt := TransferOptions{Format: TransferJSONL, MigrationOptions: MigrationOptions{CheckpointFile: checkpoint}}
+	imported, err := dst.ImportFrom(path, opt)
+	if err != nil {
+		t.Fatalf("ImportFrom() resumed error = %s", err)
+	}
+	if imported.Keys != 1 {
+		t.Errorf("ImportFrom() resumed after 2 records = %+v, want 1 key", imported)
+	}
+	if _, err = os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("the checkpoint file was not removed after the import completed: %v", err)
+	}
+
+	if _, err = src.ExportTo(path, TransferOptions{Format: "csv"}); !errors.IsNotValid(err) {
+		t.Errorf("ExportTo() with an unknown format error = %v, want NotValid", err)
+	}
+}
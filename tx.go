@@ -0,0 +1,240 @@
+package badger
+
+import (
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+// ReadTx is the read only transaction passed to the function of View.
+type ReadTx interface {
+	// Get returns a copy of the raw value stored at the key.
+	Get(key []byte) ([]byte, error)
+	// Load returns the object, or the collection, stored at the iri, as it was saved, without loading the items
+	// of the collection, and without dereferencing its properties.
+	Load(iri vocab.IRI) (vocab.Item, error)
+}
+
+// WriteTx is the read-write transaction passed to the function of Update.
+type WriteTx interface {
+	ReadTx
+	// Save stores the object, maintaining its type key, its indexes and its collections, like the Save of the
+	// repository. The objects saved are visible to the next calls of the transaction.
+	Save(it vocab.Item) (vocab.Item, error)
+}
+
+// Store is the transaction passed to the function of WithTx, which can also remove objects, and change
+// collections, like the methods of the repository with the same names.
+type Store interface {
+	WriteTx
+	Delete(it vocab.Item) error
+	AddTo(col vocab.IRI, it vocab.Item) error
+	RemoveFrom(col vocab.IRI, it vocab.Item) error
+}
+
+// txChange is a change made in a transaction, which is applied to the caches, and sent to the Mirror,
+// after the commit.
+type txChange struct {
+	op  MirrorOp
+	col vocab.IRI
+	it  vocab.Item
+	// old is the object replaced, or removed, by the change, or nil for the new ones.
+	old vocab.Item
+	// cols are the collections changed by AddTo and RemoveFrom.
+	cols vocab.IRIs
+}
+
+// txn is the ReadTx, WriteTx and Store of the repository.
+type txn struct {
+	r       *repo
+	tx      dbTxn
+	changes []txChange
+}
+
+func (t *txn) Get(key []byte) ([]byte, error) {
+	i, err := t.tx.Get(key)
+	if err != nil {
+		return nil, errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load key %s", key)
+	}
+	return i.ValueCopy(nil)
+}
+
+func (t *txn) Load(iri vocab.IRI) (vocab.Item, error) {
+	// NOTE(marius): like the Load of the repository, the item is looked up under the old host of the
+	// Config.Rewrites too, when it wasn't re-keyed yet.
+	k := t.r.storedKey(t.tx, itemPath(iri), getObjectKey)
+	i, err := t.tx.Get(k)
+	if err != nil {
+		return nil, errors.NewNotFound(wrapErr(ErrNotFound, err), "Unable to load key %s", k)
+	}
+	var it vocab.Item
+	err = i.Value(func(raw []byte) error {
+		it, err = t.r.decode(raw)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if vocab.IsNil(it) {
+		return nil, errors.NewNotFound(ErrNotFound, "%s does not exist", iri)
+	}
+	return it, nil
+}
+
+func (t *txn) Save(it vocab.Item) (vocab.Item, error) {
+	if vocab.IsNil(it) {
+		return nil, errors.NotValidf("Unable to save a nil element")
+	}
+	if len(itemPath(it.GetLink())) == 0 {
+		return nil, errors.NotValidf("Unable to save an object with an invalid IRI %s", it.GetLink())
+	}
+	if err := t.r.validate(it); err != nil {
+		return nil, err
+	}
+	if err := t.r.hooks.runBeforeSave(it); err != nil {
+		return nil, err
+	}
+	old, err := saveItem(t.r, t.tx, it)
+	if err != nil {
+		return nil, err
+	}
+	t.changes = append(t.changes, txChange{op: MirrorSave, it: it, old: old})
+	return it, nil
+}
+
+func (t *txn) Delete(it vocab.Item) error {
+	if vocab.IsNil(it) {
+		return errors.Newf("Unable to operate on nil element")
+	}
+	if it.IsCollection() {
+		return vocab.OnCollectionIntf(it, func(c vocab.CollectionInterface) error {
+			for _, it := range c.Collection() {
+				if err := t.Delete(it); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	old, err := t.Load(it.GetLink())
+	if err != nil {
+		return err
+	}
+	if err = updatePageLinks(t.tx, old, nil); err != nil {
+		return errors.Annotatef(err, "could not update collection page links")
+	}
+	if err = deleteFromPath(t.r, t.tx, old); err != nil {
+		return err
+	}
+	t.changes = append(t.changes, txChange{op: MirrorDelete, it: it, old: old})
+	return nil
+}
+
+func (t *txn) AddTo(col vocab.IRI, it vocab.Item) error {
+	if vocab.IsNil(it) {
+		return errors.Newf("Unable to operate on nil element")
+	}
+	// NOTE(marius): like AddTo of the repository, create the collection on its object, when it is missing.
+	if ob, typ := allStorageCollections.Split(col); vocab.ValidCollection(typ) {
+		if i, _ := t.Load(ob); !vocab.IsNil(i) {
+			if _, ok := typ.AddTo(i); ok {
+				if _, err := t.Save(i); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return t.onCollections(MirrorAddTo, col, it, false, addIRIFn)
+}
+
+func (t *txn) RemoveFrom(col vocab.IRI, it vocab.Item) error {
+	if vocab.IsNil(it) {
+		return errors.Newf("Unable to operate on nil element")
+	}
+	return t.onCollections(MirrorRemoveFrom, col, it, true, removeIRIFn)
+}
+
+// onCollections applies the fn of the item on the col collection, and on the collections referencing it.
+func (t *txn) onCollections(op MirrorOp, col vocab.IRI, it vocab.Item, removing bool, fn func(vocab.Item) func(vocab.IRIs) (vocab.IRIs, error)) error {
+	iri, refs := appreciationReferences(t.tx, col, it, removing)
+	if err := onCollection(t.tx, t.r.storedIRI(t.tx, col), iri, t.r.sizeLimitedFn(col, fn(iri))); err != nil {
+		return err
+	}
+	changed := vocab.IRIs{col}
+	for _, ref := range refs {
+		if err := onCollection(t.tx, t.r.storedIRI(t.tx, ref.col), ref.it, t.r.sizeLimitedFn(ref.col, fn(ref.it))); err != nil {
+			t.r.errFn("unable to update %s with %s: %+s", ref.col, ref.it, err)
+			continue
+		}
+		changed = append(changed, ref.col)
+	}
+	t.changes = append(t.changes, txChange{op: op, col: col, it: it, cols: changed})
+	return nil
+}
+
+// View runs fn in a read only transaction, which sees a consistent snapshot of the storage.
+func (r *repo) View(fn func(tx ReadTx) error) error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return r.d.View(func(tx *badger.Txn) error {
+		return fn(&txn{r: r, tx: tx})
+	})
+}
+
+// Update runs fn in a read-write transaction, so the embedding applications can change multiple objects atomically.
+// The changes are committed when fn returns nil, and discarded otherwise. The transactions conflicting with
+// concurrent ones are retried, calling fn again, up to the RetryAttempts of the Config, after which the returned
+// error wraps ErrConflict.
+//
+// After the commit, the saved objects are removed from the caches, and sent to the Mirror, like the ones of Save.
+func (r *repo) Update(fn func(tx WriteTx) error) error {
+	return r.WithTx(func(s Store) error {
+		return fn(s)
+	})
+}
+
+// WithTx runs fn in a read-write transaction, like Update, in which the objects can also be removed, and the
+// collections changed, so the operations of an activity, like saving its object, adding it to the outbox, and
+// to the inboxes of the recipients, are committed, or discarded, together.
+//
+// After the commit, the changes are applied to the caches, and sent to the Mirror, to the Watch and Subscribe
+// calls, and to the After hooks, in the order they were made.
+func (r *repo) WithTx(fn func(s Store) error) error {
+	err := r.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	t := txn{r: r, changes: make([]txChange, 0)}
+	err = r.update(func(tx dbTxn) error {
+		// NOTE(marius): the transactions failing with transient errors are retried, so the changes made
+		// by the failed attempts are forgotten.
+		t.tx, t.changes = tx, t.changes[:0]
+		return fn(&t)
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range t.changes {
+		switch c.op {
+		case MirrorSave:
+			r.clearNotFound(c.it.GetLink())
+			if vocab.IsNil(c.old) {
+				r.invalidateResults(c.it.GetLink())
+			} else {
+				r.invalidateItem(c.it.GetLink())
+			}
+		case MirrorDelete:
+			r.invalidateItem(c.old.GetLink())
+		case MirrorAddTo, MirrorRemoveFrom:
+			r.invalidateResults(c.cols...)
+		}
+		r.notify(c.op, c.col, c.it)
+	}
+	return nil
+}
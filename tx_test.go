@@ -0,0 +1,141 @@
+package badger
+
+import (
+	"errors"
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Update(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	first, second := vocab.ObjectNew(vocab.NoteType), vocab.ObjectNew(vocab.NoteType)
+	first.ID, second.ID = "https://example.com/objects/1", "https://example.com/objects/2"
+
+	failed := errors.New("invariant broken")
+	err = r.Update(func(tx WriteTx) error {
+		if _, err := tx.Save(first); err != nil {
+			return err
+		}
+		if _, err := tx.Load(first.ID); err != nil {
+			t.Errorf("Load() in the transaction of the saved object error = %s", err)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("Update() error = %v, want %v", err, failed)
+	}
+	if _, err = r.Load(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() of an object saved in a failed transaction error = %v, want NotFound", err)
+	}
+
+	err = r.Update(func(tx WriteTx) error {
+		for _, ob := range []vocab.Item{first, second} {
+			if _, err := tx.Save(ob); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %s", err)
+	}
+
+	err = r.View(func(tx ReadTx) error {
+		for _, iri := range []vocab.IRI{first.ID, second.ID} {
+			if it, err := tx.Load(iri); err != nil || !it.GetLink().Equals(iri, false) {
+				t.Errorf("Load() in the view = %v, %v, want %s", it, err, iri)
+			}
+		}
+		if raw, err := tx.Get(getObjectKey(itemPath(first.ID))); err != nil || len(raw) == 0 {
+			t.Errorf("Get() in the view = %s, %v, want the encoded object", raw, err)
+		}
+		if _, err := tx.Load("https://example.com/objects/3"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Load() in the view of a missing object error = %v, want ErrNotFound", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("View() error = %s", err)
+	}
+}
+
+func Test_repo_WithTx(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	outbox, inbox := vocab.IRI("https://example.com/~jdoe/outbox"), vocab.IRI("https://example.com/~alice/inbox")
+	members := func(col vocab.IRI) vocab.IRIs {
+		var iris vocab.IRIs
+		_ = r.View(func(tx ReadTx) error {
+			it, err := tx.Load(col)
+			if err != nil {
+				return err
+			}
+			return vocab.OnIRIs(it, func(col *vocab.IRIs) error {
+				iris = *col
+				return nil
+			})
+		})
+		return iris
+	}
+	deliver := func(s Store) error {
+		if _, err := s.Save(note); err != nil {
+			return err
+		}
+		for _, col := range []vocab.IRI{outbox, inbox} {
+			if err := s.AddTo(col, note.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	failed := errors.New("delivery failed")
+	err = r.WithTx(func(s Store) error {
+		if err := deliver(s); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("WithTx() error = %v, want %v", err, failed)
+	}
+	if _, err = r.Load(note.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() of an object saved in a failed transaction error = %v, want NotFound", err)
+	}
+	if iris := members(outbox); iris.Contains(note.ID) {
+		t.Errorf("%s contains %s after a failed transaction", outbox, note.ID)
+	}
+
+	if err = r.WithTx(deliver); err != nil {
+		t.Fatalf("WithTx() error = %s", err)
+	}
+	for _, col := range []vocab.IRI{outbox, inbox} {
+		if iris := members(col); !iris.Contains(note.ID) {
+			t.Errorf("%s = %v, want it to contain %s", col, iris, note.ID)
+		}
+	}
+
+	err = r.WithTx(func(s Store) error {
+		if err := s.RemoveFrom(inbox, note.ID); err != nil {
+			return err
+		}
+		return s.Delete(note)
+	})
+	if err != nil {
+		t.Fatalf("WithTx() removing the note error = %s", err)
+	}
+	if iris := members(inbox); iris.Contains(note.ID) {
+		t.Errorf("%s = %v, want it without %s", inbox, iris, note.ID)
+	}
+	if _, err = r.Load(note.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() of the deleted object error = %v, want NotFound", err)
+	}
+}
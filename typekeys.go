@@ -0,0 +1,158 @@
+package badger
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/filters"
+)
+
+// typeKey is the prefix of the key which stores the type of the object found at the same path.
+// It sorts before the object key, so the type is known when the iteration reaches the object,
+// without needing to load its value.
+const typeKey = "__kind:"
+
+func getTypeKey(p []byte, typ vocab.ActivityVocabularyType) []byte {
+	return bytes.Join([][]byte{p, []byte(typeKey + string(typ))}, sep)
+}
+
+func isTypeKey(k []byte) bool {
+	return bytes.Contains(k, append(append([]byte{}, sep...), typeKey...))
+}
+
+// splitTypeKey returns the path and the object type encoded in a type key.
+func splitTypeKey(k []byte) ([]byte, vocab.ActivityVocabularyType) {
+	i := bytes.LastIndex(k, append(append([]byte{}, sep...), typeKey...))
+	if i < 0 {
+		return nil, ""
+	}
+	return k[:i], vocab.ActivityVocabularyType(k[i+len(sep)+len(typeKey):])
+}
+
+// setTypeKey stores the type key for the item, removing the one corresponding to the old version, if it differs.
+func setTypeKey(b keySetter, p []byte, old, it vocab.Item) error {
+	if !vocab.IsNil(old) && old.GetType() != it.GetType() {
+		if err := b.Delete(getTypeKey(p, old.GetType())); err != nil {
+			return err
+		}
+	}
+	if len(it.GetType()) == 0 {
+		return nil
+	}
+	return b.Set(getTypeKey(p, it.GetType()), nil)
+}
+
+// typesFilter returns the object types a filter accepts, when the filter can be answered using the type keys.
+// Filters that use other operators than equality, can not be answered this way.
+func typesFilter(f Filterable) vocab.ActivityVocabularyTypes {
+	ff, ok := f.(*filters.Filters)
+	if !ok || len(ff.Types()) == 0 {
+		return nil
+	}
+	types := make(vocab.ActivityVocabularyTypes, 0)
+	for _, t := range ff.Types() {
+		if t.Operator != "" && t.Operator != "=" {
+			return nil
+		}
+		types = append(types, vocab.ActivityVocabularyType(t.Str))
+	}
+	return types
+}
+
+// typeMatches checks the tagged type of an object against the accepted types.
+// Tombstones are always a match, as the filters check their former type, which is not encoded in the key.
+func typeMatches(types vocab.ActivityVocabularyTypes, typ vocab.ActivityVocabularyType) bool {
+	if typ == vocab.TombstoneType {
+		return true
+	}
+	for _, t := range types {
+		if strings.EqualFold(string(t), string(typ)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateTypeKeys creates the missing type keys for the objects in storage, and removes the stale ones.
+// It returns the number of keys that have been changed.
+func (r *repo) MigrateTypeKeys() (int, error) {
+	err := r.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	var changes []keyChange
+	err = r.d.View(func(tx *badger.Txn) error {
+		changes, err = typeKeyChanges(r, tx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	b := r.newWriteBatch()
+	for _, c := range changes {
+		if err = c.apply(b); err != nil {
+			b.Cancel()
+			return 0, errors.Annotatef(err, "unable to migrate type key %s", c.k)
+		}
+	}
+	if err = b.Flush(); err != nil {
+		return 0, err
+	}
+	r.logFn("Migrated %d type keys", len(changes))
+	return len(changes), nil
+}
+
+// typeKeyChanges returns the type keys missing for the stored objects, and the stale ones which need removing.
+func typeKeyChanges(r *repo, tx dbTxn) ([]keyChange, error) {
+	tagged := make(map[string]vocab.ActivityVocabularyTypes)
+	types := make(map[string]vocab.ActivityVocabularyType)
+
+	it := tx.NewIterator(badger.DefaultIteratorOptions)
+	for it.Rewind(); it.Valid(); it.Next() {
+		i := it.Item()
+		k := i.Key()
+		if isTypeKey(k) {
+			p, typ := splitTypeKey(k)
+			tagged[string(p)] = append(tagged[string(p)], typ)
+			continue
+		}
+		if !isObjectKey(k) {
+			continue
+		}
+		p := string(bytes.TrimSuffix(k, append(sep, objectKey...)))
+		err := i.Value(func(raw []byte) error {
+			ob, err := loadItem(raw)
+			if err != nil {
+				return err
+			}
+			types[p] = ob.GetType()
+			return nil
+		})
+		if err != nil {
+			r.errFn("unable to load %s: %+s", k, err)
+		}
+	}
+	it.Close()
+
+	changes := make([]keyChange, 0)
+	for p, typ := range types {
+		if len(typ) == 0 || tagged[p].Contains(typ) {
+			continue
+		}
+		changes = append(changes, keyChange{k: getTypeKey([]byte(p), typ)})
+	}
+	for p, tags := range tagged {
+		for _, typ := range tags {
+			if current, ok := types[p]; ok && current == typ {
+				continue
+			}
+			changes = append(changes, keyChange{k: getTypeKey([]byte(p), typ), del: true})
+		}
+	}
+	return changes, nil
+}
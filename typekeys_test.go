@@ -0,0 +1,89 @@
+package badger
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Load_TypeKeys(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	article := vocab.ObjectNew(vocab.ArticleType)
+	article.ID = "http://example.com/objects/2"
+	for _, ob := range []*vocab.Object{note, article} {
+		if _, err = r.Save(ob); err != nil {
+			t.Fatalf("unable to save %s: %s", ob.ID, err)
+		}
+	}
+
+	res, err := r.Load("http://example.com/objects?type=Note")
+	if err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	err = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		if col.Count() != 1 || !col.Contains(note.GetLink()) {
+			t.Errorf("Load() = %v, want only %s", col.Collection(), note.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Load() returned invalid collection: %s", err)
+	}
+
+	article.Type = vocab.NoteType
+	if _, err = r.Save(article); err != nil {
+		t.Fatalf("unable to save %s: %s", article.ID, err)
+	}
+	if res, err = r.Load("http://example.com/objects?type=Note"); err != nil {
+		t.Fatalf("Load() error = %s", err)
+	}
+	_ = vocab.OnCollectionIntf(res, func(col vocab.CollectionInterface) error {
+		if col.Count() != 2 {
+			t.Errorf("Load() after type change = %v, want 2 items", col.Collection())
+		}
+		return nil
+	})
+}
+
+func Test_repo_MigrateTypeKeys(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "http://example.com/objects/1"
+	raw, _ := encodeItemFn(note)
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		p := itemPath(note.ID)
+		if err := tx.Set(getObjectKey(p), raw); err != nil {
+			return err
+		}
+		return tx.Set(getTypeKey(p, vocab.ArticleType), nil)
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store untagged object: %s", err)
+	}
+
+	cnt, err := r.MigrateTypeKeys()
+	if err != nil {
+		t.Fatalf("MigrateTypeKeys() error = %s", err)
+	}
+	if cnt != 2 {
+		t.Errorf("MigrateTypeKeys() changed %d keys, want 2", cnt)
+	}
+	if cnt, _ = r.MigrateTypeKeys(); cnt != 0 {
+		t.Errorf("MigrateTypeKeys() second run changed %d keys, want 0", cnt)
+	}
+}
@@ -0,0 +1,83 @@
+package badger
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+// Validator checks the items before Save stores them, returning an error for the ones which must be rejected.
+type Validator func(it vocab.Item) error
+
+// knownTypes are the types of the ActivityStreams vocabulary accepted by ValidateItem.
+var knownTypes = func() vocab.ActivityVocabularyTypes {
+	types := make(vocab.ActivityVocabularyTypes, 0)
+	types = append(types, vocab.ObjectTypes...)
+	types = append(types, vocab.ActorTypes...)
+	types = append(types, vocab.ActivityTypes...)
+	types = append(types, vocab.IntransitiveActivityTypes...)
+	types = append(types, vocab.CollectionTypes...)
+	return append(types, vocab.LinkTypes...)
+}()
+
+// InvalidItem is the error returned by ValidateItem, listing all the problems found in the item.
+type InvalidItem struct {
+	IRI      vocab.IRI
+	Problems []string
+}
+
+func (i InvalidItem) Error() string {
+	return fmt.Sprintf("%s is not valid: %s", i.IRI, strings.Join(i.Problems, ", "))
+}
+
+// IsInvalidItem returns true if the error signals that ValidateItem rejected an item.
+func IsInvalidItem(err error) bool {
+	i := InvalidItem{}
+	return errors.As(err, &i)
+}
+
+// ValidateItem is the Validator checking that the item has an absolute IRI and a type of the ActivityStreams
+// vocabulary, and that the activities, besides the questions, have an actor, and the transitive ones an object.
+func ValidateItem(it vocab.Item) error {
+	if vocab.IsNil(it) {
+		return InvalidItem{Problems: []string{"it is nil"}}
+	}
+	inv := InvalidItem{IRI: it.GetLink(), Problems: make([]string, 0)}
+	if u, err := url.Parse(string(it.GetLink())); err != nil || u.Scheme == "" || u.Host == "" {
+		inv.Problems = append(inv.Problems, fmt.Sprintf("the id %q is not an absolute IRI", it.GetLink()))
+	}
+	if typ := it.GetType(); typ == "" {
+		inv.Problems = append(inv.Problems, "it has no type")
+	} else if !knownTypes.Contains(typ) {
+		inv.Problems = append(inv.Problems, fmt.Sprintf("the type %q is not part of the vocabulary", typ))
+	}
+	switch {
+	case it.GetType() == vocab.QuestionType:
+		// NOTE(marius): the polls are stored as Questions, which are attributed to their authors,
+		// instead of having an actor.
+	case vocab.IntransitiveActivityTypes.Contains(it.GetType()):
+		_ = vocab.OnIntransitiveActivity(it, func(a *vocab.IntransitiveActivity) error {
+			if vocab.IsNil(a.Actor) {
+				inv.Problems = append(inv.Problems, "the activity has no actor")
+			}
+			return nil
+		})
+	case vocab.ActivityTypes.Contains(it.GetType()):
+		_ = vocab.OnActivity(it, func(a *vocab.Activity) error {
+			if vocab.IsNil(a.Actor) {
+				inv.Problems = append(inv.Problems, "the activity has no actor")
+			}
+			if vocab.IsNil(a.Object) {
+				inv.Problems = append(inv.Problems, "the activity has no object")
+			}
+			return nil
+		})
+	}
+	if len(inv.Problems) > 0 {
+		return inv
+	}
+	return nil
+}
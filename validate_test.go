@@ -0,0 +1,74 @@
+package badger
+
+import (
+	"testing"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+)
+
+func TestValidateItem(t *testing.T) {
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	create := vocab.ActivityNew("https://example.com/activities/1", vocab.CreateType, note)
+	create.Actor = vocab.IRI("https://example.com/~jdoe")
+	orphan := vocab.ActivityNew("https://example.com/activities/2", vocab.CreateType, nil)
+	relative := vocab.ObjectNew(vocab.NoteType)
+	relative.ID = "/objects/1"
+	unknown := vocab.ObjectNew("Potato")
+	unknown.ID = "https://example.com/objects/2"
+	question := &vocab.Question{ID: "https://example.com/objects/3", Type: vocab.QuestionType}
+
+	tests := []struct {
+		name     string
+		it       vocab.Item
+		problems int
+	}{
+		{name: "note", it: note},
+		{name: "activity", it: create},
+		{name: "question without actor", it: question},
+		{name: "nil", it: nil, problems: 1},
+		{name: "activity without actor and object", it: orphan, problems: 2},
+		{name: "relative IRI", it: relative, problems: 1},
+		{name: "unknown type", it: unknown, problems: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateItem(tt.it)
+			if tt.problems == 0 {
+				if err != nil {
+					t.Errorf("ValidateItem() error = %s", err)
+				}
+				return
+			}
+			inv, ok := err.(InvalidItem)
+			if !ok || len(inv.Problems) != tt.problems {
+				t.Errorf("ValidateItem() error = %v, want %d problems", err, tt.problems)
+			}
+		})
+	}
+}
+
+func Test_repo_Save_Validator(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.validator = ValidateItem
+
+	bad := vocab.ActivityNew("https://example.com/activities/1", vocab.LikeType, nil)
+	if _, err = r.Save(bad); !errors.IsNotValid(err) || !IsInvalidItem(err) {
+		t.Errorf("Save() of an invalid item error = %v, want a NotValid InvalidItem", err)
+	}
+	if _, err = r.Load(bad.ID); !errors.IsNotFound(err) {
+		t.Errorf("Load() of the rejected item error = %v, want NotFound", err)
+	}
+
+	err = r.Update(func(tx WriteTx) error {
+		_, err := tx.Save(bad)
+		return err
+	})
+	if !IsInvalidItem(err) {
+		t.Errorf("Update() saving an invalid item error = %v, want InvalidItem", err)
+	}
+}
@@ -0,0 +1,97 @@
+package badger
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// VerifyState is the overall result of Verify. Its values are meant to be used as the exit codes of the
+// commands running the verification, so they can be checked from cron jobs or monitoring.
+type VerifyState int
+
+const (
+	// VerifyClean means no problems were found.
+	VerifyClean VerifyState = iota
+	// VerifyRepairable means problems were found, and all of them can be repaired by CheckCollections.
+	VerifyRepairable
+	// VerifyFatal means the storage could not be checked, or it contains values which can't be decoded.
+	VerifyFatal
+)
+
+func (s VerifyState) String() string {
+	switch s {
+	case VerifyClean:
+		return "clean"
+	case VerifyRepairable:
+		return "repairable"
+	}
+	return "fatal"
+}
+
+func (s VerifyState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// VerifyReport is the result of Verify. It can be encoded as JSON, or written for humans with WriteText.
+type VerifyReport struct {
+	State VerifyState `json:"state"`
+	// Error is the reason the storage could not be checked.
+	Error string `json:"error,omitempty"`
+	RepairReport
+}
+
+// Verify runs the integrity checks of the storage, without repairing anything.
+// The errors preventing the checks from running are reported in the fatal state, and not returned.
+func (r *repo) Verify() VerifyReport {
+	v := VerifyReport{RepairReport: RepairReport{Issues: make([]CollectionIssue, 0)}}
+	report, err := r.CheckCollections(false)
+	if err != nil {
+		v.State = VerifyFatal
+		v.Error = err.Error()
+		return v
+	}
+	v.RepairReport = *report
+	switch {
+	case len(report.Corrupted) > 0:
+		v.State = VerifyFatal
+	case len(report.Issues) > 0:
+		v.State = VerifyRepairable
+	}
+	return v
+}
+
+// WriteText writes the report as lines of text: the state, and then one line for each problem found.
+func (v VerifyReport) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%s: checked %d collections, found %d issues\n", v.State, v.Checked, len(v.Issues)+len(v.Corrupted))
+	if err != nil {
+		return err
+	}
+	if v.Error != "" {
+		if _, err = fmt.Fprintf(w, "error: %s\n", v.Error); err != nil {
+			return err
+		}
+	}
+	for _, k := range v.Corrupted {
+		if _, err = fmt.Fprintf(w, "corrupted: %s\n", k); err != nil {
+			return err
+		}
+	}
+	for _, issue := range v.Issues {
+		line := fmt.Sprintf("%s: %s", issue.Type, issue.Collection)
+		switch issue.Type {
+		case MissingItems:
+			items := make([]string, 0, len(issue.Items))
+			for _, iri := range issue.Items {
+				items = append(items, iri.String())
+			}
+			line += ": " + strings.Join(items, " ")
+		case TotalItemsMismatch:
+			line += fmt.Sprintf(" has %d, expected %d", issue.Found, issue.Expected)
+		}
+		if _, err = fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Verify(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	actor := vocab.PersonNew("http://example.com/jdoe")
+	actor.Outbox = vocab.Outbox.IRI(actor)
+	if _, err = r.Save(actor); err != nil {
+		t.Fatalf("unable to save %s: %s", actor.ID, err)
+	}
+	if v := r.Verify(); v.State != VerifyClean || v.Checked != 1 {
+		t.Errorf("Verify() = %+v, want clean", v)
+	}
+
+	if err = r.AddTo(actor.Outbox.GetLink(), vocab.IRI("http://example.com/objects/missing")); err != nil {
+		t.Fatalf("unable to add to outbox: %s", err)
+	}
+	v := r.Verify()
+	if v.State != VerifyRepairable || len(v.Issues) != 1 || v.Issues[0].Type != MissingItems {
+		t.Errorf("Verify() = %+v, want repairable with a missing item", v)
+	}
+	buf := bytes.Buffer{}
+	if err = v.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %s", err)
+	}
+	want := "repairable: checked 1 collections, found 1 issues\nmissing-items: http://example.com/jdoe/outbox: http://example.com/objects/missing\n"
+	if buf.String() != want {
+		t.Errorf("WriteText() = %q, want %q", buf.String(), want)
+	}
+
+	if err = r.Open(); err != nil {
+		t.Fatalf("unable to open storage: %s", err)
+	}
+	err = r.d.Update(func(tx *badger.Txn) error {
+		return tx.Set(getObjectKey(itemPath("http://example.com/objects/broken")), []byte("{broken"))
+	})
+	r.Close()
+	if err != nil {
+		t.Fatalf("unable to store the broken value: %s", err)
+	}
+	// NOTE(marius): the corrupted value gets logged through errFn
+	r.errFn = t.Logf
+	v = r.Verify()
+	if v.State != VerifyFatal || int(v.State) != 2 || len(v.Corrupted) != 1 {
+		t.Errorf("Verify() = %+v, want fatal with a corrupted key", v)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %s", err)
+	}
+	if !strings.Contains(string(raw), `"state":"fatal"`) || !strings.Contains(string(raw), `"corrupted":["example.com/objects/broken/__raw"]`) {
+		t.Errorf("json.Marshal() = %s, want the fatal state and the corrupted key", raw)
+	}
+}
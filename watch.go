@@ -0,0 +1,135 @@
+package badger
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+	"github.com/go-ap/errors"
+	"github.com/go-ap/storage-badger/internal/cache"
+)
+
+// NOTE(marius): the database is opened for every operation, and badger locks its directory while open,
+// so its own Subscribe feed can't be kept open next to the repository. The changes are published instead
+// from the same places that send them to the Mirror.
+
+// watchQueueSize is the number of changes waiting for a Watch function, the ones made while it is full are dropped.
+const watchQueueSize = 256
+
+// Change is a write made to the repository, received by the functions passed to Watch.
+type Change struct {
+	Op   MirrorOp
+	Col  vocab.IRI
+	IRI  vocab.IRI
+	Item vocab.Item
+	Time time.Time
+}
+
+// WatchFn is called by Watch for every change. Returning an error stops the watch.
+type WatchFn func(Change) error
+
+type watcher struct {
+	base    []byte
+	changes chan Change
+}
+
+// watchers fans out the changes made to the repository to the running Watch calls.
+type watchers struct {
+	mu   sync.Mutex
+	subs map[*watcher]struct{}
+}
+
+func (w *watchers) add(base []byte) *watcher {
+	s := watcher{base: base, changes: make(chan Change, watchQueueSize)}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[*watcher]struct{})
+	}
+	w.subs[&s] = struct{}{}
+	return &s
+}
+
+func (w *watchers) remove(s *watcher) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	subs := make(map[*watcher]struct{}, len(w.subs))
+	for o := range w.subs {
+		if o != s {
+			subs[o] = struct{}{}
+		}
+	}
+	w.subs = subs
+}
+
+// publish sends a copy of it to the watchers of its IRI, or of the IRI of the collection.
+func (w *watchers) publish(op MirrorOp, col vocab.IRI, it vocab.Item, errFn loggerFn) {
+	if w == nil || vocab.IsNil(it) {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.subs) == 0 {
+		return
+	}
+	c := Change{Op: op, Col: col, IRI: it.GetLink(), Item: cache.Copy(it), Time: time.Now().UTC()}
+	for s := range w.subs {
+		if !s.matches(c) {
+			continue
+		}
+		select {
+		case s.changes <- c:
+		default:
+			errFn("unable to watch %s %s: the watch queue is full", c.Op, c.IRI)
+		}
+	}
+}
+
+func (s *watcher) matches(c Change) bool {
+	if len(s.base) == 0 {
+		return true
+	}
+	if isPathOrChildKey(s.base, itemPath(c.IRI)) {
+		return true
+	}
+	return len(c.Col) > 0 && isPathOrChildKey(s.base, itemPath(c.Col))
+}
+
+// notify sends the change to the Mirror, to the running Watch and Subscribe calls, and to the After hooks.
+func (r *repo) notify(op MirrorOp, col vocab.IRI, it vocab.Item) {
+	r.mirror.enqueue(op, col, it)
+	r.watchers.publish(op, col, it, r.errFn)
+	r.events.emitChange(op, col, it, r.errFn)
+	r.hooks.runAfter(op, col, it)
+}
+
+// Watch calls fn for the changes made to the objects under prefix, or to the collections under it, until ctx
+// is done, or fn returns an error. An empty prefix watches all the changes.
+//
+// The changes are received in the order they were made, and the ones made while fn can't keep up are dropped.
+func (r *repo) Watch(ctx context.Context, prefix vocab.IRI, fn WatchFn) error {
+	if fn == nil {
+		return errors.NotValidf("nil watch function")
+	}
+	if r.watchers == nil {
+		return errors.NotValidf("the repository doesn't support watching changes")
+	}
+	base := itemPath(prefix)
+	if len(prefix) > 0 && len(base) == 0 {
+		return errors.NotValidf("invalid watch prefix %s", prefix)
+	}
+	s := r.watchers.add(base)
+	defer r.watchers.remove(s)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case c := <-s.changes:
+			if err := fn(c); err != nil {
+				return err
+			}
+		}
+	}
+}
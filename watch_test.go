@@ -0,0 +1,61 @@
+package badger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vocab "github.com/go-ap/activitypub"
+)
+
+func Test_repo_Watch(t *testing.T) {
+	r, err := initBadgerForTesting(t)
+	if err != nil {
+		t.Fatalf("Unable to initialize badger: %s", err)
+	}
+	r.watchers = new(watchers)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := make([]Change, 0)
+	done := make(chan error)
+	go func() {
+		done <- r.Watch(ctx, "https://example.com/objects", func(c Change) error {
+			changes = append(changes, c)
+			if c.Op == MirrorAddTo {
+				cancel()
+			}
+			return nil
+		})
+	}()
+	for watching := 0; watching == 0; {
+		r.watchers.mu.Lock()
+		watching = len(r.watchers.subs)
+		r.watchers.mu.Unlock()
+	}
+
+	actor := vocab.PersonNew("https://example.com/actors/jdoe")
+	note := vocab.ObjectNew(vocab.NoteType)
+	note.ID = "https://example.com/objects/1"
+	for _, it := range []vocab.Item{actor, note} {
+		if _, err = r.Save(it); err != nil {
+			t.Fatalf("unable to save %s: %s", it.GetLink(), err)
+		}
+	}
+	if err = r.AddTo("https://example.com/objects", note.ID); err != nil {
+		t.Fatalf("unable to add %s to the collection: %s", note.ID, err)
+	}
+
+	if err = <-done; err != nil {
+		t.Fatalf("Watch() error = %s", err)
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("Watch() didn't receive the changes before timing out")
+	}
+	if len(changes) != 2 || changes[0].Op != MirrorSave || changes[0].IRI != note.ID {
+		t.Errorf("Watch() received %+v, want the save and the addition of %s", changes, note.ID)
+	}
+	if len(r.watchers.subs) != 0 {
+		t.Errorf("Watch() left %d watchers after returning", len(r.watchers.subs))
+	}
+}